@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"regexp"
@@ -10,7 +11,8 @@ import (
 	"gf-lt/models"
 )
 
-type ToolFunc func(map[string]string) []byte
+// ToolFunc has the signature of tools.FnHandler, so playwright tools are registered as they are
+type ToolFunc func(ctx context.Context, args map[string]string) []byte
 
 var pwToolMap = make(map[string]ToolFunc)
 
@@ -294,7 +296,7 @@ func parseOpenAIToolCall(toolCalls []interface{}) (func() []byte, string, bool)
 		if !ok {
 			return []byte(fmt.Sprintf(`{"error": "tool %s not found"}`, name))
 		}
-		return fn(args)
+		return fn(context.Background(), args)
 	}, id, true
 }
 
@@ -329,7 +331,7 @@ func findToolCallFromText(text string) (func() []byte, string, bool) {
 		if !ok {
 			return []byte(fmt.Sprintf(`{"error": "tool %s not found"}`, fc.Name))
 		}
-		return fn(fc.Args)
+		return fn(context.Background(), fc.Args)
 	}, fc.ID, true
 }
 
@@ -2,6 +2,7 @@ package agent
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"gf-lt/config"
@@ -144,13 +145,39 @@ func (ag *AgentClient) buildRequest() ([]byte, error) {
 }
 
 func (ag *AgentClient) LLMRequest(body io.Reader) ([]byte, error) {
+	return ag.LLMRequestCtx(context.Background(), body)
+}
+
+// LLMRequestCtx is LLMRequest that is aborted when ctx is done
+func (ag *AgentClient) LLMRequestCtx(ctx context.Context, body io.Reader) ([]byte, error) {
+	responseBytes, err := ag.LLMRequestRawCtx(ctx, body)
+	if err != nil {
+		return responseBytes, err
+	}
+	// Parse response and extract text content
+	text, err := extractTextFromResponse(responseBytes)
+	if err != nil {
+		ag.log.Error("failed to extract text from response", "error", err, "response_preview", string(responseBytes[:min(len(responseBytes), 500)]))
+		// Return raw response as fallback
+		return responseBytes, nil
+	}
+	return []byte(text), nil
+}
+
+// LLMRequestRaw sends the request and returns the response body as is, so tool calls can be read from it
+func (ag *AgentClient) LLMRequestRaw(body io.Reader) ([]byte, error) {
+	return ag.LLMRequestRawCtx(context.Background(), body)
+}
+
+// LLMRequestRawCtx is LLMRequestRaw that is aborted when ctx is done
+func (ag *AgentClient) LLMRequestRawCtx(ctx context.Context, body io.Reader) ([]byte, error) {
 	// Read the body for debugging (but we need to recreate it for the request)
 	bodyBytes, err := io.ReadAll(body)
 	if err != nil {
 		ag.log.Error("failed to read request body", "error", err)
 		return nil, err
 	}
-	req, err := http.NewRequest("POST", ag.cfg.CurrentAPI, bytes.NewReader(bodyBytes))
+	req, err := http.NewRequestWithContext(ctx, "POST", ag.cfg.CurrentAPI, bytes.NewReader(bodyBytes))
 	if err != nil {
 		ag.log.Error("failed to create request", "error", err)
 		return nil, err
@@ -175,14 +202,7 @@ func (ag *AgentClient) LLMRequest(body io.Reader) ([]byte, error) {
 		ag.log.Error("agent LLM request failed", "status", resp.StatusCode, "response", string(responseBytes[:min(len(responseBytes), 1000)]))
 		return responseBytes, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(responseBytes[:min(len(responseBytes), 200)]))
 	}
-	// Parse response and extract text content
-	text, err := extractTextFromResponse(responseBytes)
-	if err != nil {
-		ag.log.Error("failed to extract text from response", "error", err, "response_preview", string(responseBytes[:min(len(responseBytes), 500)]))
-		// Return raw response as fallback
-		return responseBytes, nil
-	}
-	return []byte(text), nil
+	return responseBytes, nil
 }
 
 // extractTextFromResponse parses common LLM response formats and extracts the text content.
@@ -0,0 +1,242 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gf-lt/models"
+	"slices"
+	"strings"
+)
+
+// SubAgent is AgenterA type agent started by the main chat (delegate tool):
+// own sysprompt, a subset of the main chat tools and a budget of llm requests
+type SubAgent struct {
+	*AgentClient
+	Name      string
+	sysprompt string
+	maxSteps  int
+	call      ToolCaller
+	// OnStep is called for every transcript step as it happens
+	OnStep func(SubAgentStep)
+}
+
+// ToolCaller executes a tool by name; false means the tool was not run
+type ToolCaller func(name string, args map[string]string) ([]byte, bool)
+
+// sub agent transcript step kinds
+const (
+	StepThought    = "thought"
+	StepToolCall   = "tool_call"
+	StepToolResult = "tool_result"
+	StepAnswer     = "answer"
+	StepError      = "error"
+)
+
+type SubAgentStep struct {
+	Kind string            `json:"kind"`
+	Tool string            `json:"tool,omitempty"`
+	Args map[string]string `json:"args,omitempty"`
+	Text string            `json:"text,omitempty"`
+}
+
+// sub agent result statuses
+const (
+	SubAgentDone      = "done"
+	SubAgentStepLimit = "step_limit"
+	SubAgentFailed    = "error"
+)
+
+// SubAgentResult is returned to the parent as json; transcript is only for display
+type SubAgentResult struct {
+	Agent      string         `json:"agent"`
+	Status     string         `json:"status"`
+	Result     string         `json:"result"`
+	Steps      int            `json:"steps"`
+	ToolsUsed  []string       `json:"tools_used,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Transcript []SubAgentStep `json:"-"`
+}
+
+const subAgentToolsGuide = `
+You can call tools. To call a tool answer with the call only, in this format:
+__tool_call__
+{"name": "tool_name", "args": {"arg_name": "value"}}
+__tool_call__
+The tool result comes in the next message. When the task is done, answer with the final result without a tool call.
+Available tools:
+`
+
+// NewSubAgent creates a sub agent; tools are the allowed subset, call executes them
+func NewSubAgent(client *AgentClient, name, sysprompt string, tools []models.Tool, maxSteps int, call ToolCaller) *SubAgent {
+	client.tools = tools
+	return &SubAgent{AgentClient: client, Name: name, sysprompt: sysprompt, maxSteps: max(maxSteps, 1), call: call}
+}
+
+// fullSysprompt adds the text guide of allowed tools, for endpoints that do not take tool defs
+func (a *SubAgent) fullSysprompt() string {
+	if len(a.tools) == 0 {
+		return a.sysprompt
+	}
+	sb := strings.Builder{}
+	sb.WriteString(a.sysprompt)
+	sb.WriteString("\n")
+	sb.WriteString(subAgentToolsGuide)
+	for _, t := range a.tools {
+		args := make([]string, 0, len(t.Function.Parameters.Properties))
+		for name, p := range t.Function.Parameters.Properties {
+			args = append(args, fmt.Sprintf("%s (%s)", name, p.Description))
+		}
+		fmt.Fprintf(&sb, "- %s: %s Args: %s\n", t.Function.Name, t.Function.Description, strings.Join(args, ", "))
+	}
+	return sb.String()
+}
+
+func (a *SubAgent) step(res *SubAgentResult, s SubAgentStep) {
+	res.Transcript = append(res.Transcript, s)
+	if a.OnStep != nil {
+		a.OnStep(s)
+	}
+}
+
+// Run does the tool loop until the llm answers without a tool call or the step budget is spent
+func (a *SubAgent) Run(task string) SubAgentResult {
+	res := SubAgentResult{Agent: a.Name, Status: SubAgentStepLimit}
+	req, err := a.FormFirstMsg(a.fullSysprompt(), task)
+	if err != nil {
+		res.Status, res.Error = SubAgentFailed, err.Error()
+		a.step(&res, SubAgentStep{Kind: StepError, Text: err.Error()})
+		return res
+	}
+	allowed := make(map[string]bool, len(a.tools))
+	for _, t := range a.tools {
+		allowed[t.Function.Name] = true
+	}
+	for res.Steps < a.maxSteps {
+		res.Steps++
+		resp, err := a.LLMRequestRaw(req)
+		if err != nil {
+			res.Status, res.Error = SubAgentFailed, err.Error()
+			a.step(&res, SubAgentStep{Kind: StepError, Text: err.Error()})
+			return res
+		}
+		text, calls := parseAgentReply(resp)
+		text = strings.TrimSpace(models.ThinkRE.ReplaceAllString(text, ""))
+		if len(calls) == 0 {
+			res.Status, res.Result = SubAgentDone, text
+			a.step(&res, SubAgentStep{Kind: StepAnswer, Text: text})
+			return res
+		}
+		// keep the last text, so step limit still returns something
+		res.Result = strings.TrimSpace(models.ToolCallRE.ReplaceAllString(text, ""))
+		if res.Result != "" {
+			a.step(&res, SubAgentStep{Kind: StepThought, Text: res.Result})
+		}
+		a.chatBody.Messages = append(a.chatBody.Messages, models.RoleMsg{Role: "assistant", Content: text, ToolCalls: calls})
+		for _, tc := range calls {
+			args := map[string]string{}
+			if err := json.Unmarshal([]byte(tc.FuncCall.Args), &args); err != nil {
+				args = argsToStrings(tc.FuncCall.Args)
+			}
+			a.step(&res, SubAgentStep{Kind: StepToolCall, Tool: tc.FuncCall.Name, Args: args})
+			var out []byte
+			if allowed[tc.FuncCall.Name] {
+				out, _ = a.call(tc.FuncCall.Name, args)
+				if !slices.Contains(res.ToolsUsed, tc.FuncCall.Name) {
+					res.ToolsUsed = append(res.ToolsUsed, tc.FuncCall.Name)
+				}
+			} else {
+				out = []byte(fmt.Sprintf("[error] tool %s is not available", tc.FuncCall.Name))
+			}
+			a.step(&res, SubAgentStep{Kind: StepToolResult, Tool: tc.FuncCall.Name, Text: string(out)})
+			a.chatBody.Messages = append(a.chatBody.Messages, models.RoleMsg{Role: "tool", Content: string(out), ToolCallID: tc.ID})
+		}
+		b, err := a.buildRequest()
+		if err != nil {
+			res.Status, res.Error = SubAgentFailed, err.Error()
+			a.step(&res, SubAgentStep{Kind: StepError, Text: err.Error()})
+			return res
+		}
+		req = bytes.NewReader(b)
+	}
+	a.Log().Warn("sub agent ran out of steps", "agent", a.Name, "steps", res.Steps)
+	return res
+}
+
+// ProcessTask implements AgenterA
+func (a *SubAgent) ProcessTask(task string) []byte {
+	res := a.Run(task)
+	data, err := json.Marshal(res)
+	if err != nil {
+		return []byte("failed to marshal sub agent result; err: " + err.Error())
+	}
+	return data
+}
+
+// parseAgentReply returns text of the reply and its tool calls, native or in __tool_call__ format
+func parseAgentReply(resp []byte) (string, []models.ToolCall) {
+	var chatResp struct {
+		Choices []struct {
+			Message struct {
+				Content   string            `json:"content"`
+				ToolCalls []models.ToolCall `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(resp, &chatResp); err == nil && len(chatResp.Choices) > 0 &&
+		len(chatResp.Choices[0].Message.ToolCalls) > 0 {
+		calls := chatResp.Choices[0].Message.ToolCalls
+		for i := range calls {
+			if calls[i].ID == "" {
+				calls[i].ID = fmt.Sprintf("call_%d", i)
+			}
+			calls[i].Type = "function"
+		}
+		return chatResp.Choices[0].Message.Content, calls
+	}
+	text, _ := extractTextFromResponse(resp)
+	calls := []models.ToolCall{}
+	for i, m := range models.ToolCallRE.FindAllStringSubmatch(text, -1) {
+		js := strings.TrimSpace(m[1])
+		start, end := strings.Index(js, "{"), strings.LastIndex(js, "}")
+		if start < 0 || end <= start {
+			continue
+		}
+		var fc struct {
+			Name string         `json:"name"`
+			Args map[string]any `json:"args"`
+		}
+		if err := json.Unmarshal([]byte(js[start:end+1]), &fc); err != nil || fc.Name == "" {
+			continue
+		}
+		args, _ := json.Marshal(stringifyArgs(fc.Args))
+		calls = append(calls, models.ToolCall{
+			ID:       fmt.Sprintf("call_%d", i),
+			Type:     "function",
+			FuncCall: models.ToolCallFunction{Name: fc.Name, Args: string(args)},
+		})
+	}
+	return text, calls
+}
+
+// argsToStrings reads args json with non string values
+func argsToStrings(args string) map[string]string {
+	raw := map[string]any{}
+	if err := json.Unmarshal([]byte(args), &raw); err != nil {
+		return map[string]string{}
+	}
+	return stringifyArgs(raw)
+}
+
+func stringifyArgs(raw map[string]any) map[string]string {
+	resp := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if s, ok := v.(string); ok {
+			resp[k] = s
+			continue
+		}
+		b, _ := json.Marshal(v)
+		resp[k] = string(b)
+	}
+	return resp
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"encoding/json"
+	"gf-lt/config"
+	"gf-lt/models"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSubAgentRun(t *testing.T) {
+	replies := []string{
+		`{"choices":[{"message":{"content":"","tool_calls":[
+			{"id":"c1","type":"function","function":{"name":"echo","arguments":"{\"text\":\"hi\",\"n\":2}"}},
+			{"id":"c2","type":"function","function":{"name":"bash","arguments":"{\"command\":\"rm -rf /\"}"}}]}}]}`,
+		`{"choices":[{"message":{"content":"<think>done</think>echoed hi"}}]}`,
+	}
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if requests >= len(replies) {
+			http.Error(w, "no more replies", http.StatusInternalServerError)
+			return
+		}
+		if requests == 1 && !strings.Contains(string(body), `"tool_call_id":"c1"`) {
+			t.Errorf("second request has no tool response: %s", body)
+		}
+		_, _ = w.Write([]byte(replies[requests]))
+		requests++
+	}))
+	defer srv.Close()
+	cfg := &config.Config{CurrentAPI: srv.URL + "/v1/chat/completions"}
+	client := NewAgentClient(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), func() string { return "" })
+	echo := models.Tool{Type: "function", Function: models.ToolFunc{Name: "echo"}}
+	called := map[string]map[string]string{}
+	sa := NewSubAgent(client, "helper", "you help", []models.Tool{echo}, 5,
+		func(name string, args map[string]string) ([]byte, bool) {
+			called[name] = args
+			return []byte(args["text"]), true
+		})
+	steps := 0
+	sa.OnStep = func(SubAgentStep) { steps++ }
+	res := sa.Run("echo hi")
+	if res.Status != SubAgentDone || res.Result != "echoed hi" || res.Steps != 2 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if called["echo"]["text"] != "hi" || called["echo"]["n"] != "2" {
+		t.Errorf("unexpected echo args: %v", called["echo"])
+	}
+	if _, ok := called["bash"]; ok {
+		t.Errorf("not allowed tool was called")
+	}
+	if len(res.ToolsUsed) != 1 || steps != len(res.Transcript) || steps != 5 {
+		t.Errorf("tools used %v, steps %d, transcript %d", res.ToolsUsed, steps, len(res.Transcript))
+	}
+	data := sa.ProcessTask("again")
+	out := SubAgentResult{}
+	if err := json.Unmarshal(data, &out); err != nil || out.Status != SubAgentFailed {
+		t.Errorf("expected error result when server has no replies left: %s", data)
+	}
+}
+
+func TestParseAgentReply(t *testing.T) {
+	resp := `{"content": "let me look\n__tool_call__\n{\"name\": \"websearch\", \"args\": {\"query\": \"go\", \"limit\": 3}}\n__tool_call__"}`
+	text, calls := parseAgentReply([]byte(resp))
+	if !strings.HasPrefix(text, "let me look") || len(calls) != 1 {
+		t.Fatalf("unexpected reply: %q %+v", text, calls)
+	}
+	if calls[0].FuncCall.Name != "websearch" || calls[0].FuncCall.Args != `{"limit":"3","query":"go"}` {
+		t.Errorf("unexpected call: %+v", calls[0])
+	}
+	if _, calls := parseAgentReply([]byte(`{"content": "just text"}`)); len(calls) != 0 {
+		t.Errorf("expected no calls, got %+v", calls)
+	}
+}
@@ -17,14 +17,17 @@ func NewWebAgentB(client *AgentClient, sysprompt string) *WebAgentB {
 
 // Process applies the formatting function to raw output
 func (a *WebAgentB) Process(args map[string]string, rawOutput []byte) []byte {
-	msg, err := a.FormFirstMsg(
+	// the client is shared by all web agents and calls may run concurrently,
+	// so the request is formed on a copy
+	client := *a.AgentClient
+	msg, err := client.FormFirstMsg(
 		a.sysprompt,
 		fmt.Sprintf("request:\n%+v\ntool response:\n%v", args, string(rawOutput)))
 	if err != nil {
 		a.Log().Error("failed to process the request", "error", err)
 		return []byte("failed to process the request; err: " + err.Error())
 	}
-	resp, err := a.LLMRequest(msg)
+	resp, err := client.LLMRequest(msg)
 	if err != nil {
 		a.Log().Error("failed to process the request", "error", err)
 		return []byte("failed to process the request; err: " + err.Error())
@@ -6,8 +6,11 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"gf-lt/config"
+	"gf-lt/mission"
+	"gf-lt/mockllm"
 	"gf-lt/models"
 	"gf-lt/rag"
 	"gf-lt/storage"
@@ -156,12 +159,24 @@ func outputCLIJSON() {
 	if len(toolCalls) > 0 {
 		result["tool_calls"] = toolCalls
 	}
-	if stats != nil {
+	switch {
+	case stats != nil && stats.Usage != nil:
+		result["tokens"] = map[string]int{
+			"prompt":     stats.Usage.PromptTokens,
+			"completion": stats.Usage.CompletionTokens,
+			"reasoning":  stats.Usage.ReasoningTokens,
+		}
+		result["cost"] = stats.Usage.Cost
+	case stats != nil:
 		result["tokens"] = map[string]int{
 			"prompt":     stats.Tokens,
-			"completion": stats.Tokens, // approximate — same as total for now
+			"completion": stats.Tokens, // approximate — the api sent no usage
 		}
 	}
+	// all replies of the chat, tool call rounds included
+	if total := chatUsage(chatBody.Messages); total != nil {
+		result["chat_usage"] = total
+	}
 	data, _ := json.MarshalIndent(result, "", "  ")
 	os.Stdout.Write(data)
 	os.Stdout.Write([]byte("\n"))
@@ -274,9 +289,6 @@ func processMessageTag(msg *models.RoleMsg) *models.RoleMsg {
 // filterMessagesForCharacter returns messages visible to the specified character.
 // If CharSpecificContextEnabled is false, returns all messages.
 func filterMessagesForCharacter(messages []models.RoleMsg, character string) []models.RoleMsg {
-	if strings.Contains(cfg.CurrentAPI, "chat") {
-		return messages
-	}
 	if cfg == nil || !cfg.CharSpecificContextEnabled || character == "" {
 		return messages
 	}
@@ -378,6 +390,7 @@ func createClient(connectTimeout time.Duration) *http.Client {
 		TLSHandshakeTimeout:   connectTimeout,
 		ResponseHeaderTimeout: connectTimeout,
 	}
+	mockllm.Register(transport)
 	// Client with no overall timeout (or set to streaming-safe duration)
 	return &http.Client{
 		Transport: transport,
@@ -829,22 +842,27 @@ func extractDetailedErrorFromBytes(body []byte, statusCode int) string {
 	return fmt.Sprintf("HTTP Status: %d, Response Body: %s", statusCode, string(body))
 }
 
-func finalizeRespStats(tokenCount int, startTime time.Time) {
+func finalizeRespStats(tokenCount int, startTime time.Time, usage *models.Usage) {
 	duration := time.Since(startTime).Seconds()
 	var tps float64
 	if duration > 0 {
 		tps = float64(tokenCount) / duration
 	}
+	if usage != nil && usage.Cost == 0 {
+		usage.Cost = usageCost(cfg.CurrentAPI, chatBody.Model, usage)
+	}
 	lastRespStats = &models.ResponseStats{
 		Tokens:       tokenCount,
 		Duration:     duration,
 		TokensPerSec: tps,
+		Usage:        usage,
 	}
 }
 
 // sendMsgToLLM expects streaming resp
 func sendMsgToLLM(body io.Reader) {
 	choseChunkParser()
+	llmRequestFailed, llmStreamBroken = false, false
 	// openrouter does not respect stop strings, so we have to cut the message ourselves
 	stopStrings := chatBody.MakeStopSliceExcluding("", listChatRoles())
 
@@ -857,24 +875,47 @@ func sendMsgToLLM(body io.Reader) {
 		return
 	}
 
-	req, err := http.NewRequest("POST", cfg.CurrentAPI, bytes.NewReader(bodyBytes))
-	if err != nil {
-		logger.Error("newreq error", "error", err)
-		showToast("error", "apicall failed:"+err.Error())
-		streamDone <- true
-		return
-	}
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", "Bearer "+chunkParser.GetToken())
-	req.Header.Set("Accept-Encoding", "gzip")
-	// nolint
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		logger.Error("llamacpp api", "error", err)
-		showToast("error", "apicall failed:"+err.Error())
-		streamDone <- true
-		return
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("POST", cfg.CurrentAPI, bytes.NewReader(bodyBytes))
+		if err != nil {
+			logger.Error("newreq error", "error", err)
+			showToast("error", "apicall failed:"+err.Error())
+			llmRequestFailed = true
+			streamDone <- true
+			return
+		}
+		req.Header.Add("Accept", "application/json")
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("Authorization", "Bearer "+chunkParser.GetToken())
+		req.Header.Set("Accept-Encoding", "gzip")
+		// nolint
+		resp, err = httpClient.Do(req)
+		delay, retry := retryDelay(attempt, resp, err)
+		if !retry {
+			if err != nil {
+				logger.Error("llamacpp api", "error", err)
+				showToast("error", "apicall failed:"+err.Error())
+				llmRequestFailed = true
+				streamDone <- true
+				return
+			}
+			break
+		}
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			resp.Body.Close()
+		}
+		logger.Warn("llm request failed, retrying", "reason", reason, "retry", attempt+1, "delay", delay, "link", cfg.CurrentAPI)
+		showToast("API Error", fmt.Sprintf("%s, retry %d of %d in %s", reason, attempt+1, cfg.RetryMax, delay.Round(time.Millisecond)))
+		if !waitRetry(delay) {
+			llmRequestFailed = true
+			streamDone <- true
+			return
+		}
 	}
 	// Check if the initial response is an error before starting to stream
 	if resp.StatusCode >= 400 {
@@ -885,6 +926,7 @@ func sendMsgToLLM(body io.Reader) {
 			detailedError := fmt.Sprintf("HTTP Status: %d, Failed to read response body: %v", resp.StatusCode, err)
 			showToast("API Error", detailedError)
 			resp.Body.Close()
+			llmRequestFailed = true
 			streamDone <- true
 			return
 		}
@@ -894,9 +936,11 @@ func sendMsgToLLM(body io.Reader) {
 		dumpRequestToFile(cfg.CurrentAPI, bodyBytes, chunkParser.GetToken(), resp.StatusCode)
 		showToast("API Error", detailedError)
 		resp.Body.Close()
+		llmRequestFailed = true
 		streamDone <- true
 		return
 	}
+	answeredBy = providerName(cfg.CurrentAPI, chatBody.Model)
 	//
 	defer resp.Body.Close()
 	reader := bufio.NewReader(resp.Body)
@@ -913,6 +957,11 @@ func sendMsgToLLM(body io.Reader) {
 		Args  string
 	}
 	toolCallAcc := make(map[int]*streamingToolCall)
+	var usage *models.Usage
+	// chatRound reads the stats and tool calls once the stream is done
+	defer func() {
+		streamDone <- true
+	}()
 	defer func() {
 		// Compile completed tool calls when streaming finishes
 		if len(toolCallAcc) > 0 {
@@ -937,7 +986,7 @@ func sendMsgToLLM(body io.Reader) {
 		}
 	}()
 	defer func() {
-		finalizeRespStats(tokenCount, startTime)
+		finalizeRespStats(tokenCount, startTime, usage)
 	}()
 	for {
 		var (
@@ -948,7 +997,6 @@ func sendMsgToLLM(body io.Reader) {
 		// to stop from spiriling in infinity read of bad bytes that happens with poor connection
 		if cfg.ChunkLimit > 0 && counter > cfg.ChunkLimit {
 			logger.Warn("response hit chunk limit", "limit", cfg.ChunkLimit)
-			streamDone <- true
 			break
 		}
 		line, err := reader.ReadBytes('\n')
@@ -967,7 +1015,7 @@ func sendMsgToLLM(body io.Reader) {
 				// if err.Error() != "EOF" {
 				showToast("API error", err.Error())
 			}
-			streamDone <- true
+			llmStreamBroken = true
 			break
 			// }
 			// continue
@@ -985,7 +1033,6 @@ func sendMsgToLLM(body io.Reader) {
 		line = line[6:]
 		logger.Debug("debugging resp", "line", string(line))
 		if bytes.Equal(line, []byte("[DONE]\n")) {
-			streamDone <- true
 			break
 		}
 		if bytes.Equal(line, []byte("ROUTER PROCESSING\n")) {
@@ -996,9 +1043,11 @@ func sendMsgToLLM(body io.Reader) {
 			logger.Error("error parsing response body", "error", err,
 				"line", string(line), "url", cfg.CurrentAPI)
 			showToast("LLM Response Error", "Failed to parse LLM response: "+err.Error())
-			streamDone <- true
 			break
 		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
 		// // problem: this catches any mention of the word 'error'
 		// Handle error messages in response content
 		// example needed, since llm could use the word error in the normal msg
@@ -1019,7 +1068,9 @@ func sendMsgToLLM(body io.Reader) {
 				chunkChan <- answerText
 				tokenCount++
 			}
-			streamDone <- true
+			if usage == nil && !interruptResp.Load() {
+				usage = drainUsage(reader)
+			}
 			break
 		}
 		if counter == 0 {
@@ -1055,7 +1106,6 @@ func sendMsgToLLM(body io.Reader) {
 		if chunkParser.GetAPIType() == models.APITypeCompletion &&
 			slices.Contains(stopStrings, answerText) {
 			logger.Debug("stop string detected on client side for completion endpoint", "stop_string", answerText)
-			streamDone <- true
 			break
 		}
 		if answerText != "" {
@@ -1080,7 +1130,6 @@ func sendMsgToLLM(body io.Reader) {
 	interrupt:
 		if interruptResp.Load() { // read bytes, so it would not get into beginning of the next req
 			logger.Info("interrupted bot response", "chunk_counter", counter)
-			streamDone <- true
 			break
 		}
 	}
@@ -1121,7 +1170,7 @@ func showSpinner() {
 		app.QueueUpdateDraw(func() {
 			switch {
 			case toolRunningMode.Load():
-				textArea.SetTitle(spinners[spin] + " tool")
+				textArea.SetTitle(spinners[spin] + " tool (F6 to cancel)")
 			case botRespMode.Load():
 				textArea.SetTitle(spinners[spin] + " " + botPersona + " (F6 to interrupt)")
 			default:
@@ -1150,6 +1199,10 @@ func chatRound(r *models.ChatRoundReq) error {
 	if cfg.WriteNextMsgAsCompletionAgent != "" {
 		botPersona = cfg.WriteNextMsgAsCompletionAgent
 	}
+	if !r.Resume && !r.Regen && (r.Role == cfg.UserRole || r.Role == cfg.WriteNextMsgAs) {
+		// user took the turn, director may start chars again
+		groupAutoTurns.Store(0)
+	}
 	defer func() {
 		botRespMode.Store(false)
 		ClearImageAttachments()
@@ -1164,6 +1217,12 @@ func chatRound(r *models.ChatRoundReq) error {
 	if cfg.SkipLLMResp {
 		return nil
 	}
+	sendStart := time.Now()
+	promptTokens := 0
+	if tools.IsMissionMode() {
+		promptTokens = getContextTokens()
+	}
+	var firstToken time.Duration
 	go sendMsgToLLM(reader)
 	logger.Debug("looking at vars in chatRound", "msg", r.UserMsg, "regen", r.Regen, "resume", r.Resume)
 	msgIdx := len(chatBody.Messages)
@@ -1201,6 +1260,9 @@ out:
 	for {
 		select {
 		case chunk := <-chunkChan:
+			if firstToken == 0 {
+				firstToken = time.Since(sendStart)
+			}
 			// Handle thinking blocks during streaming
 			if strings.HasPrefix(chunk, "<think>") && !inThinkingBlock {
 				// Start of thinking block
@@ -1280,9 +1342,12 @@ out:
 			Tokens:       lastRespStats.Tokens,
 			Duration:     lastRespStats.Duration,
 			TokensPerSec: lastRespStats.TokensPerSec,
+			Usage:        lastRespStats.Usage,
 		}
 		lastRespStats = nil
+		recordUsage(msgStats.Usage)
 	}
+	traceLLMRound(sendStart, firstToken, promptTokens, msgStats, respText.String())
 	if msgIdx >= len(chatBody.Messages) {
 		botRespMode.Store(false)
 		cleanChatBody()
@@ -1297,6 +1362,7 @@ out:
 			default:
 			}
 		}
+		notifyVoiceRoundDone()
 		return nil
 	}
 	if r.Resume {
@@ -1309,6 +1375,10 @@ out:
 		processedMsg := processMessageTag(&updatedMsg)
 		chatBody.Messages[len(chatBody.Messages)-1] = *processedMsg
 		if msgStats != nil && chatBody.Messages[len(chatBody.Messages)-1].Role != cfg.ToolRole {
+			// the usage of the resumed reply adds up
+			if prev := chatBody.Messages[len(chatBody.Messages)-1].Stats; prev != nil {
+				msgStats.Usage = prev.Usage.Add(msgStats.Usage)
+			}
 			chatBody.Messages[len(chatBody.Messages)-1].Stats = msgStats
 		}
 	} else {
@@ -1330,6 +1400,9 @@ out:
 	if err := updateStorageChat(activeChatName, chatBody.Messages); err != nil {
 		logger.Warn("failed to update storage", "error", err, "name", activeChatName)
 	}
+	if retryRound(r, msgIdx, respText.String()) {
+		return nil
+	}
 	// Strip think blocks before parsing for tool calls
 	respTextNoThink := models.ThinkRE.ReplaceAllString(respText.String(), "")
 	if interruptResp.Load() {
@@ -1366,13 +1439,16 @@ out:
 		default:
 		}
 	}
+	notifyVoiceRoundDone()
 	// Check if this message was sent privately to specific characters
 	// If so, trigger those characters to respond if that char is not controlled by user
-	// perhaps we should have narrator role to determine which char is next to act
+	// otherwise group director picks who is next to act
 	if cfg.AutoTurn {
 		lastMsg := chatBody.Messages[len(chatBody.Messages)-1]
 		if len(lastMsg.KnownTo) > 0 {
 			triggerPrivateMessageResponses(&lastMsg)
+		} else {
+			directNextTurn(&lastMsg)
 		}
 	}
 	return nil
@@ -1498,9 +1574,7 @@ func handleBatchToolCalls(textContent string, toolCalls []models.ToolCall) bool
 	if origModel == "" {
 		logger.Debug("handleBatchToolCalls: no VRAM-free tool found, skipping unload")
 	}
-	for _, tc := range toolCalls {
-		executeOneToolCall(tc)
-	}
+	executeToolCalls(toolCalls)
 	// Reload the original model if it was unloaded for VRAM management
 	if origModel != "" {
 		reloadModel(origModel)
@@ -1548,10 +1622,32 @@ func extractOriginalImagePath(toolMsg string) string {
 
 // executeOneToolCall executes a single tool call, appends its response to chatBody.Messages.
 func executeOneToolCall(tc models.ToolCall) {
+	appendToolCallResult(tc, runToolCall(tc))
+}
+
+// toolCallResult is the outcome of a tool call before it is added to the chat
+type toolCallResult struct {
+	status     toolCallStatus
+	msg        models.RoleMsg
+	transcript []string // steps of sub agents the call ran
+}
+
+type toolCallStatus int
+
+const (
+	toolCallSkipped toolCallStatus = iota // args could not be parsed, nothing is added
+	toolCallDenied
+	toolCallFailed
+	toolCallDone
+)
+
+// runToolCall executes the tool call without touching chatBody, so it can run concurrently
+// (concurrent calls are never dangerous, so confirmation popups do not overlap)
+func runToolCall(tc models.ToolCall) toolCallResult {
 	args, err := convertJSONToMapStringString(tc.FuncCall.Args)
 	if err != nil {
 		logger.Error("failed to parse tool call args", "name", tc.FuncCall.Name, "args", tc.FuncCall.Args, "error", err)
-		return
+		return toolCallResult{status: toolCallSkipped}
 	}
 	if !tools.IsMissionMode() {
 		if dangerous, label := tools.IsDangerousCommand(tc.FuncCall.Name, args); dangerous {
@@ -1563,29 +1659,22 @@ func executeOneToolCall(tc models.ToolCall) {
 			approved := tools.RequestConfirmation(req)
 			if !approved {
 				logger.Info("dangerous command denied", "tool", tc.FuncCall.Name, "label", label)
-				chatBody.Messages = append(chatBody.Messages, models.RoleMsg{
+				return toolCallResult{status: toolCallDenied, msg: models.RoleMsg{
 					Role:       cfg.ToolRole,
 					Content:    "[denied] This command requires user confirmation: " + label,
 					ToolCallID: tc.ID,
-				})
-				return
+				}}
 			}
 		}
 	}
 	outputHandler.Writef("\n[yellow::i][tool: %s...][-:-:-]\nargs: %s", tc.FuncCall.Name, tc.FuncCall.Args)
-	toolRunningMode.Store(true)
-	resp, ok := tools.CallToolWithAgent(tc.FuncCall.Name, args)
-	toolRunningMode.Store(false)
+	resp, transcript, ok := callToolCancelable(tc, args)
 	if !ok {
-		if tools.IsMissionMode() {
-			tools.GetCurrentMission().AddFailure()
-		}
-		chatBody.Messages = append(chatBody.Messages, models.RoleMsg{
+		return toolCallResult{status: toolCallFailed, transcript: transcript, msg: models.RoleMsg{
 			Role:       cfg.ToolRole,
 			Content:    string(resp),
 			ToolCallID: tc.ID,
-		})
-		return
+		}}
 	}
 	toolMsg := string(resp)
 	logger.Info("llm used a tool call", "tool_name", tc.FuncCall.Name, "args", args, "id", tc.ID, "cwd", tools.GetFSRoot(), "resp", toolMsg)
@@ -1676,6 +1765,27 @@ func executeOneToolCall(tc models.ToolCall) {
 			}
 		}
 	}
+	return toolCallResult{status: toolCallDone, msg: toolResponseMsg, transcript: transcript}
+}
+
+// appendToolCallResult adds the tool response to the chat and does mission bookkeeping;
+// it runs in the original call order
+func appendToolCallResult(tc models.ToolCall, res toolCallResult) {
+	switch res.status {
+	case toolCallSkipped:
+		return
+	case toolCallDenied:
+		chatBody.Messages = append(chatBody.Messages, res.msg)
+		return
+	case toolCallFailed:
+		if tools.IsMissionMode() {
+			tools.GetCurrentMission().AddFailure()
+		}
+		chatBody.Messages = append(chatBody.Messages, res.msg)
+		attachSubAgentTranscript(len(chatBody.Messages)-1, res.transcript)
+		return
+	}
+	toolResponseMsg := res.msg
 	if tools.IsMissionMode() && tools.IsToolError(tc.FuncCall.Name, toolResponseMsg.Content) {
 		tools.GetCurrentMission().AddFailure()
 		logger.Info("mission tool error detected", "tool", tc.FuncCall.Name)
@@ -1686,25 +1796,22 @@ func executeOneToolCall(tc models.ToolCall) {
 		tools.GetCurrentMission().IncrementToolCalls()
 	}
 	if tools.IsMissionMode() {
-		m := tools.GetCurrentMission()
-		key := tc.FuncCall.Name + ":" + tc.FuncCall.Args
-		if key == m.LastToolCall {
-			m.SameToolCount++
-		} else {
-			m.LastToolCall = key
-			m.SameToolCount = 1
+		step := mission.PMStep{
+			Tool:  tc.FuncCall.Name,
+			Args:  tc.FuncCall.Args,
+			Error: tools.IsToolError(tc.FuncCall.Name, toolResponseMsg.Content),
 		}
-		if m.SameToolCount > 0 && m.SameToolCount%3 == 0 {
-			m.PMGuidanceNeeded = true
-			m.Log("Loop detected: %s repeated %d times", tc.FuncCall.Name, m.SameToolCount)
+		if args, err := convertJSONToMapStringString(tc.FuncCall.Args); err == nil {
+			step.Command = args["command"]
 		}
+		tools.GetCurrentMission().ObserveToolCall(step)
 	}
 	if tools.IsMissionMode() {
 		m := tools.GetCurrentMission()
 		if m.PMGuidanceNeeded {
 			m.PMGuidanceNeeded = false
+			m.Log("PM check-in triggered at tool call %d: %s", m.Checkpoint.ToolCallCount, m.PMReason)
 			guidance := getPMGuidance(m)
-			m.Log("PM check-in triggered at tool call %d", m.Checkpoint.ToolCallCount)
 			chatBody.Messages = append(chatBody.Messages, models.RoleMsg{
 				Role:    cfg.UserRole,
 				Content: fmt.Sprintf("[PM Check-in]\n%s", guidance),
@@ -1714,6 +1821,7 @@ func executeOneToolCall(tc models.ToolCall) {
 	outputHandler.Writef("%s[-:-:b](%d) <%s>: [-:-:-]\n%s\n",
 		"\n\n", len(chatBody.Messages), cfg.ToolRole, toolResponseMsg.GetText())
 	chatBody.Messages = append(chatBody.Messages, toolResponseMsg)
+	attachSubAgentTranscript(len(chatBody.Messages)-1, res.transcript)
 }
 
 // executeSingleToolCall executes the first (and only) tool call found in the message at msgIdx.
@@ -1876,8 +1984,7 @@ func findCall(msg, toolCall string) bool {
 	// Show tool call progress indicator before execution
 	argsJSON, _ := json.Marshal(fc.Args)
 	outputHandler.Writef("\n[yellow::i][tool: %s...][-:-:-]\nargs: %s", fc.Name, string(argsJSON))
-	toolRunningMode.Store(true)
-	resp, okT := tools.CallToolWithAgent(fc.Name, fc.Args)
+	resp, transcript, okT := callToolCancelable(*chatBody.Messages[lastMsgIdx].ToolCall, fc.Args)
 	if !okT {
 		// Create tool response message with the proper tool_call_id
 		toolResponseMsg := models.RoleMsg{
@@ -1886,6 +1993,7 @@ func findCall(msg, toolCall string) bool {
 			ToolCallID: lastToolCall.ID, // Use the stored tool call ID
 		}
 		chatBody.Messages = append(chatBody.Messages, toolResponseMsg)
+		attachSubAgentTranscript(len(chatBody.Messages)-1, transcript)
 		logger.Debug("findCall: added tool not implemented response", "role", toolResponseMsg.Role,
 			"content_len", len(toolResponseMsg.Content), "tool_call_id", toolResponseMsg.ToolCallID)
 		// Clear the stored tool call ID after using it
@@ -1895,11 +2003,10 @@ func findCall(msg, toolCall string) bool {
 		crr := &models.ChatRoundReq{
 			Role: cfg.AssistantRole,
 		}
-		// failed to find tool
+		// failed to find tool, timed out or cancelled
 		chatRoundChan <- crr
 		return true
 	}
-	toolRunningMode.Store(false)
 	toolMsg := string(resp)
 	logger.Info("llm used a tool call", "tool_name", fc.Name, "too_args", fc.Args, "id", fc.ID, "tool_resp", toolMsg)
 	// Create tool response message with the proper tool_call_id
@@ -2000,6 +2107,7 @@ func findCall(msg, toolCall string) bool {
 	outputHandler.Writef("%s[-:-:b](%d) <%s>: [-:-:-]\n%s\n",
 		"\n\n", len(chatBody.Messages), cfg.ToolRole, toolResponseMsg.GetText())
 	chatBody.Messages = append(chatBody.Messages, toolResponseMsg)
+	attachSubAgentTranscript(len(chatBody.Messages)-1, transcript)
 	// Clear the stored tool call ID after using it
 	lastToolCall.ID = ""
 	// Trigger the assistant to continue processing with the new tool response
@@ -2074,7 +2182,7 @@ func chatToTextSlice(messages []models.RoleMsg, showSys bool) []string {
 			continue
 		}
 		if messages[i].Role == cfg.ToolRole || messages[i].Role == "tool" {
-			resp[i] = MsgToText(i, &messages[i])
+			resp[i] = MsgToText(i, &messages[i]) + subAgentTranscriptText(i, &messages[i])
 			continue
 		}
 		if !showSys && messages[i].Role == "system" {
@@ -2153,10 +2261,27 @@ func applyCharCard(cc *models.CharCard, loadHistory bool) {
 		}
 		logger.Warn("failed to load last agent chat;", "agent", cc.Role, "err", err, "new_history", history)
 		addNewChat("")
+		if !cfg.CLIMode && app != nil && len(cc.AltGreetings) > 0 {
+			// let the caller finish updating the ui first
+			go app.QueueUpdateDraw(func() { showGreetingSelectionPopup(cc) })
+		}
 	}
 	chatBody.Messages = history
 }
 
+// setGreeting replaces the first bot message of a fresh chat with one of the card greetings
+func setGreeting(cc *models.CharCard, idx int) error {
+	greetings := cc.Greetings()
+	if idx < 0 || idx >= len(greetings) {
+		return fmt.Errorf("no greeting #%d; card has %d", idx, len(greetings))
+	}
+	if len(chatBody.Messages) != 2 || chatBody.Messages[1].Role != cc.Role {
+		return errors.New("greeting can be changed only before the first message")
+	}
+	chatBody.Messages[1].Content = greetings[idx]
+	return nil
+}
+
 func charToStart(agentName string, keepSysP bool) bool {
 	cc := GetCardByRole(agentName)
 	if cc == nil {
@@ -2223,6 +2348,7 @@ func refreshLocalModelsIfEmpty() {
 }
 
 func startNewCLIChat() []models.RoleMsg {
+	rememberChat()
 	id, err := store.ChatGetMaxID()
 	if err != nil {
 		logger.Error("failed to get chat id", "error", err)
@@ -2262,7 +2388,7 @@ func summarizeAndStartNewChat() {
 		"chat": chatToText(chatBody.Messages, false),
 	}
 	// Call the summarize_chat tool via agent
-	summaryBytes, _ := tools.CallToolWithAgent("summarize_chat", arg)
+	summaryBytes, _ := tools.CallToolWithAgent(context.Background(), "summarize_chat", arg)
 	summary := string(summaryBytes)
 	if summary == "" {
 		showToast("error", "Failed to generate summary")
@@ -2351,6 +2477,7 @@ func init() {
 	if _, err := initSysCards(); err != nil {
 		logger.Error("failed to init sys cards", "error", err)
 	}
+	loadLoreBooks()
 	lastToolCall = &models.FuncCall{}
 	var lastChat []models.RoleMsg
 	if cfg.CLIMode {
@@ -2365,6 +2492,10 @@ func init() {
 	}
 	choseChunkParser()
 	httpClient = createClient(time.Second * 90)
+	// agents and tools use the default client
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		mockllm.Register(t)
+	}
 	orator = NewOrator(logger, cfg)
 	asr = NewSTT(logger, cfg)
 	if cfg.PlaywrightEnabled {
@@ -1,9 +1,20 @@
 package main
 import (
+	"context"
+	"fmt"
+	"gf-lt/agent"
 	"gf-lt/config"
 	"gf-lt/models"
+	"gf-lt/tools"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 func TestConsolidateConsecutiveAssistantMessages(t *testing.T) {
 	// Mock config for testing
@@ -524,6 +535,7 @@ func TestFilterMessagesForCharacter(t *testing.T) {
 		name        string
 		enabled     bool
 		character   string
+		api         string
 		wantIndices []int // indices from original messages that should be included
 	}{
 		{
@@ -562,12 +574,20 @@ func TestFilterMessagesForCharacter(t *testing.T) {
 			character:   "David",
 			wantIndices: []int{0, 1, 5},
 		},
+		{
+			name:        "chat endpoint filters too",
+			enabled:     true,
+			character:   "Bob",
+			api:         "http://localhost:8080/v1/chat/completions",
+			wantIndices: []int{0, 1, 2, 3, 5},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			testCfg := &config.Config{
 				CharSpecificContextEnabled: tt.enabled,
 				CharSpecificContextTag:     "@",
+				CurrentAPI:                 tt.api,
 			}
 			cfg = testCfg
 			got := filterMessagesForCharacter(messages, tt.character)
@@ -584,6 +604,74 @@ func TestFilterMessagesForCharacter(t *testing.T) {
 		})
 	}
 }
+func TestToChatRoles(t *testing.T) {
+	cfg = &config.Config{
+		AssistantRole:              "Alice",
+		UserRole:                   "Carl",
+		ToolRole:                   "tool",
+		CharSpecificContextEnabled: true,
+		CharSpecificContextTag:     "@",
+	}
+	messages := []models.RoleMsg{
+		{Role: "system", Content: "sys"},
+		{Role: "Alice", Content: "Hello everyone"},
+		{Role: "Carl", Content: "Hi"},
+		{Role: "Alice", Content: "Secret for Bob", KnownTo: []string{"Alice", "Bob"}},
+		{Role: "Bob", Content: "Reply to Alice", KnownTo: []string{"Alice", "Bob"}},
+		{Role: "Dana", Content: "Dana: I was here"},
+		{Role: "tool", Content: "result"},
+	}
+	tests := []struct {
+		name    string
+		persona string
+		want    []models.RoleMsg
+	}{
+		{
+			name:    "Bob replies",
+			persona: "Bob",
+			want: []models.RoleMsg{
+				{Role: "system", Content: "sys"},
+				{Role: "user", Content: "Alice: Hello everyone"},
+				{Role: "user", Content: "Hi"},
+				{Role: "user", Content: "Alice: Secret for Bob", KnownTo: []string{"Alice", "Bob"}},
+				{Role: "assistant", Content: "Reply to Alice", KnownTo: []string{"Alice", "Bob"}},
+				{Role: "user", Content: "Dana: I was here"},
+				{Role: "tool", Content: "result"},
+			},
+		},
+		{
+			name:    "Dana replies and does not see the secret",
+			persona: "Dana",
+			want: []models.RoleMsg{
+				{Role: "system", Content: "sys"},
+				{Role: "user", Content: "Alice: Hello everyone"},
+				{Role: "user", Content: "Hi"},
+				{Role: "assistant", Content: "Dana: I was here"},
+				{Role: "tool", Content: "result"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toChatRoles(filterMessagesForCharacter(messages, tt.persona), tt.persona)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("toChatRoles() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if messages[1].Content != "Hello everyone" {
+		t.Errorf("original message was changed: %q", messages[1].Content)
+	}
+	// continued message sets the persona
+	cfg.CharSpecificContextEnabled = false
+	if _, persona := filterMessagesForCurrentCharacter(messages[:5], true); persona != "Bob" {
+		t.Errorf("resume persona = %q, want Bob", persona)
+	}
+	if _, persona := filterMessagesForCurrentCharacter(messages[:5], false); persona != "Alice" {
+		t.Errorf("persona = %q, want Alice", persona)
+	}
+}
+
 func TestRoleMsgCopyPreservesKnownTo(t *testing.T) {
 	// Test that the Copy() method preserves the KnownTo field
 	originalMsg := models.RoleMsg{
@@ -636,3 +724,411 @@ func TestKnownToFieldPreservationScenario(t *testing.T) {
 		t.Errorf("KnownTo was not properly copied: got %v, want %v", copiedMsg.KnownTo, originalMsg.KnownTo)
 	}
 }
+
+func TestInjectLore(t *testing.T) {
+	cfg = &config.Config{
+		AssistantRole:   "Seraphina",
+		UserRole:        "Adam",
+		LorebookEnabled: true,
+	}
+	card := &models.CharCard{ID: "lore_test", Role: "Seraphina", CharacterBook: &models.CharacterBook{
+		Entries: []models.CharacterBookEntry{
+			{Keys: []string{"glade"}, Content: "{{char}} lives in the glade.", Enabled: true},
+			{Keys: []string{"glade"}, Content: "The glade is hidden.", Enabled: true, Position: "after_char"},
+			{Keys: []string{"castle"}, Content: "castle", Enabled: true},
+		},
+	}}
+	sysMap[card.ID] = card
+	roleToID[card.Role] = card.ID
+	defer func() {
+		delete(sysMap, card.ID)
+		delete(roleToID, card.Role)
+	}()
+	messages := []models.RoleMsg{
+		{Role: "system", Content: "sys prompt"},
+		{Role: "Seraphina", Content: "hello"},
+		{Role: "Adam", Content: "where is the glade?"},
+	}
+	got := injectLore(messages, "Seraphina")
+	want := []models.RoleMsg{
+		{Role: "system", Content: "Seraphina lives in the glade."},
+		{Role: "system", Content: "sys prompt"},
+		{Role: "system", Content: "The glade is hidden."},
+		{Role: "Seraphina", Content: "hello"},
+		{Role: "Adam", Content: "where is the glade?"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("injectLore() = %+v, want %+v", got, want)
+	}
+	if len(messages) != 3 {
+		t.Errorf("original messages were changed: %+v", messages)
+	}
+	cfg.LorebookEnabled = false
+	if got := injectLore(messages, "Seraphina"); len(got) != 3 {
+		t.Errorf("lorebook is disabled, got %+v", got)
+	}
+}
+
+func TestSaveCard(t *testing.T) {
+	prevCfg := cfg
+	defer func() { cfg = prevCfg }()
+	dir := t.TempDir()
+	cfg = &config.Config{SysDir: dir, UserRole: "Adam"}
+	spec := &models.CharCardSpec{
+		Name:               "Mira",
+		Description:        "{{char}} is a sailor.",
+		FirstMes:           "Ahoy, {{user}}!",
+		AlternateGreetings: []string{"Welcome aboard, {{user}}."},
+	}
+	out, err := saveCard(spec, "", "", false)
+	if err != nil {
+		t.Fatalf("saveCard failed: %v", err)
+	}
+	defer func() {
+		for id, cc := range sysMap {
+			if cc.FilePath == out {
+				delete(sysMap, id)
+				delete(roleToID, cc.Role)
+			}
+		}
+	}()
+	cc, err := registerCard(out)
+	if err != nil {
+		t.Fatalf("registerCard failed: %v", err)
+	}
+	if cc.SysPrompt != "Mira is a sailor." || cc.FirstMsg != "Ahoy, Adam!" || cc.AltGreetings[0] != "Welcome aboard, Adam." {
+		t.Errorf("unexpected card: %+v", cc)
+	}
+	if GetCardByRole("Mira") != cc {
+		t.Errorf("card was not registered")
+	}
+	// new card must not overwrite existing file
+	if _, err := saveCard(spec, "", "", false); err == nil {
+		t.Errorf("expected error for existing file")
+	}
+	if _, err := saveCard(spec, "", out, false); err != nil {
+		t.Errorf("saving over the card own file should work: %v", err)
+	}
+	// renamed card answers to the new role only
+	spec.Name = "Nora"
+	if _, err := saveCard(spec, "", out, false); err != nil {
+		t.Fatalf("saveCard failed: %v", err)
+	}
+	renamed, err := registerCard(out)
+	if err != nil {
+		t.Fatalf("registerCard failed: %v", err)
+	}
+	if GetCardByRole("Nora") != renamed || GetCardByRole("Mira") != nil {
+		t.Errorf("old role still registered after rename: %v", roleToID)
+	}
+}
+
+func TestDirectorNextSpeaker(t *testing.T) {
+	cfg = &config.Config{UserRole: "Carl"}
+	order := []string{"Alice", "Bob", "Dana"}
+	tests := []struct {
+		name     string
+		strategy string
+		muted    []string
+		last     string
+		text     string
+		want     string
+	}{
+		{name: "round robin", strategy: groupStrategyRoundRobin, last: "Alice", want: "Bob"},
+		{name: "round robin wraps", strategy: groupStrategyRoundRobin, last: "Dana", want: "Alice"},
+		{name: "round robin skips muted", strategy: groupStrategyRoundRobin, muted: []string{"Bob"}, last: "Alice", want: "Dana"},
+		{name: "round robin after user", strategy: groupStrategyRoundRobin, last: "Carl", want: "Alice"},
+		{name: "mention", strategy: groupStrategyMention, last: "Alice", text: "Dana, and you Bob?", want: "Dana"},
+		{name: "mention is whole word", strategy: groupStrategyMention, last: "Alice", text: "Bobby is late", want: ""},
+		{name: "speaker does not answer itself", strategy: groupStrategyMention, last: "Alice", text: "I am Alice", want: ""},
+		{name: "muted is not mentioned", strategy: groupStrategyMention, muted: []string{"Dana"}, last: "Alice", text: "Dana?", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := groupCandidates(order, tt.muted, tt.last)
+			got := nextMentioned(candidates, tt.text)
+			if tt.strategy == groupStrategyRoundRobin {
+				got = nextRoundRobin(order, candidates, tt.last)
+			}
+			if got != tt.want {
+				t.Errorf("next speaker = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	talk := map[string]float64{"Alice": 0, "Bob": 0.2, "Dana": 0.6}
+	byTalk := func(rnd float64) string {
+		return nextByTalkativeness(order, func(name string) float64 { return talk[name] }, func() float64 { return rnd })
+	}
+	if got := byTalk(0.1); got != "Bob" {
+		t.Errorf("talkativeness pick = %q, want Bob", got)
+	}
+	if got := byTalk(0.5); got != "Dana" {
+		t.Errorf("talkativeness pick = %q, want Dana", got)
+	}
+	if got := nextByTalkativeness([]string{"Alice"}, func(string) float64 { return 0 }, func() float64 { return 0 }); got != "" {
+		t.Errorf("silent char should not speak, got %q", got)
+	}
+	answers := map[string]string{"bob.": "Bob", "<think>hm</think> Dana": "Dana", "Carl": "", "I think Alice should": "Alice"}
+	for answer, want := range answers {
+		if got, err := parseSpeakerAnswer(answer, order); err != nil || got != want {
+			t.Errorf("parseSpeakerAnswer(%q) = %q, %v; want %q", answer, got, err, want)
+		}
+	}
+	if _, err := parseSpeakerAnswer("nobody", order); err == nil {
+		t.Errorf("expected error for answer without names")
+	}
+}
+
+func TestDirectorPrivateMsgAndInterrupt(t *testing.T) {
+	prevCfg, prevBody, prevChan, prevParser := cfg, chatBody, chatRoundChan, chunkParser
+	defer func() {
+		cfg, chatBody, chatRoundChan, chunkParser = prevCfg, prevBody, prevChan, prevParser
+		groupAutoTurns.Store(0)
+		interruptResp.Store(false)
+	}()
+	outputHandler = &SilentOutputHandler{}
+	// private reply is made even to a muted char after the max auto turns
+	cfg = &config.Config{UserRole: "Carl", ToolRole: "tool", CharSpecificContextEnabled: true, GroupMuted: []string{"Bob"}, GroupMaxAutoTurns: 1}
+	groupAutoTurns.Store(1)
+	chatRoundChan = make(chan *models.ChatRoundReq, 1)
+	chatBody = &models.ChatBody{Messages: []models.RoleMsg{
+		{Role: "Carl", Content: "hi"}, {Role: "Bob", Content: "hey"},
+		{Role: "Alice", Content: "psst, Bob", KnownTo: []string{"Alice", "Bob"}},
+	}}
+	triggerPrivateMessageResponses(&chatBody.Messages[2])
+	select {
+	case req := <-chatRoundChan:
+		if req.Role != "Bob" {
+			t.Errorf("expected Bob to reply privately, got %+v", req)
+		}
+	default:
+		t.Error("private message was not answered")
+	}
+	if triggerCharTurn("Alice") {
+		t.Error("director turn should stop at max auto turns")
+	}
+	// llm strategy cancels its request on interrupt
+	block := make(chan struct{})
+	cancelled := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the server sees the closed connection once the body is read
+		io.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-block:
+		}
+	}))
+	defer srv.Close()
+	defer close(block)
+	cfg.CurrentAPI = srv.URL
+	chunkParser = LCPChat{}
+	interruptResp.Store(true)
+	start := time.Now()
+	if _, err := askNextSpeaker([]string{"Alice", "Bob"}); err != errDirectorInterrupted {
+		t.Errorf("expected interrupt, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("interrupt took %s", time.Since(start))
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("director request was not cancelled")
+	}
+}
+
+func TestParseExtractedFacts(t *testing.T) {
+	answer := `<think>what to keep</think>Sure: [{"topic": "pet", "fact": "Adam has a cat named Tom", "importance": 0.8},
+{"topic": "", "fact": "no topic"}, {"topic": "weather", "fact": "it rains"}]`
+	facts, err := parseExtractedFacts(answer)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(facts) != 2 {
+		t.Fatalf("expected 2 facts, got %d: %+v", len(facts), facts)
+	}
+	if facts[0].Topic != "pet" || facts[0].Importance != 0.8 || facts[1].Importance != 0 {
+		t.Errorf("unexpected facts: %+v", facts)
+	}
+	if _, err := parseExtractedFacts("nothing to remember"); err == nil {
+		t.Errorf("expected error for answer without json array")
+	}
+	// disabled recall keeps messages as they are
+	cfg = &config.Config{MemoryEnabled: true}
+	msgs := []models.RoleMsg{{Role: "system", Content: "sys"}, {Role: "Adam", Content: "where is my cat?"}}
+	if got := injectMemories(msgs, "Eve", false); len(got) != len(msgs) {
+		t.Errorf("expected no memories injected with MemoryRecallTopK 0, got %d msgs", len(got))
+	}
+}
+
+// cancelled tool goroutines outlive the test, so tools are initialized once
+var testToolsInit sync.Once
+
+func TestExecuteToolCallsOrderAndCancel(t *testing.T) {
+	cfg = &config.Config{ToolRole: "tool", ToolConcurrency: 2, ParallelTools: []string{"slow_test"}}
+	testToolsInit.Do(func() { tools.InitTools(cfg, logger, nil) })
+	outputHandler = &SilentOutputHandler{}
+	chatBody = &models.ChatBody{}
+	var mu sync.Mutex
+	running, maxRunning, slowDone := 0, 0, 0
+	tools.FnMap["slow_test"] = func(ctx context.Context, args map[string]string) []byte {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+		// later calls finish first
+		n, _ := strconv.Atoi(args["n"])
+		time.Sleep(time.Duration(40-n*10) * time.Millisecond)
+		mu.Lock()
+		running--
+		slowDone++
+		mu.Unlock()
+		return []byte("slow " + args["n"])
+	}
+	tools.FnMap["seq_test"] = func(ctx context.Context, args map[string]string) []byte {
+		mu.Lock()
+		defer mu.Unlock()
+		return []byte(fmt.Sprintf("seq after %d", slowDone))
+	}
+	started, block := make(chan struct{}), make(chan struct{})
+	tools.FnMap["block_test"] = func(ctx context.Context, args map[string]string) []byte {
+		close(started)
+		<-block
+		return []byte("finished")
+	}
+	defer func() {
+		close(block)
+		delete(tools.FnMap, "slow_test")
+		delete(tools.FnMap, "seq_test")
+		delete(tools.FnMap, "block_test")
+	}()
+	call := func(id, name, args string) models.ToolCall {
+		return models.ToolCall{ID: id, FuncCall: models.ToolCallFunction{Name: name, Args: args}}
+	}
+	executeToolCalls([]models.ToolCall{
+		call("1", "slow_test", `{"n": "1"}`),
+		call("2", "slow_test", `{"n": "2"}`),
+		call("3", "slow_test", `{"n": "3"}`),
+		call("4", "seq_test", `{}`),
+	})
+	want := []string{"slow 1", "slow 2", "slow 3", "seq after 3"}
+	if len(chatBody.Messages) != len(want) {
+		t.Fatalf("expected %d tool msgs, got %+v", len(want), chatBody.Messages)
+	}
+	for i, msg := range chatBody.Messages {
+		if msg.Content != want[i] || msg.ToolCallID != strconv.Itoa(i+1) {
+			t.Errorf("msg %d: got %q (id %s), want %q", i, msg.Content, msg.ToolCallID, want[i])
+		}
+	}
+	if maxRunning != 2 {
+		t.Errorf("expected 2 calls at once, got %d", maxRunning)
+	}
+	chatBody.Messages = nil
+	done := make(chan struct{})
+	go func() {
+		executeOneToolCall(call("5", "block_test", `{}`))
+		close(done)
+	}()
+	<-started
+	if running := listRunningToolCalls(); len(running) != 1 || running[0].name != "block_test" {
+		t.Errorf("unexpected running calls: %+v", running)
+	}
+	cancelToolCall(-1)
+	<-done
+	if len(chatBody.Messages) != 1 || !strings.HasPrefix(chatBody.Messages[0].Content, "[cancelled]") {
+		t.Errorf("expected cancelled tool response, got %+v", chatBody.Messages)
+	}
+	if toolRunningMode.Load() {
+		t.Errorf("tool running mode is still on")
+	}
+}
+
+func TestCallToolTimeout(t *testing.T) {
+	cfg = &config.Config{ToolRole: "tool", ToolTimeout: 60, ToolTimeouts: map[string]int{"hang_test": 1}}
+	testToolsInit.Do(func() { tools.InitTools(cfg, logger, nil) })
+	outputHandler = &SilentOutputHandler{}
+	stopped := make(chan error, 1)
+	tools.FnMap["hang_test"] = func(ctx context.Context, args map[string]string) []byte {
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return nil
+	}
+	defer delete(tools.FnMap, "hang_test")
+	if got := toolTimeout("bash"); got != time.Minute {
+		t.Errorf("expected ToolTimeout for bash, got %s", got)
+	}
+	if got := toolTimeout("delegate"); got != 0 {
+		t.Errorf("expected no timeout for delegate, got %s", got)
+	}
+	cfg.ToolTimeouts["delegate"] = 900
+	if got := toolTimeout("delegate"); got != 900*time.Second {
+		t.Errorf("expected ToolTimeouts for delegate, got %s", got)
+	}
+	tc := models.ToolCall{ID: "1", FuncCall: models.ToolCallFunction{Name: "hang_test", Args: "{}"}}
+	resp, _, ok := callToolCancelable(tc, map[string]string{})
+	if ok || !strings.Contains(string(resp), "did not finish in 1s") {
+		t.Errorf("expected timeout response, got %q %v", resp, ok)
+	}
+	if err := <-stopped; err != context.DeadlineExceeded {
+		t.Errorf("tool ctx was not done by deadline: %v", err)
+	}
+}
+
+func TestSubAgentTranscriptsOfConcurrentCalls(t *testing.T) {
+	cfg = &config.Config{ToolRole: "tool", ToolConcurrency: 2, ParallelTools: []string{"agent_test"}}
+	testToolsInit.Do(func() { tools.InitTools(cfg, logger, nil) })
+	outputHandler = &SilentOutputHandler{}
+	chatBody = &models.ChatBody{}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	tools.FnMap["agent_test"] = func(ctx context.Context, args map[string]string) []byte {
+		// both calls are running before either logs its steps
+		wg.Done()
+		wg.Wait()
+		for i := range 3 {
+			onSubAgentStep(ctx, args["name"], agent.SubAgentStep{Kind: agent.StepAnswer, Text: fmt.Sprintf("step %d", i)})
+		}
+		return []byte("done")
+	}
+	defer delete(tools.FnMap, "agent_test")
+	executeToolCalls([]models.ToolCall{
+		{ID: "a", FuncCall: models.ToolCallFunction{Name: "agent_test", Args: `{"name": "alpha"}`}},
+		{ID: "b", FuncCall: models.ToolCallFunction{Name: "agent_test", Args: `{"name": "beta"}`}},
+	})
+	if len(chatBody.Messages) != 2 {
+		t.Fatalf("expected 2 tool msgs, got %+v", chatBody.Messages)
+	}
+	for i, name := range []string{"alpha", "beta"} {
+		text := subAgentTranscriptText(i, &chatBody.Messages[i])
+		if strings.Count(text, name+" answer") != 3 || strings.Count(text, "answer") != 3 {
+			t.Errorf("msg %d: expected only the 3 steps of %s, got %q", i, name, text)
+		}
+	}
+}
+
+func TestCallToolTimeoutPausedForConfirmation(t *testing.T) {
+	cfg = &config.Config{ToolRole: "tool", ToolTimeouts: map[string]int{"confirm_test": 1}}
+	testToolsInit.Do(func() { tools.InitTools(cfg, logger, nil) })
+	outputHandler = &SilentOutputHandler{}
+	tools.FnMap["confirm_test"] = func(ctx context.Context, args map[string]string) []byte {
+		if !tools.RequestConfirmationCtx(ctx, tools.ConfirmRequest{ToolName: "bash", Command: "rm -rf x"}) {
+			return []byte("denied")
+		}
+		if err := ctx.Err(); err != nil {
+			return []byte(err.Error())
+		}
+		return []byte("done")
+	}
+	defer delete(tools.FnMap, "confirm_test")
+	go func() {
+		req := <-tools.ConfirmChan
+		// the user takes longer than the timeout to answer
+		time.Sleep(1500 * time.Millisecond)
+		req.Result <- true
+	}()
+	tc := models.ToolCall{ID: "1", FuncCall: models.ToolCallFunction{Name: "confirm_test", Args: "{}"}}
+	if resp, _, ok := callToolCancelable(tc, map[string]string{}); !ok || string(resp) != "done" {
+		t.Errorf("expected confirmation wait not to count against timeout, got %q %v", resp, ok)
+	}
+}
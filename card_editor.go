@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gf-lt/models"
+	"gf-lt/pngmeta"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	cardEditorPage = "cardEditorPage"
+	// separates alternate greetings in the editor text area
+	greetingSep = "\n---\n"
+)
+
+var cardFileNameRE = regexp.MustCompile(`[^\p{L}\p{N}_-]+`)
+
+// cardForEdit returns the card spec with macros put back, to not save user name into the card
+func cardForEdit(cc *models.CharCard) (*models.CharCardSpec, string) {
+	avatar := ""
+	if strings.HasSuffix(cc.FilePath, ".png") {
+		avatar = cc.FilePath
+	}
+	return cc.ToSpec(cfg.UserRole), avatar
+}
+
+// currentChatCard makes card from the current chat: its sys prompt and first msg,
+// other fields are taken from the loaded card
+func currentChatCard() (*models.CharCardSpec, string, string) {
+	cc := &models.CharCard{Role: cfg.AssistantRole}
+	if loaded := GetCardByRole(cfg.AssistantRole); loaded != nil {
+		cp := *loaded
+		cc = &cp
+	}
+	if len(chatBody.Messages) > 0 && chatBody.Messages[0].Role == "system" {
+		cleaned := removeToolGuide([]models.RoleMsg{{Role: "system", Content: chatBody.Messages[0].Content}})
+		cc.SysPrompt = cleaned[0].Content
+	}
+	if len(chatBody.Messages) > 1 {
+		cc.FirstMsg = chatBody.Messages[1].GetText()
+	}
+	spec, avatar := cardForEdit(cc)
+	return spec, avatar, cc.FilePath
+}
+
+// saveCard writes card as png (embedding into avatar) or gf-lt json; returns written file
+func saveCard(spec *models.CharCardSpec, avatar, savePath string, asPNG bool) (string, error) {
+	if strings.TrimSpace(spec.Name) == "" {
+		return "", errors.New("card needs a name")
+	}
+	ext := ".json"
+	if asPNG {
+		ext = ".png"
+	}
+	out := savePath
+	if !strings.HasSuffix(out, ext) {
+		out = filepath.Join(cfg.SysDir, cardFileNameRE.ReplaceAllString(spec.Name, "_")+ext)
+		if _, err := os.Stat(out); err == nil {
+			return "", fmt.Errorf("file already exists: %s", out)
+		}
+	}
+	if !asPNG {
+		// {{user}} is filled on load
+		cc := spec.Simplify("{{user}}", out)
+		cc.Characters = nil
+		data, err := json.MarshalIndent(cc, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return out, os.WriteFile(out, data, 0o644)
+	}
+	if avatar == "" {
+		avatar = defaultImage
+	}
+	if !strings.HasSuffix(strings.ToLower(avatar), ".png") {
+		return "", fmt.Errorf("avatar has to be a png: %s", avatar)
+	}
+	return out, pngmeta.WriteToPng(spec, avatar, out)
+}
+
+// registerCard (re)reads saved card so it can be loaded right away;
+// a renamed card no longer answers to its old role (chats of the old id still find it in sysMap)
+func registerCard(fpath string) (*models.CharCard, error) {
+	var cc *models.CharCard
+	var err error
+	if strings.HasSuffix(fpath, ".png") {
+		cc, err = pngmeta.ReadCard(fpath, cfg.UserRole)
+	} else {
+		cc, err = pngmeta.ReadCardJson(fpath, cfg.UserRole)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for role, id := range roleToID {
+		if old, ok := sysMap[id]; ok && old.FilePath == cc.FilePath && role != cc.Role {
+			delete(roleToID, role)
+		}
+	}
+	sysMap[cc.ID] = cc
+	roleToID[cc.Role] = cc.ID
+	return cc, nil
+}
+
+// showCardEditor opens a form to create or edit a card; savePath is the file the card came from
+func showCardEditor(spec *models.CharCardSpec, avatar, savePath string) {
+	if spec == nil {
+		spec = &models.CharCardSpec{}
+	}
+	const (
+		nameLabel      = "Name"
+		descLabel      = "Description"
+		persLabel      = "Personality"
+		scenarioLabel  = "Scenario"
+		firstMsgLabel  = "First message"
+		greetingsLabel = "Alt greetings (--- line between)"
+		exampleLabel   = "Example dialogue"
+		avatarLabel    = "Avatar (png)"
+	)
+	form := tview.NewForm()
+	title := "New card"
+	if savePath != "" {
+		title = "Edit card: " + savePath
+	}
+	form.SetBorder(true).SetTitle(title + " (esc: close)").SetTitleAlign(tview.AlignLeft)
+	form.AddInputField(nameLabel, spec.Name, 60, nil, nil)
+	form.AddTextArea(descLabel, spec.Description, 0, 6, 0, nil)
+	form.AddTextArea(persLabel, spec.Personality, 0, 3, 0, nil)
+	form.AddTextArea(scenarioLabel, spec.Scenario, 0, 3, 0, nil)
+	form.AddTextArea(firstMsgLabel, spec.FirstMes, 0, 5, 0, nil)
+	form.AddTextArea(greetingsLabel, strings.Join(spec.AlternateGreetings, greetingSep), 0, 4, 0, nil)
+	form.AddTextArea(exampleLabel, spec.MesExample, 0, 4, 0, nil)
+	form.AddInputField(avatarLabel, avatar, 60, nil, nil)
+	text := func(label string) string {
+		switch item := form.GetFormItemByLabel(label).(type) {
+		case *tview.TextArea:
+			return item.GetText()
+		case *tview.InputField:
+			return item.GetText()
+		}
+		return ""
+	}
+	closeEditor := func() {
+		pages.RemovePage(cardEditorPage)
+		app.SetFocus(textArea)
+	}
+	save := func(asPNG bool) {
+		// keep fields the editor does not show (lorebook, tags, extensions)
+		edited := *spec
+		edited.Name = strings.TrimSpace(text(nameLabel))
+		edited.Description = text(descLabel)
+		edited.Personality = text(persLabel)
+		edited.Scenario = text(scenarioLabel)
+		edited.FirstMes = text(firstMsgLabel)
+		edited.MesExample = text(exampleLabel)
+		edited.AlternateGreetings = nil
+		for _, g := range strings.Split(text(greetingsLabel), greetingSep) {
+			if strings.TrimSpace(g) != "" {
+				edited.AlternateGreetings = append(edited.AlternateGreetings, strings.TrimSpace(g))
+			}
+		}
+		out, err := saveCard(&edited, strings.TrimSpace(text(avatarLabel)), savePath, asPNG)
+		if err != nil {
+			logger.Error("failed to save card", "name", edited.Name, "error", err)
+			showToast("card", "failed to save: "+err.Error())
+			return
+		}
+		if _, err := registerCard(out); err != nil {
+			logger.Warn("failed to reload saved card", "path", out, "error", err)
+		}
+		showToast("card", "saved: "+out)
+		closeEditor()
+	}
+	form.AddButton("pick avatar", func() {
+		fp := makeFilePicker()
+		filePickerOnImage = func(fpath string) {
+			if item, ok := form.GetFormItemByLabel(avatarLabel).(*tview.InputField); ok {
+				item.SetText(fpath)
+			}
+		}
+		pages.AddPage(filePickerPage, fp, true, true)
+	})
+	form.AddButton("save png", func() { save(true) })
+	form.AddButton("save json", func() { save(false) })
+	form.AddButton("cancel", closeEditor)
+	form.SetCancelFunc(closeEditor)
+	form.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			closeEditor()
+			return nil
+		}
+		return event
+	})
+	pages.AddPage(cardEditorPage, form, true, true)
+	app.SetFocus(form)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"gf-lt/models"
+	"gf-lt/storage"
+	"gf-lt/tools"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const defaultChatSearchLimit = 50
+
+// rank constant of reciprocal rank fusion; damps the weight of the top hits
+const rrfK = 60
+
+// embeddings of one chat are updated by one goroutine at a time
+var chatEmbedMu sync.Mutex
+
+// chats saved before ChatSearchEmbeddings was turned on are embedded once per run
+var chatEmbedBackfill sync.Once
+
+// searchChats finds messages in all stored chats: full-text,
+// merged with semantic hits when ChatSearchEmbeddings is on
+func searchChats(query string) ([]models.ChatHit, error) {
+	limit := cfg.ChatSearchLimit
+	if limit <= 0 {
+		limit = defaultChatSearchLimit
+	}
+	hits, err := store.SearchChats(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.ChatSearchEmbeddings {
+		return hits, nil
+	}
+	chatEmbedBackfill.Do(func() { go backfillChatEmbeddings() })
+	emb, err := tools.EmbedText(query)
+	if err != nil {
+		logger.Warn("semantic chat search is not available", "error", err)
+		return hits, nil
+	}
+	semantic, err := store.SearchChatsByVector(emb, limit)
+	if err != nil {
+		return hits, nil
+	}
+	return fuseChatHits(limit, hits, semantic), nil
+}
+
+// fuseChatHits merges ranked hit lists by reciprocal rank fusion;
+// a message found by several lists keeps the snippet of the first one
+func fuseChatHits(limit int, lists ...[]models.ChatHit) []models.ChatHit {
+	type key struct {
+		chat uint32
+		msg  int
+	}
+	byKey := map[key]int{}
+	resp := []models.ChatHit{}
+	for _, list := range lists {
+		for rank, hit := range list {
+			score := 1 / float64(rrfK+rank+1)
+			k := key{hit.ChatID, hit.MsgIdx}
+			if i, ok := byKey[k]; ok {
+				resp[i].Score += score
+				continue
+			}
+			hit.Score = score
+			byKey[k] = len(resp)
+			resp = append(resp, hit)
+		}
+	}
+	sort.SliceStable(resp, func(i, j int) bool {
+		return resp[i].Score > resp[j].Score
+	})
+	if limit > 0 && len(resp) > limit {
+		resp = resp[:limit]
+	}
+	return resp
+}
+
+// embedChatMsgs embeds new and edited messages of a saved chat for semantic search;
+// texts are copied before, the chat keeps changing while it runs
+func embedChatMsgs(chatID uint32, msgs []models.RoleMsg) {
+	if !cfg.ChatSearchEmbeddings || chatID == 0 {
+		return
+	}
+	texts := make([]string, len(msgs))
+	for i := range msgs {
+		texts[i] = msgs[i].GetText()
+	}
+	go embedChatTexts(chatID, texts)
+}
+
+// embedChatTexts embeds the texts whose checksum differs from the stored one
+func embedChatTexts(chatID uint32, texts []string) error {
+	chatEmbedMu.Lock()
+	defer chatEmbedMu.Unlock()
+	sums, err := store.ChatEmbeddingChecksums(chatID)
+	if err != nil {
+		return err
+	}
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		sum := storage.MsgChecksum(text)
+		if old, ok := sums[i]; ok && old == sum {
+			continue
+		}
+		emb, err := tools.EmbedText(text)
+		if err != nil {
+			logger.Warn("failed to embed chat message for search", "chat_id", chatID, "msg", i, "error", err)
+			return err
+		}
+		if err := store.SaveChatEmbedding(chatID, i, sum, emb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillChatEmbeddings embeds the stored chats that were saved without embeddings;
+// messages embedded before are skipped by their checksum
+func backfillChatEmbeddings() {
+	chats, err := store.ListChats()
+	if err != nil {
+		logger.Warn("failed to list chats for embedding", "error", err)
+		return
+	}
+	for i := range chats {
+		msgs, err := chats[i].ToHistory()
+		if err != nil {
+			continue
+		}
+		texts := make([]string, len(msgs))
+		for j := range msgs {
+			texts[j] = msgs[j].GetText()
+		}
+		// the embedder is down; the next run tries again
+		if err := embedChatTexts(chats[i].ID, texts); err != nil {
+			return
+		}
+	}
+}
+
+// hitChatName is the name the chat of the hit has in chatMap
+func hitChatName(hit *models.ChatHit) string {
+	if hit.ChatName != "" {
+		return hit.ChatName
+	}
+	if c, ok := sysMap[hit.Agent]; ok {
+		return fmt.Sprintf("%d_%v", hit.ChatID, c.Role)
+	}
+	return fmt.Sprintf("%d_%v", hit.ChatID, hit.Agent)
+}
+
+// loadChatHit makes the chat of the hit the active chat
+func loadChatHit(hit *models.ChatHit) error {
+	if _, err := loadHistoryChats(); err != nil {
+		return err
+	}
+	name := hitChatName(hit)
+	history, err := loadHistoryChat(name)
+	if err != nil {
+		return err
+	}
+	chatBody.Messages = history
+	return nil
+}
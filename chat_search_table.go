@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"gf-lt/models"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const chatSearchPage = "chatSearchPage"
+
+// region of the message header a search hit jumps to
+const chatHitRegion = "chat_hit"
+
+// matched words of search snippets are in «»
+var snippetMarks = strings.NewReplacer("«", "[yellow::b]", "»", "[-:-:-]")
+
+// snippetToTview escapes the snippet and colors the matched words
+func snippetToTview(s string) string {
+	return snippetMarks.Replace(tview.Escape(s))
+}
+
+// makeChatSearchPage searches messages of all stored chats.
+// Enter in the query field searches, Enter on a hit opens its chat at the message;
+// '/' goes back to the query, 'x' (or Esc in the query field) exits.
+func makeChatSearchPage() tview.Primitive {
+	headers := []string{"Chat", "Msg", "Role", "Match"}
+	table := tview.NewTable().SetBorders(true)
+	table.SetBorder(true).SetTitle("Enter: open chat at the message, /: new search, x: exit")
+	input := tview.NewInputField().SetPlaceholder("words, \"a phrase\" or prefix*")
+	input.SetBorder(true).SetTitle("Search all chats")
+	exit := func() {
+		pages.RemovePage(chatSearchPage)
+		app.SetFocus(textArea)
+	}
+	var hits []models.ChatHit
+	fill := func() {
+		table.Clear()
+		for c, h := range headers {
+			table.SetCell(0, c,
+				tview.NewTableCell(h).
+					SetSelectable(false).
+					SetTextColor(tcell.ColorYellow).
+					SetAlign(tview.AlignCenter).
+					SetAttributes(tcell.AttrBold))
+		}
+		for r := range hits {
+			hit := &hits[r]
+			cells := []string{
+				tview.Escape(hitChatName(hit)),
+				fmt.Sprint(hit.MsgIdx),
+				tview.Escape(hit.Role),
+				snippetToTview(hit.Snippet),
+			}
+			for c, text := range cells {
+				table.SetCell(r+1, c,
+					tview.NewTableCell(text).
+						SetTextColor(tcell.ColorWhite).
+						SetExpansion(c/3). // the match takes the rest of the width
+						SetAlign(tview.AlignLeft))
+			}
+		}
+		table.Select(1, 0)
+	}
+	fill()
+	input.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEsc:
+			exit()
+		case tcell.KeyEnter:
+			query := strings.TrimSpace(input.GetText())
+			if query == "" {
+				return
+			}
+			var err error
+			hits, err = searchChats(query)
+			if err != nil {
+				showToast("search", err.Error())
+				return
+			}
+			fill()
+			if len(hits) == 0 {
+				showToast("search", "nothing found in chats: "+query)
+				return
+			}
+			app.SetFocus(table)
+		}
+	})
+	table.SetSelectable(true, false).SetFixed(1, 0)
+	table.SetSelectedFunc(func(row, column int) {
+		if row < 1 || row > len(hits) {
+			return
+		}
+		if botRespMode.Load() {
+			showToast("search", "cannot switch chats while bot is responding")
+			return
+		}
+		hit := hits[row-1]
+		if err := loadChatHit(&hit); err != nil {
+			logger.Error("failed to open chat of search hit", "chat_id", hit.ChatID, "error", err)
+			showToast("search", err.Error())
+			return
+		}
+		exit()
+		textView.SetText(chatToText(chatBody.Messages, cfg.ShowSys))
+		colorText()
+		updateStatusLine()
+		jumpToMsg(hit.MsgIdx)
+	})
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() != tcell.KeyRune {
+			return event
+		}
+		switch event.Rune() {
+		case 'x':
+			exit()
+			return nil
+		case '/':
+			app.SetFocus(input)
+			return nil
+		}
+		return event
+	})
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(input, 3, 0, true).
+		AddItem(table, 0, 1, false)
+	return layout
+}
+
+// jumpToMsg highlights the header of the message in the chat view and scrolls to it;
+// Enter in the chat view clears the highlight
+func jumpToMsg(idx int) {
+	if idx < 0 || idx >= len(chatBody.Messages) {
+		return
+	}
+	// only up to the colon: expanded tool calls close the bold style right after it
+	header := fmt.Sprintf("(%d) <%s>:", idx, chatBody.Messages[idx].Role)
+	text := textView.GetText(false)
+	pos := strings.Index(text, "[-:-:b]"+header)
+	if pos < 0 {
+		// tool calls or system prompt are hidden
+		showToast("search", fmt.Sprintf("message %d is not shown (Ctrl+T shows tool calls)", idx))
+		textView.ScrollToEnd()
+		return
+	}
+	pos += len("[-:-:b]")
+	text = text[:pos] + fmt.Sprintf("[\"%s\"]%s[\"\"]", chatHitRegion, header) + text[pos+len(header):]
+	textView.SetText(text)
+	textView.Highlight(chatHitRegion).ScrollToHighlight()
+}
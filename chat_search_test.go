@@ -0,0 +1,88 @@
+package main
+
+import (
+	"gf-lt/config"
+	"gf-lt/models"
+	"gf-lt/storage"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+func TestChatSearch(t *testing.T) {
+	prevStore, prevCfg, prevBody, prevActive := store, cfg, chatBody, activeChatName
+	defer func() {
+		store, cfg, chatBody, activeChatName = prevStore, prevCfg, prevBody, prevActive
+		delete(sysMap, "search_bob")
+	}()
+	store = storage.NewProviderSQL(filepath.Join(t.TempDir(), "search.db"), logger)
+	if store == nil {
+		t.Fatal("failed to open the search db")
+	}
+	cfg = &config.Config{UserRole: "user", ToolRole: "tool", ChatSearchLimit: 10}
+	chatBody = &models.ChatBody{}
+	sysMap["search_bob"] = &models.CharCard{ID: "search_bob", Role: "Bob"}
+	msgs := `[{"role":"system","content":"You are Bob."},{"role":"user","content":"Remember the wifi password?"},` +
+		`{"role":"Bob","content":"It is written under the router."}]`
+	for _, c := range []models.Chat{
+		{ID: 1, Name: "", Msgs: msgs, Agent: "search_bob"}, // unnamed chats are listed as <id>_<role>
+		{ID: 2, Name: "other", Msgs: `[{"role":"user","content":"routers are boring"}]`, Agent: "search_bob"},
+	} {
+		c.CreatedAt, c.UpdatedAt = time.Now(), time.Now()
+		if _, err := store.UpsertChat(&c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hits, err := searchChats("router")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("expected hits in both chats, got %+v", hits)
+	}
+	var hit *models.ChatHit
+	for i := range hits {
+		if hits[i].ChatID == 1 {
+			hit = &hits[i]
+		}
+	}
+	if hit == nil || hitChatName(hit) != "1_Bob" || hit.MsgIdx != 2 || hit.Snippet != "It is written under the «router»." {
+		t.Fatalf("unexpected hit: %+v", hits)
+	}
+	if err := loadChatHit(hit); err != nil {
+		t.Fatal(err)
+	}
+	if activeChatName != "1_Bob" || len(chatBody.Messages) != 3 || chatBody.Messages[hit.MsgIdx].Role != "Bob" {
+		t.Errorf("chat of the hit is not loaded: %s %+v", activeChatName, chatBody.Messages)
+	}
+	// a message found by both searches goes first
+	fts := []models.ChatHit{{ChatID: 1, MsgIdx: 1, Snippet: "«a»"}, {ChatID: 1, MsgIdx: 2, Snippet: "«b»"}}
+	semantic := []models.ChatHit{{ChatID: 2, MsgIdx: 0, Snippet: "c"}, {ChatID: 1, MsgIdx: 2, Snippet: "b"}}
+	fused := fuseChatHits(2, fts, semantic)
+	if len(fused) != 2 || fused[0].MsgIdx != 2 || fused[0].Snippet != "«b»" || fused[1].MsgIdx != 1 {
+		t.Errorf("unexpected fused hits: %+v", fused)
+	}
+}
+
+func TestJumpToToolCallMsg(t *testing.T) {
+	prevCfg, prevBody, prevView, prevShown := cfg, chatBody, textView, toolModeShown.Load()
+	defer func() {
+		cfg, chatBody, textView = prevCfg, prevBody, prevView
+		toolModeShown.Store(prevShown)
+	}()
+	cfg = &config.Config{UserRole: "user", AssistantRole: "assistant", ToolRole: "tool"}
+	chatBody = &models.ChatBody{Messages: []models.RoleMsg{
+		{Role: "user", Content: "list files"},
+		{Role: "assistant", ToolCall: &models.ToolCall{ID: "c1", FuncCall: models.ToolCallFunction{Name: "ls"}}},
+	}}
+	toolModeShown.Store(true)
+	textView = tview.NewTextView().SetDynamicColors(true).SetRegions(true)
+	textView.SetText(strings.Join(chatToTextSlice(chatBody.Messages, true), ""))
+	jumpToMsg(1)
+	if want := `["` + chatHitRegion + `"](1) <assistant>:[""]`; !strings.Contains(textView.GetText(false), want) {
+		t.Errorf("tool call header is not highlighted:\n%s", textView.GetText(false))
+	}
+}
@@ -0,0 +1,99 @@
+// Package chatio converts chats to and from the files of other frontends:
+// markdown, standalone html and sillytavern jsonl on export;
+// sillytavern jsonl, openai (chatgpt export or api messages) json
+// and plain "role: text" transcripts on import.
+package chatio
+
+import (
+	"errors"
+	"fmt"
+	"gf-lt/models"
+)
+
+const (
+	FormatJSON     = "json" // gf-lt messages; same shape as openai api messages
+	FormatMarkdown = "md"
+	FormatHTML     = "html"
+	FormatSTJSONL  = "jsonl" // sillytavern chat file
+)
+
+// Formats are the export formats in the order they are offered
+var Formats = []string{FormatJSON, FormatMarkdown, FormatHTML, FormatSTJSONL}
+
+var ErrNoMessages = errors.New("no messages in chat file")
+
+// Roles maps the roles of gf-lt to the speakers of other frontends
+type Roles struct {
+	User string // user role; is_user of sillytavern
+	Char string // character of sillytavern chats
+	Tool string // tool responses are folded on export
+}
+
+// Chat is one imported chat; chatgpt exports hold many
+type Chat struct {
+	Title string
+	Msgs  []models.RoleMsg
+}
+
+// Ext is the file extension of the format
+func Ext(format string) string {
+	return "." + format
+}
+
+// Validate checks that imported messages can be loaded as a chat
+func Validate(msgs []models.RoleMsg) error {
+	if len(msgs) == 0 {
+		return ErrNoMessages
+	}
+	for i := range msgs {
+		if msgs[i].Role == "" {
+			return fmt.Errorf("message %d has no role", i)
+		}
+	}
+	return nil
+}
+
+// image of a message; URL is a data url for attached files
+type image struct {
+	Path string
+	URL  string
+}
+
+// msgParts is the text and the images of a message;
+// content parts are typed in a running chat and maps once read from json
+func msgParts(m *models.RoleMsg) (string, []image) {
+	if !m.HasContentParts {
+		return m.Content, nil
+	}
+	var images []image
+	for _, part := range m.ContentParts {
+		switch p := part.(type) {
+		case models.ImageContentPart:
+			images = append(images, image{Path: p.Path, URL: p.ImageURL.URL})
+		case map[string]any:
+			if p["type"] != "image_url" {
+				continue
+			}
+			img := image{}
+			img.Path, _ = p["path"].(string)
+			if u, ok := p["image_url"].(map[string]any); ok {
+				img.URL, _ = u["url"].(string)
+			}
+			images = append(images, img)
+		}
+	}
+	return m.GetText(), images
+}
+
+// msgToolCalls are the tool calls of a message, the legacy single one included
+func msgToolCalls(m *models.RoleMsg) []models.ToolCall {
+	calls := m.ToolCalls
+	if m.ToolCall != nil && m.ToolCall.ID != "" && len(calls) == 0 {
+		calls = []models.ToolCall{*m.ToolCall}
+	}
+	return calls
+}
+
+func isToolResp(m *models.RoleMsg, roles Roles) bool {
+	return (m.Role == roles.Tool || m.Role == "tool") && !m.IsShellCommand
+}
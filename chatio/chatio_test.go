@@ -0,0 +1,212 @@
+package chatio
+
+import (
+	"errors"
+	"gf-lt/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testRoles = Roles{User: "user", Char: "Bob", Tool: "tool"}
+
+func testChat() []models.RoleMsg {
+	img := models.ImageContentPart{Type: "image_url"}
+	img.ImageURL.URL = "data:image/png;base64,iVBORw0K"
+	return []models.RoleMsg{
+		{Role: "system", Content: "You are Bob."},
+		models.NewMultimodalMsg("user", []any{models.TextContentPart{Type: "text", Text: "What is <this>?"}, img}),
+		{Role: "Bob", Content: "Let me check.", ToolCalls: []models.ToolCall{{ID: "c1", FuncCall: models.ToolCallFunction{Name: "view_img", Args: `{"n":"1"}`}}}},
+		{Role: "tool", Content: "a cat\n```\nmeow\n```", ToolCallID: "c1"},
+		{Role: "Bob", Content: "It is a cat."},
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	out, err := Export(FormatMarkdown, "cats", testChat(), testRoles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := string(out)
+	for _, want := range []string{
+		"# cats\n",
+		"### user\n\nWhat is <this>?\n\n*[image]*\n",
+		"<details><summary>tool call: view_img</summary>\n\n```\n{\"n\":\"1\"}\n```\n\n</details>",
+		// fence is longer than the one in the response
+		"<details><summary>tool response</summary>\n\n````\na cat\n```\nmeow\n```\n````",
+		"### Bob\n\nIt is a cat.\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown has no %q:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "### tool") {
+		t.Errorf("tool response is not folded:\n%s", md)
+	}
+}
+
+func TestExportHTML(t *testing.T) {
+	dir := t.TempDir()
+	imgPath := filepath.Join(dir, "cat.png")
+	if err := os.WriteFile(imgPath, []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	msgs := testChat()
+	// attachment stored with its path only is read from disk
+	msgs = append(msgs, models.NewMultimodalMsg("user", []any{map[string]any{"type": "image_url", "path": imgPath, "image_url": map[string]any{"url": ""}}}))
+	// urls of imported chats are not trusted
+	msgs = append(msgs, models.NewMultimodalMsg("user", []any{
+		map[string]any{"type": "image_url", "image_url": map[string]any{"url": "javascript:alert(1)"}},
+		map[string]any{"type": "image_url", "image_url": map[string]any{"url": "https://example.com/cat.png"}},
+	}))
+	out, err := Export(FormatHTML, "cats & dogs", msgs, testRoles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(out)
+	for _, want := range []string{
+		"<title>cats &amp; dogs</title>",
+		"What is &lt;this&gt;?",
+		`<img src="data:image/png;base64,iVBORw0K">`,
+		`<img src="data:image/png;base64,cG5n">`,
+		"<summary>tool call: view_img</summary>",
+		`<details class="tool"><summary>tool response</summary>`,
+		`<img src="#ZgotmplZ">`,
+		`<img src="https://example.com/cat.png">`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("html has no %q:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "javascript:") {
+		t.Errorf("javascript url is not sanitized:\n%s", page)
+	}
+}
+
+func TestSTJSONLRoundTrip(t *testing.T) {
+	out, err := Export(FormatSTJSONL, "", testChat(), testRoles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 6 || !strings.Contains(lines[0], `"character_name":"Bob"`) {
+		t.Fatalf("unexpected sillytavern file:\n%s", out)
+	}
+	chats, err := Import(out, testRoles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chats) != 1 || chats[0].Title != "Bob" {
+		t.Fatalf("unexpected chats: %+v", chats)
+	}
+	msgs := chats[0].Msgs
+	wantRoles := []string{"system", "user", "Bob", "tool", "Bob"}
+	if len(msgs) != len(wantRoles) {
+		t.Fatalf("got %d messages: %+v", len(msgs), msgs)
+	}
+	for i, role := range wantRoles {
+		if msgs[i].Role != role {
+			t.Errorf("message %d: role %q, want %q", i, msgs[i].Role, role)
+		}
+	}
+	if _, images := msgParts(&msgs[1]); len(images) != 1 || images[0].URL != "data:image/png;base64,iVBORw0K" {
+		t.Errorf("image is lost: %+v", msgs[1])
+	}
+	if got := msgs[2].GetText(); got != "Let me check.\n[tool call: view_img {\"n\":\"1\"}]" {
+		t.Errorf("tool call: got %q", got)
+	}
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		roles []string
+		texts []string
+		title string
+	}{
+		{
+			name:  "gf-lt json",
+			data:  `[{"role":"system","content":"hi"},{"role":"Bob","content":"hello"}]`,
+			roles: []string{"system", "Bob"},
+			texts: []string{"hi", "hello"},
+		},
+		{
+			name:  "openai api messages",
+			data:  `{"messages":[{"role":"user","content":[{"type":"text","text":"2+2?"}]},{"role":"assistant","content":"4"}]}`,
+			roles: []string{"user", "assistant"},
+			texts: []string{"2+2?", "4"},
+		},
+		{
+			name: "chatgpt export follows the shown branch",
+			data: `[{"title":"Math","current_node":"c","mapping":{
+				"root":{"parent":null,"message":null},
+				"s":{"parent":"root","message":{"author":{"role":"system"},"content":{"content_type":"text","parts":[""]}}},
+				"a":{"parent":"s","message":{"author":{"role":"user"},"content":{"content_type":"text","parts":["2+2?"]}}},
+				"b":{"parent":"a","message":{"author":{"role":"assistant"},"content":{"content_type":"text","parts":["5"]}}},
+				"c":{"parent":"a","message":{"author":{"role":"assistant"},"content":{"content_type":"text","parts":["4"]}}}}}]`,
+			roles: []string{"user", "assistant"},
+			texts: []string{"2+2?", "4"},
+			title: "Math",
+		},
+		{
+			name:  "transcript",
+			data:  "\nUser: hi there\nBob: hello\nhow are you?\n\nNote - not a speaker line\nuser:\n",
+			roles: []string{"user", "Bob", "user"},
+			texts: []string{"hi there", "hello\nhow are you?\n\nNote - not a speaker line", ""},
+		},
+		{
+			name:  "transcript with colon lines",
+			data:  "Ann: do it like this\nStep 2: open the lid\nNote: it is hot\nBob: ok\nAnn: thanks\nassistant: bye\n",
+			roles: []string{"Ann", "Bob", "Ann", "assistant"},
+			texts: []string{"do it like this\nStep 2: open the lid\nNote: it is hot", "ok", "thanks", "bye"},
+		},
+		{
+			name:  "sillytavern",
+			data:  "{\"user_name\":\"Ann\",\"character_name\":\"Bob\"}\n{\"name\":\"Ann\",\"is_user\":true,\"mes\":\"hi\"}\n{\"name\":\"Bob\",\"is_user\":false,\"mes\":\"hey\",\"extra\":{}}\n",
+			roles: []string{"user", "Bob"},
+			texts: []string{"hi", "hey"},
+			title: "Bob",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chats, err := Import([]byte(tt.data), testRoles)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chats) != 1 || chats[0].Title != tt.title {
+				t.Fatalf("unexpected chats: %+v", chats)
+			}
+			msgs := chats[0].Msgs
+			if len(msgs) != len(tt.roles) {
+				t.Fatalf("got %d messages: %+v", len(msgs), msgs)
+			}
+			for i := range msgs {
+				if msgs[i].Role != tt.roles[i] || msgs[i].GetText() != tt.texts[i] {
+					t.Errorf("message %d: got %s %q, want %s %q", i, msgs[i].Role, msgs[i].GetText(), tt.roles[i], tt.texts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty":            "  \n",
+		"empty array":      "[]",
+		"no role":          `[{"content":"who said it?"}]`,
+		"broken json":      `[{"role":"user"`,
+		"unknown object":   `{"foo":1}`,
+		"text before role": "just some text\nuser: hi",
+		"broken st line":   "{\"user_name\":\"Ann\"}\n{\"name\":",
+	} {
+		if chats, err := Import([]byte(data), testRoles); err == nil {
+			t.Errorf("%s: expected error, got %+v", name, chats)
+		}
+	}
+	if _, err := Import([]byte("[]"), testRoles); !errors.Is(err, ErrNoMessages) {
+		t.Errorf("expected ErrNoMessages, got %v", err)
+	}
+}
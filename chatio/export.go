@@ -0,0 +1,228 @@
+package chatio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gf-lt/models"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// Export writes the chat in the format; title heads markdown and html exports
+func Export(format, title string, msgs []models.RoleMsg, roles Roles) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(msgs, "", "  ")
+	case FormatMarkdown:
+		return exportMarkdown(title, msgs, roles), nil
+	case FormatHTML:
+		return exportHTML(title, msgs, roles)
+	case FormatSTJSONL:
+		return exportSTJSONL(msgs, roles, time.Now())
+	}
+	return nil, fmt.Errorf("unknown export format %q, options: %s", format, strings.Join(Formats, ", "))
+}
+
+// fence is a markdown code fence longer than any backtick run of the text
+func fence(text string) string {
+	f := "```"
+	for strings.Contains(text, f) {
+		f += "`"
+	}
+	return f
+}
+
+// folded is a collapsed markdown block; github and most viewers render <details>
+func folded(summary, body string) string {
+	f := fence(body)
+	return fmt.Sprintf("<details><summary>%s</summary>\n\n%s\n%s\n%s\n\n</details>\n\n",
+		template.HTMLEscapeString(summary), f, strings.TrimRight(body, "\n"), f)
+}
+
+func exportMarkdown(title string, msgs []models.RoleMsg, roles Roles) []byte {
+	var sb strings.Builder
+	if title != "" {
+		fmt.Fprintf(&sb, "# %s\n\n", title)
+	}
+	for i := range msgs {
+		m := &msgs[i]
+		text, images := msgParts(m)
+		if isToolResp(m, roles) {
+			sb.WriteString(folded("tool response", text))
+			continue
+		}
+		fmt.Fprintf(&sb, "### %s\n\n", m.Role)
+		if strings.TrimSpace(text) != "" {
+			sb.WriteString(strings.TrimRight(text, "\n"))
+			sb.WriteString("\n\n")
+		}
+		for _, img := range images {
+			// data urls would bloat the file; html export embeds them
+			if img.Path != "" {
+				fmt.Fprintf(&sb, "![image](%s)\n\n", img.Path)
+			} else {
+				sb.WriteString("*[image]*\n\n")
+			}
+		}
+		for _, tc := range msgToolCalls(m) {
+			sb.WriteString(folded("tool call: "+tc.FuncCall.Name, tc.FuncCall.Args))
+		}
+	}
+	return []byte(sb.String())
+}
+
+var htmlTmpl = template.Must(template.New("chat").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; background: #fafafa; color: #222; }
+.msg { margin: 1em 0; padding: 0.6em 1em; border-radius: 6px; background: #fff; border: 1px solid #ddd; }
+.msg.user { background: #eef4ff; }
+.msg.system { background: #f3f3f3; color: #555; }
+.role { font-weight: bold; margin-bottom: 0.3em; }
+.text { white-space: pre-wrap; }
+img { max-width: 100%; margin-top: 0.5em; }
+details { margin-top: 0.5em; }
+pre { white-space: pre-wrap; background: #f5f5f5; padding: 0.5em; }
+</style>
+</head>
+<body>
+{{if .Title}}<h1>{{.Title}}</h1>{{end}}
+{{range .Msgs}}{{if .ToolResp}}<details class="tool"><summary>tool response</summary><pre>{{.Text}}</pre></details>
+{{else}}<div class="msg {{.Class}}">
+<div class="role">{{.Role}}</div>
+{{if .Text}}<div class="text">{{.Text}}</div>{{end}}
+{{range .Images}}<img src="{{.}}">{{end}}
+{{range .ToolCalls}}<details><summary>tool call: {{.FuncCall.Name}}</summary><pre>{{.FuncCall.Args}}</pre></details>{{end}}
+</div>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+type htmlMsg struct {
+	Role      string
+	Class     string
+	Text      string
+	Images    []any // template.URL of data images, string of other urls
+	ToolCalls []models.ToolCall
+	ToolResp  bool
+}
+
+// exportHTML makes a standalone page; attached images are embedded as data urls
+func exportHTML(title string, msgs []models.RoleMsg, roles Roles) ([]byte, error) {
+	data := struct {
+		Title string
+		Msgs  []htmlMsg
+	}{Title: title}
+	for i := range msgs {
+		m := &msgs[i]
+		text, images := msgParts(m)
+		hm := htmlMsg{
+			Role:      m.Role,
+			Class:     "char",
+			Text:      strings.TrimSpace(text),
+			ToolCalls: msgToolCalls(m),
+			ToolResp:  isToolResp(m, roles),
+		}
+		switch m.Role {
+		case roles.User:
+			hm.Class = "user"
+		case "system":
+			hm.Class = "system"
+		}
+		for _, img := range images {
+			src := imageSrc(img)
+			switch {
+			case strings.HasPrefix(src, "data:image/"):
+				// data urls are images the user attached, not input to sanitize
+				hm.Images = append(hm.Images, template.URL(src))
+			case src != "":
+				// the template sanitizes remote urls
+				hm.Images = append(hm.Images, src)
+			}
+		}
+		data.Msgs = append(data.Msgs, hm)
+	}
+	var buf bytes.Buffer
+	if err := htmlTmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// imageSrc is the data url of the image; attached files are read again
+// if the chat has only their path, remote images keep their url
+func imageSrc(img image) string {
+	if !strings.HasPrefix(img.URL, "data:") && img.Path != "" {
+		if embedded, err := models.CreateImageURLFromPath(img.Path); err == nil {
+			return embedded
+		}
+	}
+	return img.URL
+}
+
+// sillytavern chat file: a header line, then a line per message
+type stHeader struct {
+	UserName      string         `json:"user_name"`
+	CharacterName string         `json:"character_name"`
+	CreateDate    string         `json:"create_date"`
+	ChatMetadata  map[string]any `json:"chat_metadata"`
+}
+
+type stMsg struct {
+	Name     string  `json:"name"`
+	IsUser   bool    `json:"is_user"`
+	IsSystem bool    `json:"is_system"`
+	SendDate string  `json:"send_date"`
+	Mes      string  `json:"mes"`
+	Extra    stExtra `json:"extra"`
+}
+
+type stExtra struct {
+	Image string `json:"image,omitempty"`
+}
+
+const stDateLayout = "January 2, 2006 3:04pm"
+
+// exportSTJSONL writes a sillytavern chat; it has no tool messages,
+// tool responses become hidden system messages and tool calls are appended as text
+func exportSTJSONL(msgs []models.RoleMsg, roles Roles, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	header := stHeader{
+		UserName:      roles.User,
+		CharacterName: roles.Char,
+		CreateDate:    now.Format("2006-01-02@15h04m05s"),
+		ChatMetadata:  map[string]any{},
+	}
+	if err := enc.Encode(header); err != nil {
+		return nil, err
+	}
+	for i := range msgs {
+		m := &msgs[i]
+		text, images := msgParts(m)
+		for _, tc := range msgToolCalls(m) {
+			text += fmt.Sprintf("\n[tool call: %s %s]", tc.FuncCall.Name, tc.FuncCall.Args)
+		}
+		sm := stMsg{
+			Name:     m.Role,
+			IsUser:   m.Role == roles.User,
+			IsSystem: m.Role == "system" || isToolResp(m, roles),
+			SendDate: now.Format(stDateLayout),
+			Mes:      strings.TrimSpace(text),
+		}
+		if len(images) > 0 {
+			sm.Extra.Image = imageSrc(images[0])
+		}
+		if err := enc.Encode(sm); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
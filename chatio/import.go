@@ -0,0 +1,280 @@
+package chatio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gf-lt/models"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Import reads the chats of a file; the format is told by the content:
+// a json array is gf-lt (or openai api) messages or a chatgpt export,
+// a json object is one chatgpt conversation or {"messages": [...]},
+// json lines are a sillytavern chat and anything else is a "role: text" transcript.
+// Every chat is validated; user and tool roles of other frontends become roles.User and roles.Tool
+func Import(data []byte, roles Roles) ([]Chat, error) {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if len(data) == 0 {
+		return nil, ErrNoMessages
+	}
+	var (
+		chats []Chat
+		err   error
+	)
+	switch {
+	case data[0] == '[':
+		chats, err = importJSONArray(data, roles)
+	case data[0] == '{' && json.Valid(data):
+		chats, err = importJSONObject(data, roles)
+	case data[0] == '{':
+		chats, err = importSTJSONL(data, roles)
+	default:
+		chats, err = importTranscript(data, roles)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(chats) == 0 {
+		return nil, ErrNoMessages
+	}
+	for i := range chats {
+		if err := Validate(chats[i].Msgs); err != nil {
+			return nil, fmt.Errorf("chat %q: %w", chats[i].Title, err)
+		}
+	}
+	return chats, nil
+}
+
+// mapRole turns the role names of openai and transcripts into gf-lt roles
+func mapRole(role string, roles Roles) string {
+	switch strings.ToLower(role) {
+	case "user", "human":
+		if roles.User != "" {
+			return roles.User
+		}
+	case "tool", "function":
+		if roles.Tool != "" {
+			return roles.Tool
+		}
+	case "system":
+		return "system"
+	}
+	return role
+}
+
+func importJSONArray(data []byte, roles Roles) ([]Chat, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	if len(items) == 0 {
+		return nil, ErrNoMessages
+	}
+	var probe struct {
+		Mapping map[string]json.RawMessage `json:"mapping"`
+	}
+	if err := json.Unmarshal(items[0], &probe); err == nil && probe.Mapping != nil {
+		chats := make([]Chat, 0, len(items))
+		for i, item := range items {
+			chat, err := importChatGPT(item, roles)
+			if err != nil {
+				return nil, fmt.Errorf("conversation %d: %w", i, err)
+			}
+			chats = append(chats, chat)
+		}
+		return chats, nil
+	}
+	msgs, err := importMessages(data, roles)
+	if err != nil {
+		return nil, err
+	}
+	return []Chat{{Msgs: msgs}}, nil
+}
+
+func importJSONObject(data []byte, roles Roles) ([]Chat, error) {
+	var obj struct {
+		Title    string                     `json:"title"`
+		Mapping  map[string]json.RawMessage `json:"mapping"`
+		Messages json.RawMessage            `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	switch {
+	case obj.Mapping != nil:
+		chat, err := importChatGPT(data, roles)
+		if err != nil {
+			return nil, err
+		}
+		return []Chat{chat}, nil
+	case obj.Messages != nil:
+		msgs, err := importMessages(obj.Messages, roles)
+		if err != nil {
+			return nil, err
+		}
+		return []Chat{{Title: obj.Title, Msgs: msgs}}, nil
+	}
+	return nil, fmt.Errorf("json object has neither messages nor a chatgpt mapping")
+}
+
+// importMessages reads gf-lt messages; openai api messages are the same shape
+func importMessages(data []byte, roles Roles) ([]models.RoleMsg, error) {
+	msgs := []models.RoleMsg{}
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return nil, fmt.Errorf("invalid messages: %w", err)
+	}
+	for i := range msgs {
+		msgs[i].Role = mapRole(msgs[i].Role, roles)
+	}
+	return msgs, nil
+}
+
+// chatgpt conversation export: messages are nodes of a tree,
+// the shown branch is the path from current_node up to the root
+type chatGPTConv struct {
+	Title       string                 `json:"title"`
+	CurrentNode string                 `json:"current_node"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	Parent  string `json:"parent"`
+	Message *struct {
+		Author struct {
+			Role string `json:"role"`
+		} `json:"author"`
+		Content struct {
+			ContentType string `json:"content_type"`
+			Parts       []any  `json:"parts"`
+		} `json:"content"`
+	} `json:"message"`
+}
+
+func importChatGPT(data []byte, roles Roles) (Chat, error) {
+	var conv chatGPTConv
+	if err := json.Unmarshal(data, &conv); err != nil {
+		return Chat{}, fmt.Errorf("invalid chatgpt conversation: %w", err)
+	}
+	chat := Chat{Title: conv.Title}
+	seen := map[string]bool{}
+	for id := conv.CurrentNode; id != "" && !seen[id]; id = conv.Mapping[id].Parent {
+		seen[id] = true
+		node, ok := conv.Mapping[id]
+		if !ok {
+			return Chat{}, fmt.Errorf("chatgpt conversation has no node %q", id)
+		}
+		if node.Message == nil {
+			continue
+		}
+		var texts []string
+		for _, part := range node.Message.Content.Parts {
+			// images and attachments are objects; only their text is kept
+			if s, ok := part.(string); ok && strings.TrimSpace(s) != "" {
+				texts = append(texts, s)
+			}
+		}
+		if len(texts) == 0 {
+			continue
+		}
+		chat.Msgs = append(chat.Msgs, models.RoleMsg{
+			Role:    mapRole(node.Message.Author.Role, roles),
+			Content: strings.Join(texts, "\n"),
+		})
+	}
+	slices.Reverse(chat.Msgs)
+	return chat, nil
+}
+
+// importSTJSONL reads a sillytavern chat; the header line names the user and the character
+func importSTJSONL(data []byte, roles Roles) ([]Chat, error) {
+	chat := Chat{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	// messages with embedded images are long lines
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg struct {
+			stMsg
+			CharacterName string `json:"character_name"`
+		}
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return nil, fmt.Errorf("line %d: not a sillytavern chat line: %w", n, err)
+		}
+		if msg.CharacterName != "" {
+			chat.Title = msg.CharacterName
+			continue
+		}
+		if msg.Name == "" && msg.Mes == "" {
+			// header without a character or metadata line
+			continue
+		}
+		role := msg.Name
+		switch {
+		case msg.IsUser && roles.User != "":
+			role = roles.User
+		case msg.IsSystem && msg.Name == "":
+			role = "system"
+		}
+		m := models.RoleMsg{Role: role, Content: msg.Mes}
+		if msg.Extra.Image != "" {
+			text := models.TextContentPart{Type: "text", Text: msg.Mes}
+			img := models.ImageContentPart{Type: "image_url"}
+			img.ImageURL.URL = msg.Extra.Image
+			m = models.NewMultimodalMsg(role, []any{text, img})
+		}
+		chat.Msgs = append(chat.Msgs, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return []Chat{chat}, nil
+}
+
+// a transcript line starting a message: a short speaker name and a colon
+var transcriptRoleRE = regexp.MustCompile(`^([\p{L}\p{N}_][\p{L}\p{N}_ .'-]{0,39}):(?:\s+(.*))?$`)
+
+// importTranscript reads "role: text" lines; lines without a speaker continue the message.
+// After the first line only known speakers start a message: the ones seen before,
+// the standard roles and the roles of the chat, so "Step 2: ..." stays in the body
+func importTranscript(data []byte, roles Roles) ([]Chat, error) {
+	chat := Chat{}
+	var text []string
+	known := map[string]bool{}
+	for _, r := range []string{"user", "human", "assistant", "system", "tool", roles.User, roles.Char, roles.Tool} {
+		if r != "" {
+			known[strings.ToLower(r)] = true
+		}
+	}
+	flush := func() {
+		if len(chat.Msgs) > 0 {
+			chat.Msgs[len(chat.Msgs)-1].Content = strings.TrimSpace(strings.Join(text, "\n"))
+		}
+		text = nil
+	}
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := transcriptRoleRE.FindStringSubmatch(line); m != nil && (len(chat.Msgs) == 0 || known[strings.ToLower(m[1])]) {
+			known[strings.ToLower(m[1])] = true
+			flush()
+			chat.Msgs = append(chat.Msgs, models.RoleMsg{Role: mapRole(m[1], roles)})
+			text = []string{m[2]}
+			continue
+		}
+		if len(chat.Msgs) == 0 {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: expected \"role: text\", got %q", n+1, line)
+		}
+		text = append(text, line)
+	}
+	flush()
+	return []Chat{chat}, nil
+}
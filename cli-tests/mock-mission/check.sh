@@ -0,0 +1,66 @@
+#!/bin/bash
+set -e
+
+SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
+LOG_FILE=$(ls -t "$SCRIPT_DIR"/*_run.log 2>/dev/null | head -1)
+
+PASS=0
+FAIL=0
+
+log_pass() {
+    echo "[PASS] $1"
+    PASS=$((PASS + 1))
+}
+
+log_fail() {
+    echo "[FAIL] $1"
+    FAIL=$((FAIL + 1))
+}
+
+echo "=== Checking results ==="
+echo ""
+
+if grep -q "hi" /tmp/mock-mission/repo/hello.txt 2>/dev/null; then
+    log_pass "hello.txt contains 'hi'"
+else
+    log_fail "hello.txt missing or without 'hi'"
+fi
+
+if [ -f /tmp/mock-mission/issues/review/1.json ]; then
+    log_pass "issue moved to review"
+else
+    log_fail "issue not in review"
+fi
+
+if [ -f /tmp/mock-mission/issues/review/issue-1-pr.md ]; then
+    log_pass "PR file written"
+else
+    log_fail "PR file missing"
+fi
+
+if grep -q "=== Mission success ===" "$LOG_FILE" 2>/dev/null; then
+    log_pass "mission succeeded"
+else
+    log_fail "mission did not succeed"
+fi
+
+if grep -q '"tool":"create_pr"' /tmp/mock-mission/trace.jsonl 2>/dev/null; then
+    log_pass "trace has the create_pr call"
+else
+    log_fail "trace misses the create_pr call"
+fi
+
+echo ""
+echo "=== Summary ==="
+echo "PASSED: $PASS"
+echo "FAILED: $FAIL"
+
+if [ $FAIL -gt 0 ]; then
+    echo ""
+    echo "Log file: $LOG_FILE"
+    exit 1
+fi
+
+echo ""
+echo "All tests passed!"
+exit 0
@@ -0,0 +1,29 @@
+#!/bin/bash
+set -e
+
+SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
+TIMESTAMP=$(date +%Y%m%d_%H%M%S)
+LOG_FILE="$SCRIPT_DIR/${TIMESTAMP}_run.log"
+
+exec > "$LOG_FILE" 2>&1
+
+echo "=== Running teardown ==="
+"$SCRIPT_DIR/teardown.sh"
+
+echo ""
+echo "=== Running setup ==="
+"$SCRIPT_DIR/setup.sh"
+
+echo ""
+echo "=== Running mission on the mock backend ==="
+cd "$SCRIPT_DIR/../../"
+go run . -mission -issue-id 1 \
+	-api "mock://$SCRIPT_DIR/script.toml" \
+	-issues-dir /tmp/mock-mission/issues \
+	-checkpoint-file /tmp/mock-mission/checkpoint.json \
+	-trace /tmp/mock-mission/trace.jsonl
+
+echo ""
+echo "=== Done ==="
+cp "$LOG_FILE" "$SCRIPT_DIR/latest_run.log"
+echo "Log file: $LOG_FILE"
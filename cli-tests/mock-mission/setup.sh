@@ -0,0 +1,13 @@
+#!/bin/sh
+
+set -e
+
+SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
+
+mkdir -p /tmp/mock-mission/repo /tmp/mock-mission/issues/open
+cd /tmp/mock-mission/repo
+git -c init.defaultBranch=main init -q
+printf "a" > a.txt
+git add .
+git -c user.name=test -c user.email=test@localhost commit -qm initial
+cp "$SCRIPT_DIR/issue.json" /tmp/mock-mission/issues/open/1.json
@@ -0,0 +1,4 @@
+#!/bin/bash
+set -e
+
+rm -rf /tmp/mock-mission
@@ -50,6 +50,24 @@ func resolvePath(p, configDir string) string {
 
 type MCPServerConfig struct {
 	URL string `toml:"url"`
+	// stdio server is started as a child process when command is set
+	Command       string            `toml:"command"`
+	Args          []string          `toml:"args"`
+	Env           map[string]string `toml:"env"`
+	Disabled      bool              `toml:"disabled"`
+	DisabledTools []string          `toml:"disabled_tools"`
+}
+
+// FallbackProvider answers when the current api keeps failing
+type FallbackProvider struct {
+	API   string `toml:"api"`
+	Model string `toml:"model"`
+}
+
+// ModelPricing is the price of a model in USD per million tokens
+type ModelPricing struct {
+	Prompt     float64 `toml:"prompt"`
+	Completion float64 `toml:"completion"`
 }
 
 type ModelManagementConfig struct {
@@ -84,6 +102,11 @@ type Config struct {
 	ImagePreview                  bool                       `toml:"ImagePreview"`
 	EnableMouse                   bool                       `toml:"EnableMouse"`
 	MCPServers                    map[string]MCPServerConfig `toml:"MCPServers"`
+	// -mcp-serve mode: http address (empty is stdio), bearer token of http clients
+	// and tools given to mcp clients
+	MCPServeAddr  string   `toml:"MCPServeAddr"`
+	MCPServeToken string   `toml:"MCPServeToken"`
+	MCPServeTools []string `toml:"MCPServeTools"`
 	// embeddings
 	EmbedURL           string `toml:"EmbedURL"`
 	HFToken            string `toml:"HFToken"`
@@ -124,17 +147,57 @@ type Config struct {
 	STT_LANG          string `toml:"STT_LANG"`
 	ASR_MODEL         string `toml:"ASR_MODEL"`
 	STT_SILENCE_MS    int    `toml:"STT_SILENCE_MS"`
+	STT_VAD           string `toml:"STT_VAD"` // spectral (default), energy
+	// 0 (least) to 3 (most aggressive in rejecting non-speech)
+	STT_VAD_AGGRESSIVENESS int `toml:"STT_VAD_AGGRESSIVENESS"`
+	// nil keeps the recorder default, 0 disables pre-roll
+	STT_PREROLL_MS *int `toml:"STT_PREROLL_MS"`
+	VOICE_MODE     bool `toml:"VOICE_MODE"` // hands-free stt -> llm -> tts loop
 	// character spefic contetx
 	CharSpecificContextEnabled bool   `toml:"CharSpecificContextEnabled"`
 	CharSpecificContextTag     string `toml:"CharSpecificContextTag"`
 	AutoTurn                   bool   `toml:"AutoTurn"`
 	DisableToolGuide           bool   `toml:"DisableToolGuide"`
+	// group chat director: who speaks next when the last msg has no KnownTo tag
+	GroupStrategy     string   `toml:"GroupStrategy"` // none, round_robin, talkativeness, mention, llm
+	GroupMaxAutoTurns int      `toml:"GroupMaxAutoTurns"`
+	GroupMuted        []string `toml:"GroupMuted"`
+	// lorebook (world info)
+	LorebookEnabled     bool   `toml:"LorebookEnabled"`
+	LorebookDir         string `toml:"LorebookDir"`
+	LorebookScanDepth   int    `toml:"LorebookScanDepth"`
+	LorebookTokenBudget int    `toml:"LorebookTokenBudget"`
 	// playwright browser
 	PlaywrightEnabled bool `toml:"PlaywrightEnabled"`
 	MemoryEnabled     bool `toml:"MemoryEnabled"`
 	PlaywrightDebug   bool `toml:"PlaywrightDebug"` // !headless
+	// semantic memory recall (needs MemoryEnabled and embeddings)
+	MemoryRecallTopK     int     `toml:"MemoryRecallTopK"` // 0 disables recall
+	MemoryRecallMinScore float64 `toml:"MemoryRecallMinScore"`
+	MemoryDecayDays      float64 `toml:"MemoryDecayDays"` // half-life; 0 is no decay
+	MemoryExtractFacts   bool    `toml:"MemoryExtractFacts"`
+	// delegate tool (sub agents)
+	SubAgentMaxSteps int      `toml:"SubAgentMaxSteps"` // llm requests a sub agent can make
+	SubAgentTools    []string `toml:"SubAgentTools"`    // tools given when delegate call names none
+	// batch tool calls
+	ToolConcurrency int      `toml:"ToolConcurrency"` // max tool calls running at once; 1 runs them one by one
+	ParallelTools   []string `toml:"ParallelTools"`   // tools that may run concurrently
+	// tool call timeouts in seconds
+	ToolTimeout  int            `toml:"ToolTimeout"`  // 0 uses the default
+	ToolTimeouts map[string]int `toml:"ToolTimeouts"` // per tool name, overrides ToolTimeout
+	// retries and fallback of llm requests
+	RetryMax         int                `toml:"RetryMax"`         // retries of 429, 5xx and connection errors, and resumes of broken streams; 0 is none
+	RetryBaseDelayMs int                `toml:"RetryBaseDelayMs"` // first backoff, doubled every retry (default 1000)
+	RetryMaxDelayMs  int                `toml:"RetryMaxDelayMs"`  // cap of backoff and Retry-After (default 30000)
+	Fallbacks        []FallbackProvider `toml:"Fallbacks"`        // tried in order once the retries are used up
+	// search across all chats (Alt+f, /search in cli)
+	ChatSearchLimit      int  `toml:"ChatSearchLimit"`      // hits shown; 0 uses the default (50)
+	ChatSearchEmbeddings bool `toml:"ChatSearchEmbeddings"` // embed messages for semantic search (needs embeddings)
+	// cost of the replies when the api does not send it; openrouter models without an entry use its model list prices
+	Pricing map[string]ModelPricing `toml:"Pricing"` // by model name
 	// CLI mode
 	CLIMode       bool
+	MCPServeMode  bool
 	UseNotifySend bool
 	// Mission mode (auto issue solver)
 	MissionMode           bool
@@ -153,6 +216,23 @@ type Config struct {
 	IssuesDir             string                 `toml:"IssuesDir"`
 	ModelManagement       *ModelManagementConfig `toml:"ModelManagement"`
 	DisableRoll           bool                   `toml:"DisableRoll"`
+	// mission queue: runs all open issues
+	MissionQueue     bool
+	MissionParallel  int      `toml:"MissionParallel"`  // missions running at once
+	MissionLabels    []string `toml:"MissionLabels"`    // only issues with one of them, in this order
+	MissionWorktrees bool     `toml:"MissionWorktrees"` // git worktree per mission even when they run one by one
+	MissionQueueDir  string   `toml:"MissionQueueDir"`  // checkpoints, logs, worktrees and summaries
+	// PM supervisor of missions
+	MissionPMAPI          string   `toml:"MissionPMAPI"`          // empty uses the agent API
+	MissionPMModel        string   `toml:"MissionPMModel"`        // empty uses the agent model
+	MissionPMToken        string   `toml:"MissionPMToken"`        // empty uses the token of the PM API provider
+	MissionPMTriggers     []string `toml:"MissionPMTriggers"`     // check-in triggers; empty is all of them
+	MissionPMLoopRepeats  int      `toml:"MissionPMLoopRepeats"`  // same tool call in a row (default 3)
+	MissionPMNoDiffCalls  int      `toml:"MissionPMNoDiffCalls"`  // tool calls without work tree changes (default 30)
+	MissionPMTestFailures int      `toml:"MissionPMTestFailures"` // failed test runs in a row (default 2)
+	// mission budget: the mission fails once its replies used more; 0 is no limit
+	MissionMaxTokens int     `toml:"MissionMaxTokens"` // prompt and completion tokens
+	MissionMaxCost   float64 `toml:"MissionMaxCost"`   // USD
 }
 
 func LoadConfig(fn string) (*Config, error) {
@@ -176,6 +256,7 @@ func LoadConfig(fn string) (*Config, error) {
 	config.DBPATH = resolvePath(config.DBPATH, config.ConfigDir)
 	config.LogFile = resolvePath(config.LogFile, config.ConfigDir)
 	config.RAGDir = resolvePath(config.RAGDir, config.ConfigDir)
+	config.LorebookDir = resolvePath(config.LorebookDir, config.ConfigDir)
 	config.EmbedModelPath = resolvePath(config.EmbedModelPath, config.ConfigDir)
 	config.EmbedTokenizerPath = resolvePath(config.EmbedTokenizerPath, config.ConfigDir)
 	config.ExportDir = resolvePath(config.ExportDir, config.ConfigDir)
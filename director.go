@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gf-lt/agent"
+	"gf-lt/models"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// group chat director decides which char speaks after a bot msg without KnownTo tag
+const (
+	groupStrategyNone          = "none"
+	groupStrategyRoundRobin    = "round_robin"
+	groupStrategyTalkativeness = "talkativeness"
+	groupStrategyMention       = "mention"
+	groupStrategyLLM           = "llm"
+	// used when GroupMaxAutoTurns is not set
+	defaultGroupMaxAutoTurns = 3
+	// number of last msgs shown to llm when it picks the next speaker
+	directorHistory = 10
+	// how often the llm strategy checks for user interrupt while it waits for the answer
+	directorInterruptPoll = 100 * time.Millisecond
+)
+
+var errDirectorInterrupted = errors.New("director interrupted by user")
+
+var groupStrategies = []string{
+	groupStrategyNone, groupStrategyRoundRobin, groupStrategyTalkativeness,
+	groupStrategyMention, groupStrategyLLM,
+}
+
+// char turns started since the user wrote last; reset in chatRound
+var groupAutoTurns atomic.Int32
+
+// groupOrder is the speaking order: card characters first, then other chat roles by name;
+// roles controlled by the user are not in it
+func groupOrder() []string {
+	cardChars := []string{}
+	if chat, ok := chatMap[activeChatName]; ok {
+		if cc, ok := sysMap[chat.Agent]; ok {
+			cardChars = cc.Characters
+		}
+	}
+	others := []string{}
+	for _, role := range listChatRoles() {
+		if !slices.Contains(cardChars, role) {
+			others = append(others, role)
+		}
+	}
+	slices.Sort(others)
+	order := slices.Concat(cardChars, others)
+	order = slices.DeleteFunc(order, func(role string) bool {
+		return role == "" || role == "system" || role == cfg.UserRole ||
+			role == cfg.WriteNextMsgAs || role == cfg.ToolRole
+	})
+	return slices.Compact(order)
+}
+
+// groupCandidates are chars that may speak after the last speaker
+func groupCandidates(order, muted []string, last string) []string {
+	resp := []string{}
+	for _, name := range order {
+		if name == last || slices.Contains(muted, name) || slices.Contains(resp, name) {
+			continue
+		}
+		resp = append(resp, name)
+	}
+	return resp
+}
+
+// nextRoundRobin returns the first candidate after the last speaker in order
+func nextRoundRobin(order, candidates []string, last string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	start := slices.Index(order, last) + 1
+	for i := range order {
+		name := order[(start+i)%len(order)]
+		if slices.Contains(candidates, name) {
+			return name
+		}
+	}
+	return candidates[0]
+}
+
+// nextByTalkativeness picks random candidate weighted by talkativeness;
+// chars with zero talkativeness only speak when asked
+func nextByTalkativeness(candidates []string, talkativeness func(string) float64, rnd func() float64) string {
+	total := 0.0
+	weights := make([]float64, len(candidates))
+	for i, name := range candidates {
+		weights[i] = talkativeness(name)
+		total += weights[i]
+	}
+	if total <= 0 {
+		return ""
+	}
+	r := rnd() * total
+	for i, w := range weights {
+		if r < w {
+			return candidates[i]
+		}
+		r -= w
+	}
+	return candidates[len(candidates)-1]
+}
+
+// nextMentioned returns the candidate mentioned first in the text
+func nextMentioned(candidates []string, text string) string {
+	resp := ""
+	first := -1
+	for _, name := range candidates {
+		re, err := regexp.Compile(`(?i)(^|[^\p{L}\p{N}_])` + regexp.QuoteMeta(name) + `($|[^\p{L}\p{N}_])`)
+		if err != nil {
+			continue
+		}
+		loc := re.FindStringIndex(text)
+		if loc != nil && (first < 0 || loc[0] < first) {
+			resp, first = name, loc[0]
+		}
+	}
+	return resp
+}
+
+// charTalkativeness reads talkativeness from the char own card
+func charTalkativeness(name string) float64 {
+	if cc := GetCardByRole(name); cc != nil && cc.Spec != nil {
+		return cc.Spec.TalkativenessValue()
+	}
+	return models.DefaultTalkativeness
+}
+
+// askNextSpeaker lets llm choose among candidates; empty name means it is the user turn
+func askNextSpeaker(candidates []string) (string, error) {
+	ag := agent.NewAgentClient(cfg, logger, func() string { return chunkParser.GetToken() })
+	history := strings.Builder{}
+	msgs := chatBody.Messages[max(len(chatBody.Messages)-directorHistory, 0):]
+	for i := range msgs {
+		if msgs[i].Role == "system" || msgs[i].Role == cfg.ToolRole {
+			continue
+		}
+		fmt.Fprintf(&history, "%s: %s\n", msgs[i].Role, msgs[i].GetText())
+	}
+	sysPrompt := "You direct a group roleplay chat. Decide who should speak next. " +
+		"Answer with the name only, no other text."
+	userPrompt := fmt.Sprintf("Chat:\n%s\nWho speaks next? One of: %s. Answer %s if %s should reply.",
+		history.String(), strings.Join(candidates, ", "), cfg.UserRole, cfg.UserRole)
+	body, err := ag.FormFirstMsg(sysPrompt, userPrompt)
+	if err != nil {
+		return "", err
+	}
+	// the request blocks the end of the round, so the user can interrupt it like a reply;
+	// the request is cancelled then
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	type result struct {
+		resp []byte
+		err  error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		resp, err := ag.LLMRequestCtx(ctx, body)
+		resCh <- result{resp: resp, err: err}
+	}()
+	ticker := time.NewTicker(directorInterruptPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case r := <-resCh:
+			if r.err != nil {
+				return "", r.err
+			}
+			return parseSpeakerAnswer(string(r.resp), candidates)
+		case <-ticker.C:
+			if interruptResp.Load() {
+				return "", errDirectorInterrupted
+			}
+		}
+	}
+}
+
+// parseSpeakerAnswer finds candidate name in llm answer
+func parseSpeakerAnswer(answer string, candidates []string) (string, error) {
+	answer = strings.TrimSpace(models.ThinkRE.ReplaceAllString(answer, ""))
+	answer = strings.Trim(answer, " .\"'*<>:")
+	for _, name := range candidates {
+		if strings.EqualFold(answer, name) {
+			return name, nil
+		}
+	}
+	if strings.EqualFold(answer, cfg.UserRole) {
+		return "", nil
+	}
+	if name := nextMentioned(candidates, answer); name != "" {
+		return name, nil
+	}
+	return "", fmt.Errorf("no char name in answer: %q", answer)
+}
+
+// directNextTurn starts the reply of the next char by the configured strategy
+func directNextTurn(lastMsg *models.RoleMsg) {
+	if cfg.GroupStrategy == "" || cfg.GroupStrategy == groupStrategyNone {
+		return
+	}
+	if lastMsg.Role == cfg.UserRole || lastMsg.Role == cfg.WriteNextMsgAs || lastMsg.Role == cfg.ToolRole {
+		return
+	}
+	order := groupOrder()
+	candidates := groupCandidates(order, cfg.GroupMuted, lastMsg.Role)
+	if len(candidates) == 0 {
+		return
+	}
+	next := ""
+	switch cfg.GroupStrategy {
+	case groupStrategyRoundRobin:
+		next = nextRoundRobin(order, candidates, lastMsg.Role)
+	case groupStrategyTalkativeness:
+		next = nextByTalkativeness(candidates, charTalkativeness, rand.Float64)
+	case groupStrategyMention:
+		next = nextMentioned(candidates, lastMsg.GetText())
+	case groupStrategyLLM:
+		var err error
+		next, err = askNextSpeaker(candidates)
+		if errors.Is(err, errDirectorInterrupted) {
+			logger.Info("director interrupted by user")
+			return
+		}
+		if err != nil {
+			logger.Warn("director failed to ask llm, using round robin", "error", err)
+			next = nextRoundRobin(order, candidates, lastMsg.Role)
+		}
+	default:
+		logger.Warn("unknown group strategy", "strategy", cfg.GroupStrategy)
+		return
+	}
+	if next == "" {
+		return
+	}
+	logger.Debug("director picked next speaker", "strategy", cfg.GroupStrategy, "char", next)
+	triggerCharTurn(next)
+}
+
+// triggerCharTurn makes the char reply, unless it is muted or max auto turns were made
+func triggerCharTurn(name string) bool {
+	maxTurns := cfg.GroupMaxAutoTurns
+	if maxTurns <= 0 {
+		maxTurns = defaultGroupMaxAutoTurns
+	}
+	if slices.Contains(cfg.GroupMuted, name) {
+		return false
+	}
+	if int(groupAutoTurns.Load()) >= maxTurns {
+		logger.Info("max auto turns reached, waiting for user", "turns", maxTurns)
+		return false
+	}
+	groupAutoTurns.Add(1)
+	startCharTurn(name)
+	return true
+}
+
+// startCharTurn sends the round in which the char replies
+func startCharTurn(name string) {
+	outputHandler.Writef("\n[-:-:b](%d) %s[-:-:-]\n", len(chatBody.Messages), roleToIcon(name))
+	// resume with char name, so LLM continues naturally from the conversation
+	chatRoundChan <- &models.ChatRoundReq{
+		UserMsg: name + ":\n",
+		Role:    name,
+		Resume:  true,
+	}
+}
+
+// toggleGroupMute mutes or unmutes the char; returns true if it is muted now
+func toggleGroupMute(name string) bool {
+	if i := slices.Index(cfg.GroupMuted, name); i >= 0 {
+		cfg.GroupMuted = slices.Delete(cfg.GroupMuted, i, i+1)
+		return false
+	}
+	cfg.GroupMuted = append(cfg.GroupMuted, name)
+	return true
+}
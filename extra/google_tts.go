@@ -11,6 +11,7 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	google_translate_tts "github.com/GrailFinder/google-translate-tts"
 	"github.com/neurosnap/sentences/english"
@@ -28,6 +29,11 @@ type GoogleTranslateOrator struct {
 	textBuffer strings.Builder
 	interrupt  bool
 	Speed      float32
+	speaking   atomic.Bool
+}
+
+func (o *GoogleTranslateOrator) IsSpeaking() bool {
+	return o.speaking.Load()
 }
 
 func (o *GoogleTranslateOrator) stoproutine() {
@@ -134,6 +140,8 @@ func (o *GoogleTranslateOrator) GetLogger() *slog.Logger {
 
 func (o *GoogleTranslateOrator) Speak(text string) error {
 	o.logger.Debug("fn: Speak is called", "text-len", len(text))
+	o.speaking.Store(true)
+	defer o.speaking.Store(false)
 	// Generate MP3 data directly as an io.Reader
 	reader, err := o.speech.GenerateSpeech(text)
 	if err != nil {
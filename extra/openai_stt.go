@@ -46,6 +46,10 @@ func newOpenAICompatSTT(logger *slog.Logger, cfg *config.Config) *openaiSTT {
 	}
 	o.recorder = NewRecorder(logger, sr)
 	o.recorder.SetOnUtterance(o.onUtterance)
+	o.recorder.SetVAD(NewVAD(cfg, sr))
+	if cfg.STT_PREROLL_MS != nil {
+		o.recorder.SetPreRoll(time.Duration(*cfg.STT_PREROLL_MS) * time.Millisecond)
+	}
 	silenceMs := cfg.STT_SILENCE_MS
 	if silenceMs > 0 {
 		o.recorder.SetSilencePeriod(time.Duration(silenceMs) * time.Millisecond)
@@ -14,6 +14,7 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/neurosnap/sentences/english"
 )
@@ -33,6 +34,11 @@ type OpenAICompatOrator struct {
 	// textBuffer, interrupt etc. remain the same
 	textBuffer strings.Builder
 	interrupt  bool
+	speaking   atomic.Bool
+}
+
+func (o *OpenAICompatOrator) IsSpeaking() bool {
+	return o.speaking.Load()
 }
 
 func (o *OpenAICompatOrator) GetLogger() *slog.Logger {
@@ -106,6 +112,8 @@ func (o *OpenAICompatOrator) playAudio(data []byte) error {
 
 func (o *OpenAICompatOrator) Speak(text string) error {
 	o.logger.Debug("fn: Speak is called", "text-len", len(text))
+	o.speaking.Store(true)
+	defer o.speaking.Store(false)
 	data, err := o.fetchAudio(text)
 	if err != nil {
 		return err
@@ -122,6 +130,8 @@ func (o *OpenAICompatOrator) speakSentences(sentences []string) {
 	if len(sentences) == 0 {
 		return
 	}
+	o.speaking.Store(true)
+	defer o.speaking.Store(false)
 	data, err := o.fetchAudio(sentences[0])
 	if err != nil {
 		o.logger.Error("fetch failed", "sentence", sentences[0], "error", err)
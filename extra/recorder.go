@@ -30,9 +30,13 @@ type Recorder struct {
 	onUtterance   func(wav []byte)
 	silencePeriod time.Duration
 	speaking      bool
-	silenceSince  time.Time
-	noiseFloor    float64
-	nfCount       int
+	silentFor     time.Duration // measured in audio time, not wall clock
+	vad           VAD
+	frameBuf      []byte
+	// audio kept before speech onset, so first syllable is not cut
+	preRoll   time.Duration
+	preBuf    [][]byte
+	utterance *bytes.Buffer
 }
 
 func NewRecorder(logger *slog.Logger, sampleRate int) *Recorder {
@@ -41,6 +45,9 @@ func NewRecorder(logger *slog.Logger, sampleRate int) *Recorder {
 		sampleRate:    sampleRate,
 		buffer:        new(bytes.Buffer),
 		silencePeriod: 1000 * time.Millisecond,
+		vad:           NewSpectralVAD(sampleRate, 0),
+		preRoll:       300 * time.Millisecond,
+		utterance:     new(bytes.Buffer),
 	}
 }
 
@@ -52,6 +59,14 @@ func (r *Recorder) SetSilencePeriod(d time.Duration) {
 	r.silencePeriod = d
 }
 
+func (r *Recorder) SetVAD(v VAD) {
+	r.vad = v
+}
+
+func (r *Recorder) SetPreRoll(d time.Duration) {
+	r.preRoll = d
+}
+
 func (r *Recorder) Start() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -80,11 +95,7 @@ func (r *Recorder) Start() error {
 		return fmt.Errorf("ffmpeg start: %w", err)
 	}
 	r.recording = true
-	r.buffer.Reset()
-	r.speaking = false
-	r.silenceSince = time.Time{}
-	r.noiseFloor = 0
-	r.nfCount = 0
+	r.resetVAD()
 	r.wg.Add(1)
 	go func() {
 		defer r.wg.Done()
@@ -96,12 +107,7 @@ func (r *Recorder) Start() error {
 			default:
 				n, err := stdout.Read(buf)
 				if n > 0 {
-					chunk := make([]byte, n)
-					copy(chunk, buf[:n])
-					r.mu.Lock()
-					r.buffer.Write(chunk)
-					r.mu.Unlock()
-					r.feedVAD(chunk)
+					r.feed(buf[:n])
 				}
 				if err != nil {
 					if err != io.EOF {
@@ -153,50 +159,79 @@ func (r *Recorder) IsRecording() bool {
 	return r.recording
 }
 
-func (r *Recorder) feedVAD(pcm []byte) {
-	rms := computeRMS(pcm)
-	if r.nfCount < 10 {
-		if r.nfCount == 0 {
-			r.noiseFloor = rms
-		} else {
-			r.noiseFloor = r.noiseFloor*0.75 + rms*0.25
-		}
-		r.nfCount++
-		return
-	}
-	threshold := r.noiseFloor * 2.5
-	if threshold < 300 {
-		threshold = 300
+func (r *Recorder) resetVAD() {
+	r.buffer.Reset()
+	r.utterance.Reset()
+	r.frameBuf = r.frameBuf[:0]
+	r.preBuf = nil
+	r.speaking = false
+	r.silentFor = 0
+	r.vad.Reset()
+}
+
+// feed stores captured pcm and runs it through the VAD frame by frame
+func (r *Recorder) feed(pcm []byte) {
+	r.mu.Lock()
+	r.buffer.Write(pcm)
+	r.mu.Unlock()
+	frameSize := r.vad.FrameSize()
+	r.frameBuf = append(r.frameBuf, pcm...)
+	for len(r.frameBuf) >= frameSize {
+		frame := make([]byte, frameSize)
+		copy(frame, r.frameBuf)
+		r.frameBuf = r.frameBuf[frameSize:]
+		r.feedVAD(frame)
 	}
-	now := time.Now()
-	if rms > threshold {
+}
+
+func (r *Recorder) feedVAD(frame []byte) {
+	frameDur := time.Duration(len(frame)/2) * time.Second / time.Duration(r.sampleRate)
+	if r.vad.IsSpeech(frame) {
 		if !r.speaking {
 			r.speaking = true
-		}
-		r.silenceSince = time.Time{}
-	} else {
-		if r.speaking {
-			if r.silenceSince.IsZero() {
-				r.silenceSince = now
-			} else if now.Sub(r.silenceSince) >= r.silencePeriod {
-				r.speaking = false
-				r.silenceSince = time.Time{}
-				r.emitUtterance()
+			for _, f := range r.preBuf {
+				r.utterance.Write(f)
 			}
+			r.preBuf = nil
 		}
+		r.silentFor = 0
+		r.utterance.Write(frame)
+		return
+	}
+	if !r.speaking {
+		r.pushPreRoll(frame, frameDur)
+		return
+	}
+	r.utterance.Write(frame)
+	r.silentFor += frameDur
+	if r.silentFor >= r.silencePeriod {
+		r.speaking = false
+		r.silentFor = 0
+		r.emitUtterance()
+	}
+}
+
+func (r *Recorder) pushPreRoll(frame []byte, frameDur time.Duration) {
+	if r.preRoll <= 0 {
+		return
+	}
+	r.preBuf = append(r.preBuf, frame)
+	if maxFrames := int(r.preRoll / frameDur); len(r.preBuf) > maxFrames {
+		r.preBuf = r.preBuf[len(r.preBuf)-maxFrames:]
 	}
 }
 
 func (r *Recorder) emitUtterance() {
-	r.mu.Lock()
-	dataSize := r.buffer.Len()
+	dataSize := r.utterance.Len()
 	if dataSize == 0 {
-		r.mu.Unlock()
 		return
 	}
 	wav := make([]byte, 44+dataSize)
 	writeWavHeader(wav[:44], r.sampleRate, dataSize)
-	copy(wav[44:], r.buffer.Bytes())
+	copy(wav[44:], r.utterance.Bytes())
+	r.utterance.Reset()
+	// emitted audio should not be sent again on Stop
+	r.mu.Lock()
 	r.buffer.Reset()
 	r.mu.Unlock()
 	if r.onUtterance != nil {
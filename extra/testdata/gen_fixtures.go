@@ -0,0 +1,179 @@
+//go:build ignore
+
+// gen_fixtures writes the wav files used by vad tests.
+// Speech is synthesized (harmonics of a pitch shaped by vowel formants,
+// with unvoiced consonant onsets), so fixtures are reproducible and carry no voices.
+//
+//	go run extra/testdata/gen_fixtures.go
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+const sr = 16000
+
+type phrase struct {
+	start, end float64 // seconds
+	rms        float64
+}
+
+type fixture struct {
+	name     string
+	duration float64
+	roomRMS  float64
+	fanStart float64 // -1: no fan
+	fanRMS   float64
+	phrases  []phrase
+}
+
+var fixtures = []fixture{
+	{
+		name: "quiet_two_phrases.wav", duration: 5.5, roomRMS: 30, fanStart: -1,
+		phrases: []phrase{{0.8, 1.8, 3000}, {3.0, 4.2, 3000}},
+	},
+	{
+		name: "soft_speech.wav", duration: 4, roomRMS: 12, fanStart: -1,
+		phrases: []phrase{{1.0, 2.4, 120}},
+	},
+	{
+		// fan turns on after the recording started
+		name: "noisy_speech.wav", duration: 5.5, roomRMS: 30, fanStart: 0.6, fanRMS: 1200,
+		phrases: []phrase{{2.4, 3.8, 3000}},
+	},
+	{
+		name: "noise_only.wav", duration: 3, roomRMS: 30, fanStart: 0, fanRMS: 1200,
+	},
+}
+
+// vowel formants (F1, F2, F3)
+var vowels = [][3]float64{
+	{730, 1090, 2440}, // a
+	{270, 2290, 3010}, // i
+	{300, 870, 2240},  // u
+	{530, 1840, 2480}, // e
+	{570, 840, 2410},  // o
+}
+
+func main() {
+	rng := rand.New(rand.NewSource(42))
+	dir := filepath.Join("extra", "testdata")
+	for _, f := range fixtures {
+		n := int(f.duration * sr)
+		out := make([]float64, n)
+		addNoise(out, 0, f.roomRMS, rng)
+		if f.fanStart >= 0 {
+			addFan(out[int(f.fanStart*sr):], f.fanRMS, rng)
+		}
+		for _, p := range f.phrases {
+			addPhrase(out, p, rng)
+		}
+		if err := writeWav(filepath.Join(dir, f.name), out); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func addNoise(out []float64, from int, rms float64, rng *rand.Rand) {
+	for i := from; i < len(out); i++ {
+		out[i] += rng.NormFloat64() * rms
+	}
+}
+
+// addFan adds broadband noise with a low-frequency rumble and mains hum
+func addFan(out []float64, rms float64, rng *rand.Rand) {
+	var lp float64
+	for i := range out {
+		w := rng.NormFloat64()
+		lp = lp*0.9 + w*0.1
+		hum := 0.15*math.Sin(2*math.Pi*50*float64(i)/sr) + 0.05*math.Sin(2*math.Pi*150*float64(i)/sr)
+		out[i] += rms * (0.9*w + 1.5*lp + hum)
+	}
+}
+
+func addPhrase(out []float64, p phrase, rng *rand.Rand) {
+	start := int(p.start * sr)
+	end := int(p.end * sr)
+	seg := make([]float64, end-start)
+	pos := 0
+	phase := 0.0
+	for pos < len(seg) {
+		sylLen := int((0.15 + 0.1*rng.Float64()) * sr)
+		if pos+sylLen > len(seg) {
+			sylLen = len(seg) - pos
+		}
+		v := vowels[rng.Intn(len(vowels))]
+		f0 := 110 + 60*rng.Float64()
+		// unvoiced consonant at syllable start
+		burst := int(0.04 * sr)
+		if burst > sylLen/3 {
+			burst = sylLen / 3
+		}
+		var hp, prev float64
+		for i := 0; i < burst; i++ {
+			w := rng.NormFloat64()
+			hp = 0.6 * (hp + w - prev)
+			prev = w
+			seg[pos+i] += 0.35 * hp
+		}
+		for i := burst; i < sylLen; i++ {
+			t := float64(i-burst) / float64(sylLen-burst)
+			env := math.Sin(math.Pi * t)
+			f := f0 * (1 + 0.03*math.Sin(2*math.Pi*5*float64(i)/sr))
+			phase += 2 * math.Pi * f / sr
+			var s float64
+			for h := 1; float64(h)*f0 < 4000; h++ {
+				s += formantGain(float64(h)*f0, v) * math.Sin(float64(h)*phase) / math.Sqrt(float64(h))
+			}
+			seg[pos+i] += env * s
+		}
+		pos += sylLen
+		// short gap between syllables
+		pos += int(0.03 * sr)
+	}
+	// normalize to requested rms
+	var sum float64
+	for _, s := range seg {
+		sum += s * s
+	}
+	scale := p.rms / math.Sqrt(sum/float64(len(seg)))
+	for i, s := range seg {
+		out[start+i] += s * scale
+	}
+}
+
+func formantGain(freq float64, f [3]float64) float64 {
+	var g float64
+	for i, fr := range f {
+		bw := 80.0 + 40*float64(i)
+		g += 1 / (1 + math.Pow((freq-fr)/bw, 2)) / float64(i+1)
+	}
+	return g + 0.02
+}
+
+func writeWav(path string, samples []float64) error {
+	data := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		s = math.Max(-32768, math.Min(32767, math.Round(s)))
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(int16(s)))
+	}
+	header := make([]byte, 44)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(36+len(data)))
+	copy(header[8:12], "WAVE")
+	copy(header[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)
+	binary.LittleEndian.PutUint16(header[20:22], 1)
+	binary.LittleEndian.PutUint16(header[22:24], 1)
+	binary.LittleEndian.PutUint32(header[24:28], sr)
+	binary.LittleEndian.PutUint32(header[28:32], sr*2)
+	binary.LittleEndian.PutUint16(header[32:34], 2)
+	binary.LittleEndian.PutUint16(header[34:36], 16)
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], uint32(len(data)))
+	return os.WriteFile(path, append(header, data...), 0o644)
+}
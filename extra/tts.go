@@ -23,6 +23,8 @@ var (
 type Orator interface {
 	Speak(text string) error
 	Stop()
+	// IsSpeaking reports if audio is being played right now
+	IsSpeaking() bool
 	// pause and resume?
 	GetLogger() *slog.Logger
 }
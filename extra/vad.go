@@ -0,0 +1,314 @@
+//go:build extra
+// +build extra
+
+package extra
+
+import (
+	"encoding/binary"
+	"gf-lt/config"
+	"math"
+	"math/cmplx"
+	"strings"
+)
+
+// VAD decides if a frame of s16le mono pcm contains speech.
+// Frames are fed in order; implementations may keep state between them.
+type VAD interface {
+	IsSpeech(frame []byte) bool
+	// FrameSize returns the expected frame length in bytes
+	FrameSize() int
+	Reset()
+}
+
+const vadFrameMs = 20
+
+func NewVAD(cfg *config.Config, sampleRate int) VAD {
+	switch strings.ToLower(cfg.STT_VAD) {
+	case "energy", "rms":
+		return NewEnergyVAD(sampleRate)
+	default:
+		return NewSpectralVAD(sampleRate, cfg.STT_VAD_AGGRESSIVENESS)
+	}
+}
+
+func vadFrameBytes(sampleRate int) int {
+	return sampleRate * vadFrameMs / 1000 * 2
+}
+
+// EnergyVAD is the old rms threshold against noise floor learned on the first frames
+type EnergyVAD struct {
+	frameSize  int
+	noiseFloor float64
+	nfCount    int
+}
+
+func NewEnergyVAD(sampleRate int) *EnergyVAD {
+	return &EnergyVAD{frameSize: vadFrameBytes(sampleRate)}
+}
+
+func (v *EnergyVAD) FrameSize() int {
+	return v.frameSize
+}
+
+func (v *EnergyVAD) Reset() {
+	v.noiseFloor = 0
+	v.nfCount = 0
+}
+
+func (v *EnergyVAD) IsSpeech(frame []byte) bool {
+	rms := computeRMS(frame)
+	// ~200ms to learn the noise floor
+	if v.nfCount < 10 {
+		if v.nfCount == 0 {
+			v.noiseFloor = rms
+		} else {
+			v.noiseFloor = v.noiseFloor*0.75 + rms*0.25
+		}
+		v.nfCount++
+		return false
+	}
+	threshold := v.noiseFloor * 2.5
+	if threshold < 300 {
+		threshold = 300
+	}
+	return rms > threshold
+}
+
+// aggressiveness presets, same scale as webrtc vad: 0 (least) to 3 (most aggressive in rejecting non-speech)
+var spectralPresets = [4]struct {
+	snrDB       float64 // min speech band snr over the noise estimate
+	periodicity float64 // min normalized autocorrelation peak in the pitch range
+	onsetFrames int     // speech frames in a row to start speech
+	hangFrames  int     // frames to keep speech after last speech frame
+}{
+	{snrDB: 2, periodicity: 0.3, onsetFrames: 1, hangFrames: 8},
+	{snrDB: 3, periodicity: 0.4, onsetFrames: 2, hangFrames: 6},
+	{snrDB: 4.5, periodicity: 0.5, onsetFrames: 2, hangFrames: 5},
+	{snrDB: 6, periodicity: 0.6, onsetFrames: 3, hangFrames: 4},
+}
+
+const (
+	vadBandLowHz  = 250
+	vadBandHighHz = 3500
+	vadInitFrames = 10
+	// pitch range for the periodicity check
+	vadMinPitchHz = 70
+	vadMaxPitchHz = 400
+	// frames below this rms are treated as digital silence
+	vadMinRMS = 20
+)
+
+// SpectralVAD compares speech band energy against an adaptive per-bin noise estimate
+// and checks that the noise-suppressed signal is periodic in the pitch range, as voiced speech is.
+// The noise estimate keeps adapting, so it follows a fan turned on mid-recording.
+type SpectralVAD struct {
+	sampleRate  int
+	frameSize   int
+	fftSize     int
+	window      []float64
+	lowBin      int
+	highBin     int
+	noise       []float64
+	frames      int
+	prevIn      float64
+	prevOut     float64
+	history     []float64 // filtered samples of the previous and current frame
+	speechRun   int
+	hang        int
+	snrDB       float64
+	periodicity float64
+	onsetFrames int
+	hangFrames  int
+}
+
+func NewSpectralVAD(sampleRate, aggressiveness int) *SpectralVAD {
+	if aggressiveness < 0 {
+		aggressiveness = 0
+	}
+	if aggressiveness > 3 {
+		aggressiveness = 3
+	}
+	p := spectralPresets[aggressiveness]
+	frameSize := vadFrameBytes(sampleRate)
+	n := frameSize / 2
+	fftSize := 1
+	for fftSize < n {
+		fftSize <<= 1
+	}
+	window := make([]float64, n)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+	}
+	binHz := float64(sampleRate) / float64(fftSize)
+	highBin := int(vadBandHighHz / binHz)
+	if highBin > fftSize/2 {
+		highBin = fftSize / 2
+	}
+	return &SpectralVAD{
+		sampleRate:  sampleRate,
+		frameSize:   frameSize,
+		fftSize:     fftSize,
+		window:      window,
+		history:     make([]float64, 2*n),
+		lowBin:      int(vadBandLowHz / binHz),
+		highBin:     highBin,
+		noise:       make([]float64, fftSize/2+1),
+		snrDB:       p.snrDB,
+		periodicity: p.periodicity,
+		onsetFrames: p.onsetFrames,
+		hangFrames:  p.hangFrames,
+	}
+}
+
+func (v *SpectralVAD) FrameSize() int {
+	return v.frameSize
+}
+
+func (v *SpectralVAD) Reset() {
+	clear(v.noise)
+	v.frames = 0
+	v.prevIn = 0
+	v.prevOut = 0
+	clear(v.history)
+	v.speechRun = 0
+	v.hang = 0
+}
+
+func (v *SpectralVAD) IsSpeech(frame []byte) bool {
+	power := v.powerSpectrum(frame)
+	v.frames++
+	if v.frames <= vadInitFrames {
+		// assume recording starts with silence, average it as the noise estimate
+		w := 1 / float64(v.frames)
+		for k := range v.noise {
+			v.noise[k] = v.noise[k]*(1-w) + power[k]*w
+		}
+		return false
+	}
+	if v.classify(frame, power) {
+		v.speechRun++
+	} else {
+		v.speechRun = 0
+	}
+	speech := false
+	if v.speechRun >= v.onsetFrames {
+		v.hang = v.hangFrames
+		speech = true
+	} else if v.hang > 0 {
+		v.hang--
+		speech = true
+	}
+	// pauses between syllables should not leak speech into the noise estimate
+	v.updateNoise(power, speech || v.speechRun > 0)
+	return speech
+}
+
+func (v *SpectralVAD) classify(frame []byte, power []float64) bool {
+	if computeRMS(frame) < vadMinRMS {
+		return false
+	}
+	var sig, noise float64
+	for k := v.lowBin; k <= v.highBin; k++ {
+		sig += power[k]
+		noise += v.noise[k]
+	}
+	if noise <= 0 {
+		return sig > 0
+	}
+	snr := 10 * math.Log10(sig/noise)
+	if snr < v.snrDB {
+		return false
+	}
+	return v.pitchStrength() > v.periodicity
+}
+
+// pitchStrength is the normalized autocorrelation peak (0..1) over pitch lags.
+// Broadband noise (fans, traffic, keyboard) has none, voiced speech has a strong one.
+func (v *SpectralVAD) pitchStrength() float64 {
+	x := v.history
+	minLag := v.sampleRate / vadMaxPitchHz
+	maxLag := min(v.sampleRate/vadMinPitchHz, len(x)/2)
+	var peak float64
+	for lag := minLag; lag <= maxLag; lag++ {
+		var xy, xx, yy float64
+		for i := 0; i+lag < len(x); i++ {
+			xy += x[i] * x[i+lag]
+			xx += x[i] * x[i]
+			yy += x[i+lag] * x[i+lag]
+		}
+		if xx == 0 || yy == 0 {
+			continue
+		}
+		if r := xy / math.Sqrt(xx*yy); r > peak {
+			peak = r
+		}
+	}
+	return peak
+}
+
+func (v *SpectralVAD) updateNoise(power []float64, speech bool) {
+	for k := range v.noise {
+		var a float64
+		switch {
+		case power[k] < v.noise[k]:
+			a = 0.9 // noise went down, follow fast
+		case !speech:
+			a = 0.95
+		default:
+			a = 0.998 // drift up slowly even during long speech
+		}
+		v.noise[k] = v.noise[k]*a + power[k]*(1-a)
+	}
+}
+
+// powerSpectrum of the high-passed and windowed frame; also keeps filtered samples for pitchStrength
+func (v *SpectralVAD) powerSpectrum(frame []byte) []float64 {
+	buf := make([]complex128, v.fftSize)
+	n := min(len(frame)/2, len(v.window))
+	copy(v.history, v.history[len(v.window):])
+	cur := v.history[len(v.window):]
+	for i := 0; i < n; i++ {
+		s := float64(int16(binary.LittleEndian.Uint16(frame[i*2:])))
+		// one pole high-pass (~80Hz) removes dc and mains hum
+		out := 0.97 * (v.prevOut + s - v.prevIn)
+		v.prevIn = s
+		v.prevOut = out
+		cur[i] = out
+		buf[i] = complex(out*v.window[i], 0)
+	}
+	fft(buf)
+	power := make([]float64, v.fftSize/2+1)
+	for k := range power {
+		a := cmplx.Abs(buf[k])
+		power[k] = a * a
+	}
+	return power
+}
+
+// fft is an in-place radix-2 transform; len(a) must be a power of two
+func fft(a []complex128) {
+	n := len(a)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				u := a[start+k]
+				t := w * a[start+k+size/2]
+				a[start+k] = u + t
+				a[start+k+size/2] = u - t
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+//go:build extra
+// +build extra
+
+package extra
+
+import (
+	"encoding/binary"
+	"fmt"
+	"gf-lt/config"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fixtures are generated by testdata/gen_fixtures.go
+func readWavFixture(t *testing.T, name string) (pcm []byte, sampleRate int) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	if len(data) < 44 || string(data[0:4]) != "RIFF" || string(data[36:40]) != "data" {
+		t.Fatalf("%s is not a plain pcm wav", name)
+	}
+	return data[44:], int(binary.LittleEndian.Uint32(data[24:28]))
+}
+
+// runRecorder feeds the fixture the same way ffmpeg output is read
+func runRecorder(t *testing.T, name string, vad func(sr int) VAD) [][]byte {
+	t.Helper()
+	pcm, sr := readWavFixture(t, name)
+	r := NewRecorder(slog.New(slog.NewTextHandler(os.Stderr, nil)), sr)
+	if vad != nil {
+		r.SetVAD(vad(sr))
+	}
+	var utterances [][]byte
+	r.SetOnUtterance(func(wav []byte) {
+		utterances = append(utterances, wav)
+	})
+	r.resetVAD()
+	for len(pcm) > 0 {
+		n := min(4096, len(pcm))
+		r.feed(pcm[:n])
+		pcm = pcm[n:]
+	}
+	return utterances
+}
+
+func wavDuration(wav []byte, sampleRate int) time.Duration {
+	return time.Duration((len(wav)-44)/2) * time.Second / time.Duration(sampleRate)
+}
+
+func TestSpectralVADFixtures(t *testing.T) {
+	cases := []struct {
+		fixture string
+		want    int
+		// speech length of the shortest phrase in the fixture
+		minDur time.Duration
+	}{
+		{fixture: "quiet_two_phrases.wav", want: 2, minDur: time.Second},
+		{fixture: "soft_speech.wav", want: 1, minDur: 1400 * time.Millisecond},
+		{fixture: "noisy_speech.wav", want: 1, minDur: 1400 * time.Millisecond},
+		{fixture: "noise_only.wav", want: 0},
+	}
+	for aggr := 0; aggr <= 3; aggr++ {
+		for _, tc := range cases {
+			t.Run(fmt.Sprintf("%s/aggr_%d", tc.fixture, aggr), func(t *testing.T) {
+				utterances := runRecorder(t, tc.fixture, func(sr int) VAD {
+					return NewSpectralVAD(sr, aggr)
+				})
+				if len(utterances) != tc.want {
+					t.Fatalf("want %d utterances, got %d", tc.want, len(utterances))
+				}
+				for i, u := range utterances {
+					if d := wavDuration(u, 16000); d < tc.minDur {
+						t.Errorf("utterance %d is too short: %v", i, d)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestEnergyVADFailsWhereSpectralWorks(t *testing.T) {
+	energy := func(sr int) VAD { return NewEnergyVAD(sr) }
+	// below fixed 300 rms threshold
+	if got := runRecorder(t, "soft_speech.wav", energy); len(got) != 0 {
+		t.Errorf("soft_speech: expected energy vad to miss speech, got %d utterances", len(got))
+	}
+	// noise floor learned before the fan was on, so speech never ends
+	if got := runRecorder(t, "noisy_speech.wav", energy); len(got) != 0 {
+		t.Errorf("noisy_speech: expected energy vad to never end speech, got %d utterances", len(got))
+	}
+}
+
+func TestRecorderPreRoll(t *testing.T) {
+	zero := 0
+	for _, tc := range []struct {
+		preRollMs *int
+		want      time.Duration
+	}{{nil, 300 * time.Millisecond}, {&zero, 0}} {
+		cfg := &config.Config{STT_PREROLL_MS: tc.preRollMs}
+		if got := newWhisperServer(slog.New(slog.DiscardHandler), cfg).recorder.preRoll; got != tc.want {
+			t.Errorf("STT_PREROLL_MS %v: expected preroll %v, got %v", tc.preRollMs, tc.want, got)
+		}
+	}
+	pcm, sr := readWavFixture(t, "quiet_two_phrases.wav")
+	frameSize := vadFrameBytes(sr)
+	for _, preRoll := range []time.Duration{0, 300 * time.Millisecond} {
+		r := NewRecorder(slog.New(slog.NewTextHandler(os.Stderr, nil)), sr)
+		r.SetVAD(NewSpectralVAD(sr, 3))
+		r.SetPreRoll(preRoll)
+		var first []byte
+		r.SetOnUtterance(func(wav []byte) {
+			if first == nil {
+				first = wav
+			}
+		})
+		r.resetVAD()
+		r.feed(pcm)
+		if first == nil {
+			t.Fatalf("preroll %v: no utterance", preRoll)
+		}
+		// quiet room is ~30 rms, speech is ~3000
+		onset := computeRMS(first[44 : 44+frameSize])
+		if preRoll == 0 && onset < 300 {
+			t.Errorf("without preroll utterance should start with speech, first frame rms %.0f", onset)
+		}
+		if preRoll > 0 && onset > 300 {
+			t.Errorf("preroll %v: onset is cut, first frame rms %.0f", preRoll, onset)
+		}
+	}
+}
@@ -39,6 +39,10 @@ func newWhisperServer(logger *slog.Logger, cfg *config.Config) *whisperServer {
 	}
 	w.recorder = NewRecorder(logger, sr)
 	w.recorder.SetOnUtterance(w.onUtterance)
+	w.recorder.SetVAD(NewVAD(cfg, sr))
+	if cfg.STT_PREROLL_MS != nil {
+		w.recorder.SetPreRoll(time.Duration(*cfg.STT_PREROLL_MS) * time.Millisecond)
+	}
 	silenceMs := cfg.STT_SILENCE_MS
 	if silenceMs > 0 {
 		w.recorder.SetSilencePeriod(time.Duration(silenceMs) * time.Millisecond)
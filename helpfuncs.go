@@ -239,6 +239,7 @@ func initSysCards() ([]*models.CharCard, error) {
 }
 
 func startNewChat(keepSysP bool) {
+	rememberChat()
 	id, err := store.ChatGetMaxID()
 	if err != nil {
 		logger.Error("failed to get chat id", "error", err)
@@ -450,10 +451,17 @@ func makeStatusLine() string {
 	statusLine := fmt.Sprintf(statusLineTempl, activeChatName,
 		boolColors[cfg.ToolUse], modelColor, chatBody.Model, boolColors[cfg.SkipLLMResp],
 		cfg.CurrentAPI, persona, botPersona)
+	if answeredBy != "" && answeredBy != providerName(cfg.CurrentAPI, chatBody.Model) {
+		statusLine += fmt.Sprintf(" | answered by: [orange:-:b]%s[-:-:-]", answeredBy)
+	}
+	if u := chatUsage(chatBody.Messages); u != nil && u.Cost > 0 {
+		statusLine += fmt.Sprintf(" | chat cost: $%.4f (alt+u)", u.Cost)
+	}
 	if cfg.STT_ENABLED {
 		recordingS := fmt.Sprintf(" | [%s:-:b]voice recording[-:-:-] (ctrl+r)",
 			boolColors[isRecording])
 		statusLine += recordingS
+		statusLine += makeVoiceStatus()
 	}
 	// completion endpoint
 	if !strings.Contains(cfg.CurrentAPI, "chat") {
@@ -1358,7 +1366,7 @@ func MsgToText(i int, m *models.RoleMsg) string {
 	}
 	finalContent.WriteString(contentStr)
 	if m.Stats != nil {
-		fmt.Fprintf(&finalContent, "\n[gray::i][%d tok, %.1fs, %.1f t/s][-:-:-]", m.Stats.Tokens, m.Stats.Duration, m.Stats.TokensPerSec)
+		fmt.Fprintf(&finalContent, "\n[gray::i][%d tok, %.1fs, %.1f t/s%s][-:-:-]", m.Stats.Tokens, m.Stats.Duration, m.Stats.TokensPerSec, usageInfo(m.Stats.Usage))
 	}
 	textMsg := fmt.Sprintf("[-:-:b]%s[-:-:-]\n%s\n", icon, finalContent.String())
 	return strings.ReplaceAll(textMsg, "\n\n", "\n")
@@ -1416,24 +1424,14 @@ func getValidKnowToRecipient(msg *models.RoleMsg) (string, bool) {
 }
 
 // triggerPrivateMessageResponses checks if a message was sent privately to specific characters
-// and triggers those non-user characters to respond
+// and triggers those non-user characters to respond; a private message is always answered,
+// the director limits (muted chars, GroupMaxAutoTurns) do not apply
 func triggerPrivateMessageResponses(msg *models.RoleMsg) {
 	recipient, ok := getValidKnowToRecipient(msg)
 	if !ok || recipient == "" {
 		return
 	}
-	// Trigger the recipient character to respond
-	triggerMsg := recipient + ":\n"
-	// Send empty message so LLM continues naturally from the conversation
-	crr := &models.ChatRoundReq{
-		UserMsg: triggerMsg,
-		Role:    recipient,
-		Resume:  true,
-	}
-	fmt.Fprintf(textView, "\n[-:-:b](%d) ", len(chatBody.Messages))
-	fmt.Fprint(textView, roleToIcon(recipient))
-	fmt.Fprint(textView, "[-:-:-]\n")
-	chatRoundChan <- crr
+	startCharTurn(recipient)
 }
 
 func GetCardByRole(role string) *models.CharCard {
@@ -0,0 +1,639 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gf-lt/mission"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	issuesPage    = "issuesPage"
+	issueEditPage = "issueEditPage"
+	// tail of the mission log shown on the board
+	maxBoardLog = 32 * 1024
+)
+
+var issuePriorities = []string{"", "low", "medium", "high", "critical"}
+
+// boardMission is a mission process started from the issues board;
+// it writes into its log file and keeps running when the board is closed
+type boardMission struct {
+	cmd         *exec.Cmd
+	projectPath string
+	worktree    string // empty for issues without a project path
+	mu          sync.Mutex
+	done        bool
+	err         error
+}
+
+func (bm *boardMission) running() bool {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return !bm.done
+}
+
+var (
+	boardMissionsMu sync.Mutex
+	boardMissions   = map[string]*boardMission{}
+	// set while the board is open; called on the ui goroutine when a mission ends
+	boardRefresh func()
+)
+
+func boardMissionRunning(issueID string) bool {
+	boardMissionsMu.Lock()
+	defer boardMissionsMu.Unlock()
+	bm, ok := boardMissions[issueID]
+	return ok && bm.running()
+}
+
+// boardMissionDir keeps checkpoints, logs and worktrees of board missions
+// apart from the ones of the -queue runner
+func boardMissionDir() (string, error) {
+	queueDir, err := missionQueueDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(queueDir, "board")
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+func boardMissionPaths(issueID string) (checkpointPath, logPath string, err error) {
+	dir, err := boardMissionDir()
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(dir, issueID+"-checkpoint.json"), filepath.Join(dir, issueID+".log"), nil
+}
+
+// startBoardMission runs the mission of the issue as a gf-lt process, like the queue runner does;
+// an in_progress issue with a checkpoint is resumed. Missions run at once, so each works
+// in a git worktree of its project; git refuses to check out the issue branch
+// when another mission (of the board or -queue) works on it
+func startBoardMission(issue *mission.Issue, status mission.IssueStatus, issuePath string) (bool, error) {
+	if status != mission.StatusOpen && status != mission.StatusInProgress {
+		return false, fmt.Errorf("issue %s is in %s; only open and in_progress issues can be worked on", issue.ID, status)
+	}
+	boardMissionsMu.Lock()
+	defer boardMissionsMu.Unlock()
+	if bm, ok := boardMissions[issue.ID]; ok && bm.running() {
+		return false, fmt.Errorf("mission for issue %s is already running", issue.ID)
+	}
+	if issue.ProjectPath == "" {
+		// without a worktree such missions work in the cwd of gf-lt
+		for id, bm := range boardMissions {
+			if bm.projectPath == "" && bm.running() {
+				return false, fmt.Errorf("mission for issue %s is running without a project path; wait for it or set project_path of issue %s", id, issue.ID)
+			}
+		}
+	}
+	checkpointPath, logPath, err := boardMissionPaths(issue.ID)
+	if err != nil {
+		return false, err
+	}
+	args := missionArgs(issue.ID, checkpointPath)
+	bm := &boardMission{projectPath: issue.ProjectPath}
+	if issue.ProjectPath != "" {
+		dir, err := boardMissionDir()
+		if err != nil {
+			return false, err
+		}
+		if _, bm.worktree, err = addMissionWorktree(issue, issuePath, dir); err != nil {
+			return false, fmt.Errorf("failed to make worktree for issue %s: %w", issue.ID, err)
+		}
+		args = append(args, "-workdir", bm.worktree)
+	}
+	resume := false
+	if status == mission.StatusInProgress {
+		if _, err := os.Stat(checkpointPath); err == nil {
+			args = append(args, "-resume", checkpointPath)
+			resume = true
+		}
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return false, fmt.Errorf("failed to open mission log: %w", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		logFile.Close()
+		return false, err
+	}
+	fmt.Fprintf(logFile, "\n=== %s: %s ===\n", time.Now().Format(time.DateTime), strings.Join(args, " "))
+	bm.cmd = exec.Command(exe, args...)
+	bm.cmd.Stdout = logFile
+	bm.cmd.Stderr = logFile
+	if err := bm.cmd.Start(); err != nil {
+		logFile.Close()
+		return false, err
+	}
+	boardMissions[issue.ID] = bm
+	go func() {
+		err := bm.cmd.Wait()
+		logFile.Close()
+		// work of a finished mission is in its branch; stopped and failed ones keep the worktree
+		if err == nil && bm.worktree != "" {
+			if err := mission.RemoveWorktree(bm.projectPath, bm.worktree); err != nil {
+				logger.Warn("failed to remove mission worktree", "dir", bm.worktree, "error", err)
+			}
+		}
+		bm.mu.Lock()
+		bm.done, bm.err = true, err
+		bm.mu.Unlock()
+		app.QueueUpdateDraw(func() {
+			if err != nil {
+				showToast("mission", fmt.Sprintf("issue %s: mission ended: %v", issue.ID, err))
+			} else {
+				showToast("mission", fmt.Sprintf("issue %s: mission finished", issue.ID))
+			}
+			if boardRefresh != nil {
+				boardRefresh()
+			}
+		})
+	}()
+	return resume, nil
+}
+
+// stopBoardMission interrupts the mission, so it saves its checkpoint to be resumed later
+func stopBoardMission(issueID string) error {
+	boardMissionsMu.Lock()
+	defer boardMissionsMu.Unlock()
+	bm, ok := boardMissions[issueID]
+	if !ok || !bm.running() {
+		return fmt.Errorf("no running mission for issue %s", issueID)
+	}
+	return bm.cmd.Process.Signal(os.Interrupt)
+}
+
+// stopBoardMissions interrupts all missions started from the board; called on exit
+func stopBoardMissions() {
+	boardMissionsMu.Lock()
+	defer boardMissionsMu.Unlock()
+	for id, bm := range boardMissions {
+		if !bm.running() {
+			continue
+		}
+		if err := bm.cmd.Process.Signal(os.Interrupt); err != nil {
+			logger.Warn("failed to stop mission", "issue", id, "error", err)
+		}
+	}
+}
+
+// readLogTail returns up to maxBoardLog last bytes of the file
+func readLogTail(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > maxBoardLog {
+		if _, err := f.Seek(-maxBoardLog, io.SeekEnd); err != nil {
+			return "", err
+		}
+	}
+	data, err := io.ReadAll(f)
+	return string(data), err
+}
+
+// showIssuesBoard opens the kanban board of the issues directory: a column per status.
+// Arrows (h/l) switch columns, n creates an issue, enter/e edits, c comments, </> move the issue,
+// r runs or resumes its mission, s stops the mission, x exits.
+func showIssuesBoard() {
+	manager := mission.NewIssueManager(cfg.IssuesDir)
+	statuses := mission.IssueStatuses
+	columns := make([]*tview.List, len(statuses))
+	issues := make([][]*mission.Issue, len(statuses))
+	details := tview.NewTextView().SetDynamicColors(true).SetWordWrap(true)
+	details.SetTitle("Issue").SetBorder(true)
+	logView := tview.NewTextView().SetWordWrap(true)
+	logView.SetTitle("Mission log").SetBorder(true)
+	col := 0
+	selected := func() *mission.Issue {
+		i := columns[col].GetCurrentItem()
+		if i < 0 || i >= len(issues[col]) {
+			return nil
+		}
+		return issues[col][i]
+	}
+	logPath := ""
+	var logSize int64 = -1
+	// updateLog shows the mission log of the selected issue; reread only when it grew
+	updateLog := func(force bool) {
+		issue := selected()
+		if issue == nil {
+			logView.SetText("")
+			logPath, logSize = "", -1
+			return
+		}
+		_, path, err := boardMissionPaths(issue.ID)
+		if err != nil {
+			return
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			logView.SetText("no mission log yet; r to run the mission")
+			logPath, logSize = path, -1
+			return
+		}
+		if !force && path == logPath && info.Size() == logSize {
+			return
+		}
+		text, err := readLogTail(path)
+		if err != nil {
+			logger.Warn("failed to read mission log", "path", path, "error", err)
+			return
+		}
+		logPath, logSize = path, info.Size()
+		title := "Mission log: " + path
+		if boardMissionRunning(issue.ID) {
+			title += " (running)"
+		}
+		logView.SetTitle(title)
+		logView.SetText(text)
+		logView.ScrollToEnd()
+	}
+	showDetails := func() {
+		issue := selected()
+		if issue == nil {
+			details.SetText("")
+			updateLog(true)
+			return
+		}
+		sb := strings.Builder{}
+		fmt.Fprintf(&sb, "[yellow]#%s[white] %s\n", tview.Escape(issue.ID), tview.Escape(issue.Title))
+		fmt.Fprintf(&sb, "status: %s  priority: %s  labels: %s\n", statuses[col], issue.Priority, tview.Escape(strings.Join(issue.Labels, ", ")))
+		fmt.Fprintf(&sb, "project: %s  branch: %s\n\n", tview.Escape(issue.ProjectPath), tview.Escape(issue.BranchName))
+		sb.WriteString(tview.Escape(issue.Description) + "\n")
+		list := func(title string, items []string) {
+			if len(items) == 0 {
+				return
+			}
+			fmt.Fprintf(&sb, "\n[yellow]%s[white]\n", title)
+			for _, item := range items {
+				sb.WriteString("- " + tview.Escape(item) + "\n")
+			}
+		}
+		list("Acceptance criteria", issue.AcceptanceCriteria)
+		list("Context files", issue.ContextFiles)
+		checks := make([]string, len(issue.Checks))
+		for i, c := range issue.Checks {
+			checks[i] = c.Describe()
+		}
+		list("Checks", checks)
+		if len(issue.Comments) > 0 {
+			sb.WriteString("\n[yellow]Comments[white]\n")
+			for _, c := range issue.Comments {
+				fmt.Fprintf(&sb, "[gray]%s %s:[white] %s\n", c.CreatedAt.Format(time.DateTime), tview.Escape(c.Author), tview.Escape(c.Body))
+			}
+		}
+		details.SetText(sb.String()).ScrollToBeginning()
+		updateLog(true)
+	}
+	// fill reloads all columns; selectID is selected in its column and focused
+	fill := func(selectID string) {
+		if selectID == "" {
+			if issue := selected(); issue != nil {
+				selectID = issue.ID
+			}
+		}
+		for i, status := range statuses {
+			list, err := manager.ListIssues(status)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				logger.Warn("failed to list issues", "status", status, "error", err)
+			}
+			issues[i] = list
+			current := columns[i].GetCurrentItem()
+			columns[i].Clear()
+			columns[i].SetTitle(fmt.Sprintf("%s (%d)", status, len(list)))
+			for j, issue := range list {
+				mark := ""
+				if boardMissionRunning(issue.ID) {
+					mark = "[green]>[white] "
+				}
+				text := fmt.Sprintf("%s#%s %s", mark, tview.Escape(issue.ID), tview.Escape(issue.Title))
+				if issue.Priority != "" {
+					text += " [gray](" + tview.Escape(issue.Priority) + ")[white]"
+				}
+				columns[i].AddItem(text, "", 0, nil)
+				if issue.ID == selectID {
+					current = j
+					col = i
+				}
+			}
+			if current >= 0 && current < len(list) {
+				columns[i].SetCurrentItem(current)
+			}
+		}
+		app.SetFocus(columns[col])
+		showDetails()
+	}
+	closeEdit := func() {
+		pages.RemovePage(issueEditPage)
+		app.SetFocus(columns[col])
+	}
+	modal := func(p tview.Primitive, width, height int) tview.Primitive {
+		return tview.NewFlex().
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+				AddItem(nil, 0, 1, false).
+				AddItem(p, height, 1, true).
+				AddItem(nil, 0, 1, false), width, 1, true).
+			AddItem(nil, 0, 1, false)
+	}
+	// editIssue opens the issue form; nil issue creates a new open one
+	// the mission process moves and rewrites its issue, so the board must not save a stale copy
+	missionRunning := func(issue *mission.Issue) bool {
+		if boardMissionRunning(issue.ID) {
+			showToast("issues", "mission of issue "+issue.ID+" is running; stop it first")
+			return true
+		}
+		return false
+	}
+	editIssue := func(issue *mission.Issue) {
+		status := statuses[col]
+		isNew := issue == nil
+		if !isNew && missionRunning(issue) {
+			return
+		}
+		if isNew {
+			status = mission.StatusOpen
+			issue = &mission.Issue{
+				ID:          fmt.Sprintf("%d", time.Now().UnixMilli()),
+				Status:      mission.StatusOpen,
+				ProjectPath: cfg.FilePickerDir,
+			}
+		}
+		const (
+			titleLabel    = "Title"
+			descLabel     = "Description"
+			acLabel       = "Acceptance criteria (one per line)"
+			filesLabel    = "Context files (one per line)"
+			priorityLabel = "Priority"
+			labelsLabel   = "Labels (comma separated)"
+			projectLabel  = "Project path"
+			branchLabel   = "Branch"
+		)
+		form := tview.NewForm()
+		title := "Edit issue #" + issue.ID
+		if isNew {
+			title = "New issue #" + issue.ID
+		}
+		form.SetBorder(true).SetTitle(title + " (esc: close)").SetTitleAlign(tview.AlignLeft)
+		form.AddInputField(titleLabel, issue.Title, 80, nil, nil)
+		form.AddTextArea(descLabel, issue.Description, 0, 6, 0, nil)
+		form.AddTextArea(acLabel, strings.Join(issue.AcceptanceCriteria, "\n"), 0, 4, 0, nil)
+		form.AddTextArea(filesLabel, strings.Join(issue.ContextFiles, "\n"), 0, 3, 0, nil)
+		priority := 0
+		for i, p := range issuePriorities {
+			if strings.EqualFold(p, issue.Priority) {
+				priority = i
+			}
+		}
+		form.AddDropDown(priorityLabel, issuePriorities, priority, nil)
+		form.AddInputField(labelsLabel, strings.Join(issue.Labels, ", "), 80, nil, nil)
+		form.AddInputField(projectLabel, issue.ProjectPath, 80, nil, nil)
+		form.AddInputField(branchLabel, issue.BranchName, 80, nil, nil)
+		text := func(label string) string {
+			switch item := form.GetFormItemByLabel(label).(type) {
+			case *tview.TextArea:
+				return item.GetText()
+			case *tview.InputField:
+				return item.GetText()
+			}
+			return ""
+		}
+		lines := func(s string, sep string) []string {
+			resp := []string{}
+			for _, l := range strings.Split(s, sep) {
+				if l = strings.TrimSpace(l); l != "" {
+					resp = append(resp, l)
+				}
+			}
+			return resp
+		}
+		form.AddButton("save", func() {
+			if strings.TrimSpace(text(titleLabel)) == "" {
+				showToast("issues", "issue needs a title")
+				return
+			}
+			issue.Title = strings.TrimSpace(text(titleLabel))
+			issue.Description = text(descLabel)
+			issue.AcceptanceCriteria = lines(text(acLabel), "\n")
+			issue.ContextFiles = lines(text(filesLabel), "\n")
+			_, issue.Priority = form.GetFormItemByLabel(priorityLabel).(*tview.DropDown).GetCurrentOption()
+			issue.Labels = lines(text(labelsLabel), ",")
+			issue.ProjectPath = strings.TrimSpace(text(projectLabel))
+			issue.BranchName = strings.TrimSpace(text(branchLabel))
+			path := manager.IssuePath(issue.ID, status)
+			var err error
+			if isNew {
+				if err = os.MkdirAll(manager.StatusDir(status), 0755); err == nil {
+					err = mission.CreateIssue(path, issue)
+				}
+			} else {
+				if missionRunning(issue) {
+					return
+				}
+				err = mission.SaveIssue(issue, path)
+			}
+			if err != nil {
+				logger.Error("failed to save issue", "id", issue.ID, "error", err)
+				showToast("issues", "failed to save: "+err.Error())
+				return
+			}
+			closeEdit()
+			fill(issue.ID)
+		})
+		form.AddButton("cancel", closeEdit)
+		form.SetCancelFunc(closeEdit)
+		form.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() == tcell.KeyEscape {
+				closeEdit()
+				return nil
+			}
+			return event
+		})
+		pages.AddPage(issueEditPage, form, true, true)
+		app.SetFocus(form)
+	}
+	addComment := func(issue *mission.Issue) {
+		if missionRunning(issue) {
+			return
+		}
+		input := tview.NewInputField()
+		input.SetTitle("Comment on #" + issue.ID + " (enter: add, esc: cancel)").SetBorder(true)
+		input.SetDoneFunc(func(key tcell.Key) {
+			body := strings.TrimSpace(input.GetText())
+			if key != tcell.KeyEnter || body == "" {
+				closeEdit()
+				return
+			}
+			if missionRunning(issue) {
+				return
+			}
+			issue.AddComment(cfg.UserRole, body)
+			if err := mission.SaveIssue(issue, manager.IssuePath(issue.ID, statuses[col])); err != nil {
+				logger.Error("failed to save issue comment", "id", issue.ID, "error", err)
+				showToast("issues", "failed to save comment: "+err.Error())
+			}
+			closeEdit()
+			fill(issue.ID)
+		})
+		pages.AddPage(issueEditPage, modal(input, 100, 3), true, true)
+		app.SetFocus(input)
+	}
+	moveIssue := func(issue *mission.Issue, step int) {
+		to := col + step
+		if to < 0 || to >= len(statuses) {
+			return
+		}
+		if missionRunning(issue) {
+			return
+		}
+		if err := manager.MoveIssue(issue.ID, statuses[col], statuses[to]); err != nil {
+			logger.Error("failed to move issue", "id", issue.ID, "to", statuses[to], "error", err)
+			showToast("issues", "failed to move: "+err.Error())
+			return
+		}
+		fill(issue.ID)
+	}
+	done := make(chan struct{})
+	closeBoard := func() {
+		close(done)
+		boardRefresh = nil
+		pages.RemovePage(issuesPage)
+		app.SetFocus(textArea)
+	}
+	board := tview.NewFlex()
+	for i := range statuses {
+		list := tview.NewList().ShowSecondaryText(false).
+			SetSelectedBackgroundColor(tcell.ColorGray)
+		list.SetBorder(true)
+		list.SetChangedFunc(func(int, string, string, rune) {
+			if col == i {
+				showDetails()
+			}
+		})
+		list.SetFocusFunc(func() {
+			if col != i {
+				col = i
+				showDetails()
+			}
+		})
+		list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			switch {
+			case event.Key() == tcell.KeyLeft || event.Key() == tcell.KeyRune && event.Rune() == 'h':
+				col = max(col-1, 0)
+				app.SetFocus(columns[col])
+				return nil
+			case event.Key() == tcell.KeyRight || event.Key() == tcell.KeyRune && event.Rune() == 'l':
+				col = min(col+1, len(columns)-1)
+				app.SetFocus(columns[col])
+				return nil
+			case event.Key() == tcell.KeyEnter:
+				if issue := selected(); issue != nil {
+					editIssue(issue)
+				}
+				return nil
+			case event.Key() != tcell.KeyRune:
+				return event
+			}
+			issue := selected()
+			switch event.Rune() {
+			case 'x':
+				closeBoard()
+			case 'n':
+				editIssue(nil)
+			case 'e':
+				if issue != nil {
+					editIssue(issue)
+				}
+			case 'c':
+				if issue != nil {
+					addComment(issue)
+				}
+			case '<':
+				if issue != nil {
+					moveIssue(issue, -1)
+				}
+			case '>':
+				if issue != nil {
+					moveIssue(issue, 1)
+				}
+			case 'r':
+				if issue == nil {
+					return nil
+				}
+				resumed, err := startBoardMission(issue, statuses[col], manager.IssuePath(issue.ID, statuses[col]))
+				if err != nil {
+					logger.Error("failed to start mission", "issue", issue.ID, "error", err)
+					showToast("mission", err.Error())
+					return nil
+				}
+				if resumed {
+					showToast("mission", "resumed mission for issue "+issue.ID)
+				} else {
+					showToast("mission", "started mission for issue "+issue.ID)
+				}
+				fill(issue.ID)
+			case 's':
+				if issue == nil {
+					return nil
+				}
+				if err := stopBoardMission(issue.ID); err != nil {
+					showToast("mission", err.Error())
+					return nil
+				}
+				showToast("mission", "stopping mission for issue "+issue.ID+"; r resumes it")
+			default:
+				return event
+			}
+			return nil
+		})
+		columns[i] = list
+		board.AddItem(list, 0, 1, i == 0)
+	}
+	bottom := tview.NewFlex().
+		AddItem(details, 0, 1, false).
+		AddItem(logView, 0, 1, false)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(board, 0, 2, true).
+		AddItem(bottom, 0, 1, false)
+	layout.SetTitle("Issues " + cfg.IssuesDir + " (h/l: column, n: new, enter/e: edit, c: comment, </>: move, r: run/resume mission, s: stop mission, x: exit)").
+		SetBorder(true)
+	boardRefresh = func() { fill("") }
+	pages.AddPage(issuesPage, layout, true, true)
+	fill("")
+	// live mission log
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				app.QueueUpdateDraw(func() {
+					select {
+					case <-done:
+					default:
+						updateLog(false)
+					}
+				})
+			}
+		}
+	}()
+}
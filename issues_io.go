@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"gf-lt/mission"
+	"os"
+	"strings"
+)
+
+// runIssuesImportExport converts issues between the issues dir and tracker dumps or markdown files
+func runIssuesImportExport() {
+	issueManager := mission.NewIssueManager(cfg.IssuesDir)
+	if issuesImport != "" {
+		// same default as create_issue
+		res, err := issueManager.ImportIssues(issuesImport, issuesFormat, cfg.FilePickerDir)
+		if res != nil && len(res.Imported) > 0 {
+			fmt.Printf("Imported %d issues into %s: %s\n", len(res.Imported), cfg.IssuesDir, strings.Join(res.Imported, ", "))
+		}
+		if res != nil && len(res.Skipped) > 0 {
+			fmt.Printf("Skipped %d issues already in %s: %s\n", len(res.Skipped), cfg.IssuesDir, strings.Join(res.Skipped, ", "))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to import issues: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if issuesExport != "" {
+		n, err := issueManager.ExportIssues(issuesExport, issuesFormat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to export issues: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Exported %d issues to %s\n", n, issuesExport)
+	}
+}
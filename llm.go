@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"gf-lt/mockllm"
 	"gf-lt/models"
 	"gf-lt/tools"
 	"io"
@@ -87,24 +88,79 @@ func SetImageAttachment(imagePath string) {
 
 // filterMessagesForCurrentCharacter filters messages based on char-specific context.
 // Returns filtered messages and the bot persona role (target character).
-func filterMessagesForCurrentCharacter(messages []models.RoleMsg) ([]models.RoleMsg, string) {
+// On resume the persona is the author of the last (continued) message.
+func filterMessagesForCurrentCharacter(messages []models.RoleMsg, resume bool) ([]models.RoleMsg, string) {
 	botPersona := cfg.AssistantRole
 	if cfg.WriteNextMsgAsCompletionAgent != "" {
 		botPersona = cfg.WriteNextMsgAsCompletionAgent
 	}
-	if cfg == nil || !cfg.CharSpecificContextEnabled {
+	if len(messages) == 0 {
 		return messages, botPersona
 	}
 	// get last message (written by user) and checck if it has a tag
 	lm := messages[len(messages)-1]
-	recipient, ok := getValidKnowToRecipient(&lm)
-	if ok && recipient != "" {
+	if resume {
+		if lm.Role != cfg.UserRole && lm.Role != cfg.ToolRole && lm.Role != "system" {
+			botPersona = lm.Role
+		}
+	} else if recipient, ok := getValidKnowToRecipient(&lm); ok && recipient != "" {
 		botPersona = recipient
 	}
+	if !cfg.CharSpecificContextEnabled {
+		return messages, botPersona
+	}
 	filtered := filterMessagesForCharacter(messages, botPersona)
 	return filtered, botPersona
 }
 
+// toChatRoles maps roles for /chat/completions endpoints that only know system, user, assistant and tool:
+// the replying character is assistant, other characters become user turns with their name in front
+func toChatRoles(messages []models.RoleMsg, botPersona string) []models.RoleMsg {
+	resp := make([]models.RoleMsg, len(messages))
+	for i := range messages {
+		msg := *stripThinkingFromMsg(&messages[i])
+		switch {
+		case msg.Role == "system":
+		case msg.Role == cfg.ToolRole:
+			msg.Role = "tool"
+		case msg.Role == botPersona, msg.ToolCall != nil, len(msg.ToolCalls) > 0:
+			// tool calls have to stay assistant to match tool responses
+			msg.Role = "assistant"
+		case msg.Role == cfg.UserRole:
+			msg.Role = "user"
+		default:
+			namePrefix := msg.Role + ":"
+			switch {
+			case strings.HasPrefix(msg.GetText(), namePrefix):
+			case msg.HasContentParts:
+				// parts may be shared with chatBody, so prepend instead of SetText
+				msg.ContentParts = append([]any{models.TextContentPart{Type: "text", Text: namePrefix}},
+					msg.ContentParts...)
+			default:
+				msg.Content = namePrefix + " " + msg.Content
+			}
+			msg.Role = "user"
+		}
+		resp[i] = msg
+	}
+	return resp
+}
+
+// appendPostHistory adds card post_history_instructions of the replying character
+// after the chat history; it is not stored in chatBody
+func appendPostHistory(messages []models.RoleMsg, botPersona string, resume bool) []models.RoleMsg {
+	if resume {
+		return messages
+	}
+	cc := GetCardByRole(botPersona)
+	if cc == nil || strings.TrimSpace(cc.PostHistory) == "" {
+		return messages
+	}
+	resp := make([]models.RoleMsg, len(messages), len(messages)+1)
+	copy(resp, messages)
+	return append(resp, models.RoleMsg{Role: "system", Content: cc.PostHistory})
+}
+
 type ChunkParser interface {
 	ParseChunk([]byte) (*models.TextChunk, error)
 	FormMsg(msg, role string, cont bool) (io.Reader, error)
@@ -190,6 +246,11 @@ func choseChunkParser() {
 		logger.Debug("chosen openrouterchat", "link", cfg.CurrentAPI)
 		return
 	default:
+		if mockllm.IsMock(cfg.CurrentAPI) {
+			chunkParser = mockParsers[mockllm.Format(cfg.CurrentAPI)]
+			logger.Debug("chosen mock", "link", cfg.CurrentAPI, "parser", chunkParser)
+			return
+		}
 		logger.Warn("unexpected case, assuming llama.cpp on non default address", "link", cfg.CurrentAPI)
 		if strings.Contains(cfg.CurrentAPI, "chat") {
 			chunkParser = LCPChat{}
@@ -200,6 +261,16 @@ func choseChunkParser() {
 	}
 }
 
+// mockParsers read the wire formats of the mock backend
+var mockParsers = map[string]ChunkParser{
+	mockllm.FormatLCPCompletion:        LCPCompletion{},
+	mockllm.FormatLCPChat:              LCPChat{},
+	mockllm.FormatDeepSeekCompletion:   DeepSeekerCompletion{},
+	mockllm.FormatDeepSeekChat:         DeepSeekerChat{},
+	mockllm.FormatOpenRouterCompletion: OpenRouterCompletion{},
+	mockllm.FormatOpenRouterChat:       OpenRouterChat{},
+}
+
 type LCPCompletion struct {
 }
 type LCPChat struct {
@@ -263,7 +334,10 @@ func (lcp LCPCompletion) FormMsg(msg, role string, resume bool) (io.Reader, erro
 	if cfg.ToolUse && !resume && role == cfg.UserRole && !containsToolSysMsg() {
 		chatBody.Messages = append(chatBody.Messages, models.RoleMsg{Role: "system", Content: tools.ToolSysMsg})
 	}
-	filteredMessages, botPersona := filterMessagesForCurrentCharacter(chatBody.Messages)
+	filteredMessages, botPersona := filterMessagesForCurrentCharacter(chatBody.Messages, resume)
+	filteredMessages = injectLore(filteredMessages, botPersona)
+	filteredMessages = injectMemories(filteredMessages, botPersona, resume)
+	filteredMessages = appendPostHistory(filteredMessages, botPersona, resume)
 	// Build prompt and extract images inline as we process each message
 	messages := make([]string, len(filteredMessages))
 	for i := range filteredMessages {
@@ -332,6 +406,12 @@ func (lcp LCPCompletion) ParseChunk(data []byte) (*models.TextChunk, error) {
 			logger.Error("text inside of finish llmchunk", "chunk", llmchunk)
 		}
 		resp.Finished = true
+		if llmchunk.TokensEvaluated > 0 || llmchunk.TokensPredicted > 0 {
+			resp.Usage = &models.Usage{
+				PromptTokens:     llmchunk.TokensEvaluated,
+				CompletionTokens: llmchunk.TokensPredicted,
+			}
+		}
 	}
 	return resp, nil
 }
@@ -350,14 +430,19 @@ func (op LCPChat) ParseChunk(data []byte) (*models.TextChunk, error) {
 		logger.Error("failed to decode", "error", err, "line", string(data))
 		return nil, err
 	}
+	usage := llmchunk.Usage.ToUsage()
 	if len(llmchunk.Choices) == 0 {
-		logger.Warn("LCPChat empty chunk choices", "raw_data", string(data), "chunk", llmchunk)
-		return &models.TextChunk{}, nil
+		// the usage chunk at the end of the stream has no choices
+		if usage == nil {
+			logger.Warn("LCPChat empty chunk choices", "raw_data", string(data), "chunk", llmchunk)
+		}
+		return &models.TextChunk{Usage: usage}, nil
 	}
 	lastChoice := llmchunk.Choices[len(llmchunk.Choices)-1]
 	resp := &models.TextChunk{
 		Chunk:     lastChoice.Delta.Content,
 		Reasoning: lastChoice.Delta.ReasoningContent,
+		Usage:     usage,
 	}
 	// Collect ALL tool call deltas from ALL choices
 	for _, choice := range llmchunk.Choices {
@@ -428,36 +513,25 @@ func (op LCPChat) FormMsg(msg, role string, resume bool) (io.Reader, error) {
 	if cfg.ToolUse && !cfg.DisableToolGuide && !resume && role == cfg.UserRole {
 		chatBody.Messages = prependToolGuide(chatBody.Messages, tools.ToolSysMsgChat)
 	}
-	filteredMessages, _ := filterMessagesForCurrentCharacter(chatBody.Messages)
+	filteredMessages, botPersona := filterMessagesForCurrentCharacter(chatBody.Messages, resume)
+	filteredMessages = injectLore(filteredMessages, botPersona)
+	filteredMessages = injectMemories(filteredMessages, botPersona, resume)
+	filteredMessages = appendPostHistory(filteredMessages, botPersona, resume)
 	// openai /v1/chat does not support custom roles; needs to be user, assistant, system
-	// Add persona suffix to the last user message to indicate who the assistant should reply as
 	bodyCopy := &models.ChatBody{
-		Messages: make([]models.RoleMsg, len(filteredMessages)),
+		Messages: toChatRoles(filteredMessages, botPersona),
 		Model:    chatBody.Model,
 		Stream:   chatBody.Stream,
 	}
-	for i := range filteredMessages {
-		strippedMsg := *stripThinkingFromMsg(&filteredMessages[i])
-		switch strippedMsg.Role {
-		case cfg.UserRole:
-			bodyCopy.Messages[i] = strippedMsg
-			bodyCopy.Messages[i].Role = "user"
-		case cfg.AssistantRole:
-			bodyCopy.Messages[i] = strippedMsg
-			bodyCopy.Messages[i].Role = "assistant"
-		case cfg.ToolRole:
-			bodyCopy.Messages[i] = strippedMsg
-			bodyCopy.Messages[i].Role = "tool"
-		default:
-			bodyCopy.Messages[i] = strippedMsg
-		}
-	}
 	// Clean null/empty messages to prevent API issues
 	bodyCopy.Messages = consolidateAssistantMessages(bodyCopy.Messages)
 	req := models.OpenAIReq{
 		ChatBody: bodyCopy,
 		Tools:    nil,
 	}
+	if bodyCopy.Stream {
+		req.StreamOptions = &models.StreamOptions{IncludeUsage: true}
+	}
 	if cfg.ToolUse && !resume && role != cfg.ToolRole {
 		var allTools []any
 		for _, t := range tools.BaseTools {
@@ -492,12 +566,16 @@ func (ds DeepSeekerCompletion) ParseChunk(data []byte) (*models.TextChunk, error
 		logger.Error("failed to decode", "error", err, "line", string(data))
 		return nil, err
 	}
+	usage := llmchunk.Usage.ToUsage()
 	if len(llmchunk.Choices) == 0 {
-		logger.Warn("empty chunk choices", "raw_data", string(data), "chunk", llmchunk)
-		return &models.TextChunk{}, nil
+		if usage == nil {
+			logger.Warn("empty chunk choices", "raw_data", string(data), "chunk", llmchunk)
+		}
+		return &models.TextChunk{Usage: usage}, nil
 	}
 	resp := &models.TextChunk{
 		Chunk: llmchunk.Choices[0].Text,
+		Usage: usage,
 	}
 	if llmchunk.Choices[0].FinishReason != "" {
 		if resp.Chunk != "" {
@@ -537,7 +615,10 @@ func (ds DeepSeekerCompletion) FormMsg(msg, role string, resume bool) (io.Reader
 		outputHandler.Writef("%s[-:-:b](%d) %s[-:-:-]\n%s\n", "\n",
 			len(chatBody.Messages), roleToIcon(cfg.ToolRole), rollRespText)
 	}
-	filteredMessages, botPersona := filterMessagesForCurrentCharacter(chatBody.Messages)
+	filteredMessages, botPersona := filterMessagesForCurrentCharacter(chatBody.Messages, resume)
+	filteredMessages = injectLore(filteredMessages, botPersona)
+	filteredMessages = injectMemories(filteredMessages, botPersona, resume)
+	filteredMessages = appendPostHistory(filteredMessages, botPersona, resume)
 	messages := make([]string, len(filteredMessages))
 	for i := range filteredMessages {
 		messages[i] = stripThinkingFromMsg(&filteredMessages[i]).ToPrompt()
@@ -571,9 +652,11 @@ func (ds DeepSeekerChat) ParseChunk(data []byte) (*models.TextChunk, error) {
 		logger.Error("failed to decode", "error", err, "line", string(data))
 		return nil, err
 	}
-	resp := &models.TextChunk{}
+	resp := &models.TextChunk{Usage: llmchunk.Usage.ToUsage()}
 	if len(llmchunk.Choices) == 0 {
-		logger.Warn("empty chunk choices", "raw_data", string(data), "chunk", llmchunk)
+		if resp.Usage == nil {
+			logger.Warn("empty chunk choices", "raw_data", string(data), "chunk", llmchunk)
+		}
 		return resp, nil
 	}
 	if llmchunk.Choices[0].FinishReason != "" {
@@ -623,34 +706,16 @@ func (ds DeepSeekerChat) FormMsg(msg, role string, resume bool) (io.Reader, erro
 	if cfg.ToolUse && !cfg.DisableToolGuide && !resume && role == cfg.UserRole {
 		chatBody.Messages = prependToolGuide(chatBody.Messages, tools.ToolSysMsgChat)
 	}
-	filteredMessages, _ := filterMessagesForCurrentCharacter(chatBody.Messages)
-	// Create copy of chat body with standardized user role
-	// Add persona suffix to the last user message to indicate who the assistant should reply as
+	filteredMessages, botPersona := filterMessagesForCurrentCharacter(chatBody.Messages, resume)
+	filteredMessages = injectLore(filteredMessages, botPersona)
+	filteredMessages = injectMemories(filteredMessages, botPersona, resume)
+	filteredMessages = appendPostHistory(filteredMessages, botPersona, resume)
+	// openai /v1/chat does not support custom roles; needs to be user, assistant, system
 	bodyCopy := &models.ChatBody{
-		Messages: make([]models.RoleMsg, len(filteredMessages)),
+		Messages: toChatRoles(filteredMessages, botPersona),
 		Model:    chatBody.Model,
 		Stream:   chatBody.Stream,
 	}
-	for i := range filteredMessages {
-		strippedMsg := *stripThinkingFromMsg(&filteredMessages[i])
-		switch strippedMsg.Role {
-		case cfg.UserRole:
-			if i == 1 {
-				bodyCopy.Messages[i] = strippedMsg
-				bodyCopy.Messages[i].Role = "user"
-			} else {
-				bodyCopy.Messages[i] = strippedMsg
-			}
-		case cfg.AssistantRole:
-			bodyCopy.Messages[i] = strippedMsg
-			bodyCopy.Messages[i].Role = "assistant"
-		case cfg.ToolRole:
-			bodyCopy.Messages[i] = strippedMsg
-			bodyCopy.Messages[i].Role = "tool"
-		default:
-			bodyCopy.Messages[i] = strippedMsg
-		}
-	}
 	// Clean null/empty messages to prevent API issues
 	bodyCopy.Messages = consolidateAssistantMessages(bodyCopy.Messages)
 	dsBody := models.NewDSChatReq(*bodyCopy)
@@ -673,12 +738,16 @@ func (or OpenRouterCompletion) ParseChunk(data []byte) (*models.TextChunk, error
 		logger.Error("failed to decode", "error", err, "line", string(data))
 		return nil, err
 	}
+	usage := llmchunk.Usage.ToUsage()
 	if len(llmchunk.Choices) == 0 {
-		logger.Warn("empty chunk choices", "raw_data", string(data), "chunk", llmchunk)
-		return &models.TextChunk{}, nil
+		if usage == nil {
+			logger.Warn("empty chunk choices", "raw_data", string(data), "chunk", llmchunk)
+		}
+		return &models.TextChunk{Usage: usage}, nil
 	}
 	resp := &models.TextChunk{
 		Chunk: llmchunk.Choices[len(llmchunk.Choices)-1].Text,
+		Usage: usage,
 	}
 	if llmchunk.Choices[len(llmchunk.Choices)-1].FinishReason == "stop" {
 		if resp.Chunk != "" {
@@ -715,7 +784,10 @@ func (or OpenRouterCompletion) FormMsg(msg, role string, resume bool) (io.Reader
 	if cfg.ToolUse && !resume && role == cfg.UserRole && !containsToolSysMsg() {
 		chatBody.Messages = append(chatBody.Messages, models.RoleMsg{Role: "system", Content: tools.ToolSysMsg})
 	}
-	filteredMessages, botPersona := filterMessagesForCurrentCharacter(chatBody.Messages)
+	filteredMessages, botPersona := filterMessagesForCurrentCharacter(chatBody.Messages, resume)
+	filteredMessages = injectLore(filteredMessages, botPersona)
+	filteredMessages = injectMemories(filteredMessages, botPersona, resume)
+	filteredMessages = appendPostHistory(filteredMessages, botPersona, resume)
 	messages := make([]string, len(filteredMessages))
 	for i := range filteredMessages {
 		messages[i] = stripThinkingFromMsg(&filteredMessages[i]).ToPrompt()
@@ -750,14 +822,18 @@ func (or OpenRouterChat) ParseChunk(data []byte) (*models.TextChunk, error) {
 		logger.Error("failed to decode", "error", err, "line", string(data))
 		return nil, err
 	}
+	usage := llmchunk.Usage.ToUsage()
 	if len(llmchunk.Choices) == 0 {
-		logger.Warn("empty chunk choices", "raw_data", string(data), "chunk", llmchunk)
-		return &models.TextChunk{}, nil
+		if usage == nil {
+			logger.Warn("empty chunk choices", "raw_data", string(data), "chunk", llmchunk)
+		}
+		return &models.TextChunk{Usage: usage}, nil
 	}
 	lastChoice := llmchunk.Choices[len(llmchunk.Choices)-1]
 	resp := &models.TextChunk{
 		Chunk:     lastChoice.Delta.Content,
 		Reasoning: lastChoice.Delta.Reasoning,
+		Usage:     usage,
 	}
 	// Collect ALL tool call deltas from the last choice
 	resp.ToolCalls = lastChoice.Delta.ToolCalls
@@ -825,30 +901,16 @@ func (or OpenRouterChat) FormMsg(msg, role string, resume bool) (io.Reader, erro
 	if cfg.ToolUse && !cfg.DisableToolGuide && !resume && role == cfg.UserRole {
 		chatBody.Messages = prependToolGuide(chatBody.Messages, tools.ToolSysMsgChat)
 	}
-	filteredMessages, _ := filterMessagesForCurrentCharacter(chatBody.Messages)
-	// Create copy of chat body with standardized user role
-	// Add persona suffix to the last user message to indicate who the assistant should reply as
+	filteredMessages, botPersona := filterMessagesForCurrentCharacter(chatBody.Messages, resume)
+	filteredMessages = injectLore(filteredMessages, botPersona)
+	filteredMessages = injectMemories(filteredMessages, botPersona, resume)
+	filteredMessages = appendPostHistory(filteredMessages, botPersona, resume)
+	// openai /v1/chat does not support custom roles; needs to be user, assistant, system
 	bodyCopy := &models.ChatBody{
-		Messages: make([]models.RoleMsg, len(filteredMessages)),
+		Messages: toChatRoles(filteredMessages, botPersona),
 		Model:    chatBody.Model,
 		Stream:   chatBody.Stream,
 	}
-	for i := range filteredMessages {
-		strippedMsg := *stripThinkingFromMsg(&filteredMessages[i])
-		switch strippedMsg.Role {
-		case cfg.UserRole:
-			bodyCopy.Messages[i] = strippedMsg
-			bodyCopy.Messages[i].Role = "user"
-		case cfg.AssistantRole:
-			bodyCopy.Messages[i] = strippedMsg
-			bodyCopy.Messages[i].Role = "assistant"
-		case cfg.ToolRole:
-			bodyCopy.Messages[i] = strippedMsg
-			bodyCopy.Messages[i].Role = "tool"
-		default:
-			bodyCopy.Messages[i] = strippedMsg
-		}
-	}
 	// Clean null/empty messages to prevent API issues
 	bodyCopy.Messages = consolidateAssistantMessages(bodyCopy.Messages)
 	orBody := models.NewOpenRouterChatReq(*bodyCopy, defaultLCPProps, cfg.ReasoningEffort)
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"gf-lt/lorebook"
+	"gf-lt/models"
+	"gf-lt/pngmeta"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// standalone lorebooks from cfg.LorebookDir; card books are taken from sysMap on each request
+var loreBooks []*lorebook.Book
+
+func loadLoreBooks() {
+	if cfg.LorebookDir == "" {
+		return
+	}
+	books, err := lorebook.LoadDir(cfg.LorebookDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+		logger.Warn("failed to load some lorebooks", "dir", cfg.LorebookDir, "error", err)
+	}
+	loreBooks = books
+}
+
+// activeLoreBooks returns the book of the character card and all standalone books
+func activeLoreBooks(botPersona string) []*lorebook.Book {
+	books := make([]*lorebook.Book, 0, len(loreBooks)+1)
+	if b := lorebook.FromCard(GetCardByRole(botPersona)); b != nil {
+		books = append(books, b)
+	}
+	return append(books, loreBooks...)
+}
+
+// injectLore puts entries triggered by the last messages around the system prompt;
+// like post history instructions, they are not stored in chatBody
+func injectLore(messages []models.RoleMsg, botPersona string) []models.RoleMsg {
+	if !cfg.LorebookEnabled || len(messages) == 0 {
+		return messages
+	}
+	books := activeLoreBooks(botPersona)
+	if len(books) == 0 {
+		return messages
+	}
+	texts := make([]string, 0, len(messages))
+	for i := range messages {
+		if messages[i].Role == "system" {
+			continue
+		}
+		texts = append(texts, messages[i].GetText())
+	}
+	activated := lorebook.Scan(books, texts, lorebook.Options{
+		ScanDepth:   cfg.LorebookScanDepth,
+		TokenBudget: cfg.LorebookTokenBudget,
+	})
+	if len(activated) == 0 {
+		return messages
+	}
+	var before, after []string
+	for _, a := range activated {
+		content := models.FillMacros(a.Entry.Content, botPersona, cfg.UserRole)
+		if a.Entry.Position == lorebook.PositionAfterChar {
+			after = append(after, content)
+		} else {
+			before = append(before, content)
+		}
+	}
+	logger.Debug("lorebook entries triggered", "before", len(before), "after", len(after))
+	// first message is the card sys prompt
+	sysIdx := 0
+	if messages[0].Role == "system" {
+		sysIdx = 1
+	}
+	resp := make([]models.RoleMsg, 0, len(messages)+2)
+	if len(before) > 0 {
+		resp = append(resp, models.RoleMsg{Role: "system", Content: strings.Join(before, "\n")})
+	}
+	resp = append(resp, messages[:sysIdx]...)
+	if len(after) > 0 {
+		resp = append(resp, models.RoleMsg{Role: "system", Content: strings.Join(after, "\n")})
+	}
+	return append(resp, messages[sysIdx:]...)
+}
+
+// saveLoreBook writes standalone book to its file or card book into the card
+func saveLoreBook(b *lorebook.Book, cc *models.CharCard) error {
+	if b.Path != "" {
+		return b.Save(b.Path)
+	}
+	if cc == nil || cc.FilePath == "" {
+		return errors.New("book has no file to save to")
+	}
+	if strings.HasSuffix(cc.FilePath, ".png") {
+		return pngmeta.WriteToPng(cc.ToSpec(cfg.UserRole), cc.FilePath, cc.FilePath)
+	}
+	data, err := json.MarshalIndent(cc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cc.FilePath, data, 0o644)
+}
@@ -0,0 +1,363 @@
+package lorebook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gf-lt/models"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	PositionBeforeChar = "before_char"
+	PositionAfterChar  = "after_char"
+	// used when neither book nor config sets it
+	DefaultScanDepth = 4
+)
+
+// Book is a lorebook (world info): v2 character_book embedded in a card or a standalone json file
+type Book struct {
+	*models.CharacterBook
+	// file the book was loaded from; empty for books from cards
+	Path string `json:"-"`
+}
+
+// Options are defaults for the values a book does not set
+type Options struct {
+	ScanDepth   int // number of last messages to scan for keys
+	TokenBudget int // max tokens of injected entries; 0 is unlimited
+}
+
+// Activated is an entry triggered by the chat
+type Activated struct {
+	Book  *Book
+	Entry *models.CharacterBookEntry
+}
+
+// FromCard wraps the card book, so edits go to the card; nil if card has none
+func FromCard(cc *models.CharCard) *Book {
+	if cc == nil || cc.CharacterBook == nil {
+		return nil
+	}
+	return &Book{CharacterBook: cc.CharacterBook}
+}
+
+// sillytavern world info export; entries are keyed by uid
+type stWorldInfo struct {
+	Name    string                  `json:"name"`
+	Entries map[string]stWorldEntry `json:"entries"`
+}
+
+type stWorldEntry struct {
+	UID           int      `json:"uid"`
+	Key           []string `json:"key"`
+	KeySecondary  []string `json:"keysecondary"`
+	Comment       string   `json:"comment"`
+	Content       string   `json:"content"`
+	Constant      bool     `json:"constant"`
+	Selective     bool     `json:"selective"`
+	Order         int      `json:"order"`
+	Position      int      `json:"position"` // 0: before char, 1: after char
+	Disable       bool     `json:"disable"`
+	ScanDepth     *int     `json:"scanDepth"`
+	CaseSensitive *bool    `json:"caseSensitive"`
+}
+
+// Load reads a lorebook json: character_book format or sillytavern world info export
+func Load(path string) (*Book, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	probe := struct {
+		Entries json.RawMessage `json:"entries"`
+	}{}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse lorebook %s: %w", path, err)
+	}
+	b := &Book{CharacterBook: &models.CharacterBook{}, Path: path}
+	switch {
+	case len(probe.Entries) == 0:
+		return nil, fmt.Errorf("no entries in lorebook %s", path)
+	case probe.Entries[0] == '{':
+		wi := stWorldInfo{}
+		if err := json.Unmarshal(data, &wi); err != nil {
+			return nil, fmt.Errorf("failed to parse world info %s: %w", path, err)
+		}
+		b.Name = wi.Name
+		b.Entries = fromWorldInfo(wi.Entries)
+	default:
+		if err := json.Unmarshal(data, b.CharacterBook); err != nil {
+			return nil, fmt.Errorf("failed to parse lorebook %s: %w", path, err)
+		}
+	}
+	if b.Name == "" {
+		b.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return b, nil
+}
+
+func fromWorldInfo(entries map[string]stWorldEntry) []models.CharacterBookEntry {
+	sorted := make([]stWorldEntry, 0, len(entries))
+	for _, e := range entries {
+		sorted = append(sorted, e)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].UID < sorted[j].UID
+	})
+	resp := make([]models.CharacterBookEntry, 0, len(sorted))
+	for _, e := range sorted {
+		entry := models.CharacterBookEntry{
+			Keys:           e.Key,
+			SecondaryKeys:  e.KeySecondary,
+			Comment:        e.Comment,
+			Content:        e.Content,
+			Constant:       e.Constant,
+			Selective:      e.Selective,
+			InsertionOrder: e.Order,
+			Enabled:        !e.Disable,
+			CaseSensitive:  e.CaseSensitive,
+			ID:             e.UID,
+			Position:       PositionBeforeChar,
+			Extensions:     map[string]any{},
+		}
+		if e.Position == 1 {
+			entry.Position = PositionAfterChar
+		}
+		if e.ScanDepth != nil {
+			entry.Extensions["scan_depth"] = *e.ScanDepth
+		}
+		resp = append(resp, entry)
+	}
+	return resp
+}
+
+// LoadDir reads all json lorebooks in the dir
+func LoadDir(dir string) ([]*Book, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	resp := []*Book{}
+	var errs []error
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		b, err := Load(filepath.Join(dir, f.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resp = append(resp, b)
+	}
+	return resp, errors.Join(errs...)
+}
+
+// Save writes the book in character_book format
+func (b *Book) Save(path string) error {
+	if b.Extensions == nil {
+		b.Extensions = map[string]any{}
+	}
+	data, err := json.MarshalIndent(b.CharacterBook, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// EstimateTokens is a rough count, same as used for the chat context
+func EstimateTokens(s string) int {
+	return len(s) / 4
+}
+
+// Scan returns entries triggered by the messages (oldest first),
+// sorted by insertion order and cut to the token budget.
+func Scan(books []*Book, messages []string, opts Options) []Activated {
+	if opts.ScanDepth <= 0 {
+		opts.ScanDepth = DefaultScanDepth
+	}
+	resp := []Activated{}
+	for _, b := range books {
+		if b == nil || b.CharacterBook == nil {
+			continue
+		}
+		resp = append(resp, b.scan(messages, opts)...)
+	}
+	resp = cutToBudget(resp, opts.TokenBudget)
+	sort.SliceStable(resp, func(i, j int) bool {
+		return resp[i].Entry.InsertionOrder < resp[j].Entry.InsertionOrder
+	})
+	return resp
+}
+
+func (b *Book) scan(messages []string, opts Options) []Activated {
+	depth := opts.ScanDepth
+	if b.ScanDepth != nil && *b.ScanDepth > 0 {
+		depth = *b.ScanDepth
+	}
+	active := make([]bool, len(b.Entries))
+	// content of triggered entries, for recursive scanning
+	extra := []string{}
+	for {
+		added := false
+		for i := range b.Entries {
+			e := &b.Entries[i]
+			if active[i] || !e.Enabled {
+				continue
+			}
+			d := depth
+			if ed, ok := entryScanDepth(e); ok {
+				d = ed
+			}
+			if !e.Constant && !Triggers(e, slices.Concat(lastN(messages, d), extra)) {
+				continue
+			}
+			active[i] = true
+			added = true
+			extra = append(extra, e.Content)
+		}
+		if !added || !b.RecursiveScanning {
+			break
+		}
+	}
+	resp := []Activated{}
+	for i := range b.Entries {
+		if active[i] {
+			resp = append(resp, Activated{Book: b, Entry: &b.Entries[i]})
+		}
+	}
+	budget := 0
+	if b.TokenBudget != nil {
+		budget = *b.TokenBudget
+	}
+	return cutToBudget(resp, budget)
+}
+
+// cutToBudget drops lower priority entries first, constant ones are dropped last
+func cutToBudget(entries []Activated, budget int) []Activated {
+	if budget <= 0 {
+		return entries
+	}
+	sorted := make([]Activated, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Entry, sorted[j].Entry
+		if a.Constant != b.Constant {
+			return a.Constant
+		}
+		return a.Priority > b.Priority
+	})
+	resp := []Activated{}
+	used := 0
+	for _, a := range sorted {
+		tokens := EstimateTokens(a.Entry.Content)
+		if used+tokens > budget {
+			continue
+		}
+		used += tokens
+		resp = append(resp, a)
+	}
+	return resp
+}
+
+func lastN(messages []string, n int) []string {
+	if n <= 0 || n >= len(messages) {
+		return messages
+	}
+	return messages[len(messages)-n:]
+}
+
+// entryScanDepth reads per entry override from extensions
+func entryScanDepth(e *models.CharacterBookEntry) (int, bool) {
+	switch v := e.Extensions["scan_depth"].(type) {
+	case int:
+		return v, v > 0
+	case float64:
+		return int(v), v > 0
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil && n > 0
+	}
+	return 0, false
+}
+
+// Triggers reports if any of the entry keys is in the texts;
+// selective entries also need one of the secondary keys
+func Triggers(e *models.CharacterBookEntry, texts []string) bool {
+	caseSensitive := e.CaseSensitive != nil && *e.CaseSensitive
+	if !anyKeyMatches(e.Keys, texts, caseSensitive, e.UseRegex) {
+		return false
+	}
+	if e.Selective && len(e.SecondaryKeys) > 0 {
+		return anyKeyMatches(e.SecondaryKeys, texts, caseSensitive, e.UseRegex)
+	}
+	return true
+}
+
+func anyKeyMatches(keys, texts []string, caseSensitive, useRegex bool) bool {
+	for _, key := range keys {
+		re := keyRegexp(key, caseSensitive, useRegex)
+		if re == nil {
+			continue
+		}
+		for _, text := range texts {
+			if re.MatchString(text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// slashRE matches keys written as /pattern/flags
+var slashRE = regexp.MustCompile(`^/(.+)/([a-z]*)$`)
+
+// keyRegexp compiles the key; plain keys match whole words. Invalid regexps return nil.
+// Keys as /pattern/flags are case sensitive unless flags have i.
+func keyRegexp(key string, caseSensitive, useRegex bool) *regexp.Regexp {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil
+	}
+	var pattern string
+	if m := slashRE.FindStringSubmatch(key); m != nil {
+		pattern = m[1]
+		caseSensitive = !strings.Contains(m[2], "i")
+	} else if useRegex {
+		pattern = key
+	} else {
+		pattern = regexp.QuoteMeta(key)
+		// \b is ascii only in go regexp
+		if isWordRune(key, true) {
+			pattern = `\b` + pattern
+		}
+		if isWordRune(key, false) {
+			pattern += `\b`
+		}
+	}
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+func isWordRune(s string, first bool) bool {
+	r := []rune(s)
+	c := r[len(r)-1]
+	if first {
+		c = r[0]
+	}
+	return c < unicode.MaxASCII && (c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c))
+}
@@ -0,0 +1,246 @@
+package lorebook
+
+import (
+	"gf-lt/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func intPtr(i int) *int { return &i }
+
+func TestTriggers(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry models.CharacterBookEntry
+		texts []string
+		want  bool
+	}{
+		{
+			name:  "plain key",
+			entry: models.CharacterBookEntry{Keys: []string{"Eldoria"}},
+			texts: []string{"we walked into eldoria at dawn"},
+			want:  true,
+		},
+		{
+			name:  "whole word only",
+			entry: models.CharacterBookEntry{Keys: []string{"cat"}},
+			texts: []string{"concatenate the strings"},
+			want:  false,
+		},
+		{
+			name:  "case sensitive",
+			entry: models.CharacterBookEntry{Keys: []string{"Rose"}, CaseSensitive: boolPtr(true)},
+			texts: []string{"a rose in the garden"},
+			want:  false,
+		},
+		{
+			name:  "non ascii key",
+			entry: models.CharacterBookEntry{Keys: []string{"Лес"}},
+			texts: []string{"мы вошли в лес"},
+			want:  true,
+		},
+		{
+			name:  "regex entry",
+			entry: models.CharacterBookEntry{Keys: []string{`dragons?\b`}, UseRegex: true},
+			texts: []string{"Two Dragons flew over"},
+			want:  true,
+		},
+		{
+			name:  "slash regex is case sensitive without i",
+			entry: models.CharacterBookEntry{Keys: []string{`/^King/`}},
+			texts: []string{"king arthur"},
+			want:  false,
+		},
+		{
+			name:  "slash regex with i flag",
+			entry: models.CharacterBookEntry{Keys: []string{`/^King/i`}},
+			texts: []string{"king arthur"},
+			want:  true,
+		},
+		{
+			name:  "invalid regex does not match",
+			entry: models.CharacterBookEntry{Keys: []string{`([`}, UseRegex: true},
+			texts: []string{"(["},
+			want:  false,
+		},
+		{
+			name: "selective needs secondary key",
+			entry: models.CharacterBookEntry{
+				Keys: []string{"sword"}, SecondaryKeys: []string{"forge"}, Selective: true,
+			},
+			texts: []string{"he drew his sword"},
+			want:  false,
+		},
+		{
+			name: "selective with secondary key",
+			entry: models.CharacterBookEntry{
+				Keys: []string{"sword"}, SecondaryKeys: []string{"forge"}, Selective: true,
+			},
+			texts: []string{"the sword was made in the forge"},
+			want:  true,
+		},
+		{
+			name:  "secondary keys ignored if not selective",
+			entry: models.CharacterBookEntry{Keys: []string{"sword"}, SecondaryKeys: []string{"forge"}},
+			texts: []string{"he drew his sword"},
+			want:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Triggers(&tt.entry, tt.texts); got != tt.want {
+				t.Errorf("Triggers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func entryContents(activated []Activated) []string {
+	resp := []string{}
+	for _, a := range activated {
+		resp = append(resp, a.Entry.Content)
+	}
+	return resp
+}
+
+func TestScan(t *testing.T) {
+	messages := []string{
+		"the tower stands in the north",
+		"nothing here",
+		"nothing here either",
+		"let us go to the river",
+	}
+	tests := []struct {
+		name string
+		book models.CharacterBook
+		opts Options
+		want []string
+	}{
+		{
+			name: "constant and disabled entries",
+			book: models.CharacterBook{Entries: []models.CharacterBookEntry{
+				{Content: "always", Constant: true, Enabled: true},
+				{Keys: []string{"river"}, Content: "disabled", Enabled: false},
+			}},
+			want: []string{"always"},
+		},
+		{
+			name: "scan depth from options",
+			book: models.CharacterBook{Entries: []models.CharacterBookEntry{
+				{Keys: []string{"tower"}, Content: "tower", Enabled: true},
+				{Keys: []string{"river"}, Content: "river", Enabled: true},
+			}},
+			opts: Options{ScanDepth: 2},
+			want: []string{"river"},
+		},
+		{
+			name: "book scan depth overrides options",
+			book: models.CharacterBook{ScanDepth: intPtr(10), Entries: []models.CharacterBookEntry{
+				{Keys: []string{"tower"}, Content: "tower", Enabled: true},
+			}},
+			opts: Options{ScanDepth: 1},
+			want: []string{"tower"},
+		},
+		{
+			name: "entry scan depth",
+			book: models.CharacterBook{Entries: []models.CharacterBookEntry{
+				{Keys: []string{"tower"}, Content: "tower", Enabled: true, Extensions: map[string]any{"scan_depth": float64(4)}},
+			}},
+			opts: Options{ScanDepth: 1},
+			want: []string{"tower"},
+		},
+		{
+			name: "insertion order",
+			book: models.CharacterBook{Entries: []models.CharacterBookEntry{
+				{Keys: []string{"river"}, Content: "second", Enabled: true, InsertionOrder: 20},
+				{Keys: []string{"river"}, Content: "first", Enabled: true, InsertionOrder: 10},
+			}},
+			want: []string{"first", "second"},
+		},
+		{
+			name: "recursive scanning",
+			book: models.CharacterBook{RecursiveScanning: true, Entries: []models.CharacterBookEntry{
+				{Keys: []string{"ferryman"}, Content: "ferryman", Enabled: true, InsertionOrder: 2},
+				{Keys: []string{"river"}, Content: "the ferryman guards the river", Enabled: true, InsertionOrder: 1},
+			}},
+			want: []string{"the ferryman guards the river", "ferryman"},
+		},
+		{
+			name: "no recursion by default",
+			book: models.CharacterBook{Entries: []models.CharacterBookEntry{
+				{Keys: []string{"ferryman"}, Content: "ferryman", Enabled: true},
+				{Keys: []string{"river"}, Content: "the ferryman guards the river", Enabled: true},
+			}},
+			want: []string{"the ferryman guards the river"},
+		},
+		{
+			name: "token budget drops low priority",
+			book: models.CharacterBook{TokenBudget: intPtr(12), Entries: []models.CharacterBookEntry{
+				{Keys: []string{"river"}, Content: strings.Repeat("a", 40), Enabled: true, Priority: 1, InsertionOrder: 1},
+				{Keys: []string{"river"}, Content: strings.Repeat("b", 40), Enabled: true, Priority: 5, InsertionOrder: 2},
+			}},
+			want: []string{strings.Repeat("b", 40)},
+		},
+		{
+			name: "global token budget",
+			book: models.CharacterBook{Entries: []models.CharacterBookEntry{
+				{Keys: []string{"river"}, Content: strings.Repeat("a", 40), Enabled: true, Priority: 5},
+				{Keys: []string{"river"}, Content: strings.Repeat("b", 40), Enabled: true, Priority: 1},
+			}},
+			opts: Options{TokenBudget: 10},
+			want: []string{strings.Repeat("a", 40)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := entryContents(Scan([]*Book{{CharacterBook: &tt.book}}, messages, tt.opts))
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Scan() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadWorldInfo(t *testing.T) {
+	dir := t.TempDir()
+	wi := `{"entries": {
+		"1": {"uid": 1, "key": ["castle"], "content": "castle lore", "order": 5, "position": 1, "scanDepth": 2},
+		"0": {"uid": 0, "key": ["moat"], "content": "moat lore", "disable": true}
+	}}`
+	if err := os.WriteFile(filepath.Join(dir, "world.json"), []byte(wi), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	books, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+	if len(books) != 1 || books[0].Name != "world" || len(books[0].Entries) != 2 {
+		t.Fatalf("unexpected books: %+v", books)
+	}
+	e := books[0].Entries[1]
+	if e.Content != "castle lore" || e.Position != PositionAfterChar || e.InsertionOrder != 5 || !e.Enabled {
+		t.Errorf("entry was not converted: %+v", e)
+	}
+	if books[0].Entries[0].Enabled {
+		t.Errorf("disabled entry should not be enabled")
+	}
+	// saved in character_book format and read back
+	path := filepath.Join(dir, "saved.json")
+	if err := books[0].Save(path); err != nil {
+		t.Fatal(err)
+	}
+	b, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Entries) != 2 || b.Entries[1].Content != "castle lore" {
+		t.Errorf("unexpected saved book: %+v", b)
+	}
+	if d, ok := entryScanDepth(&b.Entries[1]); !ok || d != 2 {
+		t.Errorf("entry scan depth was lost: %v", b.Entries[1].Extensions)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"gf-lt/lorebook"
+	"gf-lt/models"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	lorebookPage     = "lorebookPage"
+	lorebookEditPage = "lorebookEdit"
+)
+
+type loreRow struct {
+	book *lorebook.Book
+	card *models.CharCard // owner of the card book; nil for standalone books
+	idx  int
+}
+
+// makeLorebookTable lists entries of the current card book and standalone lorebooks.
+// Enter edits the cell, 'a' adds an entry, 's' saves changed books, 'x' exits.
+func makeLorebookTable() *tview.Table {
+	headers := []string{"Book", "Keys", "Secondary keys", "Content", "Order", "Priority", "Position", "Enabled", "Constant", "Selective", "delete"}
+	table := tview.NewTable().SetBorders(true)
+	table.SetTitle("Lorebook (enter: edit, a: add entry, s: save, x: exit)").SetBorder(true)
+	cc := GetCardByRole(cfg.AssistantRole)
+	cardBook := lorebook.FromCard(cc)
+	dirty := make(map[*lorebook.Book]*models.CharCard)
+	rows := []loreRow{}
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	preview := func(s string, limit int) string {
+		s = strings.ReplaceAll(s, "\n", " ")
+		if len([]rune(s)) > limit {
+			s = string([]rune(s)[:limit]) + "..."
+		}
+		return tview.Escape(s)
+	}
+	fill := func() {
+		table.Clear()
+		rows = rows[:0]
+		for c, h := range headers {
+			table.SetCell(0, c,
+				tview.NewTableCell(h).
+					SetSelectable(false).
+					SetTextColor(tcell.ColorYellow).
+					SetAlign(tview.AlignCenter).
+					SetAttributes(tcell.AttrBold))
+		}
+		if cardBook != nil {
+			for i := range cardBook.Entries {
+				rows = append(rows, loreRow{book: cardBook, card: cc, idx: i})
+			}
+		}
+		for _, b := range loreBooks {
+			for i := range b.Entries {
+				rows = append(rows, loreRow{book: b, idx: i})
+			}
+		}
+		for r, row := range rows {
+			e := &row.book.Entries[row.idx]
+			bookName := row.book.Name
+			if row.card != nil {
+				bookName = "card: " + row.card.Role
+			}
+			if bookName == "" {
+				bookName = row.book.Path
+			}
+			position := e.Position
+			if position == "" {
+				position = lorebook.PositionBeforeChar
+			}
+			cells := []string{
+				preview(bookName, 20),
+				preview(strings.Join(e.Keys, ", "), 30),
+				preview(strings.Join(e.SecondaryKeys, ", "), 30),
+				preview(e.Content, 40),
+				strconv.Itoa(e.InsertionOrder),
+				strconv.Itoa(e.Priority),
+				position,
+				yesNo(e.Enabled),
+				yesNo(e.Constant),
+				yesNo(e.Selective),
+				"delete",
+			}
+			for c, text := range cells {
+				table.SetCell(r+1, c,
+					tview.NewTableCell(text).
+						SetSelectable(c > 0).
+						SetTextColor(tcell.ColorWhite).
+						SetAlign(tview.AlignCenter))
+			}
+		}
+	}
+	fill()
+	modal := func(p tview.Primitive, width, height int) tview.Primitive {
+		return tview.NewFlex().
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+				AddItem(nil, 0, 1, false).
+				AddItem(p, height, 1, true).
+				AddItem(nil, 0, 1, false), width, 1, true).
+			AddItem(nil, 0, 1, false)
+	}
+	// editText opens an input (or text area for multiline) and calls done with the new value
+	editText := func(title, value string, multiline bool, done func(string)) {
+		finish := func(text string) {
+			done(text)
+			pages.RemovePage(lorebookEditPage)
+			fill()
+			app.SetFocus(table)
+		}
+		if multiline {
+			area := tview.NewTextArea()
+			area.SetText(value, true)
+			area.SetTitle(title + " (esc to save)").SetBorder(true)
+			area.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+				if event.Key() == tcell.KeyEscape {
+					finish(area.GetText())
+					return nil
+				}
+				return event
+			})
+			pages.AddPage(lorebookEditPage, modal(area, 100, 20), true, true)
+			app.SetFocus(area)
+			return
+		}
+		input := tview.NewInputField().SetText(value)
+		input.SetTitle(title).SetBorder(true)
+		input.SetDoneFunc(func(key tcell.Key) {
+			if key == tcell.KeyEnter {
+				finish(input.GetText())
+				return
+			}
+			pages.RemovePage(lorebookEditPage)
+			app.SetFocus(table)
+		})
+		pages.AddPage(lorebookEditPage, modal(input, 80, 3), true, true)
+		app.SetFocus(input)
+	}
+	splitKeys := func(s string) []string {
+		keys := []string{}
+		for _, k := range strings.Split(s, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				keys = append(keys, k)
+			}
+		}
+		return keys
+	}
+	table.Select(1, 1).SetSelectable(true, true).SetFixed(1, 1)
+	table.SetSelectedFunc(func(r, c int) {
+		if r == 0 || r > len(rows) {
+			return
+		}
+		row := rows[r-1]
+		e := &row.book.Entries[row.idx]
+		dirty[row.book] = row.card
+		switch headers[c] {
+		case "Keys":
+			editText("keys (comma separated)", strings.Join(e.Keys, ", "), false, func(s string) {
+				e.Keys = splitKeys(s)
+			})
+		case "Secondary keys":
+			editText("secondary keys (comma separated)", strings.Join(e.SecondaryKeys, ", "), false, func(s string) {
+				e.SecondaryKeys = splitKeys(s)
+			})
+		case "Content":
+			editText("content", e.Content, true, func(s string) {
+				e.Content = s
+			})
+		case "Order", "Priority":
+			field := &e.InsertionOrder
+			if headers[c] == "Priority" {
+				field = &e.Priority
+			}
+			editText(strings.ToLower(headers[c]), strconv.Itoa(*field), false, func(s string) {
+				n, err := strconv.Atoi(strings.TrimSpace(s))
+				if err != nil {
+					showToast("lorebook", "not a number: "+s)
+					return
+				}
+				*field = n
+			})
+		case "Position":
+			if e.Position == lorebook.PositionAfterChar {
+				e.Position = lorebook.PositionBeforeChar
+			} else {
+				e.Position = lorebook.PositionAfterChar
+			}
+			fill()
+		case "Enabled":
+			e.Enabled = !e.Enabled
+			fill()
+		case "Constant":
+			e.Constant = !e.Constant
+			fill()
+		case "Selective":
+			e.Selective = !e.Selective
+			fill()
+		case "delete":
+			row.book.Entries = append(row.book.Entries[:row.idx], row.book.Entries[row.idx+1:]...)
+			fill()
+			if r > len(rows) {
+				table.Select(max(len(rows), 1), c)
+			}
+		}
+	})
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() != tcell.KeyRune {
+			return event
+		}
+		switch event.Rune() {
+		case 'x':
+			if len(dirty) > 0 {
+				showToast("lorebook", "unsaved changes are kept until restart; press s to save")
+			}
+			pages.RemovePage(lorebookPage)
+			app.SetFocus(textArea)
+			return nil
+		case 'a':
+			// add to the book of the selected row; card book by default
+			r, _ := table.GetSelection()
+			var book *lorebook.Book
+			var card *models.CharCard
+			if r > 0 && r <= len(rows) {
+				book, card = rows[r-1].book, rows[r-1].card
+			} else {
+				if cc == nil {
+					showToast("lorebook", "no card to add entry to")
+					return nil
+				}
+				if cardBook == nil {
+					cc.CharacterBook = &models.CharacterBook{Extensions: map[string]any{}}
+					cardBook = lorebook.FromCard(cc)
+				}
+				book, card = cardBook, cc
+			}
+			book.Entries = append(book.Entries, models.CharacterBookEntry{
+				Keys:       []string{},
+				Enabled:    true,
+				Position:   lorebook.PositionBeforeChar,
+				Extensions: map[string]any{},
+			})
+			dirty[book] = card
+			fill()
+			for i, row := range rows {
+				if row.book == book && row.idx == len(book.Entries)-1 {
+					table.Select(i+1, 1)
+				}
+			}
+			return nil
+		case 's':
+			saved := 0
+			for b, card := range dirty {
+				if err := saveLoreBook(b, card); err != nil {
+					logger.Error("failed to save lorebook", "book", b.Name, "error", err)
+					showToast("lorebook", "failed to save: "+err.Error())
+					continue
+				}
+				delete(dirty, b)
+				saved++
+			}
+			showToast("lorebook", fmt.Sprintf("saved %d book(s)", saved))
+			return nil
+		}
+		return event
+	})
+	return table
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"gf-lt/chatio"
 	"gf-lt/mcp"
 	"gf-lt/mission"
 	"gf-lt/models"
@@ -49,11 +50,22 @@ var (
 	cliCardPath              string
 	cliContinue              bool
 	cliMsg                   string
+	cliVoice                 bool
 	mcpManager               *mcp.Manager
 	missionResumeFile        string
 	missionAgentCard         string
 	missionIssueID           string
 	missionCheckpoint        string
+	mcpServeAddr             string
+	missionWorkdir           string
+	missionLabels            string
+	issuesImport             string
+	issuesExport             string
+	issuesFormat             string
+	missionTracePath         string
+	pmTriggers               string
+	traceReplay              string
+	traceSummary             string
 	missionSummarizeFailures int // track consecutive summarization failures
 	cliExitCode              int
 )
@@ -66,26 +78,57 @@ func main() {
 	flag.StringVar(&cliCardPath, "card", "", "Path to syscard JSON file")
 	flag.BoolVar(&cliContinue, "continue", false, "Continue from last chat (by agent or card)")
 	flag.StringVar(&cliMsg, "msg", "", "Send message and exit (one-shot mode)")
+	flag.BoolVar(&cliVoice, "voice", false, "Start in voice conversation mode (needs STT, TTS is optional)")
 	flag.BoolVar(&cfg.MissionMode, "mission", false, "Run in mission mode (auto issue solver)")
 	flag.StringVar(&missionIssueID, "issue-id", "", "Issue ID to process in mission mode")
 	flag.StringVar(&missionAgentCard, "agent-card", "", "Path to agent card for mission mode")
 	flag.StringVar(&missionResumeFile, "resume", "", "Resume mission from checkpoint file")
 	flag.StringVar(&missionCheckpoint, "checkpoint-file", "", "Custom checkpoint file path")
 	flag.IntVar(&cfg.MissionPMInterval, "pm-interval", 75, "PM check-in interval (tool calls)")
+	flag.StringVar(&pmTriggers, "pm-triggers", "", "Comma separated PM check-in triggers: interval, loop, no_diff, test_failures, failures (default: MissionPMTriggers from config, or all)")
+	flag.StringVar(&cfg.MissionPMModel, "pm-model", cfg.MissionPMModel, "Model of the PM supervisor (default: the agent model)")
+	flag.StringVar(&cfg.MissionPMAPI, "pm-api", cfg.MissionPMAPI, "API endpoint of the PM supervisor (default: the agent API)")
 	flag.IntVar(&cfg.MissionMaxFailures, "max-failures", 3, "Max consecutive failures before abort")
+	flag.IntVar(&cfg.MissionMaxTokens, "max-tokens", cfg.MissionMaxTokens, "Mission fails once its replies used more tokens (default: MissionMaxTokens from config, 0 is no limit)")
+	flag.Float64Var(&cfg.MissionMaxCost, "max-cost", cfg.MissionMaxCost, "Mission fails once its replies cost more USD (default: MissionMaxCost from config, 0 is no limit)")
 	flag.StringVar(&cfg.OutputFormat, "output", "text", "Output format: text (streaming) or json (non-streaming, complete response)")
 	flag.BoolVar(&cfg.MissionQuiet, "quiet", false, "Suppress tool call logging in mission mode")
 	flag.BoolVar(&cfg.MissionToolsEnabled, "mission-tools", false, "Enable mission tools (move_issue, create_pr, etc.) in non-mission mode")
+	flag.BoolVar(&cfg.MissionQueue, "queue", false, "Run missions for all open issues by priority (implies -mission)")
+	flag.IntVar(&cfg.MissionParallel, "parallel", cfg.MissionParallel, "Missions running at once in -queue mode, each in its own git worktree")
+	flag.StringVar(&missionLabels, "labels", "", "Comma separated labels: -queue runs only issues with one of them, in this order (default: MissionLabels from config)")
+	flag.BoolVar(&cfg.MissionWorktrees, "worktrees", cfg.MissionWorktrees, "Run every -queue mission in its own git worktree, also when they run one by one")
+	flag.StringVar(&missionWorkdir, "workdir", "", "Work in this directory instead of the issue project_path (set by -queue for worktrees)")
 	flag.StringVar(&cfg.IssuesDir, "issues-dir", "auto", "Directory containing issues (default: ./issues, overridden by GF_LT_ISSUES_DIR env if set)")
+	flag.StringVar(&issuesImport, "import-issues", "", "Import issues from a GitHub/GitLab/Gitea issue JSON dump or Markdown file (or a directory of them) into the issues dir and exit")
+	flag.StringVar(&issuesExport, "export-issues", "", "Export all issues of the issues dir as Markdown files into this directory (or a JSON dump with -issues-format) and exit")
+	flag.StringVar(&issuesFormat, "issues-format", "auto", "Format of -import-issues/-export-issues: auto, github, gitlab, gitea or markdown")
+	flag.StringVar(&missionTracePath, "trace", "", "Trace file of the mission steps (default: checkpoint file with -trace.jsonl suffix)")
+	flag.StringVar(&traceReplay, "replay", "", "Step through a mission trace file in the TUI and exit")
+	flag.StringVar(&traceSummary, "trace-summary", "", "Print time, tokens, cost and errors per tool of a mission trace file and exit")
 	flag.StringVar(&cfg.CurrentAPI, "api", "", "Override API endpoint (default: from config.toml)")
+	flag.BoolVar(&cfg.MCPServeMode, "mcp-serve", false, "Serve gf-lt tools, rag documents and chats as an MCP server (stdio, or http with -mcp-addr)")
+	flag.StringVar(&mcpServeAddr, "mcp-addr", "", "Address of the MCP http server, e.g. :8099 for 127.0.0.1:8099 (default: MCPServeAddr from config, empty is stdio)")
 	flag.Parse()
 	// Restore config.toml ChatAPI if --api flag wasn't explicitly set
 	if cfg.CurrentAPI == "" {
 		cfg.CurrentAPI = cfg.ChatAPI
 	}
+	if cfg.MissionQueue {
+		cfg.MissionMode = true
+	}
+	if missionLabels != "" {
+		cfg.MissionLabels = strings.Split(missionLabels, ",")
+	}
+	if pmTriggers != "" {
+		cfg.MissionPMTriggers = strings.Split(pmTriggers, ",")
+	}
 	if cfg.MissionMode {
 		cfg.CLIMode = true
 	}
+	if cliVoice {
+		cfg.VOICE_MODE = true
+	}
 	// Priority: -model flag > GF_LT_MODEL env > "auto"
 	if cfg.CurrentModel == "auto" {
 		if envModel := os.Getenv("GF_LT_MODEL"); envModel != "" {
@@ -100,14 +143,44 @@ func main() {
 			cfg.IssuesDir = "./issues"
 		}
 	}
+	if issuesImport != "" || issuesExport != "" {
+		runIssuesImportExport()
+		return
+	}
+	if traceSummary != "" {
+		runTraceSummary(traceSummary)
+		return
+	}
+	if traceReplay != "" {
+		runTraceReplay(traceReplay)
+		return
+	}
 	chatBody.Model = cfg.CurrentModel
 	tools.InitTools(cfg, logger, store)
+	tools.SetTokenFunc(func() string {
+		if chunkParser == nil {
+			return ""
+		}
+		return chunkParser.GetToken()
+	})
+	tools.SetSubAgentObserver(onSubAgentStep)
+	if cfg.MCPServeMode {
+		runMCPServeMode()
+		return
+	}
+	// queue runner only starts mission processes
+	if cfg.MissionQueue {
+		runMissionQueue()
+		return
+	}
 	if cfg.ToolUse && len(cfg.MCPServers) > 0 {
 		mcpManager = mcp.NewManager(cfg, logger)
+		mcpManager.SetResourceUpdatedHandler(onMCPResourceUpdated)
 		if err := mcpManager.ConnectAll(context.Background()); err != nil {
 			logger.Error("failed to connect to MCP servers", "error", err)
 		} else {
 			mcpManager.RegisterToolHandlers(tools.FnMap)
+			tools.RegisterToolResolver(mcpManager.ToolHandler)
 		}
 	}
 	_ = mcpManager
@@ -128,6 +201,11 @@ func main() {
 	}
 	initTUI()
 	go updateModelLists()
+	if cfg.VOICE_MODE {
+		if err := startVoiceMode(); err != nil {
+			logger.Warn("failed to start voice mode", "error", err)
+		}
+	}
 	pages.AddPage("main", flex, true, true)
 	if err := app.SetRoot(pages,
 		true).EnableMouse(cfg.EnableMouse).EnablePaste(true).Run(); err != nil {
@@ -146,7 +224,11 @@ func setupSignalHandler() {
 		if m := tools.GetCurrentMission(); m != nil {
 			issueID = m.Issue.ID
 			m.Status = mission.StatusAborted
-			m.SaveCheckpoint(mission.DefaultCheckpointPath())
+			checkpointPath := missionCheckpoint
+			if checkpointPath == "" {
+				checkpointPath = mission.DefaultCheckpointPath()
+			}
+			m.SaveCheckpoint(checkpointPath)
 		}
 		exportMissionChat(issueID)
 		code := 130 // 128 + SIGINT(2)
@@ -216,6 +298,13 @@ func runCLIMode() {
 		}
 		return
 	}
+	if cfg.VOICE_MODE {
+		if err := startVoiceMode(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start voice mode: %v\n", err)
+		} else {
+			fmt.Println("Voice mode is on, speak to send a message (/voice to turn off).")
+		}
+	}
 	scanner := bufio.NewScanner(os.Stdin)
 	for {
 		fmt.Print("> ")
@@ -226,7 +315,13 @@ func runCLIMode() {
 		if msg == "" {
 			continue
 		}
-		if strings.HasPrefix(msg, "/") {
+		if expanded, ok, err := expandMCPPrompt(msg); ok {
+			if err != nil {
+				fmt.Printf("MCP prompt: %v\n\n", err)
+				continue
+			}
+			msg = expanded
+		} else if strings.HasPrefix(msg, "/") {
 			if !handleCLICommand(msg) {
 				return
 			}
@@ -237,6 +332,11 @@ func runCLIMode() {
 		if cfg.WriteNextMsgAs != "" {
 			persona = cfg.WriteNextMsgAs
 		}
+		// rounds started by voice also signal cliRespDone
+		select {
+		case <-cliRespDone:
+		default:
+		}
 		chatRoundChan <- &models.ChatRoundReq{Role: persona, UserMsg: msg}
 		<-cliRespDone
 		fmt.Println()
@@ -254,12 +354,21 @@ func printCLIHelp() {
 	fmt.Println("  /help, /h              - Show this help message")
 	fmt.Println("  /new, /n               - Start a new chat (clears conversation)")
 	fmt.Println("  /card <path>, /c <path> - Load a different syscard")
+	fmt.Println("  /greeting [n]          - List card greetings or start with greeting n")
 	fmt.Println("  /undo, /u              - Delete last message")
 	fmt.Println("  /history, /ls          - List chat sessions")
 	fmt.Println("  /hs [index]            - Show chat history (messages)")
 	fmt.Println("  /load <name>           - Load a specific chat by name")
+	fmt.Println("  /search <query>        - Search messages of all chats")
+	fmt.Println("  /export [format]       - Export chat (json, md, html, jsonl)")
+	fmt.Println("  /import <path>         - Import chat file (json, SillyTavern jsonl, role: text)")
 	fmt.Println("  /model <name>, /m <name> - Switch model")
 	fmt.Println("  /api <index>, /a <index>  - Switch API link (no index to list)")
+	fmt.Println("  /voice, /v             - Toggle voice conversation mode (needs STT)")
+	fmt.Println("  /group [strategy]      - Show or set group chat next speaker strategy")
+	fmt.Println("  /mute <name>           - Mute or unmute char in group chat")
+	fmt.Println("  /prompts               - List MCP prompts")
+	fmt.Println("  /server:prompt [args]  - Send expanded MCP prompt (args as key=value)")
 	fmt.Println("  /quit, /q, /exit       - Exit CLI mode")
 	fmt.Println()
 	fmt.Printf("Current syscard: %s\n", cfg.AssistantRole)
@@ -296,6 +405,61 @@ func handleCLICommand(msg string) bool {
 		charToStart(card.Role, false)
 		startNewCLIChat()
 		fmt.Printf("Switched to syscard: %s (%s)\n", card.Role, card.FilePath)
+	case "/greeting":
+		cc := GetCardByRole(cfg.AssistantRole)
+		if cc == nil {
+			fmt.Println("No card loaded.")
+			return true
+		}
+		if len(args) == 0 {
+			for i, g := range cc.Greetings() {
+				fmt.Printf("  [%d] %s\n", i, g)
+			}
+			return true
+		}
+		idx, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Println("Usage: /greeting [n]")
+			return true
+		}
+		if err := setGreeting(cc, idx); err != nil {
+			fmt.Printf("Failed to set greeting: %v\n", err)
+			return true
+		}
+		fmt.Printf("(1) <%s>: %s\n", cc.Role, chatBody.Messages[1].Content)
+	case "/voice", "/v":
+		if err := toggleVoiceMode(); err != nil {
+			fmt.Printf("Voice mode: %v\n", err)
+			return true
+		}
+		if voiceMode.Load() {
+			fmt.Println("Voice mode is on, speak to send a message.")
+		} else {
+			fmt.Println("Voice mode is off.")
+		}
+	case "/group":
+		if len(args) == 0 {
+			fmt.Printf("Group strategy: %s (options: %s)\n", cfg.GroupStrategy, strings.Join(groupStrategies, ", "))
+			fmt.Printf("Muted: %s\n", strings.Join(cfg.GroupMuted, ", "))
+			return true
+		}
+		if !slices.Contains(groupStrategies, args[0]) {
+			fmt.Printf("Unknown strategy %q, options: %s\n", args[0], strings.Join(groupStrategies, ", "))
+			return true
+		}
+		cfg.GroupStrategy = args[0]
+		fmt.Printf("Group strategy: %s\n", cfg.GroupStrategy)
+	case "/mute":
+		if len(args) == 0 {
+			fmt.Println("Usage: /mute <name>")
+			return true
+		}
+		name := strings.Join(args, " ")
+		if toggleGroupMute(name) {
+			fmt.Printf("%s is muted.\n", name)
+		} else {
+			fmt.Printf("%s is unmuted.\n", name)
+		}
 	case "/undo", "/u":
 		if botRespMode.Load() {
 			fmt.Println("Cannot delete while bot is responding.")
@@ -342,6 +506,46 @@ func handleCLICommand(msg string) bool {
 			fmt.Printf("Warning: card not found for agent: %s\n", chat.Agent)
 		}
 		fmt.Printf("Loaded chat: %s\n", name)
+	case "/export":
+		format := chatio.FormatJSON
+		if len(args) > 0 {
+			format = strings.ToLower(args[0])
+		}
+		fp, err := exportChat(format)
+		if err != nil {
+			fmt.Printf("Failed to export chat: %v\n", err)
+			return true
+		}
+		fmt.Printf("Exported chat to %s\n", fp)
+	case "/import":
+		if len(args) == 0 {
+			fmt.Println("Usage: /import <path>")
+			return true
+		}
+		if err := importChat(strings.Join(args, " ")); err != nil {
+			fmt.Printf("Failed to import chat: %v\n", err)
+			return true
+		}
+		cliPrevOutput = ""
+		fmt.Printf("Imported chat: %s (%d messages, char: %s)\n", activeChatName, len(chatBody.Messages), cfg.AssistantRole)
+	case "/search":
+		if len(args) == 0 {
+			fmt.Println("Usage: /search <query>")
+			return true
+		}
+		hits, err := searchChats(strings.Join(args, " "))
+		if err != nil {
+			fmt.Printf("Search failed: %v\n", err)
+			return true
+		}
+		if len(hits) == 0 {
+			fmt.Println("Nothing found.")
+			return true
+		}
+		for i := range hits {
+			fmt.Printf("%s (%d) <%s>: %s\n", hitChatName(&hits[i]), hits[i].MsgIdx, hits[i].Role, hits[i].Snippet)
+		}
+		fmt.Println("\nUse /load <name> and /hs <index> to read the message.")
 	case "/hs":
 		if len(chatBody.Messages) == 0 {
 			fmt.Println("No messages in current chat.")
@@ -428,6 +632,8 @@ func handleCLICommand(msg string) bool {
 		}
 		cfg.CurrentAPI = cfg.ApiLinks[idx]
 		fmt.Printf("Switched to API: %s\n", cfg.CurrentAPI)
+	case "/prompts":
+		printMCPPrompts()
 	case "/quit", "/q", "/exit":
 		fmt.Println("Goodbye!")
 		return false
@@ -438,6 +644,36 @@ func handleCLICommand(msg string) bool {
 	return true
 }
 
+// runMCPServeMode serves gf-lt tools to other mcp clients; stdout belongs to the protocol in stdio mode
+func runMCPServeMode() {
+	// tools asking the tui for confirmation (delegate) are denied; served tools ask the mcp client
+	go func() {
+		for req := range tools.ConfirmChan {
+			logger.Warn("confirmation is not available in mcp serve mode, denied", "tool", req.ToolName, "command", req.Command)
+			req.Result <- false
+		}
+	}()
+	serveCtx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	server := mcp.NewServer(cfg, logger, store)
+	addr := cfg.MCPServeAddr
+	if mcpServeAddr != "" {
+		addr = mcpServeAddr
+	}
+	var err error
+	if addr == "" {
+		logger.Info("serving MCP over stdio")
+		err = server.ServeStdio(serveCtx)
+	} else {
+		fmt.Fprintf(os.Stderr, "Serving MCP (streamable http) on %s\n", addr)
+		err = server.ServeHTTP(serveCtx, addr)
+	}
+	if err != nil && serveCtx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "MCP server failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
 func runMissionMode() {
 	setupSignalHandler()
 	outputHandler = &CLIOutputHandler{}
@@ -492,6 +728,20 @@ func runMissionMode() {
 	if resumeFrom != nil {
 		m.Checkpoint = resumeFrom
 	}
+	triggers, err := mission.NewPMTriggers(cfg.MissionPMTriggers, mission.PMOptions{
+		LoopRepeats:  cfg.MissionPMLoopRepeats,
+		NoDiffCalls:  cfg.MissionPMNoDiffCalls,
+		TestFailures: cfg.MissionPMTestFailures,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid PM triggers: %v\n", err)
+		os.Exit(1)
+	}
+	m.Triggers = triggers
+	m.Budget = mission.Budget{MaxTokens: cfg.MissionMaxTokens, MaxCost: cfg.MissionMaxCost}
+	if missionWorkdir != "" {
+		m.Checkpoint.WorkDir = missionWorkdir
+	}
 	// Load agent card if provided
 	var agentSysprompt string
 	if missionAgentCard != "" {
@@ -515,8 +765,19 @@ func runMissionMode() {
 	if !cfg.MissionQuiet {
 		fmt.Println("\n=== Mission Started ===")
 		fmt.Printf("Issue: %s - %s\n", issue.ID, issue.Title)
-		fmt.Printf("Project: %s\n", issue.ProjectPath)
+		fmt.Printf("Project: %s\n", m.ProjectDir())
 		fmt.Printf("PM Interval: %d tool calls\n", cfg.MissionPMInterval)
+		triggerNames := make([]string, len(m.Triggers))
+		for i, t := range m.Triggers {
+			triggerNames[i] = t.Name()
+		}
+		fmt.Printf("PM Triggers: %s\n", strings.Join(triggerNames, ", "))
+		if cfg.MissionPMModel != "" || cfg.MissionPMAPI != "" {
+			fmt.Printf("PM Model: %s %s\n", cfg.MissionPMModel, cfg.MissionPMAPI)
+		}
+		if cfg.MissionMaxTokens > 0 || cfg.MissionMaxCost > 0 {
+			fmt.Printf("Budget: %d tokens, $%.2f (0 is no limit)\n", cfg.MissionMaxTokens, cfg.MissionMaxCost)
+		}
 		fmt.Printf("Max Failures: %d\n\n", cfg.MissionMaxFailures)
 	}
 	runMission(m, checkpointPath, agentSysprompt)
@@ -529,6 +790,15 @@ func runMission(m *mission.Mission, checkpointPath string, agentSysprompt string
 	if err := m.SaveCheckpoint(checkpointPath); err != nil {
 		m.Log("Warning: failed to save initial checkpoint: %v", err)
 	}
+	if missionTracePath == "" {
+		missionTracePath = mission.TracePath(checkpointPath)
+	}
+	if trace, err := mission.OpenTrace(missionTracePath, m.Issue.ID); err != nil {
+		m.Log("Warning: mission steps are not traced: %v", err)
+	} else {
+		m.Trace = trace
+		m.Trace.Record(mission.TraceEvent{Kind: mission.TraceStart, Model: cfg.CurrentModel, Text: m.Issue.Title})
+	}
 	tools.SetCurrentMission(m)
 	// Set token callback for agent-based LLM calls (PM agent, etc.)
 	tools.SetTokenFunc(func() string {
@@ -542,11 +812,11 @@ func runMission(m *mission.Mission, checkpointPath string, agentSysprompt string
 	tools.InitPMAgent(cfg, logger)
 	startNewCLIChat()
 	// Set working directory to issue's project path
-	if m.Issue.ProjectPath != "" {
-		if err := tools.SetFSCwd(m.Issue.ProjectPath); err != nil {
-			m.Log("Warning: failed to set CWD to %s: %v", m.Issue.ProjectPath, err)
+	if projectDir := m.ProjectDir(); projectDir != "" {
+		if err := tools.SetFSCwd(projectDir); err != nil {
+			m.Log("Warning: failed to set CWD to %s: %v", projectDir, err)
 		} else {
-			m.Log("Working directory set to: %s (FilePickerDir=%s)", m.Issue.ProjectPath, tools.GetFSRoot())
+			m.Log("Working directory set to: %s (FilePickerDir=%s)", projectDir, tools.GetFSRoot())
 		}
 	}
 	// Build initial system message with agent prompt + issue context
@@ -554,13 +824,23 @@ func runMission(m *mission.Mission, checkpointPath string, agentSysprompt string
 	if wf, err := os.ReadFile("docs/issue-workflow.md"); err == nil {
 		workflowDocs = "\n\n## Issue Workflow Guidelines:\n" + string(wf)
 	}
+	if m.Checkpoint.WorkDir != "" {
+		workflowDocs += fmt.Sprintf("\n\nYou work in a git worktree with branch %s already checked out; commit there and do not switch branches.", m.Issue.BranchName)
+	}
+	if len(m.Issue.Checks) > 0 {
+		checks := make([]string, len(m.Issue.Checks))
+		for i, c := range m.Issue.Checks {
+			checks[i] = c.Describe()
+		}
+		workflowDocs += "\n\nChecks that create_pr runs in the project path; it refuses the PR while any of them fails:\n- " + strings.Join(checks, "\n- ")
+	}
 	systemMsg := fmt.Sprintf(
 		"%s\n\n## Current Issue\n\nIssue ID: %s\nTitle: %s\n\nDescription:\n%s\n\nProject path: %s\n\nAcceptance criteria:\n- %s%s",
 		agentSysprompt,
 		m.Issue.ID,
 		m.Issue.Title,
 		m.Issue.Description,
-		m.Issue.ProjectPath,
+		m.ProjectDir(),
 		strings.Join(m.Issue.AcceptanceCriteria, "\n- "),
 		workflowDocs,
 	)
@@ -609,11 +889,21 @@ func missionMessageLoop(m *mission.Mission, checkpointPath string, startTime tim
 			// Response complete - check for mission completion signals
 			m.Log("Response complete. Tool calls: %d, Failures: %d",
 				m.Checkpoint.ToolCallCount, m.Checkpoint.ConsecutiveFailures)
+			// budget is checked before the PM check-in, which asks the llm again
+			if reason := m.OverBudget(); reason != "" && m.Status != mission.StatusSuccess {
+				m.Log("Mission over budget, stopping: %s", reason)
+				missionComplete(m, checkpointPath, mission.StatusFailed, startTime)
+				return
+			}
 			// PM check-in — checked before success/abort so guidance can fire mid-mission
 			if m.PMGuidanceNeeded {
 				m.PMGuidanceNeeded = false
-				m.Log("PM check-in triggered at tool call %d", m.Checkpoint.ToolCallCount)
+				m.Log("PM check-in triggered at tool call %d: %s", m.Checkpoint.ToolCallCount, m.PMReason)
 				pmResponse := getPMGuidance(m)
+				if m.StopReason != "" {
+					missionComplete(m, checkpointPath, mission.StatusFailed, startTime)
+					return
+				}
 				m.AddToConversation(cfg.UserRole, fmt.Sprintf("[PM Check-in]\n%s", pmResponse))
 				chatBody.Messages = append(chatBody.Messages, models.RoleMsg{
 					Role: cfg.UserRole, Content: fmt.Sprintf("[PM Check-in]\n%s", pmResponse),
@@ -621,6 +911,11 @@ func missionMessageLoop(m *mission.Mission, checkpointPath string, startTime tim
 				chatRoundChan <- &models.ChatRoundReq{Role: cfg.AssistantRole}
 				continue
 			}
+			// PM ended the mission during a tool call check-in
+			if m.StopReason != "" && m.Status != mission.StatusSuccess {
+				missionComplete(m, checkpointPath, mission.StatusFailed, startTime)
+				return
+			}
 			// Check for create_pr tool completion
 			if m.Status == mission.StatusSuccess {
 				missionComplete(m, checkpointPath, mission.StatusSuccess, startTime)
@@ -658,6 +953,9 @@ func missionMessageLoop(m *mission.Mission, checkpointPath string, startTime tim
 			m.Log("Mission interrupted")
 			m.Status = mission.StatusAborted
 			m.SaveCheckpoint(checkpointPath)
+			m.Trace.Record(mission.TraceEvent{Kind: mission.TraceEnd, Status: string(mission.StatusAborted),
+				DurationMs: time.Since(startTime).Milliseconds(), Error: "interrupted"})
+			m.Trace.Close()
 			return
 		}
 	}
@@ -683,7 +981,16 @@ func summarizeAndCompact() {
 	}
 	toSummarize := messages[:split]
 	toKeep := messages[split:]
+	start := time.Now()
 	summary, err := tools.SummarizeChat(toSummarize)
+	ev := mission.TraceEvent{Time: start, Kind: mission.TraceSummarize, DurationMs: time.Since(start).Milliseconds(),
+		PromptTokens: contextTokens, Messages: split, Text: summary}
+	if err != nil {
+		ev.Error = err.Error()
+	} else if strings.TrimSpace(summary) == "" {
+		ev.Error = "empty summary"
+	}
+	missionTrace().Record(ev)
 	if err != nil || strings.TrimSpace(summary) == "" {
 		missionSummarizeFailures++
 		logger.Warn("context summarization failed, continuing without compression", "error", err, "consecutive_failures", missionSummarizeFailures)
@@ -719,27 +1026,57 @@ func isLastAssistantMsgEmpty() bool {
 	return false
 }
 
+// getPMGuidance asks the PM to review the mission; its decision is applied to the mission
+// and saved as an issue comment, the returned text goes to the agent
 func getPMGuidance(m *mission.Mission) string {
 	ac := "N/A"
 	if len(m.Issue.AcceptanceCriteria) > 0 {
 		ac = "- " + strings.Join(m.Issue.AcceptanceCriteria, "\n- ")
 	}
+	reason := m.PMReason
+	if reason == "" {
+		reason = "check-in"
+	}
+	m.PMReason = ""
+	plan := m.Checkpoint.Plan
+	if plan == "" {
+		plan = "none yet"
+	}
 	msg := fmt.Sprintf(
 		"You are the project manager. Address the coding agent directly as \"you\".\n"+
 			"Give clear, imperative instructions: tell it exactly what to check, fix, or do next.\n\n"+
 			"Issue: %s (%s)\n"+
 			"Description: %s\n"+
 			"Acceptance criteria:\n%s\n"+
-			"Branch: %s\nTool calls so far: %d\nCommits: %v\nConsecutive failures: %d\n\n"+
+			"Branch: %s\nTool calls so far: %d\nCommits: %v\nConsecutive failures: %d\n"+
+			"Check-in reason: %s\nCurrent plan:\n%s\n\n"+
 			"Review what the agent has done. What should it do now?\n"+
-			"Be direct. For example: \"Run the tests now\", \"Check that main.go handles the error\", \"Commit your changes\", \"You missed the empty input case — add it.\"",
+			"Be direct. For example: \"Run the tests now\", \"Check that main.go handles the error\", \"Commit your changes\", \"You missed the empty input case — add it.\"\n\n%s",
 		m.Issue.Title, m.Issue.ID, m.Issue.Description,
 		ac,
 		m.Issue.BranchName,
 		m.Checkpoint.ToolCallCount, m.Checkpoint.CommitsMade,
 		m.Checkpoint.ConsecutiveFailures,
+		reason, plan,
+		mission.PMReplyFormat,
 	)
-	return tools.PMAgentChat(msg)
+	start := time.Now()
+	reply := tools.PMAgentChat(msg)
+	decision := mission.ParsePMDecision(reply)
+	m.ApplyPMDecision(reason, decision)
+	m.Trace.Record(mission.TraceEvent{Time: start, Kind: mission.TracePM, DurationMs: time.Since(start).Milliseconds(),
+		Status: decision.Action, Text: reply})
+	if decision.Action == mission.PMStop {
+		m.Log("PM stopped the mission: %s", decision.Reason)
+	}
+	guidance := decision.Guidance
+	if decision.Action == mission.PMReplan {
+		guidance = strings.TrimSpace(guidance + "\n\nNew plan, follow it:\n" + decision.Plan)
+	}
+	if guidance == "" {
+		guidance = reply
+	}
+	return guidance
 }
 
 func exportMissionChat(issueID string) {
@@ -768,6 +1105,8 @@ func missionComplete(m *mission.Mission, checkpointPath string, status mission.M
 	// Save final checkpoint
 	m.Status = status
 	m.SaveCheckpoint(checkpointPath)
+	m.Trace.Record(mission.TraceEvent{Kind: mission.TraceEnd, Status: string(status), DurationMs: duration.Milliseconds()})
+	m.Trace.Close()
 	// Move issue to appropriate status
 	switch status {
 	case mission.StatusSuccess:
@@ -781,6 +1120,15 @@ func missionComplete(m *mission.Mission, checkpointPath string, status mission.M
 		fmt.Printf("Tool calls: %d\n", m.Checkpoint.ToolCallCount)
 		fmt.Printf("Commits: %d\n", len(m.Checkpoint.CommitsMade))
 		fmt.Printf("Duration: %s\n", duration)
+		if m.StopReason != "" {
+			fmt.Printf("Stopped by PM: %s\n", m.StopReason)
+		}
+		if m.Checkpoint.Tokens > 0 {
+			fmt.Printf("Usage: %d tokens, $%.4f\n", m.Checkpoint.Tokens, m.Checkpoint.Cost)
+		}
+		if reason := m.OverBudget(); reason != "" {
+			fmt.Printf("Over budget: %s\n", reason)
+		}
 	}
 	if cfg.OutputFormat == "json" {
 		result := mission.MissionResult{
@@ -790,6 +1138,17 @@ func missionComplete(m *mission.Mission, checkpointPath string, status mission.M
 			Commits:    m.Checkpoint.CommitsMade,
 			ToolCalls:  m.Checkpoint.ToolCallCount,
 			Duration:   duration,
+			Checks:     m.Checkpoint.CheckResults,
+			Tokens:     m.Checkpoint.Tokens,
+			Cost:       m.Checkpoint.Cost,
+		}
+		if m.StopReason != "" {
+			result.Error = fmt.Errorf("stopped by PM: %s", m.StopReason)
+		} else if reason := m.OverBudget(); reason != "" && status != mission.StatusSuccess {
+			result.Error = fmt.Errorf("over budget: %s", reason)
+		}
+		if m.Trace != nil {
+			result.Trace = missionTracePath
 		}
 		fmt.Println(result.ToJSON())
 	}
@@ -3,9 +3,14 @@ package mcp
 import (
 	"context"
 	"encoding/json"
+	"gf-lt/config"
 	"log/slog"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -186,3 +191,165 @@ func resultFromInt(i int64) string {
 	}
 	return string(buf[n:])
 }
+
+// the test binary serves mcp over stdio when started by TestManagerStdioLifecycle
+func TestMain(m *testing.M) {
+	if os.Getenv("GF_LT_MCP_TEST_SERVER") == "1" {
+		server := mcp.NewServer(&mcp.Implementation{Name: "stdio-test", Version: "1.0.0"}, &mcp.ServerOptions{
+			SubscribeHandler:   func(context.Context, *mcp.SubscribeRequest) error { return nil },
+			UnsubscribeHandler: func(context.Context, *mcp.UnsubscribeRequest) error { return nil },
+		})
+		server.AddTool(&mcp.Tool{Name: "pid", InputSchema: map[string]any{"type": "object"}},
+			func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: strconv.Itoa(os.Getpid())}}}, nil
+			})
+		server.AddTool(&mcp.Tool{Name: "crash", InputSchema: map[string]any{"type": "object"}},
+			func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				os.Exit(1)
+				return nil, nil
+			})
+		server.AddResource(&mcp.Resource{Name: "notes", URI: "test://notes", MIMEType: "text/plain"},
+			func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+				return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{{URI: req.Params.URI, Text: "buy milk"}}}, nil
+			})
+		server.AddPrompt(&mcp.Prompt{Name: "review", Arguments: []*mcp.PromptArgument{{Name: "file", Required: true}, {Name: "focus"}}},
+			func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+				text := "review " + req.Params.Arguments["file"] + " for " + req.Params.Arguments["focus"]
+				return &mcp.GetPromptResult{Messages: []*mcp.PromptMessage{{Role: "user", Content: &mcp.TextContent{Text: text}}}}, nil
+			})
+		// adding a tool sends tools/list_changed
+		server.AddTool(&mcp.Tool{Name: "grow", InputSchema: map[string]any{"type": "object"}},
+			func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				server.AddTool(&mcp.Tool{Name: "grown", InputSchema: map[string]any{"type": "object"}},
+					func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+						return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "grown"}}}, nil
+					})
+				_ = server.ResourceUpdated(ctx, &mcp.ResourceUpdatedNotificationParams{URI: "test://notes"})
+				return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "ok"}}}, nil
+			})
+		_ = server.Run(context.Background(), &mcp.StdioTransport{})
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestManagerStdioLifecycle(t *testing.T) {
+	minBackoff = 10 * time.Millisecond
+	cfg := &config.Config{MCPServers: map[string]config.MCPServerConfig{
+		"local": {Command: os.Args[0], Args: []string{"-test.run=^$"}, Env: map[string]string{"GF_LT_MCP_TEST_SERVER": "1"}},
+	}}
+	m := NewManager(cfg, testLogger())
+	if err := m.ConnectAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if got := len(m.GetOpenAITools()); got != 3 {
+		t.Fatalf("expected 3 tools, got %d", got)
+	}
+	handler, ok := m.ToolHandler("mcp_local_pid")
+	if !ok {
+		t.Fatal("pid tool not resolved")
+	}
+	pid := string(handler(context.Background(), nil))
+	m.callTool(context.Background(), "mcp_local_crash", nil)
+	// the server process is restarted
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if m.Servers()[0].Status == StatusConnected {
+			if newPid := string(handler(context.Background(), nil)); newPid != pid && !strings.Contains(newPid, "MCP") {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server was not restarted: %+v", m.Servers())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err := m.SetToolEnabled("local", "crash", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(m.GetOpenAITools()); got != 2 {
+		t.Errorf("expected 2 tools after disabling, got %d", got)
+	}
+	if _, ok := m.ToolHandler("mcp_local_crash"); ok {
+		t.Errorf("disabled tool should not resolve")
+	}
+	if err := m.SetServerEnabled("local", false); err != nil {
+		t.Fatal(err)
+	}
+	if m.HasTools() || m.Servers()[0].Status != StatusDisabled {
+		t.Errorf("disabled server still has tools: %+v", m.Servers())
+	}
+	if err := m.SetServerEnabled("local", true); err != nil {
+		t.Fatal(err)
+	}
+	if !m.HasTools() {
+		t.Errorf("enabled server has no tools: %+v", m.Servers())
+	}
+	// restarts in a row leave one live connection behind
+	for range 3 {
+		if err := m.Restart("local"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := m.Servers()[0]; st.Status != StatusConnected || len(m.GetOpenAITools()) != 2 {
+		t.Errorf("unexpected server after restarts: %+v", st)
+	}
+	if got := cfg.MCPServers["local"].DisabledTools; !slices.Equal(got, []string{"crash"}) {
+		t.Errorf("disabled tools not kept in the config: %q", got)
+	}
+}
+
+func TestManagerResourcesAndPrompts(t *testing.T) {
+	cfg := &config.Config{MCPServers: map[string]config.MCPServerConfig{
+		"local": {Command: os.Args[0], Args: []string{"-test.run=^$"}, Env: map[string]string{"GF_LT_MCP_TEST_SERVER": "1"}},
+	}}
+	m := NewManager(cfg, testLogger())
+	updated := make(chan string, 1)
+	m.SetResourceUpdatedHandler(func(server, uri string) { updated <- server + " " + uri })
+	if err := m.ConnectAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	ctx := context.Background()
+	resources, err := m.Resources(ctx)
+	if err != nil || len(resources) != 1 || resources[0].URI != "test://notes" || !resources[0].Subscribable {
+		t.Fatalf("unexpected resources: %+v %v", resources, err)
+	}
+	contents, err := m.ReadResource(ctx, "local", "test://notes")
+	if err != nil || len(contents) != 1 || contents[0].Text != "buy milk" {
+		t.Fatalf("unexpected contents: %+v %v", contents, err)
+	}
+	if err := m.SubscribeResource(ctx, "local", "test://notes"); err != nil {
+		t.Fatal(err)
+	}
+	text, ok, err := m.ExpandPromptCommand(ctx, `/local:review main.go focus="error handling"`)
+	if !ok || err != nil || text != "review main.go for error handling" {
+		t.Errorf("unexpected prompt expansion: %q %v %v", text, ok, err)
+	}
+	if _, _, err := m.ExpandPromptCommand(ctx, "/local:review"); err == nil {
+		t.Errorf("expected error for missing required argument")
+	}
+	if _, ok, _ := m.ExpandPromptCommand(ctx, "/help"); ok {
+		t.Errorf("/help is not a prompt")
+	}
+	m.callTool(context.Background(), "mcp_local_grow", nil)
+	select {
+	case got := <-updated:
+		if got != "local test://notes" {
+			t.Errorf("unexpected update: %s", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("no resource update notification")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(m.GetOpenAITools()) != 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("tool list was not refreshed: %d tools", len(m.GetOpenAITools()))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if _, ok := m.ToolHandler("mcp_local_grown"); !ok {
+		t.Errorf("new tool not resolved")
+	}
+}
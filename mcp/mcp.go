@@ -2,22 +2,37 @@ package mcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"gf-lt/config"
 	"gf-lt/tools"
 	"log/slog"
+	"maps"
 	"os"
+	"os/exec"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 type MCPServer struct {
-	name    string
-	url     string
+	name   string
+	cfg    config.MCPServerConfig
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	// closed when the supervise goroutine of the last start exits
+	done chan struct{}
+	// session and tools are replaced on every (re)connect
 	session *mcp.ClientSession
 	tools   []mcp.Tool
+	prompts []mcp.Prompt
+	status  string
+	lastErr error
 }
 
 const (
@@ -25,11 +40,50 @@ const (
 	ClientVersion = "1.0.0"
 )
 
+// server statuses shown in the mcp page
+const (
+	StatusConnecting = "connecting"
+	StatusConnected  = "connected"
+	StatusFailed     = "failed"
+	StatusDisabled   = "disabled"
+)
+
+var (
+	connectTimeout = 30 * time.Second
+	// http servers are pinged to notice they went down; stdio servers are noticed by the closed pipe
+	pingInterval = 30 * time.Second
+	minBackoff   = time.Second
+	maxBackoff   = time.Minute
+)
+
 type Manager struct {
-	cfg     *config.Config
+	cfg *config.Config
+	// guards cfg.MCPServers; enable and disable choices are written into it
+	cfgMu   sync.Mutex
 	logger  *slog.Logger
 	servers map[string]*MCPServer
+	mu      sync.RWMutex
 	toolMap map[string]*MCPServer
+	// called when a subscribed resource changes
+	onResourceUpdated func(server, uri string)
+	// progress token of a running call -> its output stream
+	progress    sync.Map
+	progressSeq atomic.Int64
+}
+
+// ServerInfo is a snapshot of server state for the ui
+type ServerInfo struct {
+	Name      string
+	Transport string
+	Status    string
+	Error     string
+	Enabled   bool
+	Tools     []ToolInfo
+}
+
+type ToolInfo struct {
+	Name    string
+	Enabled bool
 }
 
 func NewManager(cfg *config.Config, logger *slog.Logger) *Manager {
@@ -41,75 +95,341 @@ func NewManager(cfg *config.Config, logger *slog.Logger) *Manager {
 	}
 }
 
+// ConnectAll starts all enabled servers; the first connection attempt is waited for,
+// later reconnects happen in background
 func (m *Manager) ConnectAll(ctx context.Context) error {
-	if len(m.cfg.MCPServers) == 0 {
-		return nil
-	}
-
-	for name, serverCfg := range m.cfg.MCPServers {
-		if serverCfg.URL == "" {
-			m.logger.Warn("MCP server URL not configured, skipping", "server", name)
+	m.cfgMu.Lock()
+	configs := maps.Clone(m.cfg.MCPServers)
+	m.cfgMu.Unlock()
+	for name, serverCfg := range configs {
+		if serverCfg.URL == "" && serverCfg.Command == "" {
+			m.logger.Warn("MCP server has neither url nor command, skipping", "server", name)
 			continue
 		}
-
-		server := &MCPServer{
-			name: name,
-			url:  serverCfg.URL,
-		}
-
-		if err := server.connect(ctx, m.logger); err != nil {
-			m.logger.Error("failed to connect to MCP server", "server", name, "url", serverCfg.URL, "error", err)
+		server := &MCPServer{name: name, cfg: serverCfg, status: StatusDisabled}
+		m.servers[name] = server
+		if serverCfg.Disabled {
 			continue
 		}
-
-		m.servers[name] = server
-		m.logger.Info("connected to MCP server", "server", name, "url", serverCfg.URL, "tools", len(server.tools))
+		m.start(ctx, server)
 	}
-
 	return nil
 }
 
-func (s *MCPServer) connect(ctx context.Context, logger *slog.Logger) error {
-	transport := &mcp.StreamableClientTransport{
-		Endpoint:             s.url,
-		DisableStandaloneSSE: true,
+func (s *MCPServer) transportName() string {
+	if s.cfg.Command != "" {
+		return "stdio"
 	}
+	return "http"
+}
+
+func (s *MCPServer) newTransport(logger *slog.Logger) mcp.Transport {
+	if s.cfg.Command == "" {
+		return &mcp.StreamableClientTransport{
+			Endpoint:             s.cfg.URL,
+			DisableStandaloneSSE: true,
+		}
+	}
+	cmd := exec.Command(s.cfg.Command, s.cfg.Args...)
+	if len(s.cfg.Env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range s.cfg.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+	// stderr would break the tui
+	cmd.Stderr = &stderrLog{logger: logger, server: s.name}
+	return &mcp.CommandTransport{Command: cmd}
+}
+
+// stderrLog writes stderr of stdio servers to the log
+type stderrLog struct {
+	logger *slog.Logger
+	server string
+}
 
-	client := mcp.NewClient(&mcp.Implementation{Name: ClientName, Version: ClientVersion}, nil)
+func (w *stderrLog) Write(p []byte) (int, error) {
+	w.logger.Debug("MCP server stderr", "server", w.server, "text", strings.TrimSpace(string(p)))
+	return len(p), nil
+}
 
-	session, err := client.Connect(ctx, transport, nil)
+func (s *MCPServer) connect(ctx context.Context, m *Manager) error {
+	client := mcp.NewClient(&mcp.Implementation{Name: ClientName, Version: ClientVersion}, m.clientOptions(s))
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	session, err := client.Connect(ctx, s.newTransport(m.logger), nil)
 	if err != nil {
 		return fmt.Errorf("failed to connect to MCP server: %w", err)
 	}
-
+	tools, err := listTools(ctx, session)
+	if err != nil {
+		session.Close()
+		return err
+	}
+	prompts, err := listPrompts(ctx, session)
+	if err != nil {
+		m.logger.Warn("failed to list MCP prompts", "server", s.name, "error", err)
+	}
+	s.mu.Lock()
 	s.session = session
+	s.tools = tools
+	s.prompts = prompts
+	s.mu.Unlock()
+	return nil
+}
 
+func listTools(ctx context.Context, session *mcp.ClientSession) ([]mcp.Tool, error) {
 	result, err := session.ListTools(ctx, &mcp.ListToolsParams{})
 	if err != nil {
+		return nil, fmt.Errorf("failed to list tools: %w", err)
+	}
+	tools := make([]mcp.Tool, len(result.Tools))
+	for i, t := range result.Tools {
+		tools[i] = *t
+	}
+	return tools, nil
+}
+
+// clientOptions handles server notifications: changed tool and prompt lists are fetched again
+func (m *Manager) clientOptions(s *MCPServer) *mcp.ClientOptions {
+	return &mcp.ClientOptions{
+		// handlers run on the connection goroutine, requests to the server are made from another one
+		ToolListChangedHandler: func(ctx context.Context, req *mcp.ToolListChangedRequest) {
+			go m.refreshTools(req.Session, s)
+		},
+		PromptListChangedHandler: func(ctx context.Context, req *mcp.PromptListChangedRequest) {
+			go m.refreshPrompts(req.Session, s)
+		},
+		ResourceUpdatedHandler: func(ctx context.Context, req *mcp.ResourceUpdatedNotificationRequest) {
+			m.logger.Debug("MCP resource updated", "server", s.name, "uri", req.Params.URI)
+			if m.onResourceUpdated != nil {
+				m.onResourceUpdated(s.name, req.Params.URI)
+			}
+		},
+		// progress messages of a running tool call go to its output stream
+		ProgressNotificationHandler: func(ctx context.Context, req *mcp.ProgressNotificationClientRequest) {
+			if fn, ok := m.progress.Load(req.Params.ProgressToken); ok && req.Params.Message != "" {
+				fn.(func(string))(req.Params.Message + "\n")
+			}
+		},
+	}
+}
+
+func (m *Manager) refreshTools(session *mcp.ClientSession, s *MCPServer) {
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	tools, err := listTools(ctx, session)
+	if err != nil {
+		m.logger.Warn("failed to refresh MCP tools", "server", s.name, "error", err)
+		return
+	}
+	s.mu.Lock()
+	if s.session != session {
+		s.mu.Unlock()
+		return
+	}
+	s.tools = tools
+	s.mu.Unlock()
+	m.rebuildToolMap()
+	m.logger.Info("MCP tool list changed", "server", s.name, "tools", len(tools))
+}
+
+// start connects the server and keeps it connected until stop
+func (m *Manager) start(ctx context.Context, s *MCPServer) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	s.mu.Lock()
+	s.cancel = cancel
+	s.done = done
+	s.status = StatusConnecting
+	s.mu.Unlock()
+	first := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.supervise(ctx, s, first)
+	}()
+	<-first
+}
+
+// stop disconnects the server; stdio server process is terminated.
+// It waits for the supervise goroutine, so a connect in progress cannot
+// leave its session behind for the next start
+func (m *Manager) stop(s *MCPServer) {
+	s.mu.Lock()
+	cancel, session, done := s.cancel, s.session, s.done
+	s.cancel, s.session, s.done = nil, nil, nil
+	s.status = StatusDisabled
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	if session != nil {
 		session.Close()
-		return fmt.Errorf("failed to list tools: %w", err)
 	}
+	if done != nil {
+		<-done
+	}
+	m.rebuildToolMap()
+}
 
-	s.tools = make([]mcp.Tool, len(result.Tools))
-	for i, t := range result.Tools {
-		s.tools[i] = *t
+func (m *Manager) supervise(ctx context.Context, s *MCPServer, first chan struct{}) {
+	backoff := minBackoff
+	for {
+		err := s.connect(ctx, m)
+		if first != nil {
+			close(first)
+			first = nil
+		}
+		if ctx.Err() != nil {
+			if err == nil {
+				s.closeSession()
+			}
+			return
+		}
+		if err != nil {
+			s.setStatus(StatusFailed, err)
+			m.logger.Error("failed to connect to MCP server", "server", s.name, "transport", s.transportName(), "error", err, "retry_in", backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff = minBackoff
+		s.setStatus(StatusConnected, nil)
+		m.rebuildToolMap()
+		m.logger.Info("connected to MCP server", "server", s.name, "transport", s.transportName(), "tools", len(s.tools))
+		err = m.waitDisconnect(ctx, s)
+		if ctx.Err() != nil {
+			return
+		}
+		s.closeSession()
+		s.setStatus(StatusConnecting, err)
+		m.rebuildToolMap()
+		m.logger.Warn("MCP server connection lost, reconnecting", "server", s.name, "error", err)
 	}
-	return nil
+}
+
+// waitDisconnect returns when the session is closed (process exited) or http server stops answering pings
+func (m *Manager) waitDisconnect(ctx context.Context, s *MCPServer) error {
+	s.mu.Lock()
+	session := s.session
+	s.mu.Unlock()
+	if session == nil {
+		return errors.New("no session")
+	}
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+	var tick <-chan time.Time
+	if s.cfg.Command == "" {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			session.Close()
+			return ctx.Err()
+		case err := <-done:
+			if err == nil {
+				err = mcp.ErrConnectionClosed
+			}
+			return err
+		case <-tick:
+			pingCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+			err := session.Ping(pingCtx, nil)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				return fmt.Errorf("ping failed: %w", err)
+			}
+		}
+	}
+}
+
+func (s *MCPServer) closeSession() {
+	s.mu.Lock()
+	session := s.session
+	s.session = nil
+	s.mu.Unlock()
+	if session != nil {
+		session.Close()
+	}
+}
+
+func (s *MCPServer) setStatus(status string, err error) {
+	s.mu.Lock()
+	s.status = status
+	s.lastErr = err
+	s.mu.Unlock()
+}
+
+// snapshot returns session and enabled tools of a connected server
+func (s *MCPServer) snapshot() (*mcp.ClientSession, []mcp.Tool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.session == nil || s.status != StatusConnected {
+		return nil, nil
+	}
+	tools := make([]mcp.Tool, 0, len(s.tools))
+	for _, t := range s.tools {
+		if !slices.Contains(s.cfg.DisabledTools, t.Name) {
+			tools = append(tools, t)
+		}
+	}
+	return s.session, tools
+}
+
+func (m *Manager) sortedServers() []*MCPServer {
+	names := make([]string, 0, len(m.servers))
+	for name := range m.servers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	resp := make([]*MCPServer, len(names))
+	for i, name := range names {
+		resp[i] = m.servers[name]
+	}
+	return resp
+}
+
+func (m *Manager) rebuildToolMap() {
+	toolMap := make(map[string]*MCPServer)
+	for _, server := range m.sortedServers() {
+		_, tools := server.snapshot()
+		for _, tool := range tools {
+			toolMap[fmt.Sprintf("mcp_%s_%s", server.name, tool.Name)] = server
+		}
+	}
+	m.mu.Lock()
+	m.toolMap = toolMap
+	m.mu.Unlock()
+}
+
+func (m *Manager) serverOfTool(name string) (*MCPServer, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	server, ok := m.toolMap[name]
+	return server, ok
 }
 
 func (m *Manager) GetTools() []mcp.Tool {
 	var allTools []mcp.Tool
-	for _, server := range m.servers {
-		allTools = append(allTools, server.tools...)
+	for _, server := range m.sortedServers() {
+		_, tools := server.snapshot()
+		allTools = append(allTools, tools...)
 	}
 	return allTools
 }
 
 func (m *Manager) GetOpenAITools() []any {
 	var tools []any
-	for _, server := range m.servers {
-		for i := range server.tools {
-			openAITool := convertToolToOpenAI(server.name, &server.tools[i])
+	for _, server := range m.sortedServers() {
+		_, serverTools := server.snapshot()
+		for i := range serverTools {
+			openAITool := convertToolToOpenAI(server.name, &serverTools[i])
 			tools = append(tools, openAITool)
 		}
 	}
@@ -117,7 +437,98 @@ func (m *Manager) GetOpenAITools() []any {
 }
 
 func (m *Manager) HasTools() bool {
-	return len(m.servers) > 0
+	for _, server := range m.servers {
+		if _, tools := server.snapshot(); len(tools) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Servers returns state of all configured servers, sorted by name
+func (m *Manager) Servers() []ServerInfo {
+	resp := []ServerInfo{}
+	for _, s := range m.sortedServers() {
+		s.mu.Lock()
+		info := ServerInfo{
+			Name:      s.name,
+			Transport: s.transportName(),
+			Status:    s.status,
+			Enabled:   !s.cfg.Disabled,
+		}
+		if s.lastErr != nil {
+			info.Error = s.lastErr.Error()
+		}
+		for _, t := range s.tools {
+			info.Tools = append(info.Tools, ToolInfo{Name: t.Name, Enabled: !slices.Contains(s.cfg.DisabledTools, t.Name)})
+		}
+		s.mu.Unlock()
+		resp = append(resp, info)
+	}
+	return resp
+}
+
+// SetServerEnabled connects or disconnects the server; the choice lasts until restart of gf-lt
+func (m *Manager) SetServerEnabled(name string, enabled bool) error {
+	s, ok := m.servers[name]
+	if !ok {
+		return fmt.Errorf("no such MCP server: %s", name)
+	}
+	s.mu.Lock()
+	wasEnabled := !s.cfg.Disabled
+	s.cfg.Disabled = !enabled
+	s.mu.Unlock()
+	m.saveServerCfg(s)
+	switch {
+	case enabled && !wasEnabled:
+		m.start(context.Background(), s)
+	case !enabled && wasEnabled:
+		m.stop(s)
+	}
+	return nil
+}
+
+// Restart reconnects the server (restarts stdio server process)
+func (m *Manager) Restart(name string) error {
+	s, ok := m.servers[name]
+	if !ok {
+		return fmt.Errorf("no such MCP server: %s", name)
+	}
+	m.stop(s)
+	s.mu.Lock()
+	s.cfg.Disabled = false
+	s.mu.Unlock()
+	m.saveServerCfg(s)
+	m.start(context.Background(), s)
+	return nil
+}
+
+// SetToolEnabled hides the tool from the llm or shows it again
+func (m *Manager) SetToolEnabled(server, tool string, enabled bool) error {
+	s, ok := m.servers[server]
+	if !ok {
+		return fmt.Errorf("no such MCP server: %s", server)
+	}
+	s.mu.Lock()
+	// the slice is shared with the config copy, so it is not changed in place
+	s.cfg.DisabledTools = slices.DeleteFunc(slices.Clone(s.cfg.DisabledTools), func(t string) bool { return t == tool })
+	if !enabled {
+		s.cfg.DisabledTools = append(s.cfg.DisabledTools, tool)
+	}
+	s.mu.Unlock()
+	m.saveServerCfg(s)
+	m.rebuildToolMap()
+	return nil
+}
+
+// saveServerCfg writes the server config into the gf-lt config; the choice lasts until restart of gf-lt
+func (m *Manager) saveServerCfg(s *MCPServer) {
+	s.mu.Lock()
+	serverCfg := s.cfg
+	s.mu.Unlock()
+	m.cfgMu.Lock()
+	m.cfg.MCPServers[s.name] = serverCfg
+	m.cfgMu.Unlock()
 }
 
 // IsVRAMFreeTool checks whether the given tool name belongs to an MCP server
@@ -127,7 +538,7 @@ func (m *Manager) IsVRAMFreeTool(name string) bool {
 		m.logger.Debug("IsVRAMFreeTool: ModelManagement not configured, skipping", "tool", name)
 		return false
 	}
-	server, ok := m.toolMap[name]
+	server, ok := m.serverOfTool(name)
 	if !ok {
 		m.logger.Debug("IsVRAMFreeTool: tool not found in any MCP server", "tool", name)
 		return false
@@ -143,22 +554,33 @@ func (m *Manager) IsVRAMFreeTool(name string) bool {
 }
 
 func (m *Manager) RegisterToolHandlers(fnMap map[string]tools.FnHandler) {
-	for _, server := range m.servers {
-		for _, tool := range server.tools {
-			prefixedName := fmt.Sprintf("mcp_%s_%s", server.name, tool.Name)
-			m.toolMap[prefixedName] = server
-
-			fnMap[prefixedName] = func(args map[string]string) []byte {
-				return m.callTool(prefixedName, args)
-			}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for prefixedName := range m.toolMap {
+		fnMap[prefixedName] = func(ctx context.Context, args map[string]string) []byte {
+			return m.callTool(ctx, prefixedName, args)
 		}
 	}
 }
 
-func (m *Manager) callTool(name string, args map[string]string) []byte {
-	server, ok := m.toolMap[name]
+// ToolHandler resolves tools of servers that connected after RegisterToolHandlers
+func (m *Manager) ToolHandler(name string) (tools.FnHandler, bool) {
+	if _, ok := m.serverOfTool(name); !ok {
+		return nil, false
+	}
+	return func(ctx context.Context, args map[string]string) []byte {
+		return m.callTool(ctx, name, args)
+	}, true
+}
+
+func (m *Manager) callTool(ctx context.Context, name string, args map[string]string) []byte {
+	server, ok := m.serverOfTool(name)
 	if !ok {
-		return []byte(fmt.Sprintf("MCP tool %s not found", name))
+		return []byte(fmt.Sprintf("MCP tool %s not found or disabled", name))
+	}
+	session, _ := server.snapshot()
+	if session == nil {
+		return []byte(fmt.Sprintf("MCP server %s is not connected", server.name))
 	}
 
 	toolName := strings.TrimPrefix(name, fmt.Sprintf("mcp_%s_", server.name))
@@ -168,10 +590,17 @@ func (m *Manager) callTool(name string, args map[string]string) []byte {
 		mcpArgs[k] = v
 	}
 
-	result, err := server.session.CallTool(context.Background(), &mcp.CallToolParams{
+	params := &mcp.CallToolParams{
 		Name:      toolName,
 		Arguments: mcpArgs,
-	})
+	}
+	if stream := tools.OutputStream(ctx); stream != nil {
+		token := fmt.Sprintf("%s-%d", name, m.progressSeq.Add(1))
+		params.SetProgressToken(token)
+		m.progress.Store(token, stream)
+		defer m.progress.Delete(token)
+	}
+	result, err := session.CallTool(ctx, params)
 	if err != nil {
 		return []byte(fmt.Sprintf("MCP tool call failed: %v", err))
 	}
@@ -287,8 +716,6 @@ func convertInputSchema(schema any) map[string]any {
 
 func (m *Manager) Close() {
 	for _, server := range m.servers {
-		if server.session != nil {
-			server.session.Close()
-		}
+		m.stop(server)
 	}
 }
@@ -0,0 +1,292 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ResourceInfo describes a resource the user can attach to the message
+type ResourceInfo struct {
+	Server      string
+	URI         string
+	Name        string
+	MIMEType    string
+	Description string
+	// server supports subscribing to updates
+	Subscribable bool
+}
+
+// ResourceContent is a read resource: text or binary blob
+type ResourceContent struct {
+	URI      string
+	MIMEType string
+	Text     string
+	Blob     []byte
+}
+
+// PromptInfo describes a prompt template of a server, used as /server:prompt command
+type PromptInfo struct {
+	Server      string
+	Name        string
+	Description string
+	Args        []PromptArg
+}
+
+type PromptArg struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// Command is the slash command that expands the prompt
+func (p PromptInfo) Command() string {
+	return "/" + p.Server + ":" + p.Name
+}
+
+// SetResourceUpdatedHandler sets the func called when a subscribed resource changes
+func (m *Manager) SetResourceUpdatedHandler(fn func(server, uri string)) {
+	m.onResourceUpdated = fn
+}
+
+func (m *Manager) session(server string) (*mcp.ClientSession, error) {
+	s, ok := m.servers[server]
+	if !ok {
+		return nil, fmt.Errorf("no such MCP server: %s", server)
+	}
+	session, _ := s.snapshot()
+	if session == nil {
+		return nil, fmt.Errorf("MCP server %s is not connected", server)
+	}
+	return session, nil
+}
+
+// Resources lists resources of all connected servers; servers without resources are skipped
+func (m *Manager) Resources(ctx context.Context) ([]ResourceInfo, error) {
+	resp := []ResourceInfo{}
+	var errs []error
+	for _, s := range m.sortedServers() {
+		session, _ := s.snapshot()
+		if session == nil {
+			continue
+		}
+		caps := session.InitializeResult().Capabilities
+		if caps == nil || caps.Resources == nil {
+			continue
+		}
+		for r, err := range session.Resources(ctx, nil) {
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+				break
+			}
+			resp = append(resp, ResourceInfo{
+				Server:       s.name,
+				URI:          r.URI,
+				Name:         r.Name,
+				MIMEType:     r.MIMEType,
+				Description:  r.Description,
+				Subscribable: caps.Resources.Subscribe,
+			})
+		}
+	}
+	return resp, errors.Join(errs...)
+}
+
+// ReadResource returns all contents of the resource
+func (m *Manager) ReadResource(ctx context.Context, server, uri string) ([]ResourceContent, error) {
+	session, err := m.session(server)
+	if err != nil {
+		return nil, err
+	}
+	result, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: uri})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource %s: %w", uri, err)
+	}
+	resp := make([]ResourceContent, 0, len(result.Contents))
+	for _, c := range result.Contents {
+		if c == nil {
+			continue
+		}
+		resp = append(resp, ResourceContent{URI: c.URI, MIMEType: c.MIMEType, Text: c.Text, Blob: c.Blob})
+	}
+	return resp, nil
+}
+
+// SubscribeResource asks the server to notify about resource changes;
+// subscriptions are lost when the server reconnects
+func (m *Manager) SubscribeResource(ctx context.Context, server, uri string) error {
+	session, err := m.session(server)
+	if err != nil {
+		return err
+	}
+	if err := session.Subscribe(ctx, &mcp.SubscribeParams{URI: uri}); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", uri, err)
+	}
+	return nil
+}
+
+func listPrompts(ctx context.Context, session *mcp.ClientSession) ([]mcp.Prompt, error) {
+	caps := session.InitializeResult().Capabilities
+	if caps == nil || caps.Prompts == nil {
+		return nil, nil
+	}
+	prompts := []mcp.Prompt{}
+	for p, err := range session.Prompts(ctx, nil) {
+		if err != nil {
+			return prompts, err
+		}
+		prompts = append(prompts, *p)
+	}
+	return prompts, nil
+}
+
+func (m *Manager) refreshPrompts(session *mcp.ClientSession, s *MCPServer) {
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	prompts, err := listPrompts(ctx, session)
+	if err != nil {
+		m.logger.Warn("failed to refresh MCP prompts", "server", s.name, "error", err)
+		return
+	}
+	s.mu.Lock()
+	if s.session == session {
+		s.prompts = prompts
+	}
+	s.mu.Unlock()
+}
+
+// Prompts returns prompt templates of connected servers
+func (m *Manager) Prompts() []PromptInfo {
+	resp := []PromptInfo{}
+	for _, s := range m.sortedServers() {
+		s.mu.Lock()
+		if s.status != StatusConnected {
+			s.mu.Unlock()
+			continue
+		}
+		for _, p := range s.prompts {
+			info := PromptInfo{Server: s.name, Name: p.Name, Description: p.Description}
+			for _, a := range p.Arguments {
+				if a != nil {
+					info.Args = append(info.Args, PromptArg{Name: a.Name, Description: a.Description, Required: a.Required})
+				}
+			}
+			resp = append(resp, info)
+		}
+		s.mu.Unlock()
+	}
+	return resp
+}
+
+// FindPrompt returns the prompt for the /server:prompt command
+func (m *Manager) FindPrompt(command string) (PromptInfo, bool) {
+	for _, p := range m.Prompts() {
+		if p.Command() == command {
+			return p, true
+		}
+	}
+	return PromptInfo{}, false
+}
+
+// ParsePromptCommand parses args of `/server:prompt key=value key2="quoted value"`;
+// text without key= goes to the first argument of the prompt
+func ParsePromptCommand(line string, prompt PromptInfo) map[string]string {
+	args := map[string]string{}
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), prompt.Command()))
+	var free []string
+	for _, field := range splitQuoted(rest) {
+		key, value, ok := strings.Cut(field, "=")
+		if ok && slices.ContainsFunc(prompt.Args, func(a PromptArg) bool { return a.Name == key }) {
+			args[key] = strings.Trim(value, `"`)
+			continue
+		}
+		free = append(free, strings.Trim(field, `"`))
+	}
+	if len(free) > 0 && len(prompt.Args) > 0 {
+		if _, ok := args[prompt.Args[0].Name]; !ok {
+			args[prompt.Args[0].Name] = strings.Join(free, " ")
+		}
+	}
+	return args
+}
+
+// splitQuoted splits on spaces outside of double quotes
+func splitQuoted(s string) []string {
+	fields := []string{}
+	var cur strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case r == ' ' && !quoted:
+			if cur.Len() > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+// GetPrompt expands the prompt; text of all messages is joined
+func (m *Manager) GetPrompt(ctx context.Context, server, name string, args map[string]string) (string, error) {
+	prompt, ok := m.FindPrompt("/" + server + ":" + name)
+	if !ok {
+		return "", fmt.Errorf("no such MCP prompt: %s:%s", server, name)
+	}
+	for _, a := range prompt.Args {
+		if a.Required && args[a.Name] == "" {
+			return "", fmt.Errorf("prompt %s requires argument %s", prompt.Command(), a.Name)
+		}
+	}
+	session, err := m.session(server)
+	if err != nil {
+		return "", err
+	}
+	result, err := session.GetPrompt(ctx, &mcp.GetPromptParams{Name: name, Arguments: args})
+	if err != nil {
+		return "", fmt.Errorf("failed to get prompt %s: %w", name, err)
+	}
+	parts := make([]string, 0, len(result.Messages))
+	for _, msg := range result.Messages {
+		if msg == nil {
+			continue
+		}
+		switch c := msg.Content.(type) {
+		case *mcp.TextContent:
+			parts = append(parts, c.Text)
+		case *mcp.EmbeddedResource:
+			if c.Resource != nil && c.Resource.Text != "" {
+				parts = append(parts, fmt.Sprintf("[resource: %s]\n%s", c.Resource.URI, c.Resource.Text))
+			}
+		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// ExpandPromptCommand expands the line if it starts with a /server:prompt command;
+// ok is false when the line is not a known prompt command
+func (m *Manager) ExpandPromptCommand(ctx context.Context, line string) (text string, ok bool, err error) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "/") {
+		return "", false, nil
+	}
+	command, _, _ := strings.Cut(line, " ")
+	prompt, ok := m.FindPrompt(command)
+	if !ok {
+		return "", false, nil
+	}
+	text, err = m.GetPrompt(ctx, prompt.Server, prompt.Name, ParsePromptCommand(line, prompt))
+	return text, true, err
+}
@@ -0,0 +1,344 @@
+package mcp
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gf-lt/config"
+	"gf-lt/models"
+	"gf-lt/rag"
+	"gf-lt/storage"
+	"gf-lt/tools"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// tools given to mcp clients when MCPServeTools is not set
+var defaultServeTools = []string{"bash", "file_edit", "insert_at", "rag_search", "memory", "websearch", "read_url"}
+
+const (
+	chatURIPrefix = "gf-lt://chats/"
+	ragURIPrefix  = "gf-lt://rag/"
+)
+
+// Server exposes gf-lt tools, rag documents and chat history to other mcp clients
+type Server struct {
+	cfg    *config.Config
+	logger *slog.Logger
+	chats  storage.ChatHistory
+	server *mcp.Server
+	// uris of currently listed resources
+	mu        sync.Mutex
+	resources map[string]bool
+}
+
+func NewServer(cfg *config.Config, logger *slog.Logger, chats storage.ChatHistory) *Server {
+	s := &Server{
+		cfg:       cfg,
+		logger:    logger,
+		chats:     chats,
+		server:    mcp.NewServer(&mcp.Implementation{Name: ClientName, Version: ClientVersion}, nil),
+		resources: make(map[string]bool),
+	}
+	s.addTools()
+	s.server.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "chat",
+		URITemplate: chatURIPrefix + "{id}",
+		MIMEType:    "text/plain",
+		Description: "gf-lt chat history by id",
+	}, s.readResource)
+	s.server.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "rag document",
+		URITemplate: ragURIPrefix + "{file}",
+		MIMEType:    "text/plain",
+		Description: "text of a document loaded into gf-lt rag store",
+	}, s.readResource)
+	// chats and documents change while serving, so the list is made on request
+	s.server.AddReceivingMiddleware(func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			if method == "resources/list" {
+				s.syncResources()
+			}
+			return next(ctx, method, req)
+		}
+	})
+	s.syncResources()
+	return s
+}
+
+func (s *Server) addTools() {
+	names := s.cfg.MCPServeTools
+	if len(names) == 0 {
+		names = defaultServeTools
+	}
+	for _, name := range names {
+		if _, ok := tools.FnMap[name]; !ok {
+			s.logger.Warn("tool to serve is not available, skipping", "tool", name)
+			continue
+		}
+		def, ok := toolDef(name)
+		if !ok {
+			s.logger.Warn("tool to serve has no definition, skipping", "tool", name)
+			continue
+		}
+		s.server.AddTool(&mcp.Tool{
+			Name:        name,
+			Description: def.Function.Description,
+			InputSchema: inputSchema(def.Function.Parameters),
+		}, s.toolHandler(name))
+	}
+}
+
+func toolDef(name string) (models.Tool, bool) {
+	for _, t := range tools.BaseTools {
+		if t.Function.Name == name {
+			return t, true
+		}
+	}
+	return models.Tool{}, false
+}
+
+func inputSchema(params models.ToolFuncParams) map[string]any {
+	props := map[string]any{}
+	for name, p := range params.Properties {
+		props[name] = map[string]any{"type": p.Type, "description": p.Description}
+	}
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(params.Required) > 0 {
+		schema["required"] = params.Required
+	}
+	return schema
+}
+
+// toolHandler calls the tool like the chat does; dangerous commands are confirmed by the client user
+func (s *Server) toolHandler(name string) mcp.ToolHandler {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		raw := map[string]any{}
+		if len(req.Params.Arguments) > 0 {
+			if err := json.Unmarshal(req.Params.Arguments, &raw); err != nil {
+				return errorResult("invalid arguments: " + err.Error()), nil
+			}
+		}
+		args := argsToStrings(raw)
+		if dangerous, label := tools.IsDangerousCommand(name, args); dangerous {
+			if !s.confirm(ctx, req.Session, name, args, label) {
+				return errorResult("[denied] This command requires user confirmation: " + label), nil
+			}
+		}
+		s.logger.Info("mcp client called a tool", "tool", name, "args", args)
+		resp, ok := tools.CallToolWithAgent(ctx, name, args)
+		if !ok {
+			return errorResult(string(resp)), nil
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: string(resp)}}}, nil
+	}
+}
+
+// confirm asks the client user through elicitation; clients without elicitation get dangerous commands denied
+func (s *Server) confirm(ctx context.Context, session *mcp.ServerSession, name string, args map[string]string, label string) bool {
+	res, err := session.Elicit(ctx, &mcp.ElicitParams{
+		Message: fmt.Sprintf("gf-lt tool %s wants to run a dangerous command (%s):\n%s\nAllow?", name, label, args["command"]),
+	})
+	if err != nil {
+		s.logger.Warn("dangerous command denied, confirmation failed", "tool", name, "label", label, "error", err)
+		return false
+	}
+	s.logger.Info("dangerous command confirmation", "tool", name, "label", label, "action", res.Action)
+	return res.Action == "accept"
+}
+
+func errorResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{IsError: true, Content: []mcp.Content{&mcp.TextContent{Text: text}}}
+}
+
+// argsToStrings makes tool args of the string map gf-lt tools take
+func argsToStrings(raw map[string]any) map[string]string {
+	args := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch val := v.(type) {
+		case string:
+			args[k] = val
+		case float64:
+			args[k] = strconv.FormatFloat(val, 'f', -1, 64)
+		case bool:
+			args[k] = strconv.FormatBool(val)
+		case nil:
+		default:
+			data, _ := json.Marshal(val)
+			args[k] = string(data)
+		}
+	}
+	return args
+}
+
+func chatURI(id uint32) string {
+	return chatURIPrefix + strconv.FormatUint(uint64(id), 10)
+}
+
+func ragURI(filename string) string {
+	return ragURIPrefix + url.PathEscape(filename)
+}
+
+// syncResources lists current chats and rag documents as resources
+func (s *Server) syncResources() {
+	current := map[string]*mcp.Resource{}
+	if s.chats != nil {
+		chats, err := s.chats.ListChats()
+		if err != nil {
+			s.logger.Warn("failed to list chats for mcp resources", "error", err)
+		}
+		for _, c := range chats {
+			uri := chatURI(c.ID)
+			current[uri] = &mcp.Resource{
+				URI:         uri,
+				Name:        c.Name,
+				MIMEType:    "text/plain",
+				Description: fmt.Sprintf("chat with %s, updated %s", c.Agent, c.UpdatedAt.Format(time.DateTime)),
+			}
+		}
+	}
+	if r := rag.GetInstance(); r != nil {
+		files, err := r.ListLoaded()
+		if err != nil {
+			s.logger.Warn("failed to list rag files for mcp resources", "error", err)
+		}
+		for _, f := range files {
+			uri := ragURI(f)
+			current[uri] = &mcp.Resource{URI: uri, Name: f, MIMEType: "text/plain", Description: "rag document"}
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := []string{}
+	for uri := range s.resources {
+		if _, ok := current[uri]; !ok {
+			removed = append(removed, uri)
+			delete(s.resources, uri)
+		}
+	}
+	if len(removed) > 0 {
+		s.server.RemoveResources(removed...)
+	}
+	for uri, res := range current {
+		if !s.resources[uri] {
+			s.server.AddResource(res, s.readResource)
+			s.resources[uri] = true
+		}
+	}
+}
+
+func (s *Server) readResource(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	var text string
+	switch {
+	case strings.HasPrefix(uri, chatURIPrefix):
+		id, err := strconv.ParseUint(strings.TrimPrefix(uri, chatURIPrefix), 10, 32)
+		if err != nil || s.chats == nil {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		chat, err := s.chats.GetChatByID(uint32(id))
+		if err != nil {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		text, err = chatText(chat)
+		if err != nil {
+			return nil, err
+		}
+	case strings.HasPrefix(uri, ragURIPrefix):
+		r := rag.GetInstance()
+		filename, err := url.PathUnescape(strings.TrimPrefix(uri, ragURIPrefix))
+		if err != nil || r == nil {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		text, err = r.FileText(filename)
+		if err != nil {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+	default:
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{{URI: uri, MIMEType: "text/plain", Text: text}}}, nil
+}
+
+// chatText formats chat messages as "role: content" blocks
+func chatText(chat *models.Chat) (string, error) {
+	msgs, err := chat.ToHistory()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse chat %d: %w", chat.ID, err)
+	}
+	parts := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		parts = append(parts, fmt.Sprintf("%s: %s", m.Role, m.GetText()))
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// ServeStdio serves over stdin/stdout until the client disconnects or ctx is done
+func (s *Server) ServeStdio(ctx context.Context) error {
+	return s.server.Run(ctx, &mcp.StdioTransport{})
+}
+
+// ServeHTTP serves streamable http on addr until ctx is done;
+// an address without a host binds loopback, other hosts need MCPServeToken
+func (s *Server) ServeHTTP(ctx context.Context, addr string) error {
+	addr, err := serveAddr(addr, s.cfg.MCPServeToken)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Addr: addr, Handler: s.httpHandler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// serveAddr binds ":port" to loopback; the served tools include bash and file editing,
+// so other interfaces are refused without a token
+func serveAddr(addr, token string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid mcp serve address %q: %w", addr, err)
+	}
+	if host == "" {
+		return net.JoinHostPort("127.0.0.1", port), nil
+	}
+	if ip := net.ParseIP(host); (ip == nil || !ip.IsLoopback()) && host != "localhost" && token == "" {
+		return "", fmt.Errorf("refusing to serve mcp on %s without MCPServeToken", addr)
+	}
+	return addr, nil
+}
+
+// httpHandler is the streamable http handler; with MCPServeToken set
+// requests must carry it as a bearer token
+func (s *Server) httpHandler() http.Handler {
+	handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return s.server }, nil)
+	token := s.cfg.MCPServeToken
+	if token == "" {
+		return handler
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,138 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"gf-lt/config"
+	"gf-lt/models"
+	"gf-lt/tools"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type fakeChats struct {
+	chats []models.Chat
+}
+
+func (f *fakeChats) ListChats() ([]models.Chat, error) { return f.chats, nil }
+func (f *fakeChats) GetChatByID(id uint32) (*models.Chat, error) {
+	for i := range f.chats {
+		if f.chats[i].ID == id {
+			return &f.chats[i], nil
+		}
+	}
+	return nil, errors.New("not found")
+}
+func (f *fakeChats) GetChatByChar(char string) ([]models.Chat, error)      { return nil, nil }
+func (f *fakeChats) GetLastChat() (*models.Chat, error)                    { return nil, nil }
+func (f *fakeChats) GetLastChatByAgent(agent string) (*models.Chat, error) { return nil, nil }
+func (f *fakeChats) UpsertChat(chat *models.Chat) (*models.Chat, error)    { return chat, nil }
+func (f *fakeChats) RemoveChat(id uint32) error                            { return nil }
+func (f *fakeChats) ChatGetMaxID() (uint32, error)                         { return 0, nil }
+
+func TestServer(t *testing.T) {
+	defer func(baseTools []models.Tool) { tools.BaseTools = baseTools }(tools.BaseTools)
+	tools.FnMap["echo_test"] = func(ctx context.Context, args map[string]string) []byte { return []byte("echo: " + args["text"] + " " + args["n"]) }
+	tools.BaseTools = append(tools.BaseTools, models.Tool{Type: "function", Function: models.ToolFunc{
+		Name: "echo_test", Description: "echo",
+		Parameters: models.ToolFuncParams{Type: "object", Properties: map[string]models.ToolArgProps{"text": {Type: "string"}}},
+	}})
+	defer delete(tools.FnMap, "echo_test")
+	cfg := &config.Config{MCPServeTools: []string{"echo_test", "bash", "no_such_tool"}}
+	chats := &fakeChats{chats: []models.Chat{{ID: 7, Name: "first", Agent: "bot",
+		Msgs: `[{"role":"user","content":"hi"},{"role":"bot","content":"hello"}]`}}}
+	srv := NewServer(cfg, testLogger(), chats)
+	ctx := context.Background()
+	connect := func(opts *mcp.ClientOptions) *mcp.ClientSession {
+		t1, t2 := mcp.NewInMemoryTransports()
+		if _, err := srv.server.Connect(ctx, t1, nil); err != nil {
+			t.Fatal(err)
+		}
+		session, err := mcp.NewClient(&mcp.Implementation{Name: "test", Version: "1.0.0"}, opts).Connect(ctx, t2, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return session
+	}
+	session := connect(nil)
+	defer session.Close()
+	list, err := session.ListTools(ctx, nil)
+	if err != nil || len(list.Tools) != 2 {
+		t.Fatalf("expected 2 tools: %+v %v", list, err)
+	}
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "echo_test", Arguments: map[string]any{"text": "hi", "n": 3}})
+	if err != nil || res.IsError || res.Content[0].(*mcp.TextContent).Text != "echo: hi 3" {
+		t.Fatalf("unexpected echo result: %+v %v", res, err)
+	}
+	// no elicitation support: dangerous command is denied without running
+	res, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "bash", Arguments: map[string]any{"command": "rm -rf /tmp/gf-lt-never"}})
+	if err != nil || !res.IsError || !strings.Contains(res.Content[0].(*mcp.TextContent).Text, "[denied]") {
+		t.Errorf("dangerous command not denied: %+v %v", res, err)
+	}
+	declining := connect(&mcp.ClientOptions{ElicitationHandler: func(context.Context, *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+		return &mcp.ElicitResult{Action: "decline"}, nil
+	}})
+	defer declining.Close()
+	res, err = declining.CallTool(ctx, &mcp.CallToolParams{Name: "bash", Arguments: map[string]any{"command": "rm -rf /tmp/gf-lt-never"}})
+	if err != nil || !res.IsError {
+		t.Errorf("declined command not denied: %+v %v", res, err)
+	}
+	resources, err := session.ListResources(ctx, nil)
+	if err != nil || len(resources.Resources) != 1 || resources.Resources[0].URI != "gf-lt://chats/7" {
+		t.Fatalf("unexpected resources: %+v %v", resources, err)
+	}
+	read, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "gf-lt://chats/7"})
+	if err != nil || read.Contents[0].Text != "user: hi\n\nbot: hello" {
+		t.Fatalf("unexpected chat text: %+v %v", read, err)
+	}
+	chats.chats = append(chats.chats, models.Chat{ID: 8, Name: "second", Msgs: "[]"})
+	if resources, _ := session.ListResources(ctx, nil); len(resources.Resources) != 2 {
+		t.Errorf("new chat is not listed: %+v", resources)
+	}
+	if _, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "gf-lt://chats/99"}); err == nil {
+		t.Errorf("expected error for missing chat")
+	}
+}
+
+func TestServeHTTPAuth(t *testing.T) {
+	for _, tt := range []struct {
+		addr, token, want string
+	}{
+		{":8099", "", "127.0.0.1:8099"},
+		{"localhost:8099", "", "localhost:8099"},
+		{"[::1]:8099", "", "[::1]:8099"},
+		{"0.0.0.0:8099", "", ""},
+		{"192.168.1.5:8099", "", ""},
+		{"0.0.0.0:8099", "secret", "0.0.0.0:8099"},
+		{"8099", "", ""},
+	} {
+		got, err := serveAddr(tt.addr, tt.token)
+		if got != tt.want || (err == nil) != (tt.want != "") {
+			t.Errorf("serveAddr(%q, %q) = %q, %v; want %q", tt.addr, tt.token, got, err, tt.want)
+		}
+	}
+	srv := NewServer(&config.Config{MCPServeTools: []string{"no_such_tool"}, MCPServeToken: "secret"}, testLogger(), &fakeChats{})
+	ts := httptest.NewServer(srv.httpHandler())
+	defer ts.Close()
+	for auth, want := range map[string]int{"": http.StatusUnauthorized, "Bearer wrong": http.StatusUnauthorized, "Bearer secret": http.StatusOK} {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(
+			`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json, text/event-stream")
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("authorization %q: status %d, want %d", auth, resp.StatusCode, want)
+		}
+	}
+}
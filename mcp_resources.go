@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gf-lt/mcp"
+	"os"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const mcpResourcesPage = "mcpResourcesPage"
+
+type mcpResourceRow struct {
+	resource *mcp.ResourceInfo
+	prompt   *mcp.PromptInfo
+}
+
+// expandMCPPrompt replaces /server:prompt command with the prompt text;
+// ok is false when the text is not an mcp prompt command
+func expandMCPPrompt(text string) (string, bool, error) {
+	if mcpManager == nil {
+		return "", false, nil
+	}
+	return mcpManager.ExpandPromptCommand(context.Background(), text)
+}
+
+// formatMCPPrompt makes a usage line of the prompt: /server:prompt arg=<arg> [opt=<opt>]
+func formatMCPPrompt(p *mcp.PromptInfo) string {
+	parts := []string{p.Command()}
+	for _, a := range p.Args {
+		if a.Required {
+			parts = append(parts, a.Name+"=<"+a.Name+">")
+		} else {
+			parts = append(parts, "["+a.Name+"=<"+a.Name+">]")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// attachMCPResource adds read resource contents: images go to pending image attachments,
+// text is added to the text area; returns what was attached
+func attachMCPResource(uri string, contents []mcp.ResourceContent) (string, error) {
+	var text strings.Builder
+	attached := []string{}
+	for _, c := range contents {
+		switch {
+		case len(c.Blob) > 0 && strings.HasPrefix(c.MIMEType, "image/"):
+			f, err := os.CreateTemp("", "mcp-resource-*."+strings.TrimPrefix(c.MIMEType, "image/"))
+			if err != nil {
+				return "", err
+			}
+			_, err = f.Write(c.Blob)
+			f.Close()
+			if err != nil {
+				return "", err
+			}
+			AddImageAttachment(f.Name())
+			attached = append(attached, "image "+c.URI)
+		case c.Text != "":
+			fmt.Fprintf(&text, "[resource: %s]\n%s\n", c.URI, c.Text)
+			attached = append(attached, c.URI)
+		default:
+			logger.Warn("mcp resource content is neither text nor image, skipping", "uri", c.URI, "mime", c.MIMEType)
+		}
+	}
+	if text.Len() > 0 {
+		current := textArea.GetText()
+		if current != "" && !strings.HasSuffix(current, "\n") {
+			current += "\n"
+		}
+		textArea.SetText(current+text.String(), true)
+	}
+	if len(attached) == 0 {
+		return "", fmt.Errorf("resource %s has no text or image content", uri)
+	}
+	return strings.Join(attached, ", "), nil
+}
+
+func onMCPResourceUpdated(server, uri string) {
+	if cfg.CLIMode {
+		fmt.Printf("\n[mcp] resource updated: %s (%s)\n", uri, server)
+		return
+	}
+	app.QueueUpdateDraw(func() {
+		showToast("mcp resource updated", server+": "+uri)
+	})
+}
+
+// makeMCPResourcesTable lists resources and prompts of connected mcp servers.
+// Enter on a resource attaches it to the next msg, on a prompt puts the command into the text area;
+// 's' subscribes to resource updates, 'x' exits.
+func makeMCPResourcesTable(resources []mcp.ResourceInfo, prompts []mcp.PromptInfo) *tview.Table {
+	headers := []string{"Server", "Kind", "Name", "URI / usage", "Description"}
+	table := tview.NewTable().SetBorders(true)
+	table.SetTitle("MCP resources and prompts (enter: attach/use, s: subscribe, x: exit)").SetBorder(true)
+	for c, h := range headers {
+		table.SetCell(0, c,
+			tview.NewTableCell(h).
+				SetSelectable(false).
+				SetTextColor(tcell.ColorYellow).
+				SetAlign(tview.AlignCenter).
+				SetAttributes(tcell.AttrBold))
+	}
+	rows := []mcpResourceRow{}
+	addRow := func(row mcpResourceRow, cells []string) {
+		rows = append(rows, row)
+		for c, text := range cells {
+			table.SetCell(len(rows), c,
+				tview.NewTableCell(tview.Escape(text)).
+					SetTextColor(tcell.ColorWhite).
+					SetAlign(tview.AlignLeft))
+		}
+	}
+	for i := range resources {
+		r := &resources[i]
+		addRow(mcpResourceRow{resource: r}, []string{r.Server, "resource", r.Name, r.URI, r.Description})
+	}
+	for i := range prompts {
+		p := &prompts[i]
+		addRow(mcpResourceRow{prompt: p}, []string{p.Server, "prompt", p.Name, formatMCPPrompt(p), p.Description})
+	}
+	closePage := func() {
+		pages.RemovePage(mcpResourcesPage)
+		app.SetFocus(textArea)
+	}
+	table.Select(1, 0).SetSelectable(true, false).SetFixed(1, 0)
+	table.SetSelectedFunc(func(r, c int) {
+		if r == 0 || r > len(rows) {
+			return
+		}
+		row := rows[r-1]
+		if row.prompt != nil {
+			// required args are left to fill in
+			template := row.prompt.Command()
+			for _, a := range row.prompt.Args {
+				if a.Required {
+					template += " " + a.Name + "="
+				}
+			}
+			textArea.SetText(template, true)
+			closePage()
+			return
+		}
+		res := row.resource
+		go func() {
+			contents, err := mcpManager.ReadResource(context.Background(), res.Server, res.URI)
+			app.QueueUpdateDraw(func() {
+				attached := ""
+				if err == nil {
+					attached, err = attachMCPResource(res.URI, contents)
+				}
+				if err != nil {
+					logger.Error("failed to attach mcp resource", "uri", res.URI, "error", err)
+					showToast("mcp", err.Error())
+					return
+				}
+				showToast("mcp", "attached: "+attached)
+				closePage()
+			})
+		}()
+	})
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() != tcell.KeyRune {
+			return event
+		}
+		switch event.Rune() {
+		case 'x':
+			closePage()
+			return nil
+		case 's':
+			r, _ := table.GetSelection()
+			if r == 0 || r > len(rows) || rows[r-1].resource == nil {
+				return nil
+			}
+			res := rows[r-1].resource
+			if !res.Subscribable {
+				showToast("mcp", res.Server+" does not support subscriptions")
+				return nil
+			}
+			go func() {
+				err := mcpManager.SubscribeResource(context.Background(), res.Server, res.URI)
+				app.QueueUpdateDraw(func() {
+					if err != nil {
+						logger.Error("failed to subscribe to mcp resource", "uri", res.URI, "error", err)
+						showToast("mcp", err.Error())
+						return
+					}
+					showToast("mcp", "subscribed to "+res.URI)
+				})
+			}()
+			return nil
+		}
+		return event
+	})
+	return table
+}
+
+// showMCPResources fetches resources in background and opens the page
+func showMCPResources() {
+	go func() {
+		resources, err := mcpManager.Resources(context.Background())
+		if err != nil {
+			logger.Warn("failed to list some mcp resources", "error", err)
+		}
+		prompts := mcpManager.Prompts()
+		app.QueueUpdateDraw(func() {
+			if len(resources) == 0 && len(prompts) == 0 {
+				msg := "connected MCP servers have no resources or prompts"
+				if err != nil {
+					msg = err.Error()
+				}
+				showToast("mcp", msg)
+				return
+			}
+			pages.AddPage(mcpResourcesPage, makeMCPResourcesTable(resources, prompts), true, true)
+		})
+	}()
+}
+
+// printMCPPrompts lists prompt commands in cli mode
+func printMCPPrompts() {
+	if mcpManager == nil {
+		fmt.Println("No MCP servers configured.")
+		return
+	}
+	prompts := mcpManager.Prompts()
+	if len(prompts) == 0 {
+		fmt.Println("Connected MCP servers have no prompts.")
+		return
+	}
+	fmt.Println("MCP prompts:")
+	for i := range prompts {
+		fmt.Printf("  %s\n", formatMCPPrompt(&prompts[i]))
+		if prompts[i].Description != "" {
+			fmt.Printf("      %s\n", prompts[i].Description)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const mcpPage = "mcpPage"
+
+type mcpRow struct {
+	server string
+	tool   string // empty for the server row
+}
+
+// makeMCPTable lists mcp servers and their tools.
+// Enter enables/disables the server or tool, 'r' restarts the server, 'x' exits.
+func makeMCPTable() *tview.Table {
+	headers := []string{"Server", "Tool", "Transport", "Status", "Enabled"}
+	table := tview.NewTable().SetBorders(true)
+	table.SetTitle("MCP servers (enter: enable/disable, r: restart, x: exit)").SetBorder(true)
+	rows := []mcpRow{}
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	fill := func() {
+		table.Clear()
+		rows = rows[:0]
+		for c, h := range headers {
+			table.SetCell(0, c,
+				tview.NewTableCell(h).
+					SetSelectable(false).
+					SetTextColor(tcell.ColorYellow).
+					SetAlign(tview.AlignCenter).
+					SetAttributes(tcell.AttrBold))
+		}
+		for _, s := range mcpManager.Servers() {
+			status := s.Status
+			if s.Error != "" {
+				status += ": " + s.Error
+			}
+			rows = append(rows, mcpRow{server: s.Name})
+			cells := []string{s.Name, "", s.Transport, tview.Escape(status), yesNo(s.Enabled)}
+			for c, text := range cells {
+				table.SetCell(len(rows), c,
+					tview.NewTableCell(text).
+						SetTextColor(tcell.ColorWhite).
+						SetAlign(tview.AlignCenter))
+			}
+			for _, t := range s.Tools {
+				rows = append(rows, mcpRow{server: s.Name, tool: t.Name})
+				cells := []string{"", t.Name, "", "", yesNo(t.Enabled)}
+				for c, text := range cells {
+					table.SetCell(len(rows), c,
+						tview.NewTableCell(text).
+							SetTextColor(tcell.ColorLightGray).
+							SetAlign(tview.AlignCenter))
+				}
+			}
+		}
+	}
+	fill()
+	// connecting may take a while, so it is done in background
+	inBackground := func(fn func() error) {
+		go func() {
+			err := fn()
+			app.QueueUpdateDraw(func() {
+				if err != nil {
+					logger.Error("mcp server action failed", "error", err)
+					showToast("mcp", err.Error())
+				}
+				fill()
+			})
+		}()
+	}
+	table.Select(1, 0).SetSelectable(true, false).SetFixed(1, 0)
+	table.SetSelectedFunc(func(r, c int) {
+		if r == 0 || r > len(rows) {
+			return
+		}
+		row := rows[r-1]
+		for _, s := range mcpManager.Servers() {
+			if s.Name != row.server {
+				continue
+			}
+			if row.tool == "" {
+				enabled := !s.Enabled
+				inBackground(func() error { return mcpManager.SetServerEnabled(s.Name, enabled) })
+				showToast("mcp", s.Name+": enabled "+yesNo(enabled))
+				return
+			}
+			for _, t := range s.Tools {
+				if t.Name == row.tool {
+					if err := mcpManager.SetToolEnabled(s.Name, t.Name, !t.Enabled); err != nil {
+						showToast("mcp", err.Error())
+					}
+					fill()
+					return
+				}
+			}
+		}
+	})
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() != tcell.KeyRune {
+			return event
+		}
+		switch event.Rune() {
+		case 'x':
+			pages.RemovePage(mcpPage)
+			app.SetFocus(textArea)
+			return nil
+		case 'r':
+			r, _ := table.GetSelection()
+			if r == 0 || r > len(rows) {
+				return nil
+			}
+			name := rows[r-1].server
+			showToast("mcp", "restarting "+name)
+			inBackground(func() error { return mcpManager.Restart(name) })
+			return nil
+		}
+		return event
+	})
+	return table
+}
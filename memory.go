@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gf-lt/agent"
+	"gf-lt/models"
+	"gf-lt/storage"
+	"gf-lt/tools"
+	"slices"
+	"strings"
+	"time"
+)
+
+// extractedFact is one item of llm answer when facts are extracted from a chat
+type extractedFact struct {
+	Topic      string  `json:"topic"`
+	Fact       string  `json:"fact"`
+	Importance float64 `json:"importance"`
+}
+
+const extractFactsPrompt = `Extract lasting facts worth remembering from the chat below: about the user, the characters, their relationships, preferences, plans and events.
+Skip small talk and anything that only matters for this conversation.
+Answer with a json array only: [{"topic": "short unique topic", "fact": "the fact", "importance": 0.5}]
+importance is 0 (trivia) to 1 (core fact). Answer [] if there is nothing to remember.`
+
+// saveMemory embeds the memory (if embedder is available) and stores it
+func saveMemory(m *models.Memory) error {
+	m.Embedding = nil
+	emb, err := tools.EmbedMemory(m.Topic, m.Mind)
+	if err != nil {
+		logger.Warn("failed to embed memory, it will not be recalled", "topic", m.Topic, "error", err)
+	} else {
+		m.Embedding = storage.SerializeVector(emb)
+	}
+	_, err = store.Memorise(m)
+	return err
+}
+
+// renameMemory moves the memory to another topic of its agent; an existing memory
+// of that topic is not overwritten and the old topic is removed only once the new one is saved
+func renameMemory(m *models.Memory, topic string) error {
+	topics, err := store.RecallTopics(m.Agent)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(topics, topic) {
+		return fmt.Errorf("%s already has a memory about %q", m.Agent, topic)
+	}
+	renamed := *m
+	renamed.Topic = topic
+	if err := saveMemory(&renamed); err != nil {
+		return err
+	}
+	if err := store.Forget(m.Agent, m.Topic); err != nil {
+		return fmt.Errorf("saved as %q, but failed to remove %q: %w", topic, m.Topic, err)
+	}
+	*m = renamed
+	return nil
+}
+
+// recallMemories returns memories of the agent relevant to the query, best first
+func recallMemories(agentName, query string) ([]models.Memory, error) {
+	emb, err := tools.EmbedText(query)
+	if err != nil {
+		return nil, err
+	}
+	memories, err := store.SearchMemories(agentName, emb, cfg.MemoryRecallTopK, tools.MemoryHalfLife(cfg))
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(memories, func(m models.Memory) bool {
+		return m.Score < cfg.MemoryRecallMinScore
+	}), nil
+}
+
+// injectMemories adds memories relevant to the last message right before it;
+// like lore, they are not stored in chatBody
+func injectMemories(messages []models.RoleMsg, botPersona string, resume bool) []models.RoleMsg {
+	if resume || !cfg.MemoryEnabled || cfg.MemoryRecallTopK <= 0 || len(messages) == 0 {
+		return messages
+	}
+	query := ""
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "system" && messages[i].Role != cfg.ToolRole {
+			query = messages[i].GetText()
+			break
+		}
+	}
+	if strings.TrimSpace(query) == "" {
+		return messages
+	}
+	memories, err := recallMemories(botPersona, query)
+	if err != nil {
+		logger.Warn("failed to recall memories", "agent", botPersona, "error", err)
+		return messages
+	}
+	if len(memories) == 0 {
+		return messages
+	}
+	lines := make([]string, len(memories))
+	topics := make([]string, len(memories))
+	for i, m := range memories {
+		lines[i] = fmt.Sprintf("- %s: %s", m.Topic, m.Mind)
+		topics[i] = m.Topic
+	}
+	if err := store.TouchMemories(botPersona, topics); err != nil {
+		logger.Warn("failed to mark memories as recalled", "error", err)
+	}
+	logger.Debug("memories recalled", "agent", botPersona, "topics", topics)
+	memMsg := models.RoleMsg{Role: "system", Content: "Relevant memories:\n" + strings.Join(lines, "\n")}
+	last := len(messages) - 1
+	return slices.Concat(messages[:last], []models.RoleMsg{memMsg}, messages[last:])
+}
+
+// parseExtractedFacts reads the json array from llm answer
+func parseExtractedFacts(answer string) ([]extractedFact, error) {
+	answer = models.ThinkRE.ReplaceAllString(answer, "")
+	start := strings.Index(answer, "[")
+	end := strings.LastIndex(answer, "]")
+	if start < 0 || end < start {
+		return nil, errors.New("no json array in answer")
+	}
+	facts := []extractedFact{}
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &facts); err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(facts, func(f extractedFact) bool {
+		return strings.TrimSpace(f.Topic) == "" || strings.TrimSpace(f.Fact) == ""
+	}), nil
+}
+
+// extractChatFacts asks llm for facts from the chat and stores them as memories of the agent
+func extractChatFacts(messages []models.RoleMsg, agentName string) error {
+	chat := strings.Builder{}
+	for i := range messages {
+		if messages[i].Role == "system" || messages[i].Role == cfg.ToolRole {
+			continue
+		}
+		fmt.Fprintf(&chat, "%s: %s\n", messages[i].Role, messages[i].GetText())
+	}
+	ag := agent.NewAgentClient(cfg, logger, func() string { return chunkParser.GetToken() })
+	body, err := ag.FormFirstMsg(extractFactsPrompt, chat.String())
+	if err != nil {
+		return err
+	}
+	resp, err := ag.LLMRequest(body)
+	if err != nil {
+		return err
+	}
+	facts, err := parseExtractedFacts(string(resp))
+	if err != nil {
+		return err
+	}
+	for _, f := range facts {
+		m := &models.Memory{
+			Agent:      agentName,
+			Topic:      strings.TrimSpace(f.Topic),
+			Mind:       strings.TrimSpace(f.Fact),
+			Importance: min(max(f.Importance, 0), 1),
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
+		if err := saveMemory(m); err != nil {
+			return err
+		}
+	}
+	logger.Info("facts extracted from chat", "agent", agentName, "count", len(facts))
+	return nil
+}
+
+// rememberChat extracts facts from the current chat in background, before it is replaced
+func rememberChat() {
+	if !cfg.MemoryEnabled || !cfg.MemoryExtractFacts || len(chatBody.Messages) <= 2 {
+		return
+	}
+	messages := slices.Clone(chatBody.Messages)
+	agentName := cfg.AssistantRole
+	go func() {
+		if err := extractChatFacts(messages, agentName); err != nil {
+			logger.Warn("failed to extract facts from chat", "error", err)
+		}
+	}()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"gf-lt/models"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	memoryPage     = "memoryPage"
+	memoryEditPage = "memoryEdit"
+)
+
+// makeMemoryTable lists memories of all agents; edits are re-embedded on save.
+// Enter edits the cell or deletes the memory, 'x' exits.
+func makeMemoryTable() *tview.Table {
+	headers := []string{"Agent", "Topic", "Mind", "Importance", "Recalls", "Updated", "delete"}
+	table := tview.NewTable().SetBorders(true)
+	table.SetTitle("Memories (enter: edit, x: exit)").SetBorder(true)
+	memories := []models.Memory{}
+	preview := func(s string, limit int) string {
+		s = strings.ReplaceAll(s, "\n", " ")
+		if len([]rune(s)) > limit {
+			s = string([]rune(s)[:limit]) + "..."
+		}
+		return tview.Escape(s)
+	}
+	fill := func() {
+		table.Clear()
+		for c, h := range headers {
+			table.SetCell(0, c,
+				tview.NewTableCell(h).
+					SetSelectable(false).
+					SetTextColor(tcell.ColorYellow).
+					SetAlign(tview.AlignCenter).
+					SetAttributes(tcell.AttrBold))
+		}
+		var err error
+		memories, err = store.ListMemories("")
+		if err != nil {
+			logger.Error("failed to list memories", "error", err)
+			showToast("memory", "failed to list: "+err.Error())
+			return
+		}
+		for r, m := range memories {
+			cells := []string{
+				preview(m.Agent, 20),
+				preview(m.Topic, 30),
+				preview(m.Mind, 50),
+				strconv.FormatFloat(m.Importance, 'f', 2, 64),
+				strconv.Itoa(m.RecallCount),
+				m.UpdatedAt.Format("2006-01-02 15:04"),
+				"delete",
+			}
+			for c, text := range cells {
+				table.SetCell(r+1, c,
+					tview.NewTableCell(text).
+						SetSelectable(c > 0 && c != 4 && c != 5).
+						SetTextColor(tcell.ColorWhite).
+						SetAlign(tview.AlignCenter))
+			}
+		}
+	}
+	fill()
+	modal := func(p tview.Primitive, width, height int) tview.Primitive {
+		return tview.NewFlex().
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+				AddItem(nil, 0, 1, false).
+				AddItem(p, height, 1, true).
+				AddItem(nil, 0, 1, false), width, 1, true).
+			AddItem(nil, 0, 1, false)
+	}
+	// editText opens an input (or text area for multiline) and calls done with the new value
+	editText := func(title, value string, multiline bool, done func(string)) {
+		finish := func(text string) {
+			done(text)
+			pages.RemovePage(memoryEditPage)
+			fill()
+			app.SetFocus(table)
+		}
+		if multiline {
+			area := tview.NewTextArea()
+			area.SetText(value, true)
+			area.SetTitle(title + " (esc to save)").SetBorder(true)
+			area.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+				if event.Key() == tcell.KeyEscape {
+					finish(area.GetText())
+					return nil
+				}
+				return event
+			})
+			pages.AddPage(memoryEditPage, modal(area, 100, 20), true, true)
+			app.SetFocus(area)
+			return
+		}
+		input := tview.NewInputField().SetText(value)
+		input.SetTitle(title).SetBorder(true)
+		input.SetDoneFunc(func(key tcell.Key) {
+			if key == tcell.KeyEnter {
+				finish(input.GetText())
+				return
+			}
+			pages.RemovePage(memoryEditPage)
+			app.SetFocus(table)
+		})
+		pages.AddPage(memoryEditPage, modal(input, 80, 3), true, true)
+		app.SetFocus(input)
+	}
+	save := func(m *models.Memory) {
+		if err := saveMemory(m); err != nil {
+			logger.Error("failed to save memory", "topic", m.Topic, "error", err)
+			showToast("memory", "failed to save: "+err.Error())
+		}
+	}
+	table.Select(1, 1).SetSelectable(true, true).SetFixed(1, 1)
+	table.SetSelectedFunc(func(r, c int) {
+		if r == 0 || r > len(memories) {
+			return
+		}
+		m := memories[r-1]
+		switch headers[c] {
+		case "Topic":
+			editText("topic", m.Topic, false, func(s string) {
+				s = strings.TrimSpace(s)
+				if s == "" || s == m.Topic {
+					return
+				}
+				if err := renameMemory(&m, s); err != nil {
+					logger.Error("failed to rename memory", "topic", m.Topic, "new_topic", s, "error", err)
+					showToast("memory", "failed to rename: "+err.Error())
+				}
+			})
+		case "Mind":
+			editText("mind", m.Mind, true, func(s string) {
+				if s == m.Mind {
+					return
+				}
+				m.Mind = s
+				save(&m)
+			})
+		case "Importance":
+			editText("importance (0-1)", strconv.FormatFloat(m.Importance, 'f', 2, 64), false, func(s string) {
+				imp, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+				if err != nil || imp < 0 || imp > 1 {
+					showToast("memory", "importance has to be a number from 0 to 1: "+s)
+					return
+				}
+				m.Importance = imp
+				save(&m)
+			})
+		case "delete":
+			if err := store.Forget(m.Agent, m.Topic); err != nil {
+				showToast("memory", "failed to delete: "+err.Error())
+				return
+			}
+			fill()
+			if r > len(memories) {
+				table.Select(max(len(memories), 1), c)
+			}
+			showToast("memory", fmt.Sprintf("deleted: %s/%s", m.Agent, m.Topic))
+		}
+	})
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyRune && event.Rune() == 'x' {
+			pages.RemovePage(memoryPage)
+			return nil
+		}
+		return event
+	})
+	return table
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"gf-lt/models"
+	"gf-lt/storage"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenameMemory(t *testing.T) {
+	prevStore := store
+	defer func() { store = prevStore }()
+	store = storage.NewProviderSQL(filepath.Join(t.TempDir(), "memory.db"), logger)
+	if store == nil {
+		t.Fatal("failed to open the memory db")
+	}
+	for _, m := range []models.Memory{
+		{Agent: "Bob", Topic: "cat", Mind: "the cat is called Tom"},
+		{Agent: "Bob", Topic: "dog", Mind: "the dog is called Rex"},
+	} {
+		if err := saveMemory(&m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := models.Memory{Agent: "Bob", Topic: "cat", Mind: "the cat is called Tom"}
+	if err := renameMemory(&m, "dog"); err == nil {
+		t.Error("expected rename onto an existing topic to fail")
+	}
+	if mind, _ := store.Recall("Bob", "dog"); mind != "the dog is called Rex" {
+		t.Errorf("existing memory was overwritten: %q", mind)
+	}
+	if mind, _ := store.Recall("Bob", "cat"); mind != "the cat is called Tom" {
+		t.Errorf("memory was lost by the failed rename: %q", mind)
+	}
+	if err := renameMemory(&m, "pet cat"); err != nil {
+		t.Fatal(err)
+	}
+	topics, err := store.RecallTopics("Bob")
+	if err != nil || len(topics) != 2 || m.Topic != "pet cat" {
+		t.Errorf("unexpected topics after rename: %v %v (%q)", topics, err, m.Topic)
+	}
+	if mind, _ := store.Recall("Bob", "pet cat"); mind != "the cat is called Tom" {
+		t.Errorf("renamed memory: %q", mind)
+	}
+}
@@ -0,0 +1,27 @@
+package mission
+
+import "fmt"
+
+// Budget caps the tokens and the cost of a mission; 0 is no limit
+type Budget struct {
+	MaxTokens int
+	MaxCost   float64 // USD
+}
+
+// AddUsage counts a reply against the budget; the totals are kept in the checkpoint
+func (m *Mission) AddUsage(tokens int, cost float64) {
+	m.Checkpoint.Tokens += tokens
+	m.Checkpoint.Cost += cost
+}
+
+// OverBudget tells which limit the mission went past; empty while it is within the budget
+func (m *Mission) OverBudget() string {
+	cp := m.Checkpoint
+	if m.Budget.MaxTokens > 0 && cp.Tokens > m.Budget.MaxTokens {
+		return fmt.Sprintf("used %d tokens of the %d token budget", cp.Tokens, m.Budget.MaxTokens)
+	}
+	if m.Budget.MaxCost > 0 && cp.Cost > m.Budget.MaxCost {
+		return fmt.Sprintf("spent $%.4f of the $%.4f budget", cp.Cost, m.Budget.MaxCost)
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package mission
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestOverBudget(t *testing.T) {
+	m := NewMission(&Issue{ID: "ISSUE-1"}, nil, 10, 3, true)
+	m.AddUsage(5000, 0.5)
+	if reason := m.OverBudget(); reason != "" {
+		t.Errorf("no budget set, got %q", reason)
+	}
+	m.Budget = Budget{MaxTokens: 8000, MaxCost: 1}
+	m.AddUsage(2000, 0.25)
+	if reason := m.OverBudget(); reason != "" {
+		t.Errorf("within budget, got %q", reason)
+	}
+	m.AddUsage(2000, 0.1)
+	if reason := m.OverBudget(); !strings.Contains(reason, "9000 tokens") {
+		t.Errorf("token budget not enforced: %q", reason)
+	}
+	m.Budget.MaxTokens = 0
+	m.AddUsage(0, 0.5)
+	if reason := m.OverBudget(); !strings.Contains(reason, "$1.3500") {
+		t.Errorf("cost budget not enforced: %q", reason)
+	}
+	// the totals survive a resume from the checkpoint
+	data, err := json.Marshal(m.Checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := &Checkpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		t.Fatal(err)
+	}
+	if cp.Tokens != 9000 || cp.Cost != m.Checkpoint.Cost {
+		t.Errorf("usage lost in checkpoint: %d %f", cp.Tokens, cp.Cost)
+	}
+}
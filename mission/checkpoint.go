@@ -14,6 +14,7 @@ type Checkpoint struct {
 	IssueID             string    `json:"issue_id"`
 	IssuePath           string    `json:"issue_path"`
 	ProjectPath         string    `json:"project_path"`
+	WorkDir             string    `json:"work_dir,omitempty"` // git worktree of the queue runner, used instead of ProjectPath
 	BranchName          string    `json:"branch_name"`
 	AgentCardID         string    `json:"agent_card_id"`
 	AgentCardPath       string    `json:"agent_card_path"`
@@ -21,6 +22,10 @@ type Checkpoint struct {
 	ToolCallCount       int       `json:"tool_call_count"`
 	ConsecutiveFailures int       `json:"consecutive_failures"`
 	CommitsMade         []string  `json:"commits_made"`
+	CheckResults        []CheckResult `json:"check_results,omitempty"` // of the last create_pr
+	Plan                string    `json:"plan,omitempty"` // latest plan of the PM
+	Tokens              int       `json:"tokens,omitempty"` // prompt and completion tokens of the agent replies
+	Cost                float64   `json:"cost,omitempty"`   // USD
 	CreatedAt           time.Time `json:"created_at"`
 	UpdatedAt           time.Time `json:"updated_at"`
 }
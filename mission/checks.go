@@ -0,0 +1,164 @@
+package mission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCheckTimeout = 300 * time.Second
+	// tail of check output kept for reports
+	maxCheckOutput = 4000
+)
+
+// Check is an executable acceptance check of an issue: a command that has to exit 0
+// (and print Contains, when set) or a file that has to exist (and contain Contains, when set)
+type Check struct {
+	Name     string `json:"name"` // e.g. build, test, lint
+	Command  string `json:"command,omitempty"`
+	File     string `json:"file,omitempty"` // relative to the project path
+	Contains string `json:"contains,omitempty"`
+	Timeout  int    `json:"timeout,omitempty"` // seconds; 0 is 300
+}
+
+type CheckResult struct {
+	Name     string `json:"name"`
+	Passed   bool   `json:"passed"`
+	Output   string `json:"output,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Describe is a one line description of the check for prompts and reports
+func (c Check) Describe() string {
+	var desc string
+	switch {
+	case c.Command != "":
+		desc = "`" + c.Command + "` succeeds"
+		if c.Contains != "" {
+			desc += fmt.Sprintf(" and prints %q", c.Contains)
+		}
+	case c.Contains != "":
+		desc = fmt.Sprintf("%s contains %q", c.File, c.Contains)
+	default:
+		desc = c.File + " exists"
+	}
+	if c.Name != "" {
+		desc = c.Name + ": " + desc
+	}
+	return desc
+}
+
+var errNoProjectDir = errors.New("not run: the issue has no project path")
+
+// RunChecks runs all checks in dir, one by one; without a dir they all fail,
+// commands are not run in the cwd of gf-lt
+func RunChecks(ctx context.Context, dir string, checks []Check) []CheckResult {
+	results := make([]CheckResult, 0, len(checks))
+	for i, c := range checks {
+		start := time.Now()
+		res := CheckResult{Name: c.Name}
+		if res.Name == "" {
+			res.Name = fmt.Sprintf("check %d", i+1)
+		}
+		var err error
+		if dir == "" {
+			err = errNoProjectDir
+		} else if c.Command != "" {
+			res.Output, err = runCheckCommand(ctx, dir, c)
+		} else {
+			err = checkFile(dir, c)
+		}
+		res.Passed = err == nil
+		if err != nil {
+			res.Error = err.Error()
+		}
+		res.Duration = time.Since(start).Round(time.Millisecond).String()
+		results = append(results, res)
+	}
+	return results
+}
+
+func runCheckCommand(ctx context.Context, dir string, c Check) (string, error) {
+	timeout := defaultCheckTimeout
+	if c.Timeout > 0 {
+		timeout = time.Duration(c.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	cmd.Dir = dir
+	cmd.WaitDelay = 2 * time.Second
+	out, err := cmd.CombinedOutput()
+	output := tail(string(out), maxCheckOutput)
+	if ctx.Err() == context.DeadlineExceeded {
+		return output, fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		return output, err
+	}
+	if c.Contains != "" && !strings.Contains(string(out), c.Contains) {
+		return output, fmt.Errorf("output does not contain %q", c.Contains)
+	}
+	return output, nil
+}
+
+func checkFile(dir string, c Check) error {
+	if c.File == "" {
+		return errors.New("check has neither command nor file")
+	}
+	path := c.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("file %s: %w", c.File, err)
+	}
+	if c.Contains != "" && !strings.Contains(string(data), c.Contains) {
+		return fmt.Errorf("file %s does not contain %q", c.File, c.Contains)
+	}
+	return nil
+}
+
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "...\n" + s[len(s)-n:]
+}
+
+func ChecksPassed(results []CheckResult) bool {
+	for _, r := range results {
+		if !r.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// ChecksMarkdown is the checks section of the PR file
+func ChecksMarkdown(results []CheckResult) string {
+	sb := strings.Builder{}
+	for _, r := range results {
+		mark := "PASS"
+		if !r.Passed {
+			mark = "FAIL"
+		}
+		fmt.Fprintf(&sb, "- **%s** %s (%s)", mark, r.Name, r.Duration)
+		if r.Error != "" {
+			fmt.Fprintf(&sb, ": %s", r.Error)
+		}
+		sb.WriteString("\n")
+		if out := strings.TrimSpace(r.Output); out != "" {
+			fmt.Fprintf(&sb, "\n```\n%s\n```\n\n", out)
+		}
+	}
+	return sb.String()
+}
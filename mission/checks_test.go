@@ -0,0 +1,51 @@
+package mission
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunChecks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	checks := []Check{
+		{Name: "build", Command: "pwd"},
+		{Name: "test", Command: "echo 'FAIL x'; exit 1"},
+		{Name: "lint", Command: "echo ok", Contains: "clean"},
+		{Name: "file", File: "main.go", Contains: "package main"},
+		{Name: "missing", File: "README.md"},
+		{Command: "sleep 5", Timeout: 1},
+	}
+	results := RunChecks(context.Background(), dir, checks)
+	want := []bool{true, false, false, true, false, false}
+	for i, r := range results {
+		if r.Passed != want[i] {
+			t.Errorf("check %q: passed %v, want %v (%s)", r.Name, r.Passed, want[i], r.Error)
+		}
+	}
+	if !strings.Contains(results[0].Output, filepath.Base(dir)) {
+		t.Errorf("command did not run in the project dir: %q", results[0].Output)
+	}
+	if !strings.Contains(results[1].Output, "FAIL x") {
+		t.Errorf("failed check output is lost: %q", results[1].Output)
+	}
+	if results[5].Name != "check 6" || !strings.Contains(results[5].Error, "timed out") {
+		t.Errorf("unexpected timeout result: %+v", results[5])
+	}
+	if ChecksPassed(results) || !ChecksPassed(results[:1]) {
+		t.Error("ChecksPassed does not follow the results")
+	}
+	for _, r := range RunChecks(context.Background(), "", checks[:1]) {
+		if r.Passed || r.Output != "" {
+			t.Errorf("check ran without a project path: %+v", r)
+		}
+	}
+	if md := ChecksMarkdown(results[:2]); !strings.Contains(md, "**PASS** build") || !strings.Contains(md, "**FAIL** test") {
+		t.Errorf("unexpected checks markdown:\n%s", md)
+	}
+}
@@ -41,6 +41,7 @@ type Issue struct {
 	RelatedIssues     []string     `json:"related_issues,omitempty"`
 	AcceptanceCriteria []string    `json:"acceptance_criteria,omitempty"`
 	ContextFiles      []string     `json:"context_files,omitempty"`
+	Checks            []Check      `json:"checks,omitempty"` // run by create_pr
 	Comments          []Comment    `json:"comments,omitempty"`
 }
 
@@ -176,6 +177,10 @@ func (m *IssueManager) MoveIssue(id string, fromStatus, toStatus IssueStatus) er
 	}
 
 	issue.MoveStatus(toStatus)
+	data, err = json.MarshalIndent(&issue, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal issue JSON: %w", err)
+	}
 
 	if err := os.WriteFile(toPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write to new location: %w", err)
@@ -0,0 +1,243 @@
+package mission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Markdown issue: YAML frontmatter with the issue fields, the description as body, then
+// "## Acceptance Criteria" checklist and "## Comments" with a "### author, time" heading per comment.
+// Only the YAML gf-lt writes is understood: scalars, flow lists ([a, "b"]) and block lists (- a).
+
+const commentsHeading = "## Comments"
+
+// ParseMarkdownIssue reads an issue from markdown with YAML frontmatter
+func ParseMarkdownIssue(data []byte) (*Issue, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if !strings.HasPrefix(text, "---\n") {
+		return nil, errors.New("markdown issue has no frontmatter")
+	}
+	front, body, ok := strings.Cut(text[len("---\n"):], "\n---")
+	if !ok {
+		return nil, errors.New("markdown issue frontmatter is not closed with ---")
+	}
+	if i := strings.IndexByte(body, '\n'); i >= 0 {
+		body = body[i+1:]
+	} else {
+		body = ""
+	}
+	scalars, lists, err := parseFrontmatter(front)
+	if err != nil {
+		return nil, err
+	}
+	list := func(key string) []string {
+		if items, ok := lists[key]; ok {
+			return items
+		}
+		return flowList(scalars[key])
+	}
+	issue := &Issue{
+		Version:       IssueVersion,
+		ID:            yamlString(scalars["id"]),
+		Title:         yamlString(scalars["title"]),
+		Status:        IssueStatus(yamlString(scalars["status"])),
+		ProjectPath:   yamlString(scalars["project_path"]),
+		BranchName:    yamlString(scalars["branch_name"]),
+		Priority:      yamlString(scalars["priority"]),
+		Labels:        list("labels"),
+		RelatedIssues: list("related_issues"),
+		ContextFiles:  list("context_files"),
+	}
+	if issue.ID == "" {
+		return nil, errors.New("markdown issue has no id")
+	}
+	if !slices.Contains(IssueStatuses, issue.Status) {
+		issue.Status = StatusOpen
+	}
+	if raw := scalars["checks"]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &issue.Checks); err != nil {
+			return nil, fmt.Errorf("markdown issue checks: %w", err)
+		}
+	}
+	issue.CreatedAt = yamlTime(scalars["created_at"])
+	issue.UpdatedAt = yamlTime(scalars["updated_at"])
+	if i := lastHeading(body, commentsHeading); i >= 0 {
+		issue.Comments = parseMarkdownComments(body[i+len(commentsHeading):])
+		body = body[:i]
+	}
+	issue.Description, issue.AcceptanceCriteria = splitAcceptance(strings.TrimSpace(body))
+	return issue, nil
+}
+
+// MarshalMarkdownIssue writes the issue as markdown with YAML frontmatter
+func MarshalMarkdownIssue(issue *Issue) []byte {
+	sb := strings.Builder{}
+	sb.WriteString("---\n")
+	scalar := func(key, value string) {
+		if value != "" || key == "id" || key == "title" {
+			fmt.Fprintf(&sb, "%s: %s\n", key, strconv.Quote(value))
+		}
+	}
+	// json is YAML flow syntax
+	flow := func(key string, value any, empty bool) {
+		if empty {
+			return
+		}
+		data, _ := json.Marshal(value)
+		fmt.Fprintf(&sb, "%s: %s\n", key, data)
+	}
+	scalar("id", issue.ID)
+	scalar("title", issue.Title)
+	scalar("status", string(issue.Status))
+	scalar("priority", issue.Priority)
+	flow("labels", issue.Labels, len(issue.Labels) == 0)
+	scalar("project_path", issue.ProjectPath)
+	scalar("branch_name", issue.BranchName)
+	flow("related_issues", issue.RelatedIssues, len(issue.RelatedIssues) == 0)
+	flow("context_files", issue.ContextFiles, len(issue.ContextFiles) == 0)
+	flow("checks", issue.Checks, len(issue.Checks) == 0)
+	if !issue.CreatedAt.IsZero() {
+		scalar("created_at", issue.CreatedAt.Format(time.RFC3339))
+	}
+	if !issue.UpdatedAt.IsZero() {
+		scalar("updated_at", issue.UpdatedAt.Format(time.RFC3339))
+	}
+	sb.WriteString("---\n\n")
+	done := issue.Status == StatusDone || issue.Status == StatusArchive
+	if body := joinAcceptance(issue.Description, issue.AcceptanceCriteria, done); body != "" {
+		sb.WriteString(strings.TrimRight(body, "\n") + "\n")
+	}
+	if len(issue.Comments) > 0 {
+		sb.WriteString("\n" + commentsHeading + "\n")
+		for _, c := range issue.Comments {
+			head := c.Author
+			if !c.CreatedAt.IsZero() {
+				head += ", " + c.CreatedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(&sb, "\n### %s\n\n%s\n", head, strings.TrimSpace(c.Body))
+		}
+	}
+	return []byte(sb.String())
+}
+
+// parseFrontmatter returns raw values of "key: value" lines and items of block lists
+func parseFrontmatter(front string) (map[string]string, map[string][]string, error) {
+	scalars := map[string]string{}
+	lists := map[string][]string{}
+	listKey := ""
+	for n, line := range strings.Split(front, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok && listKey != "" {
+			lists[listKey] = append(lists[listKey], yamlString(item))
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, " ") {
+			return nil, nil, fmt.Errorf("frontmatter line %d: expected key: value, got %q", n+1, line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		listKey = ""
+		if value == "" {
+			listKey = key
+			lists[key] = []string{}
+			continue
+		}
+		scalars[key] = value
+	}
+	return scalars, lists, nil
+}
+
+func yamlString(v string) string {
+	v = strings.TrimSpace(v)
+	switch {
+	case len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"':
+		if s, err := strconv.Unquote(v); err == nil {
+			return s
+		}
+		return v[1 : len(v)-1]
+	case len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'':
+		return strings.ReplaceAll(v[1:len(v)-1], "''", "'")
+	}
+	return v
+}
+
+// flowList reads [a, "b"]; a single value is a list of one
+func flowList(v string) []string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil
+	}
+	if !strings.HasPrefix(v, "[") {
+		return []string{yamlString(v)}
+	}
+	var items []string
+	if err := json.Unmarshal([]byte(v), &items); err == nil {
+		return items
+	}
+	items = []string{}
+	for _, item := range strings.Split(strings.Trim(v, "[]"), ",") {
+		if item = yamlString(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func yamlTime(v string) time.Time {
+	v = yamlString(v)
+	for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// lastHeading is the offset of the last line that is the heading, -1 when there is none
+func lastHeading(body, heading string) int {
+	lines := strings.Split(body, "\n")
+	offset, found := 0, -1
+	for _, line := range lines {
+		if strings.EqualFold(strings.TrimSpace(line), heading) {
+			found = offset
+		}
+		offset += len(line) + 1
+	}
+	return found
+}
+
+func parseMarkdownComments(section string) []Comment {
+	comments := []Comment{}
+	var current *Comment
+	body := []string{}
+	flush := func() {
+		if current != nil {
+			current.Body = strings.TrimSpace(strings.Join(body, "\n"))
+			comments = append(comments, *current)
+		}
+		body = body[:0]
+	}
+	for _, line := range strings.Split(section, "\n") {
+		if head, ok := strings.CutPrefix(line, "### "); ok {
+			flush()
+			current = &Comment{Author: strings.TrimSpace(head)}
+			if author, ts, ok := strings.Cut(head, ", "); ok {
+				if t := yamlTime(ts); !t.IsZero() {
+					current.Author, current.CreatedAt = strings.TrimSpace(author), t
+				}
+			}
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+	return comments
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -27,11 +28,17 @@ type Mission struct {
 	MaxFailures       int
 	Quiet             bool
 	PMGuidanceNeeded  bool
+	PMReason          string // why the PM checks in
+	Triggers          []PMTrigger
+	StopReason        string // set when the PM ends the mission
 	LastToolCall      string
 	SameToolCount     int
+	Trace             *Tracer // nil when the mission is not traced
+	Budget            Budget
 }
 
 func NewMission(issue *Issue, issueManager *IssueManager, pmInterval, maxFailures int, quiet bool) *Mission {
+	triggers, _ := NewPMTriggers(nil, PMOptions{})
 	return &Mission{
 		Status:      StatusRunning,
 		Issue:      issue,
@@ -40,6 +47,7 @@ func NewMission(issue *Issue, issueManager *IssueManager, pmInterval, maxFailure
 		PMInterval: pmInterval,
 		MaxFailures: maxFailures,
 		Quiet:      quiet,
+		Triggers:   triggers,
 	}
 }
 
@@ -69,8 +77,27 @@ func (m *Mission) LogTool(name string, args ...interface{}) {
 
 func (m *Mission) IncrementToolCalls() {
 	m.Checkpoint.IncrementToolCalls()
-	if m.ShouldPMCheckIn() {
+}
+
+// ObserveToolCall tracks repeated calls and runs the PM triggers; every trigger sees every call
+func (m *Mission) ObserveToolCall(step PMStep) {
+	key := step.Tool + ":" + step.Args
+	if key == m.LastToolCall {
+		m.SameToolCount++
+	} else {
+		m.LastToolCall = key
+		m.SameToolCount = 1
+	}
+	reasons := []string{}
+	for _, t := range m.Triggers {
+		if reason := t.Check(m, step); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	if len(reasons) > 0 && !m.PMGuidanceNeeded {
 		m.PMGuidanceNeeded = true
+		m.PMReason = strings.Join(reasons, "; ")
+		m.Log("PM check-in needed: %s", m.PMReason)
 	}
 }
 
@@ -213,6 +240,14 @@ func (m *Mission) SaveIssue() error {
 	return SaveIssue(m.Issue, path)
 }
 
+// ProjectDir is where the mission works: its worktree or the issue project path
+func (m *Mission) ProjectDir() string {
+	if m.Checkpoint.WorkDir != "" {
+		return m.Checkpoint.WorkDir
+	}
+	return m.Issue.ProjectPath
+}
+
 func (m *Mission) GetConversationForLLM() []Message {
 	return m.Checkpoint.Conversation
 }
@@ -230,18 +265,54 @@ type MissionResult struct {
 	ToolCalls  int
 	Duration   time.Duration
 	Error      error
+	Checks     []CheckResult
+	Trace      string // trace file of the mission steps
+	Tokens     int     // used by the agent replies
+	Cost       float64 // USD
+	// set by the queue runner
+	Worktree   string
+	Checkpoint string
+	Log        string
 }
 
-func (r MissionResult) ToJSON() string {
-	data, _ := json.MarshalIndent(map[string]interface{}{
-		"status":       r.Status,
-		"issue_id":     r.IssueID,
-		"branch_name":  r.BranchName,
-		"pr_url":       r.PRURL,
-		"commits":      r.Commits,
-		"tool_calls":    r.ToolCalls,
+func (r MissionResult) MarshalJSON() ([]byte, error) {
+	errMsg := ""
+	if r.Error != nil {
+		errMsg = r.Error.Error()
+	}
+	fields := map[string]interface{}{
+		"status":           r.Status,
+		"issue_id":         r.IssueID,
+		"branch_name":      r.BranchName,
+		"pr_url":           r.PRURL,
+		"commits":          r.Commits,
+		"tool_calls":       r.ToolCalls,
 		"session_duration": r.Duration.String(),
-		"error":        r.Error,
-	}, "", "  ")
+		"error":            errMsg,
+	}
+	if r.Tokens > 0 {
+		fields["tokens"] = r.Tokens
+		fields["cost"] = r.Cost
+	}
+	if len(r.Checks) > 0 {
+		fields["checks"] = r.Checks
+	}
+	if r.Trace != "" {
+		fields["trace"] = r.Trace
+	}
+	if r.Worktree != "" {
+		fields["worktree"] = r.Worktree
+	}
+	if r.Checkpoint != "" {
+		fields["checkpoint"] = r.Checkpoint
+	}
+	if r.Log != "" {
+		fields["log"] = r.Log
+	}
+	return json.Marshal(fields)
+}
+
+func (r MissionResult) ToJSON() string {
+	data, _ := json.MarshalIndent(r, "", "  ")
 	return string(data)
-}
\ No newline at end of file
+}
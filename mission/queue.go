@@ -0,0 +1,154 @@
+package mission
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// order of issue priorities in the queue; issues without priority go with medium
+var priorityRank = map[string]int{
+	"critical": 0,
+	"urgent":   0,
+	"high":     1,
+	"medium":   2,
+	"normal":   2,
+	"":         2,
+	"low":      3,
+}
+
+func issuePriorityRank(priority string) int {
+	if rank, ok := priorityRank[strings.ToLower(strings.TrimSpace(priority))]; ok {
+		return rank
+	}
+	return len(priorityRank)
+}
+
+// labelRank is the position in labels of the first label the issue has; -1 when it has none of them
+func labelRank(issue *Issue, labels []string) int {
+	for i, label := range labels {
+		if slices.Contains(issue.Labels, label) {
+			return i
+		}
+	}
+	return -1
+}
+
+// IssueStatuses are the issue directories in workflow order
+var IssueStatuses = []IssueStatus{StatusOpen, StatusInProgress, StatusReview, StatusDone, StatusArchive}
+
+// ListIssues returns issues of the status directory ordered by priority, then by creation time;
+// files that fail to load are skipped
+func (m *IssueManager) ListIssues(status IssueStatus) ([]*Issue, error) {
+	dir := m.StatusDir(status)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s directory: %w", status, err)
+	}
+	issues := []*Issue{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		issue, err := LoadIssue(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issueLess(issues[i], issues[j], nil)
+	})
+	return issues, nil
+}
+
+func issueLess(a, b *Issue, labels []string) bool {
+	if pa, pb := issuePriorityRank(a.Priority), issuePriorityRank(b.Priority); pa != pb {
+		return pa < pb
+	}
+	if la, lb := labelRank(a, labels), labelRank(b, labels); la != lb {
+		return la < lb
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// QueueIssues returns open issues to run one after another: only issues with one of labels
+// (all when labels is empty), ordered by priority, then by the order of labels, then by creation time
+func (m *IssueManager) QueueIssues(labels []string) ([]*Issue, error) {
+	open, err := m.ListIssues(StatusOpen)
+	if err != nil {
+		return nil, err
+	}
+	issues := []*Issue{}
+	for _, issue := range open {
+		if len(labels) > 0 && labelRank(issue, labels) < 0 {
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issueLess(issues[i], issues[j], labels)
+	})
+	return issues, nil
+}
+
+// DefaultBranchName is the worktree branch of an issue without branch_name
+func DefaultBranchName(issue *Issue) string {
+	return "mission/issue-" + issue.ID
+}
+
+func git(repo string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// AddWorktree checks out branch into dir as a git worktree of repo; the branch is made
+// from HEAD of repo when it does not exist. A worktree already in dir is reused.
+func AddWorktree(repo, dir, branch string) error {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return fmt.Errorf("failed to create worktrees directory: %w", err)
+	}
+	if _, err := git(repo, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		_, err = git(repo, "worktree", "add", dir, branch)
+		return err
+	}
+	_, err := git(repo, "worktree", "add", "-b", branch, dir)
+	return err
+}
+
+// RemoveWorktree removes the worktree in dir; its branch is kept
+func RemoveWorktree(repo, dir string) error {
+	_, err := git(repo, "worktree", "remove", "--force", dir)
+	return err
+}
+
+// QueueSummary is the final report of the queue runner
+type QueueSummary struct {
+	StartedAt time.Time       `json:"started_at"`
+	Duration  string          `json:"duration"`
+	Parallel  int             `json:"parallel"`
+	Succeeded int             `json:"succeeded"`
+	Failed    int             `json:"failed"`
+	Results   []MissionResult `json:"results"`
+}
+
+func (s *QueueSummary) ToJSON() string {
+	data, _ := json.MarshalIndent(s, "", "  ")
+	return string(data)
+}
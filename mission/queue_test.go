@@ -0,0 +1,141 @@
+package mission
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestQueueIssues(t *testing.T) {
+	m := NewIssueManager(t.TempDir())
+	if err := os.MkdirAll(m.StatusDir(StatusOpen), 0755); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, issue := range []*Issue{
+		{ID: "1", Priority: "low", Labels: []string{"bug"}, CreatedAt: now},
+		{ID: "2", Priority: "high", Labels: []string{"ui"}, CreatedAt: now.Add(time.Minute)},
+		{ID: "3", Priority: "high", Labels: []string{"bug"}, CreatedAt: now.Add(2 * time.Minute)},
+		{ID: "4", Labels: []string{"docs"}, CreatedAt: now.Add(-time.Minute)},
+		{ID: "5", Priority: "high", Labels: []string{"bug"}, CreatedAt: now.Add(-time.Hour)},
+	} {
+		if err := CreateIssue(m.IssuePath(issue.ID, StatusOpen), issue); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := func(issues []*Issue) []string {
+		resp := []string{}
+		for _, i := range issues {
+			resp = append(resp, i.ID)
+		}
+		return resp
+	}
+	tests := []struct {
+		labels []string
+		want   []string
+	}{
+		{nil, []string{"5", "2", "3", "4", "1"}},
+		{[]string{"bug", "ui"}, []string{"5", "3", "2", "1"}},
+		{[]string{"ui", "bug"}, []string{"2", "5", "3", "1"}},
+		{[]string{"docs"}, []string{"4"}},
+	}
+	for _, tt := range tests {
+		issues, err := m.QueueIssues(tt.labels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(issues); !slices.Equal(got, tt.want) {
+			t.Errorf("labels %v: got %v, want %v", tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestListIssues(t *testing.T) {
+	m := NewIssueManager(t.TempDir())
+	if _, err := m.ListIssues(StatusReview); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing directory: got %v", err)
+	}
+	if err := os.MkdirAll(m.StatusDir(StatusOpen), 0755); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, issue := range []*Issue{
+		{ID: "1", Priority: "low", CreatedAt: now},
+		{ID: "2", Priority: "critical", CreatedAt: now},
+		{ID: "3", CreatedAt: now},
+	} {
+		if err := CreateIssue(m.IssuePath(issue.ID, StatusOpen), issue); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.MoveIssue("2", StatusOpen, StatusReview); err != nil {
+		t.Fatal(err)
+	}
+	open, err := m.ListIssues(StatusOpen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open) != 2 || open[0].ID != "3" || open[1].ID != "1" {
+		t.Errorf("unexpected open issues: %v", open)
+	}
+	review, err := m.ListIssues(StatusReview)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// moved file has its new status
+	if len(review) != 1 || review[0].Status != StatusReview {
+		t.Errorf("unexpected review issues: %v", review)
+	}
+}
+
+func TestWorktree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if _, err := git(repo, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := filepath.Join(t.TempDir(), "worktrees", "7")
+	if err := AddWorktree(repo, dir, "mission/issue-7"); err != nil {
+		t.Fatal(err)
+	}
+	// reused when the queue runs again
+	if err := AddWorktree(repo, dir, "mission/issue-7"); err != nil {
+		t.Fatal(err)
+	}
+	if branch, err := git(dir, "rev-parse", "--abbrev-ref", "HEAD"); err != nil || branch != "mission/issue-7" {
+		t.Fatalf("unexpected worktree branch %q: %v", branch, err)
+	}
+	if err := RemoveWorktree(repo, dir); err != nil {
+		t.Fatal(err)
+	}
+	// branch is kept and checked out again
+	if err := AddWorktree(repo, dir, "mission/issue-7"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMissionResultJSON(t *testing.T) {
+	data := (&QueueSummary{Results: []MissionResult{{Status: StatusFailed, IssueID: "3", Error: os.ErrNotExist, Log: "3.log"}}}).ToJSON()
+	var got struct {
+		Results []map[string]any `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	r := got.Results[0]
+	if r["issue_id"] != "3" || r["error"] != "file does not exist" || r["log"] != "3.log" || r["worktree"] != nil {
+		t.Errorf("unexpected result json: %v", r)
+	}
+}
@@ -0,0 +1,285 @@
+package mission
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PMStep is a finished tool call as seen by the PM triggers
+type PMStep struct {
+	Tool    string
+	Args    string // raw json args
+	Command string // command of bash calls
+	Error   bool
+}
+
+// PMTrigger decides after every tool call whether the PM checks in;
+// it returns the reason shown to the PM, or "" to stay quiet
+type PMTrigger interface {
+	Name() string
+	Check(m *Mission, step PMStep) string
+}
+
+// PMOptions are the thresholds of the built-in triggers
+type PMOptions struct {
+	LoopRepeats  int // same tool call with same args in a row
+	NoDiffCalls  int // tool calls without a change of the work tree
+	TestFailures int // failed test runs in a row
+}
+
+var DefaultPMOptions = PMOptions{LoopRepeats: 3, NoDiffCalls: 30, TestFailures: 2}
+
+// DefaultPMTriggers are used when the config names none
+var DefaultPMTriggers = []string{"interval", "loop", "no_diff", "test_failures", "failures"}
+
+var pmTriggers = map[string]func(PMOptions) PMTrigger{
+	"interval":      func(PMOptions) PMTrigger { return intervalTrigger{} },
+	"loop":          func(o PMOptions) PMTrigger { return loopTrigger{repeats: o.LoopRepeats} },
+	"no_diff":       func(o PMOptions) PMTrigger { return &noDiffTrigger{calls: o.NoDiffCalls} },
+	"test_failures": func(o PMOptions) PMTrigger { return &testFailuresTrigger{limit: o.TestFailures} },
+	"failures":      func(PMOptions) PMTrigger { return failuresTrigger{} },
+}
+
+// RegisterPMTrigger adds a trigger that can be named in MissionPMTriggers
+func RegisterPMTrigger(name string, fn func(PMOptions) PMTrigger) {
+	pmTriggers[name] = fn
+}
+
+// NewPMTriggers makes the named triggers; zero thresholds take the defaults
+func NewPMTriggers(names []string, opts PMOptions) ([]PMTrigger, error) {
+	if len(names) == 0 {
+		names = DefaultPMTriggers
+	}
+	if opts.LoopRepeats <= 0 {
+		opts.LoopRepeats = DefaultPMOptions.LoopRepeats
+	}
+	if opts.NoDiffCalls <= 0 {
+		opts.NoDiffCalls = DefaultPMOptions.NoDiffCalls
+	}
+	if opts.TestFailures <= 0 {
+		opts.TestFailures = DefaultPMOptions.TestFailures
+	}
+	triggers := make([]PMTrigger, 0, len(names))
+	for _, name := range names {
+		fn, ok := pmTriggers[strings.TrimSpace(name)]
+		if !ok {
+			known := make([]string, 0, len(pmTriggers))
+			for k := range pmTriggers {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown PM trigger %q (known: %s)", name, strings.Join(known, ", "))
+		}
+		triggers = append(triggers, fn(opts))
+	}
+	return triggers, nil
+}
+
+// intervalTrigger checks in every PMInterval tool calls
+type intervalTrigger struct{}
+
+func (intervalTrigger) Name() string { return "interval" }
+
+func (intervalTrigger) Check(m *Mission, _ PMStep) string {
+	if !m.ShouldPMCheckIn() {
+		return ""
+	}
+	return fmt.Sprintf("regular check-in after %d tool calls", m.Checkpoint.ToolCallCount)
+}
+
+// loopTrigger checks in when the agent repeats the same call
+type loopTrigger struct{ repeats int }
+
+func (loopTrigger) Name() string { return "loop" }
+
+func (t loopTrigger) Check(m *Mission, step PMStep) string {
+	if m.SameToolCount == 0 || m.SameToolCount%t.repeats != 0 {
+		return ""
+	}
+	return fmt.Sprintf("loop: %s called %d times in a row with the same args", step.Tool, m.SameToolCount)
+}
+
+// noDiffTrigger checks in when the work tree did not change for a number of tool calls;
+// git runs once per that many calls
+type noDiffTrigger struct {
+	calls     int
+	state     string
+	checkedAt int
+	started   bool
+}
+
+func (*noDiffTrigger) Name() string { return "no_diff" }
+
+func (t *noDiffTrigger) Check(m *Mission, _ PMStep) string {
+	dir := m.ProjectDir()
+	if dir == "" {
+		return ""
+	}
+	n := m.Checkpoint.ToolCallCount
+	if t.started && n-t.checkedAt < t.calls {
+		return ""
+	}
+	state, err := workTreeState(dir)
+	if err != nil {
+		return "" // not a git repo
+	}
+	unchanged := t.started && state == t.state
+	t.state, t.checkedAt, t.started = state, n, true
+	if !unchanged {
+		return ""
+	}
+	return fmt.Sprintf("no changes in the work tree for the last %d tool calls", t.calls)
+}
+
+// workTreeState changes with every commit and every edit of the work tree
+func workTreeState(dir string) (string, error) {
+	status, err := git(dir, "status", "--porcelain")
+	if err != nil {
+		return "", err
+	}
+	head, _ := git(dir, "rev-parse", "HEAD")
+	diff, _ := git(dir, "diff", "HEAD")
+	sum := sha256.Sum256([]byte(head + "\x00" + status + "\x00" + diff))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+var testCommandRE = regexp.MustCompile(`(^|[\s;&|(])(go test|pytest|python3? -m (pytest|unittest)|npm (run )?test|yarn test|pnpm test|cargo test|make (test|check)|mvn test|gradle test|\./gradlew test|jest|vitest|rspec|phpunit|ctest|mix test|dotnet test)\b`)
+
+// IsTestCommand tells if the bash command runs tests
+func IsTestCommand(command string) bool {
+	return testCommandRE.MatchString(command)
+}
+
+// testFailuresTrigger checks in when test runs keep failing
+type testFailuresTrigger struct {
+	limit    int
+	failures int
+}
+
+func (*testFailuresTrigger) Name() string { return "test_failures" }
+
+func (t *testFailuresTrigger) Check(_ *Mission, step PMStep) string {
+	if !IsTestCommand(step.Command) {
+		return ""
+	}
+	if !step.Error {
+		t.failures = 0
+		return ""
+	}
+	t.failures++
+	if t.failures%t.limit != 0 {
+		return ""
+	}
+	return fmt.Sprintf("tests failed %d times in a row: %s", t.failures, step.Command)
+}
+
+// failuresTrigger checks in on the failures right before the mission aborts
+type failuresTrigger struct{}
+
+func (failuresTrigger) Name() string { return "failures" }
+
+func (failuresTrigger) Check(m *Mission, step PMStep) string {
+	failures := m.Checkpoint.ConsecutiveFailures
+	if !step.Error || failures < max(m.MaxFailures-1, 1) || failures >= m.MaxFailures {
+		return ""
+	}
+	return fmt.Sprintf("%d consecutive failed tool calls, the mission aborts at %d", failures, m.MaxFailures)
+}
+
+// decisions of a PM check-in
+const (
+	PMContinue = "continue"
+	PMReplan   = "replan" // PM wrote a new plan
+	PMStop     = "stop"   // PM ends the mission
+)
+
+// PMReplyFormat is asked of the PM on check-ins and read by ParsePMDecision
+const PMReplyFormat = `Answer in this format:
+DECISION: continue | replan | stop <reason>
+PLAN:
+1. <step>
+GUIDANCE:
+<instructions for the agent>
+
+Use replan with a PLAN when the approach has to change; the plan replaces the current one.
+Use stop only when the issue cannot be solved this way or the agent is not making progress; it ends the mission.`
+
+// PMDecision is a parsed PM check-in reply
+type PMDecision struct {
+	Action   string
+	Reason   string // of stop
+	Plan     string
+	Guidance string
+}
+
+var pmHeaderRE = regexp.MustCompile(`(?i)^[\s#*_]*(decision|plan|guidance)[*_]*\s*:[*_]*\s*(.*)$`)
+
+// ParsePMDecision reads a PM reply; a reply without the format is guidance to continue
+func ParsePMDecision(reply string) PMDecision {
+	sections := map[string][]string{}
+	section := "guidance"
+	for _, line := range strings.Split(reply, "\n") {
+		if m := pmHeaderRE.FindStringSubmatch(line); m != nil {
+			section = strings.ToLower(m[1])
+			if m[2] != "" {
+				sections[section] = append(sections[section], m[2])
+			}
+			continue
+		}
+		sections[section] = append(sections[section], line)
+	}
+	text := func(name string) string {
+		return strings.TrimSpace(strings.Join(sections[name], "\n"))
+	}
+	d := PMDecision{Action: PMContinue, Plan: text("plan"), Guidance: text("guidance")}
+	action, reason, _ := strings.Cut(text("decision"), " ")
+	switch strings.ToLower(strings.Trim(action, ".,:;*|")) {
+	case PMStop:
+		d.Action = PMStop
+		d.Reason = strings.TrimSpace(strings.TrimLeft(reason, "-:—"))
+		if d.Reason == "" {
+			d.Reason = d.Guidance
+		}
+		if d.Reason == "" {
+			d.Reason = "stopped by the PM"
+		}
+	case PMReplan:
+		if d.Plan != "" {
+			d.Action = PMReplan
+		}
+	}
+	if d.Action == PMContinue && d.Plan != "" {
+		d.Action = PMReplan
+	}
+	return d
+}
+
+// ApplyPMDecision keeps the new plan, marks the mission to stop when the PM says so
+// and saves the review as an issue comment
+func (m *Mission) ApplyPMDecision(reason string, d PMDecision) {
+	if d.Plan != "" {
+		m.Checkpoint.Plan = d.Plan
+	}
+	if d.Action == PMStop {
+		m.StopReason = d.Reason
+	}
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "PM check-in (%s): %s", reason, d.Action)
+	if d.Action == PMStop {
+		sb.WriteString(" - " + d.Reason)
+	}
+	if d.Guidance != "" {
+		sb.WriteString("\n\n" + d.Guidance)
+	}
+	if d.Plan != "" {
+		sb.WriteString("\n\nPlan:\n" + d.Plan)
+	}
+	m.AddIssueComment("pm", sb.String())
+	if err := m.SaveIssue(); err != nil {
+		m.Log("Warning: failed to save PM comment: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package mission
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePMDecision(t *testing.T) {
+	tests := []struct {
+		reply  string
+		action string
+		plan   string
+		reason string
+	}{
+		{"Looks fine, continue.", PMContinue, "", ""},
+		{"DECISION: continue\nGUIDANCE:\nRun the tests now.", PMContinue, "", ""},
+		{"**Decision:** replan\n**Plan:**\n1. revert main.go\n2. add a test\nGUIDANCE: start over", PMReplan, "1. revert main.go\n2. add a test", ""},
+		{"DECISION: replan\nGUIDANCE: no plan given", PMContinue, "", ""},
+		{"DECISION: stop - the issue needs a database the agent cannot reach\nGUIDANCE:\nstop here", PMStop, "", "the issue needs a database the agent cannot reach"},
+		{"DECISION: STOP\nGUIDANCE: going in circles", PMStop, "", "going in circles"},
+	}
+	for _, tt := range tests {
+		d := ParsePMDecision(tt.reply)
+		if d.Action != tt.action || d.Plan != tt.plan || d.Reason != tt.reason {
+			t.Errorf("%q: got %+v", tt.reply, d)
+		}
+		if d.Action == PMContinue && d.Guidance == "" {
+			t.Errorf("%q: guidance lost", tt.reply)
+		}
+	}
+}
+
+func TestPMTriggers(t *testing.T) {
+	if _, err := NewPMTriggers([]string{"interval", "nope"}, PMOptions{}); err == nil {
+		t.Error("unknown trigger accepted")
+	}
+	triggers, err := NewPMTriggers([]string{"interval", "loop", "test_failures", "failures"}, PMOptions{LoopRepeats: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Mission{Issue: &Issue{ID: "1"}, Checkpoint: &Checkpoint{}, PMInterval: 10, MaxFailures: 3, Quiet: true, Triggers: triggers}
+	call := func(step PMStep) string {
+		m.IncrementToolCalls()
+		if step.Error {
+			m.AddFailure()
+		} else {
+			m.ResetFailures()
+		}
+		m.ObserveToolCall(step)
+		reason := m.PMReason
+		m.PMGuidanceNeeded, m.PMReason = false, ""
+		return reason
+	}
+	read := PMStep{Tool: "bash", Args: `{"command":"cat a.go"}`, Command: "cat a.go"}
+	if r := call(read); r != "" {
+		t.Errorf("first call triggered: %s", r)
+	}
+	if r := call(read); !strings.Contains(r, "loop") {
+		t.Errorf("repeated call did not trigger loop: %q", r)
+	}
+	test := PMStep{Tool: "bash", Command: "cd app && go test ./...", Error: true}
+	if r := call(test); r != "" {
+		t.Errorf("first test failure triggered: %s", r)
+	}
+	test.Args = "other"
+	if r := call(test); !strings.Contains(r, "tests failed 2 times") || !strings.Contains(r, "consecutive failed") {
+		t.Errorf("second test failure did not trigger both: %q", r)
+	}
+	for m.Checkpoint.ToolCallCount < 9 {
+		call(PMStep{Tool: "bash", Args: string(rune('a' + m.Checkpoint.ToolCallCount))})
+	}
+	if r := call(PMStep{Tool: "file_edit"}); !strings.Contains(r, "after 10 tool calls") {
+		t.Errorf("interval did not trigger: %q", r)
+	}
+	if IsTestCommand("echo go testing") || !IsTestCommand("pytest -x") {
+		t.Error("unexpected test command detection")
+	}
+}
+
+func TestNoDiffTrigger(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := t.TempDir()
+	if _, err := git(repo, "init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	triggers, err := NewPMTriggers([]string{"no_diff"}, PMOptions{NoDiffCalls: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Mission{Issue: &Issue{ID: "1", ProjectPath: repo}, Checkpoint: &Checkpoint{}, Quiet: true, Triggers: triggers}
+	fired := []int{}
+	for i := 1; i <= 6; i++ {
+		if i == 3 {
+			if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte("a"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		m.IncrementToolCalls()
+		m.ObserveToolCall(PMStep{Tool: "bash", Args: string(rune('a' + i))})
+		if m.PMGuidanceNeeded {
+			fired = append(fired, i)
+			m.PMGuidanceNeeded = false
+		}
+	}
+	// checked at 1, 3 (changed), 5 (unchanged)
+	if len(fired) != 1 || fired[0] != 5 {
+		t.Errorf("no_diff fired at %v, want [5]", fired)
+	}
+}
+
+func TestApplyPMDecision(t *testing.T) {
+	manager := NewIssueManager(t.TempDir())
+	if err := os.MkdirAll(manager.StatusDir(StatusInProgress), 0755); err != nil {
+		t.Fatal(err)
+	}
+	issue := &Issue{ID: "4", Title: "t", Status: StatusInProgress}
+	if err := CreateIssue(manager.IssuePath("4", StatusInProgress), issue); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMission(issue, manager, 75, 3, true)
+	m.ApplyPMDecision("loop", ParsePMDecision("DECISION: replan\nPLAN:\n1. write a test\nGUIDANCE: stop reading files"))
+	m.ApplyPMDecision("regular check-in", ParsePMDecision("DECISION: stop out of ideas"))
+	if m.Checkpoint.Plan != "1. write a test" || m.StopReason != "out of ideas" {
+		t.Errorf("decision not applied: plan %q, stop %q", m.Checkpoint.Plan, m.StopReason)
+	}
+	saved, err := manager.LoadByID("4", StatusInProgress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Comments) != 2 || saved.Comments[0].Author != "pm" ||
+		!strings.Contains(saved.Comments[0].Body, "PM check-in (loop): replan") || !strings.Contains(saved.Comments[1].Body, "stop - out of ideas") {
+		t.Errorf("unexpected PM comments: %+v", saved.Comments)
+	}
+}
@@ -0,0 +1,277 @@
+package mission
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+// kinds of trace events
+const (
+	TraceStart     = "start"
+	TraceLLM       = "llm"
+	TraceTool      = "tool"
+	TracePM        = "pm"
+	TraceSummarize = "summarize"
+	TraceEnd       = "end"
+)
+
+// statuses of tool trace events
+const (
+	TraceOK        = "ok"
+	TraceError     = "error"     // tool ran and reported an error
+	TraceFailed    = "failed"    // tool was not found or its args were wrong
+	TraceTimeout   = "timeout"   // killed after its timeout
+	TraceCancelled = "cancelled" // cancelled by the user or interrupt
+)
+
+// text of events is cut to keep traces small; output size keeps the full length
+const maxTraceText = 4000
+
+// TraceEvent is a line of the mission trace; fields not used by its kind are empty
+type TraceEvent struct {
+	Seq        int       `json:"seq"`
+	Time       time.Time `json:"time"`
+	Kind       string    `json:"kind"`
+	IssueID    string    `json:"issue_id,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	// llm: estimated tokens of the prompt, tokens of the response, time to the first token
+	// and the cost reported by the api
+	PromptTokens     int     `json:"prompt_tokens,omitempty"`
+	CompletionTokens int     `json:"completion_tokens,omitempty"`
+	FirstTokenMs     int64   `json:"first_token_ms,omitempty"`
+	Model            string  `json:"model,omitempty"`
+	Cost             float64 `json:"cost,omitempty"`
+	// tool
+	Tool       string            `json:"tool,omitempty"`
+	Args       map[string]string `json:"args,omitempty"`
+	Status     string            `json:"status,omitempty"` // tool status, pm decision or mission status of the end event
+	OutputSize int               `json:"output_size,omitempty"`
+	// summarize: messages replaced by the summary
+	Messages int `json:"messages,omitempty"`
+	// response, tool output, pm guidance or summary
+	Text  string `json:"text,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Duration of the step
+func (e *TraceEvent) Duration() time.Duration {
+	return time.Duration(e.DurationMs) * time.Millisecond
+}
+
+// Name is the tool name for tool events and the kind for others
+func (e *TraceEvent) Name() string {
+	if e.Kind == TraceTool {
+		return e.Tool
+	}
+	return e.Kind
+}
+
+// Tracer appends events to the trace file of a mission; nil Tracer records nothing
+type Tracer struct {
+	mu      sync.Mutex
+	f       *os.File
+	enc     *json.Encoder
+	seq     int
+	issueID string
+}
+
+// OpenTrace opens the trace for appending, so a resumed mission continues its trace
+func OpenTrace(path, issueID string) (*Tracer, error) {
+	events, err := LoadTrace(path)
+	if err != nil && !os.IsNotExist(err) {
+		events = nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace: %w", err)
+	}
+	// a line cut by a crash must not swallow the next event
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			f.WriteString("\n")
+		}
+	}
+	t := &Tracer{f: f, enc: json.NewEncoder(f), issueID: issueID}
+	if len(events) > 0 {
+		t.seq = events[len(events)-1].Seq
+	}
+	return t, nil
+}
+
+// Record writes the event with the next sequence number
+func (t *Tracer) Record(ev TraceEvent) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seq++
+	ev.Seq = t.seq
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	if ev.IssueID == "" {
+		ev.IssueID = t.issueID
+	}
+	if len(ev.Text) > maxTraceText {
+		cut := maxTraceText
+		for cut > 0 && !utf8.RuneStart(ev.Text[cut]) {
+			cut--
+		}
+		ev.Text = ev.Text[:cut] + "..."
+	}
+	if err := t.enc.Encode(ev); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write trace event: %v\n", err)
+	}
+}
+
+func (t *Tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.f.Close()
+}
+
+// TracePath is the trace of the checkpoint: mission-checkpoint.json -> mission-checkpoint-trace.jsonl
+func TracePath(checkpointPath string) string {
+	return strings.TrimSuffix(checkpointPath, ".json") + "-trace.jsonl"
+}
+
+// LoadTrace reads all events of the trace file; lines that do not decode,
+// like the last one of a crashed mission, are skipped
+func LoadTrace(path string) ([]TraceEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	events := []TraceEvent{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	var bad error
+	for sc.Scan() {
+		line++
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var ev TraceEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			if bad == nil {
+				bad = fmt.Errorf("trace line %d: %w", line, err)
+			}
+			continue
+		}
+		events = append(events, ev)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(events) == 0 && bad != nil {
+		return nil, bad
+	}
+	return events, nil
+}
+
+// TraceStat aggregates the steps of one name: a tool, llm, pm or summarize
+type TraceStat struct {
+	Name             string
+	Calls            int
+	Errors           int // tool errors, failures, timeouts and cancels; llm and pm errors
+	Total            time.Duration
+	Max              time.Duration
+	OutputSize       int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+}
+
+func (s *TraceStat) Avg() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Calls)
+}
+
+// SummarizeTrace aggregates time, tokens, cost and output per step name, most time first
+func SummarizeTrace(events []TraceEvent) []TraceStat {
+	byName := map[string]*TraceStat{}
+	for i := range events {
+		ev := &events[i]
+		if ev.Kind == TraceStart || ev.Kind == TraceEnd {
+			continue
+		}
+		st, ok := byName[ev.Name()]
+		if !ok {
+			st = &TraceStat{Name: ev.Name()}
+			byName[ev.Name()] = st
+		}
+		st.Calls++
+		if ev.Error != "" || ev.Kind == TraceTool && ev.Status != TraceOK {
+			st.Errors++
+		}
+		st.Total += ev.Duration()
+		st.Max = max(st.Max, ev.Duration())
+		st.OutputSize += ev.OutputSize
+		st.PromptTokens += ev.PromptTokens
+		st.CompletionTokens += ev.CompletionTokens
+		st.Cost += ev.Cost
+	}
+	stats := make([]TraceStat, 0, len(byName))
+	for _, st := range byName {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Total != stats[j].Total {
+			return stats[i].Total > stats[j].Total
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// FormatTraceSummary is the summary table of the trace with a total line
+func FormatTraceSummary(events []TraceEvent) string {
+	sb := strings.Builder{}
+	if len(events) > 0 {
+		first, last := events[0], events[len(events)-1]
+		fmt.Fprintf(&sb, "issue %s: %d steps, %s wall time", first.IssueID, len(events), last.Time.Sub(first.Time).Round(time.Second))
+		if last.Kind == TraceEnd {
+			fmt.Fprintf(&sb, ", %s", last.Status)
+		}
+		sb.WriteString("\n\n")
+	}
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "step\tcalls\terrors\ttotal\tavg\tmax\toutput\tprompt tok\tcompletion tok\tcost\t")
+	row := func(st TraceStat) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%d\t%d\t%d\t$%.4f\t\n", st.Name, st.Calls, st.Errors,
+			st.Total.Round(time.Millisecond), st.Avg().Round(time.Millisecond), st.Max.Round(time.Millisecond),
+			st.OutputSize, st.PromptTokens, st.CompletionTokens, st.Cost)
+	}
+	total := TraceStat{Name: "total"}
+	for _, st := range SummarizeTrace(events) {
+		row(st)
+		total.Calls += st.Calls
+		total.Errors += st.Errors
+		total.Total += st.Total
+		total.Max = max(total.Max, st.Max)
+		total.OutputSize += st.OutputSize
+		total.PromptTokens += st.PromptTokens
+		total.CompletionTokens += st.CompletionTokens
+		total.Cost += st.Cost
+	}
+	row(total)
+	w.Flush()
+	return sb.String()
+}
@@ -0,0 +1,74 @@
+package mission
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestTrace(t *testing.T) {
+	path := TracePath(filepath.Join(t.TempDir(), "7-checkpoint.json"))
+	if !strings.HasSuffix(path, "7-checkpoint-trace.jsonl") {
+		t.Fatalf("unexpected trace path %s", path)
+	}
+	tr, err := OpenTrace(path, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Record(TraceEvent{Kind: TraceStart, Text: "title"})
+	tr.Record(TraceEvent{Kind: TraceLLM, DurationMs: 2000, PromptTokens: 100, CompletionTokens: 20, Cost: 0.0125})
+	// the cut falls inside the two byte rune
+	tr.Record(TraceEvent{Kind: TraceTool, Tool: "bash", DurationMs: 300, Status: TraceOK, OutputSize: 10, Text: strings.Repeat("a", maxTraceText-1) + "é" + "bc"})
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// a crash cut the last line; the resumed mission continues after it
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"seq":4,"kind":"to`)
+	f.Close()
+	tr, err = OpenTrace(path, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Record(TraceEvent{Kind: TraceTool, Tool: "bash", DurationMs: 700, Status: TraceTimeout})
+	tr.Record(TraceEvent{Kind: TraceEnd, Status: string(StatusSuccess)})
+	tr.Close()
+	var nilTracer *Tracer
+	nilTracer.Record(TraceEvent{Kind: TraceLLM})
+	events, err := LoadTrace(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 5 {
+		t.Fatalf("expected 5 events, got %d", len(events))
+	}
+	for i, ev := range events {
+		if ev.Seq != i+1 || ev.IssueID != "7" || ev.Time.IsZero() {
+			t.Errorf("unexpected event %d: %+v", i, ev)
+		}
+	}
+	if text := events[2].Text; len(text) != maxTraceText-1+len("...") || !utf8.ValidString(text) {
+		t.Errorf("tool output was not cut on a rune boundary: %d %q", len(text), text[len(text)-5:])
+	}
+	stats := SummarizeTrace(events)
+	if len(stats) != 2 || stats[0].Name != "llm" || stats[1].Name != "bash" {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if llm := stats[0]; llm.Cost != 0.0125 {
+		t.Errorf("unexpected llm cost: %+v", llm)
+	}
+	if bash := stats[1]; bash.Calls != 2 || bash.Errors != 1 || bash.Total != time.Second || bash.Max != 700*time.Millisecond || bash.Avg() != 500*time.Millisecond {
+		t.Errorf("unexpected bash stats: %+v", bash)
+	}
+	summary := FormatTraceSummary(events)
+	if !strings.Contains(summary, "issue 7: 5 steps") || !strings.Contains(summary, "success") || !strings.Contains(summary, "total") ||
+		!strings.Contains(summary, "$0.0125") {
+		t.Errorf("unexpected summary:\n%s", summary)
+	}
+}
@@ -0,0 +1,501 @@
+package mission
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// issue file formats of ImportIssues and ExportIssues
+const (
+	FormatAuto     = "auto"
+	FormatGitHub   = "github"
+	FormatGitLab   = "gitlab"
+	FormatGitea    = "gitea"
+	FormatMarkdown = "markdown"
+)
+
+var (
+	// task list item: "- [ ] text", "* [x] text"
+	checklistRE = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s+(.+?)\s*$`)
+	headingRE   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	// "#12" issue reference, not part of a word, url fragment or html entity
+	issueRefRE = regexp.MustCompile(`(?:^|[^\w&/#])#(\d+)\b`)
+	// "priority: high", "priority::high" (gitlab scoped label), "priority/high"
+	priorityLabelRE = regexp.MustCompile(`(?i)^priority\s*(?::{1,2}|/)\s*(\w+)$`)
+)
+
+// splitAcceptance takes acceptance criteria out of a tracker issue body: items of the checklist
+// under an "Acceptance criteria" heading (the section is removed from the description),
+// or all checklist items of the body when there is no such heading
+func splitAcceptance(body string) (string, []string) {
+	lines := strings.Split(body, "\n")
+	start, end, level := -1, len(lines), 0
+	for i, line := range lines {
+		m := headingRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if start >= 0 && len(m[1]) <= level {
+			end = i
+			break
+		}
+		if start < 0 && strings.Contains(strings.ToLower(m[2]), "acceptance") {
+			start, level = i, len(m[1])
+		}
+	}
+	criteria := []string{}
+	if start < 0 {
+		for _, line := range lines {
+			if m := checklistRE.FindStringSubmatch(line); m != nil {
+				criteria = append(criteria, m[2])
+			}
+		}
+		return body, criteria
+	}
+	for _, line := range lines[start+1 : end] {
+		if m := checklistRE.FindStringSubmatch(line); m != nil {
+			criteria = append(criteria, m[2])
+		}
+	}
+	rest := append(slices.Clone(lines[:start]), lines[end:]...)
+	return strings.TrimSpace(strings.Join(rest, "\n")), criteria
+}
+
+// joinAcceptance appends acceptance criteria to the description as a checklist, unless it has them
+func joinAcceptance(description string, criteria []string, checked bool) string {
+	if len(criteria) == 0 {
+		return description
+	}
+	if _, found := splitAcceptance(description); len(found) > 0 {
+		return description
+	}
+	mark := "[ ]"
+	if checked {
+		mark = "[x]"
+	}
+	sb := strings.Builder{}
+	sb.WriteString(strings.TrimRight(description, "\n"))
+	sb.WriteString("\n\n## Acceptance Criteria\n\n")
+	for _, c := range criteria {
+		fmt.Fprintf(&sb, "- %s %s\n", mark, c)
+	}
+	return strings.TrimLeft(sb.String(), "\n")
+}
+
+// issueRefs returns ids of issues referenced as #N in the text, except the issue itself
+func issueRefs(text, self string) []string {
+	refs := []string{}
+	for _, m := range issueRefRE.FindAllStringSubmatch(text, -1) {
+		if m[1] != self && !slices.Contains(refs, m[1]) {
+			refs = append(refs, m[1])
+		}
+	}
+	return refs
+}
+
+// splitPriority takes the priority out of tracker labels
+func splitPriority(labels []string) ([]string, string) {
+	rest := []string{}
+	priority := ""
+	for _, l := range labels {
+		if m := priorityLabelRE.FindStringSubmatch(strings.TrimSpace(l)); m != nil {
+			priority = strings.ToLower(m[1])
+			continue
+		}
+		rest = append(rest, l)
+	}
+	return rest, priority
+}
+
+func trackerStatus(state string) IssueStatus {
+	if strings.EqualFold(state, "closed") {
+		return StatusDone
+	}
+	return StatusOpen
+}
+
+func trackerState(status IssueStatus, format string) string {
+	if status == StatusDone || status == StatusArchive {
+		return "closed"
+	}
+	if format == FormatGitLab {
+		return "opened"
+	}
+	return "open"
+}
+
+type trackerUser struct {
+	Login    string `json:"login"`    // github, gitea
+	Username string `json:"username"` // gitlab
+}
+
+// trackerLabels are label names or label objects with a name
+type trackerLabels []string
+
+func (l *trackerLabels) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	for _, r := range raw {
+		var name string
+		if err := json.Unmarshal(r, &name); err != nil {
+			var obj struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(r, &obj); err != nil {
+				return err
+			}
+			name = obj.Name
+		}
+		*l = append(*l, name)
+	}
+	return nil
+}
+
+// trackerComment is a github/gitea issue comment, a gitlab note or a comment of `gh issue view --json`
+type trackerComment struct {
+	User         *trackerUser `json:"user"`
+	Author       *trackerUser `json:"author"`
+	Body         string       `json:"body"`
+	CreatedAt    time.Time    `json:"created_at"`
+	CreatedAtCLI time.Time    `json:"createdAt"`
+	System       bool         `json:"system"`
+}
+
+func (c *trackerComment) toComment() Comment {
+	author := "unknown"
+	for _, u := range []*trackerUser{c.User, c.Author} {
+		if u != nil && u.Login != "" {
+			author = u.Login
+		} else if u != nil && u.Username != "" {
+			author = u.Username
+		}
+	}
+	created := c.CreatedAt
+	if created.IsZero() {
+		created = c.CreatedAtCLI
+	}
+	return Comment{Author: author, Body: c.Body, CreatedAt: created}
+}
+
+// trackerIssue is an issue of the github, gitea or gitlab api; comments are inlined by the dump
+type trackerIssue struct {
+	GfltID       string           `json:"gf_lt_id"` // set by ExportIssues
+	Number       int              `json:"number"`
+	IID          int              `json:"iid"`
+	Title        string           `json:"title"`
+	Body         string           `json:"body"`
+	Description  string           `json:"description"`
+	State        string           `json:"state"`
+	Labels       trackerLabels    `json:"labels"`
+	CreatedAt    time.Time        `json:"created_at"`
+	CreatedAtCLI time.Time        `json:"createdAt"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+	Comments     json.RawMessage  `json:"comments"` // count in the rest api, list in dumps
+	Notes        []trackerComment `json:"notes"`
+	PullRequest  json.RawMessage  `json:"pull_request"`
+}
+
+func (t *trackerIssue) toIssue() *Issue {
+	id := t.GfltID
+	switch {
+	case id != "":
+	case t.IID != 0:
+		id = strconv.Itoa(t.IID)
+	default:
+		id = strconv.Itoa(t.Number)
+	}
+	body := t.Body
+	if body == "" {
+		body = t.Description
+	}
+	description, criteria := splitAcceptance(body)
+	labels, priority := splitPriority(t.Labels)
+	issue := &Issue{
+		Version:            IssueVersion,
+		ID:                 id,
+		Title:              t.Title,
+		Description:        description,
+		Status:             trackerStatus(t.State),
+		Labels:             labels,
+		Priority:           priority,
+		CreatedAt:          t.CreatedAt,
+		UpdatedAt:          t.UpdatedAt,
+		RelatedIssues:      issueRefs(body, id),
+		AcceptanceCriteria: criteria,
+	}
+	if issue.CreatedAt.IsZero() {
+		issue.CreatedAt = t.CreatedAtCLI
+	}
+	var comments []trackerComment
+	if len(t.Comments) > 0 && t.Comments[0] == '[' {
+		if err := json.Unmarshal(t.Comments, &comments); err != nil {
+			comments = nil
+		}
+	}
+	for _, c := range append(comments, t.Notes...) {
+		if !c.System {
+			issue.Comments = append(issue.Comments, c.toComment())
+		}
+	}
+	return issue
+}
+
+// parseTrackerJSON reads an issue or a list of issues of the github, gitea or gitlab api;
+// pull requests in github issue lists are skipped
+func parseTrackerJSON(data []byte) ([]*Issue, error) {
+	data = bytes.TrimSpace(data)
+	var list []trackerIssue
+	if len(data) > 0 && data[0] == '{' {
+		var one trackerIssue
+		if err := json.Unmarshal(data, &one); err != nil {
+			return nil, err
+		}
+		list = append(list, one)
+	} else if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	issues := []*Issue{}
+	for i := range list {
+		if len(list[i].PullRequest) > 0 && string(list[i].PullRequest) != "null" {
+			continue
+		}
+		if list[i].GfltID == "" && list[i].Number == 0 && list[i].IID == 0 {
+			return nil, fmt.Errorf("issue %d (%q) has no number or iid", i, list[i].Title)
+		}
+		issues = append(issues, list[i].toIssue())
+	}
+	return issues, nil
+}
+
+// trackerJSON makes an issue of the github/gitea (number, body, label objects)
+// or gitlab (iid, description, label names, notes) api
+func trackerJSON(issue *Issue, format string) map[string]any {
+	labels := append([]string{}, issue.Labels...)
+	if issue.Priority != "" {
+		if format == FormatGitLab {
+			labels = append(labels, "priority::"+issue.Priority)
+		} else {
+			labels = append(labels, "priority: "+issue.Priority)
+		}
+	}
+	status := trackerState(issue.Status, format)
+	body := joinAcceptance(issue.Description, issue.AcceptanceCriteria, status == "closed")
+	if missing := slices.DeleteFunc(slices.Clone(issue.RelatedIssues), func(id string) bool {
+		return slices.Contains(issueRefs(body, issue.ID), id)
+	}); len(missing) > 0 {
+		body = strings.TrimRight(body, "\n") + "\n\nRelated: #" + strings.Join(missing, ", #") + "\n"
+	}
+	resp := map[string]any{
+		"gf_lt_id":   issue.ID,
+		"title":      issue.Title,
+		"state":      status,
+		"created_at": issue.CreatedAt,
+		"updated_at": issue.UpdatedAt,
+	}
+	comments := make([]map[string]any, len(issue.Comments))
+	if format == FormatGitLab {
+		if iid, err := strconv.Atoi(issue.ID); err == nil {
+			resp["iid"] = iid
+		}
+		resp["description"] = body
+		resp["labels"] = labels
+		for i, c := range issue.Comments {
+			comments[i] = map[string]any{"author": map[string]string{"username": c.Author}, "body": c.Body, "created_at": c.CreatedAt}
+		}
+		resp["notes"] = comments
+		return resp
+	}
+	if number, err := strconv.Atoi(issue.ID); err == nil {
+		resp["number"] = number
+	}
+	resp["body"] = body
+	labelObjs := make([]map[string]string, len(labels))
+	for i, l := range labels {
+		labelObjs[i] = map[string]string{"name": l}
+	}
+	resp["labels"] = labelObjs
+	for i, c := range issue.Comments {
+		comments[i] = map[string]any{"user": map[string]string{"login": c.Author}, "body": c.Body, "created_at": c.CreatedAt}
+	}
+	resp["comments"] = comments
+	return resp
+}
+
+// detectFormat picks the format of an issue file: markdown by extension,
+// gitlab for json with iid, github (same as gitea) for other json
+func detectFormat(path string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return FormatMarkdown
+	}
+	if bytes.Contains(data, []byte(`"iid"`)) {
+		return FormatGitLab
+	}
+	return FormatGitHub
+}
+
+// ParseIssues reads issues of the file in the format (FormatAuto detects it)
+func ParseIssues(path, format string) ([]*Issue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" || format == FormatAuto {
+		format = detectFormat(path, data)
+	}
+	var issues []*Issue
+	switch format {
+	case FormatMarkdown:
+		issue, err := ParseMarkdownIssue(data)
+		if err != nil {
+			return nil, err
+		}
+		issues = []*Issue{issue}
+	case FormatGitHub, FormatGitLab, FormatGitea:
+		if issues, err = parseTrackerJSON(data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown issue format: %s", format)
+	}
+	for _, issue := range issues {
+		if err := ValidateIssueID(issue.ID); err != nil {
+			return nil, err
+		}
+	}
+	return issues, nil
+}
+
+// ValidateIssueID checks that the id can name the issue file: it is a plain file name,
+// so imported issues are not written outside the issues directory
+func ValidateIssueID(id string) error {
+	if id == "" || id == "." || id == ".." || filepath.Base(id) != id || strings.ContainsRune(id, '\\') {
+		return fmt.Errorf("invalid issue id %q", id)
+	}
+	return nil
+}
+
+// ImportResult lists what ImportIssues did
+type ImportResult struct {
+	Imported []string
+	Skipped  []string // already in the issues directory
+}
+
+// ImportIssues saves issues of the file, or of all .json and .md files in the directory, into
+// the issues directory by their status; projectPath is set where the issue has none.
+// Issues whose id is already in any status directory are skipped.
+func (m *IssueManager) ImportIssues(path, format, projectPath string) (*ImportResult, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		files = files[:0]
+		for _, e := range entries {
+			switch strings.ToLower(filepath.Ext(e.Name())) {
+			case ".json", ".md", ".markdown":
+				if !e.IsDir() {
+					files = append(files, filepath.Join(path, e.Name()))
+				}
+			}
+		}
+	}
+	res := &ImportResult{}
+	for _, f := range files {
+		issues, err := ParseIssues(f, format)
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", f, err)
+		}
+		for _, issue := range issues {
+			if _, _, err := m.LoadByIDAny(issue.ID); err == nil {
+				res.Skipped = append(res.Skipped, issue.ID)
+				continue
+			}
+			if issue.ProjectPath == "" {
+				issue.ProjectPath = projectPath
+			}
+			if issue.Status == "" {
+				issue.Status = StatusOpen
+			}
+			if err := os.MkdirAll(m.StatusDir(issue.Status), 0755); err != nil {
+				return res, fmt.Errorf("failed to create status directory: %w", err)
+			}
+			if err := CreateIssue(m.IssuePath(issue.ID, issue.Status), issue); err != nil {
+				return res, err
+			}
+			res.Imported = append(res.Imported, issue.ID)
+		}
+	}
+	return res, nil
+}
+
+// ExportIssues writes issues of all status directories: markdown files into the directory out,
+// or a json list of the tracker format into the file out (issues.json when out is a directory)
+func (m *IssueManager) ExportIssues(out, format string) (int, error) {
+	if format == "" || format == FormatAuto {
+		format = FormatMarkdown
+		if strings.EqualFold(filepath.Ext(out), ".json") {
+			format = FormatGitHub
+		}
+	}
+	issues := []*Issue{}
+	for _, status := range IssueStatuses {
+		list, err := m.ListIssues(status)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return 0, err
+		}
+		for _, issue := range list {
+			// directory is the truth, files moved by hand keep their old status
+			issue.Status = status
+		}
+		issues = append(issues, list...)
+	}
+	switch format {
+	case FormatMarkdown:
+		if err := os.MkdirAll(out, 0755); err != nil {
+			return 0, err
+		}
+		for _, issue := range issues {
+			if err := ValidateIssueID(issue.ID); err != nil {
+				return 0, err
+			}
+			if err := os.WriteFile(filepath.Join(out, issue.ID+".md"), MarshalMarkdownIssue(issue), 0644); err != nil {
+				return 0, err
+			}
+		}
+	case FormatGitHub, FormatGitLab, FormatGitea:
+		if info, err := os.Stat(out); err == nil && info.IsDir() {
+			out = filepath.Join(out, "issues.json")
+		}
+		list := make([]map[string]any, len(issues))
+		for i, issue := range issues {
+			list[i] = trackerJSON(issue, format)
+		}
+		data, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return 0, err
+		}
+		if err := os.WriteFile(out, data, 0644); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("unknown issue format: %s", format)
+	}
+	return len(issues), nil
+}
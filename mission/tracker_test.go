@@ -0,0 +1,159 @@
+package mission
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseTrackerJSON(t *testing.T) {
+	github := `[
+  {"number": 12, "title": "Login times out", "state": "open",
+   "body": "Login fails after 30s, see #7 and #12.\n\n## Acceptance criteria\n- [ ] timeout is 60s\n- [x] error is shown\n\n## Notes\nnothing",
+   "labels": [{"name": "bug"}, {"name": "priority: high"}],
+   "created_at": "2026-05-10T10:00:00Z", "comments": [{"user": {"login": "ann"}, "body": "same here", "created_at": "2026-05-11T10:00:00Z"}]},
+  {"number": 13, "title": "a pull request", "state": "open", "pull_request": {"url": "x"}},
+  {"number": 14, "title": "closed one", "state": "closed", "body": "- [ ] only item", "comments": 3}
+]`
+	issues, err := parseTrackerJSON([]byte(github))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("pull request was not skipped: %d issues", len(issues))
+	}
+	got := issues[0]
+	if got.ID != "12" || got.Priority != "high" || !slices.Equal(got.Labels, []string{"bug"}) || got.Status != StatusOpen {
+		t.Errorf("unexpected issue fields: %+v", got)
+	}
+	if !slices.Equal(got.AcceptanceCriteria, []string{"timeout is 60s", "error is shown"}) {
+		t.Errorf("unexpected acceptance criteria: %q", got.AcceptanceCriteria)
+	}
+	if want := "Login fails after 30s, see #7 and #12.\n\n## Notes\nnothing"; got.Description != want {
+		t.Errorf("acceptance section not removed from description: %q", got.Description)
+	}
+	if !slices.Equal(got.RelatedIssues, []string{"7"}) {
+		t.Errorf("unexpected related issues: %q", got.RelatedIssues)
+	}
+	if len(got.Comments) != 1 || got.Comments[0].Author != "ann" || got.Comments[0].CreatedAt.IsZero() {
+		t.Errorf("unexpected comments: %+v", got.Comments)
+	}
+	if closed := issues[1]; closed.Status != StatusDone || !slices.Equal(closed.AcceptanceCriteria, []string{"only item"}) {
+		t.Errorf("unexpected closed issue: %+v", closed)
+	}
+	gitlab := `{"iid": 3, "id": 99, "title": "Slow page", "description": "It is slow", "state": "opened",
+  "labels": ["perf", "priority::critical"],
+  "notes": [{"author": {"username": "bob"}, "body": "added label", "system": true}, {"author": {"username": "bob"}, "body": "on it"}]}`
+	issues, err = parseTrackerJSON([]byte(gitlab))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := issues[0]; got.ID != "3" || got.Priority != "critical" || len(got.Comments) != 1 || got.Comments[0].Author != "bob" {
+		t.Errorf("unexpected gitlab issue: %+v", got)
+	}
+}
+
+func TestMarkdownIssueRoundTrip(t *testing.T) {
+	issue := &Issue{
+		Version:            IssueVersion,
+		ID:                 "5",
+		Title:              `Fix "login": timeout`,
+		Description:        "Users are logged out.\n\nSee #4.",
+		Status:             StatusReview,
+		ProjectPath:        "/tmp/app",
+		Labels:             []string{"bug", "auth"},
+		Priority:           "high",
+		CreatedAt:          time.Date(2026, 5, 10, 10, 0, 0, 0, time.UTC),
+		UpdatedAt:          time.Date(2026, 5, 11, 10, 0, 0, 0, time.UTC),
+		RelatedIssues:      []string{"4"},
+		AcceptanceCriteria: []string{"timeout is 60s"},
+		ContextFiles:       []string{"auth/login.go"},
+		Checks:             []Check{{Name: "test", Command: "go test ./..."}},
+		Comments:           []Comment{{Author: "solver", Body: "starting\n\nfirst step", CreatedAt: time.Date(2026, 5, 12, 10, 0, 0, 0, time.UTC)}},
+	}
+	got, err := ParseMarkdownIssue(MarshalMarkdownIssue(issue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, issue) {
+		t.Errorf("round trip changed the issue:\ngot  %+v\nwant %+v", got, issue)
+	}
+	handWritten := "---\nid: 8\ntitle: 'It''s broken'\nlabels:\n  - ui\n  - \"docs\"\ncontext_files: [main.go, tui.go]\n---\nBody\n"
+	got, err = ParseMarkdownIssue([]byte(handWritten))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "8" || got.Title != "It's broken" || got.Status != StatusOpen || got.Description != "Body" ||
+		!slices.Equal(got.Labels, []string{"ui", "docs"}) || !slices.Equal(got.ContextFiles, []string{"main.go", "tui.go"}) {
+		t.Errorf("unexpected hand written issue: %+v", got)
+	}
+}
+
+func TestImportTraversalID(t *testing.T) {
+	dir := t.TempDir()
+	m := NewIssueManager(filepath.Join(dir, "issues"))
+	files := map[string]string{
+		"evil.md":   "---\nid: ../../../escaped\ntitle: evil\n---\n",
+		"evil.json": `[{"gf_lt_id": "../escaped", "number": 1, "title": "evil"}]`,
+		"dot.md":    "---\nid: ..\ntitle: dot\n---\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if res, err := m.ImportIssues(path, FormatAuto, ""); err == nil {
+			t.Errorf("%s: expected invalid id error, got %+v", name, res)
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "escaped*")); len(matches) != 0 {
+		t.Errorf("issue written outside the issues dir: %v", matches)
+	}
+	for _, id := range []string{"12", "fix-login", "a.b"} {
+		if err := ValidateIssueID(id); err != nil {
+			t.Errorf("valid id %q rejected: %v", id, err)
+		}
+	}
+}
+
+func TestImportExportIssues(t *testing.T) {
+	src := NewIssueManager(t.TempDir())
+	if err := os.MkdirAll(src.StatusDir(StatusDone), 0755); err != nil {
+		t.Fatal(err)
+	}
+	issue := &Issue{ID: "21", Title: "done one", Description: "text", Status: StatusDone,
+		Labels: []string{"bug"}, Priority: "low", AcceptanceCriteria: []string{"works"},
+		Comments: []Comment{{Author: "ann", Body: "ok"}}}
+	if err := CreateIssue(src.IssuePath(issue.ID, StatusDone), issue); err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{FormatMarkdown, FormatGitHub, FormatGitLab, FormatGitea} {
+		out := filepath.Join(t.TempDir(), "export")
+		if format != FormatMarkdown {
+			out += ".json"
+		}
+		if n, err := src.ExportIssues(out, format); err != nil || n != 1 {
+			t.Fatalf("%s export: %d issues, %v", format, n, err)
+		}
+		dst := NewIssueManager(t.TempDir())
+		res, err := dst.ImportIssues(out, FormatAuto, "/tmp/app")
+		if err != nil || len(res.Imported) != 1 {
+			t.Fatalf("%s import: %+v, %v", format, res, err)
+		}
+		got, err := dst.LoadByID("21", StatusDone)
+		if err != nil {
+			t.Fatalf("%s import: %v", format, err)
+		}
+		if got.Title != issue.Title || got.Description != "text" || got.Priority != "low" || got.ProjectPath != "/tmp/app" ||
+			!slices.Equal(got.Labels, issue.Labels) || !slices.Equal(got.AcceptanceCriteria, issue.AcceptanceCriteria) ||
+			len(got.Comments) != 1 || got.Comments[0].Author != "ann" {
+			t.Errorf("%s round trip: %+v", format, got)
+		}
+		if res, err := dst.ImportIssues(out, FormatAuto, ""); err != nil || len(res.Skipped) != 1 {
+			t.Errorf("%s: existing issue not skipped: %+v, %v", format, res, err)
+		}
+	}
+}
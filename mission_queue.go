@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"gf-lt/mission"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMissionQueueDir = "mission-queue"
+	// time a mission process has to save its checkpoint after interrupt
+	missionStopDelay = 30 * time.Second
+)
+
+// flags of the queue runner that are not given to mission processes
+var queueOnlyFlags = map[string]bool{
+	"mission": true, "queue": true, "parallel": true, "labels": true, "worktrees": true,
+	"workdir": true, "issue-id": true, "checkpoint-file": true, "resume": true, "output": true,
+}
+
+// runMissionQueue works through open issues by priority and label, up to MissionParallel at once.
+// Mission state is global, so every mission is a gf-lt process of its own; it runs in a git worktree
+// when MissionWorktrees is set or several missions run at once.
+func runMissionQueue() {
+	issueManager := mission.NewIssueManager(cfg.IssuesDir)
+	issues, err := issueManager.QueueIssues(cfg.MissionLabels)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list open issues: %v\n", err)
+		os.Exit(1)
+	}
+	if len(issues) == 0 {
+		fmt.Fprintln(os.Stderr, "No open issues found")
+		os.Exit(1)
+	}
+	parallel := max(cfg.MissionParallel, 1)
+	useWorktrees := cfg.MissionWorktrees || parallel > 1
+	queueDir, err := missionQueueDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create queue directory: %v\n", err)
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Printf("[QUEUE] %d issues, %d at once, worktrees: %v\n", len(issues), parallel, useWorktrees)
+	summary := mission.QueueSummary{StartedAt: time.Now(), Parallel: parallel, Results: make([]mission.MissionResult, len(issues))}
+	// workers take issues in queue order
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range parallel {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				summary.Results[i] = runQueuedMission(ctx, issueManager, issues[i], queueDir, useWorktrees)
+			}
+		}()
+	}
+	for i := range issues {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	summary.Duration = time.Since(summary.StartedAt).String()
+	for _, r := range summary.Results {
+		if r.Status == mission.StatusSuccess {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+	}
+	data := summary.ToJSON()
+	summaryPath := filepath.Join(queueDir, fmt.Sprintf("summary-%s.json", summary.StartedAt.Format("20060102-150405")))
+	if err := os.WriteFile(summaryPath, []byte(data), 0644); err != nil {
+		logger.Error("failed to write mission queue summary", "error", err)
+	}
+	fmt.Println(data)
+	fmt.Printf("[QUEUE] done: %d succeeded, %d failed; summary: %s\n", summary.Succeeded, summary.Failed, summaryPath)
+	if summary.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// missionQueueDir is where mission processes keep their checkpoints and logs
+func missionQueueDir() (string, error) {
+	queueDir := cfg.MissionQueueDir
+	if queueDir == "" {
+		queueDir = defaultMissionQueueDir
+	}
+	queueDir, err := filepath.Abs(queueDir)
+	if err != nil {
+		return "", err
+	}
+	return queueDir, os.MkdirAll(queueDir, 0755)
+}
+
+// missionArgs are flags of a mission process: the issue, its checkpoint and flags given to the runner
+func missionArgs(issueID, checkpointPath string) []string {
+	args := []string{"-mission", "-issue-id", issueID, "-checkpoint-file", checkpointPath}
+	flag.Visit(func(f *flag.Flag) {
+		if !queueOnlyFlags[f.Name] {
+			args = append(args, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	return args
+}
+
+// addMissionWorktree makes the git worktree of the issue in <dir>/worktrees/<id> on the issue branch;
+// a generated branch name is saved to the issue file at issuePath
+func addMissionWorktree(issue *mission.Issue, issuePath, dir string) (branch, worktree string, err error) {
+	if issue.ProjectPath == "" {
+		return "", "", errors.New("issue has no project_path to make a worktree of")
+	}
+	branch = issue.BranchName
+	if branch == "" {
+		branch = mission.DefaultBranchName(issue)
+	}
+	worktree = filepath.Join(dir, "worktrees", issue.ID)
+	if err := mission.AddWorktree(issue.ProjectPath, worktree, branch); err != nil {
+		return "", "", err
+	}
+	// the mission process loads the issue with its branch
+	if issue.BranchName == "" {
+		issue.SetBranchName(branch)
+		if err := mission.SaveIssue(issue, issuePath); err != nil {
+			logger.Warn("failed to save issue branch name", "issue", issue.ID, "error", err)
+		}
+	}
+	return branch, worktree, nil
+}
+
+// runQueuedMission runs the mission process of the issue and makes its result from exit code and checkpoint
+func runQueuedMission(ctx context.Context, issueManager *mission.IssueManager, issue *mission.Issue, queueDir string, useWorktree bool) mission.MissionResult {
+	start := time.Now()
+	res := mission.MissionResult{Status: mission.StatusFailed, IssueID: issue.ID, BranchName: issue.BranchName}
+	if ctx.Err() != nil {
+		res.Status, res.Error = mission.StatusAborted, errors.New("queue interrupted before the mission started")
+		return res
+	}
+	checkpointPath := filepath.Join(queueDir, issue.ID+"-checkpoint.json")
+	logPath := filepath.Join(queueDir, issue.ID+".log")
+	args := missionArgs(issue.ID, checkpointPath)
+	if useWorktree {
+		branch, dir, err := addMissionWorktree(issue, issueManager.IssuePath(issue.ID, mission.StatusOpen), queueDir)
+		if err != nil {
+			res.Error = err
+			return res
+		}
+		res.BranchName, res.Worktree = branch, dir
+		args = append(args, "-workdir", dir)
+	}
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		res.Error = fmt.Errorf("failed to create mission log: %w", err)
+		return res
+	}
+	defer logFile.Close()
+	exe, err := os.Executable()
+	if err != nil {
+		res.Error = err
+		return res
+	}
+	cmd := exec.CommandContext(ctx, exe, args...)
+	// interrupt lets the mission save its checkpoint
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = missionStopDelay
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	res.Checkpoint, res.Log = checkpointPath, logPath
+	fmt.Printf("[QUEUE] issue %s started: %s (log: %s)\n", issue.ID, issue.Title, res.Log)
+	err = cmd.Run()
+	res.Duration = time.Since(start)
+	switch {
+	case err == nil:
+		res.Status = mission.StatusSuccess
+	case ctx.Err() != nil || cmd.ProcessState != nil && (cmd.ProcessState.ExitCode() == 130 || cmd.ProcessState.ExitCode() == 143):
+		res.Status, res.Error = mission.StatusAborted, err
+	default:
+		res.Error = err
+	}
+	if cp, err := mission.LoadCheckpoint(res.Checkpoint); err == nil {
+		res.Commits, res.ToolCalls, res.Checks = cp.CommitsMade, cp.ToolCallCount, cp.CheckResults
+		res.Tokens, res.Cost = cp.Tokens, cp.Cost
+		if cp.BranchName != "" {
+			res.BranchName = cp.BranchName
+		}
+	}
+	if _, err := os.Stat(mission.TracePath(res.Checkpoint)); err == nil {
+		res.Trace = mission.TracePath(res.Checkpoint)
+	}
+	// work of a finished mission is in its branch; failed ones keep the worktree to look into
+	if res.Status == mission.StatusSuccess && res.Worktree != "" {
+		if err := mission.RemoveWorktree(issue.ProjectPath, res.Worktree); err != nil {
+			logger.Warn("failed to remove mission worktree", "dir", res.Worktree, "error", err)
+		} else {
+			res.Worktree = ""
+		}
+	}
+	fmt.Printf("[QUEUE] issue %s %s in %s\n", issue.ID, res.Status, res.Duration.Round(time.Second))
+	if res.Error != nil {
+		fmt.Printf("[QUEUE] issue %s error: %s\n", issue.ID, strings.TrimSpace(res.Error.Error()))
+	}
+	return res
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"gf-lt/mission"
+	"gf-lt/models"
+	"gf-lt/tools"
+	"time"
+)
+
+// missionTrace is the tracer of the running mission, nil outside of mission mode
+func missionTrace() *mission.Tracer {
+	m := tools.GetCurrentMission()
+	if m == nil {
+		return nil
+	}
+	return m.Trace
+}
+
+func traceLLMRound(start time.Time, firstToken time.Duration, promptTokens int, stats *models.ResponseStats, resp string) {
+	t := missionTrace()
+	if t == nil {
+		return
+	}
+	ev := mission.TraceEvent{
+		Time:         start,
+		Kind:         mission.TraceLLM,
+		DurationMs:   time.Since(start).Milliseconds(),
+		PromptTokens: promptTokens,
+		FirstTokenMs: firstToken.Milliseconds(),
+		Model:        chatBody.Model,
+		Text:         resp,
+	}
+	if stats != nil {
+		ev.CompletionTokens = stats.Tokens
+	}
+	// the api counts better than the estimate
+	if stats != nil && stats.Usage != nil {
+		ev.PromptTokens = stats.Usage.PromptTokens
+		ev.CompletionTokens = stats.Usage.CompletionTokens
+		ev.Cost = stats.Usage.Cost
+	}
+	if interruptResp.Load() {
+		ev.Error = "interrupted"
+	}
+	t.Record(ev)
+}
+
+func traceToolCall(name string, args map[string]string, start time.Time, resp []byte, status string) {
+	missionTrace().Record(mission.TraceEvent{
+		Time:       start,
+		Kind:       mission.TraceTool,
+		DurationMs: time.Since(start).Milliseconds(),
+		Tool:       name,
+		Args:       args,
+		Status:     status,
+		OutputSize: len(resp),
+		Text:       string(resp),
+	})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"gf-lt/config"
+	"gf-lt/mockllm"
+	"gf-lt/models"
+	"gf-lt/tools"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of the mock backend tests")
+
+// goldenMsg is the part of a chat message the golden files keep; stats change between runs
+// and system messages with every tool description
+type goldenMsg struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	ToolCalls  []goldenToolCall `json:"tool_calls,omitempty"`
+}
+
+type goldenToolCall struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	Args string `json:"args"`
+}
+
+func toGolden(msgs []models.RoleMsg) []goldenMsg {
+	res := make([]goldenMsg, 0, len(msgs))
+	for i := range msgs {
+		if msgs[i].Role == "system" {
+			continue
+		}
+		g := goldenMsg{Role: msgs[i].Role, Content: msgs[i].Content, ToolCallID: msgs[i].ToolCallID}
+		for _, tc := range msgs[i].ToolCalls {
+			g.ToolCalls = append(g.ToolCalls, goldenToolCall{ID: tc.ID, Name: tc.FuncCall.Name, Args: tc.FuncCall.Args})
+		}
+		if msgs[i].ToolCall != nil && len(g.ToolCalls) == 0 {
+			g.ToolCalls = append(g.ToolCalls, goldenToolCall{ID: msgs[i].ToolCall.ID, Name: msgs[i].ToolCall.FuncCall.Name, Args: msgs[i].ToolCall.FuncCall.Args})
+		}
+		res = append(res, g)
+	}
+	return res
+}
+
+func checkGolden(t *testing.T, name string, got any) {
+	t.Helper()
+	data, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, '\n')
+	path := filepath.Join("testdata", "mock", "golden", name+".json")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -run %s -update to create it)", err, t.Name())
+	}
+	if string(want) != string(data) {
+		t.Errorf("%s differs from the golden file %s:\n%s", name, path, data)
+	}
+}
+
+// mockRound sends the user message through chatRound like the -cli -msg mode
+// and waits for the answer, tool calls included
+func mockRound(t *testing.T, msg string) {
+	t.Helper()
+	chatRoundChan <- &models.ChatRoundReq{Role: cfg.UserRole, UserMsg: msg}
+	select {
+	case <-cliRespDone:
+	case <-time.After(10 * time.Second):
+		t.Fatal("round did not finish")
+	}
+}
+
+func setupMockChat(t *testing.T, api string) {
+	t.Helper()
+	mockllm.Reset()
+	cfg = &config.Config{
+		CLIMode: true, ToolUse: true, ChunkLimit: 1000,
+		UserRole: "user", AssistantRole: "assistant", ToolRole: "tool",
+		CurrentAPI: api, CurrentModel: "mock", ToolTimeout: 10,
+	}
+	testToolsInit.Do(func() { tools.InitTools(cfg, logger, nil) })
+	outputHandler = &SilentOutputHandler{}
+	cliRespDone = make(chan bool, 1)
+	chatBody = &models.ChatBody{Model: "mock", Stream: true, Messages: []models.RoleMsg{{Role: "system", Content: "You are a test assistant."}}}
+	lastToolCall = &models.FuncCall{}
+	lastCompletedToolCalls = nil
+}
+
+func TestMockChatRound(t *testing.T) {
+	tools.FnMap["clock_test"] = func(ctx context.Context, args map[string]string) []byte {
+		return []byte("12:00 " + args["zone"])
+	}
+	defer delete(tools.FnMap, "clock_test")
+	for _, format := range mockllm.Formats {
+		t.Run(format, func(t *testing.T) {
+			setupMockChat(t, "mock://testdata/mock/tool_round.toml?format="+format)
+			mockRound(t, "What time is it in UTC?")
+			checkGolden(t, "tool_round_"+format, toGolden(chatBody.Messages))
+		})
+	}
+}
+
+func TestMockErrors(t *testing.T) {
+	// failed requests are dumped for debugging; keep the tree clean
+	if _, err := os.Stat("dumps"); os.IsNotExist(err) {
+		defer os.RemoveAll("dumps")
+	}
+	setupMockChat(t, "mock://testdata/mock/errors.toml")
+	for _, msg := range []string{"rate limited", "cut", "malformed", "script is over"} {
+		mockRound(t, msg)
+	}
+	checkGolden(t, "errors", toGolden(chatBody.Messages))
+	script, err := mockllm.Get(cfg.CurrentAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(script.Requests()); n != 4 {
+		t.Errorf("expected 4 requests, got %d", n)
+	}
+	if !strings.Contains(script.Requests()[3], "script is over") {
+		t.Errorf("unexpected last request: %s", script.Requests()[3])
+	}
+}
@@ -0,0 +1,241 @@
+// Package mockllm is a scripted LLM backend for offline tests: api urls like
+// mock://testdata/script.toml are answered with the turns of the script,
+// streamed in the wire format of one of the chunk parsers.
+package mockllm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+)
+
+const Scheme = "mock"
+
+// wire formats, one per chunk parser
+const (
+	FormatLCPCompletion        = "lcp-completion"
+	FormatLCPChat              = "lcp-chat"
+	FormatDeepSeekCompletion   = "deepseek-completion"
+	FormatDeepSeekChat         = "deepseek-chat"
+	FormatOpenRouterCompletion = "openrouter-completion"
+	FormatOpenRouterChat       = "openrouter-chat"
+)
+
+var Formats = []string{
+	FormatLCPCompletion, FormatLCPChat,
+	FormatDeepSeekCompletion, FormatDeepSeekChat,
+	FormatOpenRouterCompletion, FormatOpenRouterChat,
+}
+
+type ToolCall struct {
+	ID   string         `toml:"id" json:"id"`
+	Name string         `toml:"name" json:"name"`
+	Args map[string]any `toml:"args" json:"args"`
+}
+
+// Usage is the token usage a turn reports at the end of its reply
+type Usage struct {
+	PromptTokens     int     `toml:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int     `toml:"completion_tokens" json:"completion_tokens"`
+	ReasoningTokens  int     `toml:"reasoning_tokens" json:"reasoning_tokens"`
+	Cost             float64 `toml:"cost" json:"cost"` // sent only by the openrouter formats
+}
+
+// Turn is the reply to one request
+type Turn struct {
+	// reply to every request whose last message contains it, instead of the next turn in order
+	Match     string     `toml:"match" json:"match"`
+	Reasoning string     `toml:"reasoning" json:"reasoning"`
+	Text      string     `toml:"text" json:"text"`
+	ToolCalls []ToolCall `toml:"tool_calls" json:"tool_calls"`
+	// http error reply: status (default 500) with Error as the error message
+	Status     int    `toml:"status" json:"status"`
+	Error      string `toml:"error" json:"error"`
+	RetryAfter int    `toml:"retry_after" json:"retry_after"` // seconds, sent as Retry-After header of the error
+	// stream breaks: Malformed sends a chunk that is not json, Cut closes the stream without finishing
+	Malformed bool `toml:"malformed" json:"malformed"`
+	Cut       bool `toml:"cut" json:"cut"`
+	DelayMs   int  `toml:"delay_ms" json:"delay_ms"` // between chunks
+	// streamed only when the request asks for it, like the real apis do
+	Usage *Usage `toml:"usage" json:"usage"`
+}
+
+// Script is a loaded script with its position; turns without Match are used in order
+type Script struct {
+	Format    string `toml:"format" json:"format"`
+	ChunkSize int    `toml:"chunk_size" json:"chunk_size"` // runes per chunk, 0 streams word by word
+	Turns     []Turn `toml:"turns" json:"turns"`
+
+	mu       sync.Mutex
+	next     int
+	requests []string
+}
+
+// LoadScript reads a toml or json (by extension) script
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Script{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, s)
+	} else {
+		err = toml.Unmarshal(data, s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mock script %s: %w", path, err)
+	}
+	if s.Format == "" {
+		s.Format = FormatLCPChat
+	}
+	if !validFormat(s.Format) {
+		return nil, fmt.Errorf("mock script %s: unknown format %q (known: %s)", path, s.Format, strings.Join(Formats, ", "))
+	}
+	return s, nil
+}
+
+func validFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Requests are the bodies of the requests the script answered, in order
+func (s *Script) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// turn picks the reply to the request and records it
+func (s *Script) turn(body string) (*Turn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, body)
+	last := lastMessage(body)
+	for i := range s.Turns {
+		if t := &s.Turns[i]; t.Match != "" && strings.Contains(last, t.Match) {
+			return t, nil
+		}
+	}
+	for s.next < len(s.Turns) {
+		t := &s.Turns[s.next]
+		s.next++
+		if t.Match == "" {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("mock script has no turn left for request %d", len(s.requests))
+}
+
+// lastMessage is the text of the last chat message, or the prompt of completion requests
+func lastMessage(body string) string {
+	var req struct {
+		Prompt   string `json:"prompt"`
+		Messages []struct {
+			Content any `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return body
+	}
+	if len(req.Messages) == 0 {
+		return req.Prompt
+	}
+	switch c := req.Messages[len(req.Messages)-1].Content.(type) {
+	case string:
+		return c
+	case nil:
+		return ""
+	default:
+		data, _ := json.Marshal(c)
+		return string(data)
+	}
+}
+
+var (
+	scriptsMu sync.Mutex
+	scripts   = map[string]*Script{}
+)
+
+// ParseURL splits mock://path?format=name into the script path and the format override
+func ParseURL(api string) (path, format string, err error) {
+	rest, ok := strings.CutPrefix(api, Scheme+"://")
+	if !ok {
+		return "", "", fmt.Errorf("not a %s:// url: %s", Scheme, api)
+	}
+	path, query, _ := strings.Cut(rest, "?")
+	for _, kv := range strings.Split(query, "&") {
+		if v, ok := strings.CutPrefix(kv, "format="); ok {
+			format = v
+		}
+	}
+	if path == "" {
+		return "", "", errors.New("mock url has no script path")
+	}
+	return path, format, nil
+}
+
+// IsMock tells if the api url is served by the mock backend
+func IsMock(api string) bool {
+	return strings.HasPrefix(api, Scheme+"://")
+}
+
+// Get returns the script of the url, loaded on first use; the script keeps its position
+// until Reset, so a session replays it once
+func Get(api string) (*Script, error) {
+	path, format, err := ParseURL(api)
+	if err != nil {
+		return nil, err
+	}
+	key := path + "?format=" + format
+	scriptsMu.Lock()
+	defer scriptsMu.Unlock()
+	if s, ok := scripts[key]; ok {
+		return s, nil
+	}
+	s, err := LoadScript(path)
+	if err != nil {
+		return nil, err
+	}
+	if format != "" {
+		if !validFormat(format) {
+			return nil, fmt.Errorf("unknown mock format %q (known: %s)", format, strings.Join(Formats, ", "))
+		}
+		s.Format = format
+	}
+	scripts[key] = s
+	return s, nil
+}
+
+// Format is the wire format of the url; unknown scripts get lcp-chat
+func Format(api string) string {
+	s, err := Get(api)
+	if err != nil {
+		return FormatLCPChat
+	}
+	return s.Format
+}
+
+// Reset forgets the loaded scripts, so they start from the first turn again
+func Reset() {
+	scriptsMu.Lock()
+	defer scriptsMu.Unlock()
+	scripts = map[string]*Script{}
+}
+
+// Register makes the transport serve mock:// urls
+func Register(t *http.Transport) {
+	t.RegisterProtocol(Scheme, Transport{})
+}
@@ -0,0 +1,202 @@
+package mockllm
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testScript = `
+format = "openrouter-chat"
+chunk_size = 4
+
+[[turns]]
+reasoning = "think"
+text = "hello world"
+
+[[turns.tool_calls]]
+name = "bash"
+args = { command = "ls" }
+
+[[turns]]
+match = "again"
+text = "matched"
+
+[[turns]]
+status = 503
+error = "overloaded"
+`
+
+func writeScript(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "script.toml")
+	if err := os.WriteFile(path, []byte(testScript), 0644); err != nil {
+		t.Fatal(err)
+	}
+	Reset()
+	return "mock://" + path
+}
+
+func post(t *testing.T, api, body string) *http.Response {
+	t.Helper()
+	tr := &http.Transport{}
+	Register(tr)
+	req, err := http.NewRequest("POST", api, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: tr}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// events reads the data lines of a stream
+func events(t *testing.T, r io.Reader) []string {
+	t.Helper()
+	res := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			res = append(res, data)
+		}
+	}
+	return res
+}
+
+func TestStream(t *testing.T) {
+	api := writeScript(t)
+	resp := post(t, api, `{"stream": true, "messages": [{"role": "user", "content": "hi"}]}`)
+	defer resp.Body.Close()
+	lines := events(t, resp.Body)
+	if len(lines) < 2 || lines[len(lines)-1] != "[DONE]" {
+		t.Fatalf("stream does not end with [DONE]: %v", lines)
+	}
+	text, reasoning, finish, tools := "", "", "", 0
+	for _, line := range lines[:len(lines)-1] {
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content   string `json:"content"`
+					Reasoning string `json:"reasoning"`
+					ToolCalls []struct {
+						Function struct {
+							Name      string `json:"name"`
+							Arguments string `json:"arguments"`
+						} `json:"function"`
+					} `json:"tool_calls"`
+				} `json:"delta"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			t.Fatalf("bad chunk %s: %v", line, err)
+		}
+		c := chunk.Choices[0]
+		text += c.Delta.Content
+		reasoning += c.Delta.Reasoning
+		finish = c.FinishReason
+		for _, tc := range c.Delta.ToolCalls {
+			tools++
+			if tc.Function.Name != "bash" || tc.Function.Arguments != `{"command":"ls"}` {
+				t.Errorf("unexpected tool call %+v", tc)
+			}
+		}
+	}
+	if text != "hello world" || reasoning != "think" || finish != "tool_calls" || tools != 1 {
+		t.Errorf("unexpected stream: text %q, reasoning %q, finish %q, tools %d", text, reasoning, finish, tools)
+	}
+	// a matching turn answers out of order and can be used again
+	for range 2 {
+		resp = post(t, api, `{"messages": [{"role": "user", "content": "once again"}]}`)
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(data), `"content":"matched"`) {
+			t.Errorf("unexpected reply: %s", data)
+		}
+	}
+	resp = post(t, api, `{"stream": true}`)
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 503 || !strings.Contains(string(data), "overloaded") {
+		t.Errorf("expected error reply, got %d %s", resp.StatusCode, data)
+	}
+	resp = post(t, api, `{"stream": true}`)
+	resp.Body.Close()
+	if resp.StatusCode != 500 {
+		t.Errorf("expected 500 once the script is over, got %d", resp.StatusCode)
+	}
+	s, _ := Get(api)
+	if len(s.Requests()) != 5 {
+		t.Errorf("expected 5 recorded requests, got %d", len(s.Requests()))
+	}
+}
+
+func TestTextFormats(t *testing.T) {
+	api := writeScript(t) + "?format=" + FormatLCPCompletion
+	if Format(api) != FormatLCPCompletion {
+		t.Fatalf("format override ignored: %s", Format(api))
+	}
+	resp := post(t, api, `{"stream": true, "prompt": "hi"}`)
+	defer resp.Body.Close()
+	text, stopped := "", false
+	for _, line := range events(t, resp.Body) {
+		var chunk struct {
+			Content string `json:"content"`
+			Stop    bool   `json:"stop"`
+		}
+		if line == "[DONE]" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			t.Fatalf("bad chunk %s: %v", line, err)
+		}
+		text += chunk.Content
+		stopped = stopped || chunk.Stop
+	}
+	want := "<think>think</think>\nhello world\n__tool_call__\n{\"args\":{\"command\":\"ls\"},\"name\":\"bash\"}\n__tool_call__"
+	if text != want || !stopped {
+		t.Errorf("unexpected completion text (stop %v):\n%s", stopped, text)
+	}
+	if _, err := Get(writeScript(t) + "?format=nope"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	if got := split("a b\nc", 0); strings.Join(got, "|") != "a |b\n|c" {
+		t.Errorf("word split: %q", got)
+	}
+	if got := split("привет", 4); strings.Join(got, "|") != "прив|ет" {
+		t.Errorf("rune split: %q", got)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.toml")
+	script := "format = \"openrouter-chat\"\n[[turns]]\ntext = \"hi\"\nusage = { prompt_tokens = 10, completion_tokens = 2, cost = 0.5 }\n"
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	Reset()
+	for _, body := range []string{`{"stream": true}`, `{"stream": true, "usage": {"include": true}}`} {
+		resp := post(t, "mock://"+path, body)
+		lines := events(t, resp.Body)
+		resp.Body.Close()
+		hasUsage := strings.Contains(strings.Join(lines, "\n"), `"usage":{`)
+		if hasUsage != strings.Contains(body, "include") {
+			t.Errorf("%s: usage chunk sent %v: %v", body, hasUsage, lines)
+		}
+		if hasUsage && !strings.Contains(lines[len(lines)-2], `"cost":0.5`) {
+			t.Errorf("usage chunk is not the last one: %v", lines)
+		}
+		// the script has one turn
+		Reset()
+	}
+}
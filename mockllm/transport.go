@@ -0,0 +1,333 @@
+package mockllm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Transport answers requests to mock:// urls from their scripts
+type Transport struct{}
+
+func (Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s, err := Get(req.URL.String())
+	if err != nil {
+		return nil, err
+	}
+	body := []byte{}
+	if req.Body != nil {
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	t, err := s.turn(string(body))
+	if err != nil {
+		return errorResponse(req, http.StatusInternalServerError, err.Error()), nil
+	}
+	if t.Status != 0 || t.Error != "" {
+		status := t.Status
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		resp := errorResponse(req, status, t.Error)
+		if t.RetryAfter > 0 {
+			resp.Header.Set("Retry-After", strconv.Itoa(t.RetryAfter))
+		}
+		return resp, nil
+	}
+	var opts struct {
+		Stream        bool `json:"stream"`
+		StreamOptions struct {
+			IncludeUsage bool `json:"include_usage"`
+		} `json:"stream_options"`
+		Usage struct {
+			Include bool `json:"include"`
+		} `json:"usage"`
+	}
+	_ = json.Unmarshal(body, &opts)
+	if !opts.Stream {
+		data, err := json.Marshal(s.reply(t))
+		if err != nil {
+			return nil, err
+		}
+		return response(req, http.StatusOK, "application/json", io.NopCloser(strings.NewReader(string(data)))), nil
+	}
+	pr, pw := io.Pipe()
+	go s.stream(req, t, pw, opts.StreamOptions.IncludeUsage || opts.Usage.Include)
+	return response(req, http.StatusOK, "text/event-stream", pr), nil
+}
+
+func response(req *http.Request, status int, contentType string, body io.ReadCloser) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{contentType}},
+		Body:       body,
+		Request:    req,
+	}
+}
+
+// errorResponse is shaped like the openai error replies the bot reads details from
+func errorResponse(req *http.Request, status int, msg string) *http.Response {
+	if msg == "" {
+		msg = http.StatusText(status)
+	}
+	data, _ := json.Marshal(map[string]any{"error": map[string]any{"message": msg, "code": status}})
+	return response(req, status, "application/json", io.NopCloser(strings.NewReader(string(data))))
+}
+
+// streamsToolCalls tells if the parser of the format reads tool call deltas;
+// the others get the calls as __tool_call__ text
+func streamsToolCalls(format string) bool {
+	return format == FormatLCPChat || format == FormatOpenRouterChat
+}
+
+func isChat(format string) bool {
+	return strings.HasSuffix(format, "-chat")
+}
+
+// toolCallText is a tool call the way models write it into text replies
+func toolCallText(tc ToolCall) string {
+	args := tc.Args
+	if args == nil {
+		args = map[string]any{}
+	}
+	data, _ := json.Marshal(map[string]any{"name": tc.Name, "args": args})
+	return "__tool_call__\n" + string(data) + "\n__tool_call__"
+}
+
+// content is the reply text of the turn in the format: inline thinking and tool calls
+// where the format has no place for them
+func (s *Script) content(t *Turn) (reasoning, text string) {
+	reasoning, text = t.Reasoning, t.Text
+	if !streamsToolCalls(s.Format) {
+		for _, tc := range t.ToolCalls {
+			if text != "" {
+				text += "\n"
+			}
+			text += toolCallText(tc)
+		}
+	}
+	if reasoning != "" && !isChat(s.Format) {
+		text = "<think>" + reasoning + "</think>\n" + text
+		reasoning = ""
+	}
+	return reasoning, text
+}
+
+func (tc ToolCall) id(i int) string {
+	if tc.ID != "" {
+		return tc.ID
+	}
+	return fmt.Sprintf("call_mock_%d", i)
+}
+
+func (tc ToolCall) arguments() string {
+	if tc.Args == nil {
+		return "{}"
+	}
+	data, _ := json.Marshal(tc.Args)
+	return string(data)
+}
+
+// usage is the usage object of openai-like replies
+func (s *Script) usage(t *Turn) map[string]any {
+	u := map[string]any{
+		"prompt_tokens":             t.Usage.PromptTokens,
+		"completion_tokens":         t.Usage.CompletionTokens,
+		"total_tokens":              t.Usage.PromptTokens + t.Usage.CompletionTokens,
+		"completion_tokens_details": map[string]any{"reasoning_tokens": t.Usage.ReasoningTokens},
+	}
+	if s.Format == FormatOpenRouterChat || s.Format == FormatOpenRouterCompletion {
+		u["cost"] = t.Usage.Cost
+	}
+	return u
+}
+
+// reply is the whole answer of a request with stream off
+func (s *Script) reply(t *Turn) any {
+	reasoning, text := s.content(t)
+	var resp map[string]any
+	switch s.Format {
+	case FormatLCPCompletion:
+		resp = map[string]any{"content": text, "stop": true}
+		if t.Usage != nil {
+			resp["tokens_evaluated"], resp["tokens_predicted"] = t.Usage.PromptTokens, t.Usage.CompletionTokens
+		}
+		return resp
+	case FormatDeepSeekCompletion, FormatOpenRouterCompletion:
+		resp = map[string]any{"choices": []any{map[string]any{"index": 0, "text": text, "finish_reason": "stop"}}}
+		if t.Usage != nil {
+			resp["usage"] = s.usage(t)
+		}
+		return resp
+	}
+	msg := map[string]any{"role": "assistant", "content": text}
+	finish := "stop"
+	if reasoning != "" {
+		msg["reasoning_content"] = reasoning
+		msg["reasoning"] = reasoning
+	}
+	if len(t.ToolCalls) > 0 && streamsToolCalls(s.Format) {
+		calls := make([]any, 0, len(t.ToolCalls))
+		for i, tc := range t.ToolCalls {
+			calls = append(calls, map[string]any{
+				"id": tc.id(i), "index": i, "type": "function",
+				"function": map[string]any{"name": tc.Name, "arguments": tc.arguments()},
+			})
+		}
+		msg["tool_calls"] = calls
+		finish = "tool_calls"
+	}
+	resp = map[string]any{"choices": []any{map[string]any{"index": 0, "message": msg, "finish_reason": finish}}}
+	if t.Usage != nil {
+		resp["usage"] = s.usage(t)
+	}
+	return resp
+}
+
+// stream writes the turn as server sent events; withUsage ends it with the usage chunk
+func (s *Script) stream(req *http.Request, t *Turn, w *io.PipeWriter, withUsage bool) {
+	delay := time.Duration(t.DelayMs) * time.Millisecond
+	send := func(data string) bool {
+		if delay > 0 {
+			select {
+			case <-req.Context().Done():
+				w.CloseWithError(req.Context().Err())
+				return false
+			case <-time.After(delay):
+			}
+		}
+		if _, err := io.WriteString(w, "data: "+data+"\n\n"); err != nil {
+			return false
+		}
+		return true
+	}
+	chunk := func(v any) bool {
+		data, _ := json.Marshal(v)
+		return send(string(data))
+	}
+	reasoning, text := s.content(t)
+	for _, part := range split(reasoning, s.ChunkSize) {
+		if !chunk(s.delta("", part)) {
+			return
+		}
+	}
+	for _, part := range split(text, s.ChunkSize) {
+		if !chunk(s.delta(part, "")) {
+			return
+		}
+	}
+	if t.Malformed {
+		send(`{"choices":[{"delta":{"content":"broken`)
+		w.Close()
+		return
+	}
+	finish := "stop"
+	if len(t.ToolCalls) > 0 && streamsToolCalls(s.Format) {
+		finish = "tool_calls"
+		for i, tc := range t.ToolCalls {
+			if !chunk(s.toolDelta(i, tc)) {
+				return
+			}
+		}
+	}
+	if t.Cut {
+		w.Close()
+		return
+	}
+	if !chunk(s.finish(t, finish)) {
+		w.Close()
+		return
+	}
+	// llama.cpp /completion counts the tokens in its last chunk instead
+	if withUsage && t.Usage != nil && s.Format != FormatLCPCompletion {
+		if !chunk(map[string]any{"choices": []any{}, "usage": s.usage(t)}) {
+			return
+		}
+	}
+	send("[DONE]")
+	w.Close()
+}
+
+// delta is a streamed piece of text or reasoning
+func (s *Script) delta(text, reasoning string) any {
+	switch s.Format {
+	case FormatLCPCompletion:
+		return map[string]any{"content": text, "stop": false}
+	case FormatDeepSeekCompletion, FormatOpenRouterCompletion:
+		return map[string]any{"choices": []any{map[string]any{"index": 0, "text": text, "finish_reason": nil}}}
+	}
+	delta := map[string]any{"content": text}
+	if reasoning != "" {
+		if s.Format == FormatOpenRouterChat {
+			delta["reasoning"] = reasoning
+		} else {
+			delta["reasoning_content"] = reasoning
+		}
+	}
+	return map[string]any{"choices": []any{map[string]any{"index": 0, "delta": delta, "finish_reason": nil}}}
+}
+
+// toolDelta streams a whole tool call in one chunk
+func (s *Script) toolDelta(i int, tc ToolCall) any {
+	call := map[string]any{
+		"id": tc.id(i), "index": i, "type": "function",
+		"function": map[string]any{"name": tc.Name, "arguments": tc.arguments()},
+	}
+	delta := map[string]any{"content": "", "tool_calls": []any{call}}
+	return map[string]any{"choices": []any{map[string]any{"index": 0, "delta": delta, "finish_reason": nil}}}
+}
+
+func (s *Script) finish(t *Turn, reason string) any {
+	switch s.Format {
+	case FormatLCPCompletion:
+		last := map[string]any{"content": "", "stop": true}
+		if t.Usage != nil {
+			last["tokens_evaluated"], last["tokens_predicted"] = t.Usage.PromptTokens, t.Usage.CompletionTokens
+		}
+		return last
+	case FormatDeepSeekCompletion, FormatOpenRouterCompletion:
+		return map[string]any{"choices": []any{map[string]any{"index": 0, "text": "", "finish_reason": reason}}}
+	}
+	return map[string]any{"choices": []any{map[string]any{"index": 0, "delta": map[string]any{"content": ""}, "finish_reason": reason}}}
+}
+
+// split cuts the text into chunks of size runes, or after each word with size 0
+func split(text string, size int) []string {
+	if text == "" {
+		return nil
+	}
+	parts := []string{}
+	if size <= 0 {
+		start := 0
+		for i, r := range text {
+			if (r == ' ' || r == '\n') && i+1 < len(text) {
+				parts = append(parts, text[start:i+1])
+				start = i + 1
+			}
+		}
+		return append(parts, text[start:])
+	}
+	for len(text) > 0 {
+		n, i := 0, 0
+		for i < len(text) && n < size {
+			_, w := utf8.DecodeRuneInString(text[i:])
+			i += w
+			n++
+		}
+		parts = append(parts, text[:i])
+		text = text[i:]
+	}
+	return parts
+}
@@ -4,44 +4,204 @@ import (
 	"crypto/md5"
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
 // https://github.com/malfoyslastname/character-card-spec-v2/blob/main/spec_v2.md
-// what a bloat; trim to Role->Msg pair and first msg
+// https://github.com/kwaroran/character-card-spec-v3/blob/main/SPEC_V3.md
+// v1 fields are flat at the top level; v2 and v3 put the same fields (and more) under "data"
 type CharCardSpec struct {
 	Name           string `json:"name"`
 	Description    string `json:"description"`
 	Personality    string `json:"personality"`
 	FirstMes       string `json:"first_mes"`
-	Avatar         string `json:"avatar"`
-	Chat           string `json:"chat"`
+	Avatar         string `json:"avatar,omitempty"`
+	Chat           string `json:"chat,omitempty"`
 	MesExample     string `json:"mes_example"`
 	Scenario       string `json:"scenario"`
-	CreateDate     string `json:"create_date"`
-	Talkativeness  string `json:"talkativeness"`
-	Fav            bool   `json:"fav"`
-	Creatorcomment string `json:"creatorcomment"`
-	Spec           string `json:"spec"`
-	SpecVersion    string `json:"spec_version"`
-	Tags           []any  `json:"tags"`
-	Extentions     []byte `json:"extentions"`
+	CreateDate     string `json:"create_date,omitempty"`
+	Talkativeness  string `json:"talkativeness,omitempty"`
+	Fav            bool   `json:"fav,omitempty"`
+	Creatorcomment string `json:"creatorcomment,omitempty"`
+	Spec           string `json:"spec,omitempty"`
+	SpecVersion    string `json:"spec_version,omitempty"`
+	Tags           []any  `json:"tags,omitempty"`
+	// v2
+	CreatorNotes            string         `json:"creator_notes,omitempty"`
+	SystemPrompt            string         `json:"system_prompt,omitempty"`
+	PostHistoryInstructions string         `json:"post_history_instructions,omitempty"`
+	AlternateGreetings      []string       `json:"alternate_greetings,omitempty"`
+	CharacterBook           *CharacterBook `json:"character_book,omitempty"`
+	Creator                 string         `json:"creator,omitempty"`
+	CharacterVersion        string         `json:"character_version,omitempty"`
+	Extensions              map[string]any `json:"extensions,omitempty"`
+	// v3
+	Assets                   []CardAsset       `json:"assets,omitempty"`
+	Nickname                 string            `json:"nickname,omitempty"`
+	CreatorNotesMultilingual map[string]string `json:"creator_notes_multilingual,omitempty"`
+	Source                   []string          `json:"source,omitempty"`
+	GroupOnlyGreetings       []string          `json:"group_only_greetings,omitempty"`
+	CreationDate             int64             `json:"creation_date,omitempty"`
+	ModificationDate         int64             `json:"modification_date,omitempty"`
 }
 
-type Spec2Wrapper struct {
-	Data CharCardSpec `json:"data"`
+// CharacterBook is the card embedded lorebook (world info)
+type CharacterBook struct {
+	Name              string               `json:"name,omitempty"`
+	Description       string               `json:"description,omitempty"`
+	ScanDepth         *int                 `json:"scan_depth,omitempty"`
+	TokenBudget       *int                 `json:"token_budget,omitempty"`
+	RecursiveScanning bool                 `json:"recursive_scanning,omitempty"`
+	Extensions        map[string]any       `json:"extensions"`
+	Entries           []CharacterBookEntry `json:"entries"`
+}
+
+type CharacterBookEntry struct {
+	Keys           []string       `json:"keys"`
+	Content        string         `json:"content"`
+	Extensions     map[string]any `json:"extensions"`
+	Enabled        bool           `json:"enabled"`
+	InsertionOrder int            `json:"insertion_order"`
+	CaseSensitive  *bool          `json:"case_sensitive,omitempty"`
+	UseRegex       bool           `json:"use_regex,omitempty"` // v3
+	Name           string         `json:"name,omitempty"`
+	Priority       int            `json:"priority,omitempty"`
+	ID             any            `json:"id,omitempty"` // number or string
+	Comment        string         `json:"comment,omitempty"`
+	Selective      bool           `json:"selective,omitempty"`
+	SecondaryKeys  []string       `json:"secondary_keys,omitempty"`
+	Constant       bool           `json:"constant,omitempty"`
+	Position       string         `json:"position,omitempty"` // before_char, after_char
+}
+
+// CardAsset is v3 asset; in png uri is "__asset:<path>" with data in "chara-ext-asset_:<path>" chunk
+type CardAsset struct {
+	Type string `json:"type"`
+	URI  string `json:"uri"`
+	Name string `json:"name"`
+	Ext  string `json:"ext"`
+}
+
+// CardWrapper is the json stored in png chunks: v2/v3 data and v1 fields at the top level,
+// so older readers still get the basics
+type CardWrapper struct {
+	CharCardSpec
+	Data *CharCardSpec `json:"data,omitempty"`
+}
+
+const (
+	SpecV2        = "chara_card_v2"
+	SpecV2Version = "2.0"
+	SpecV3        = "chara_card_v3"
+	SpecV3Version = "3.0"
+	// sillytavern default for cards that do not set it
+	DefaultTalkativeness = 0.5
+)
+
+// Wrap makes v2 or v3 wrapper of the card
+func (c *CharCardSpec) Wrap(v3 bool) *CardWrapper {
+	data := *c
+	data.Spec, data.SpecVersion = "", ""
+	if data.Extensions == nil {
+		data.Extensions = map[string]any{}
+	}
+	w := &CardWrapper{
+		CharCardSpec: CharCardSpec{
+			Name:        c.Name,
+			Description: c.Description,
+			Personality: c.Personality,
+			FirstMes:    c.FirstMes,
+			MesExample:  c.MesExample,
+			Scenario:    c.Scenario,
+			Tags:        c.Tags,
+			Spec:        SpecV3,
+			SpecVersion: SpecV3Version,
+		},
+		Data: &data,
+	}
+	if !v3 {
+		w.Spec, w.SpecVersion = SpecV2, SpecV2Version
+		data.Assets = nil
+		data.Nickname = ""
+		data.CreatorNotesMultilingual = nil
+		data.Source = nil
+		data.GroupOnlyGreetings = nil
+		data.CreationDate, data.ModificationDate = 0, 0
+	}
+	return w
+}
+
+// Unwrap returns the card from v2/v3 data or falls back to v1 top-level fields
+func (w *CardWrapper) Unwrap() *CharCardSpec {
+	if w.Data == nil || w.Data.Name == "" {
+		return &w.CharCardSpec
+	}
+	card := *w.Data
+	card.Spec, card.SpecVersion = w.Spec, w.SpecVersion
+	return &card
+}
+
+func FillMacros(s, char, user string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "{{char}}", char), "{{user}}", user)
+}
+
+// TalkativenessValue is how eager the char is to speak in group chats (0 to 1);
+// sillytavern keeps it in extensions, older cards at the top level
+func (c *CharCardSpec) TalkativenessValue() float64 {
+	var v any = c.Talkativeness
+	if ext, ok := c.Extensions["talkativeness"]; ok {
+		v = ext
+	}
+	switch t := v.(type) {
+	case float64:
+		return min(max(t, 0), 1)
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(t), 64); err == nil {
+			return min(max(f, 0), 1)
+		}
+	}
+	return DefaultTalkativeness
+}
+
+// sysPrompt joins the fields describing the character, as other frontends do
+func (c *CharCardSpec) sysPrompt(userName string) string {
+	parts := []string{}
+	// {{original}} is the frontend default prompt; there is none here
+	if sp := strings.TrimSpace(strings.ReplaceAll(c.SystemPrompt, "{{original}}", "")); sp != "" {
+		parts = append(parts, sp)
+	}
+	if c.Description != "" {
+		parts = append(parts, c.Description)
+	}
+	if c.Personality != "" {
+		parts = append(parts, "{{char}}'s personality: "+c.Personality)
+	}
+	if c.Scenario != "" {
+		parts = append(parts, "Scenario: "+c.Scenario)
+	}
+	if c.MesExample != "" {
+		parts = append(parts, "Example dialogue:\n"+c.MesExample)
+	}
+	return FillMacros(strings.Join(parts, "\n\n"), c.Name, userName)
 }
 
 func (c *CharCardSpec) Simplify(userName, fpath string) *CharCard {
-	fm := strings.ReplaceAll(strings.ReplaceAll(c.FirstMes, "{{char}}", c.Name), "{{user}}", userName)
-	sysPr := strings.ReplaceAll(strings.ReplaceAll(c.Description, "{{char}}", c.Name), "{{user}}", userName)
+	greetings := make([]string, len(c.AlternateGreetings))
+	for i, g := range c.AlternateGreetings {
+		greetings[i] = FillMacros(g, c.Name, userName)
+	}
 	return &CharCard{
-		ID:         ComputeCardID(c.Name, fpath),
-		SysPrompt:  sysPr,
-		FirstMsg:   fm,
-		Role:       c.Name,
-		FilePath:   fpath,
-		Characters: []string{c.Name, userName},
+		ID:            ComputeCardID(c.Name, fpath),
+		SysPrompt:     c.sysPrompt(userName),
+		FirstMsg:      FillMacros(c.FirstMes, c.Name, userName),
+		Role:          c.Name,
+		FilePath:      fpath,
+		Characters:    []string{c.Name, userName},
+		AltGreetings:  greetings,
+		PostHistory:   FillMacros(strings.ReplaceAll(c.PostHistoryInstructions, "{{original}}", ""), c.Name, userName),
+		CharacterBook: c.CharacterBook,
+		Spec:          c,
 	}
 }
 
@@ -50,22 +210,53 @@ func ComputeCardID(role, filePath string) string {
 }
 
 type CharCard struct {
-	ID         string   `json:"id"`
-	SysPrompt  string   `json:"sys_prompt"`
-	FirstMsg   string   `json:"first_msg"`
-	Role       string   `json:"role"`
-	Characters []string `json:"chars"`
-	FilePath   string   `json:"filepath"`
+	ID            string         `json:"id"`
+	SysPrompt     string         `json:"sys_prompt"`
+	FirstMsg      string         `json:"first_msg"`
+	Role          string         `json:"role"`
+	Characters    []string       `json:"chars"`
+	FilePath      string         `json:"filepath"`
+	AltGreetings  []string       `json:"alternate_greetings,omitempty"`
+	PostHistory   string         `json:"post_history_instructions,omitempty"`
+	CharacterBook *CharacterBook `json:"character_book,omitempty"`
+	// full card it was made from (png cards), to not lose fields on write
+	Spec *CharCardSpec `json:"-"`
+}
+
+// Greetings returns first message followed by alternate greetings
+func (cc *CharCard) Greetings() []string {
+	return append([]string{cc.FirstMsg}, cc.AltGreetings...)
 }
 
 func (cc *CharCard) ToSpec(userName string) *CharCardSpec {
-	descr := strings.ReplaceAll(strings.ReplaceAll(cc.SysPrompt, cc.Role, "{{char}}"), userName, "{{user}}")
-	return &CharCardSpec{
-		Name:        cc.Role,
-		Description: descr,
-		FirstMes:    cc.FirstMsg,
-		Spec:        "chara_card_v2",
-		SpecVersion: "2.0",
-		Extentions:  []byte("{}"),
+	unfill := func(s string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(s, cc.Role, "{{char}}"), userName, "{{user}}")
+	}
+	spec := &CharCardSpec{
+		Spec:        SpecV2,
+		SpecVersion: SpecV2Version,
+	}
+	if cc.Spec != nil {
+		*spec = *cc.Spec
+	}
+	// sys prompt is composed of several fields; keep them as is unless it was edited
+	if cc.Spec == nil || cc.Spec.sysPrompt(userName) != cc.SysPrompt {
+		spec.Description = unfill(cc.SysPrompt)
+		spec.SystemPrompt = ""
+		spec.Personality = ""
+		spec.Scenario = ""
+		spec.MesExample = ""
+	}
+	spec.Name = cc.Role
+	spec.FirstMes = unfill(cc.FirstMsg)
+	spec.AlternateGreetings = nil
+	for _, g := range cc.AltGreetings {
+		spec.AlternateGreetings = append(spec.AlternateGreetings, unfill(g))
+	}
+	spec.PostHistoryInstructions = unfill(cc.PostHistory)
+	spec.CharacterBook = cc.CharacterBook
+	if spec.Extensions == nil {
+		spec.Extensions = map[string]any{}
 	}
+	return spec
 }
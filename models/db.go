@@ -22,6 +22,17 @@ func (c *Chat) ToHistory() ([]RoleMsg, error) {
 	return resp, nil
 }
 
+// ChatHit is a message of a stored chat found by chat search
+type ChatHit struct {
+	ChatID   uint32  `db:"chat_id" json:"chat_id"`
+	ChatName string  `db:"chat_name" json:"chat_name"`
+	Agent    string  `db:"agent" json:"agent"`
+	MsgIdx   int     `db:"msg_idx" json:"msg_idx"`
+	Role     string  `db:"role" json:"role"`
+	Snippet  string  `db:"snippet" json:"snippet"`
+	Score    float64 `db:"score" json:"score"` // higher is better
+}
+
 /*
 memories should have two key system
 to be able to store different perspectives
@@ -34,6 +45,14 @@ type Memory struct {
 	Mind      string    `db:"mind" json:"mind"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	// 0 (trivia) to 1 (core fact); weights recall score
+	Importance float64 `db:"importance" json:"importance"`
+	// serialized []float32 of topic and mind; nil if embedder was not available
+	Embedding   []byte     `db:"embedding" json:"-"`
+	RecallCount int        `db:"recall_count" json:"recall_count"`
+	RecalledAt  *time.Time `db:"recalled_at" json:"recalled_at,omitempty"`
+	// relevance to the recall query; not stored
+	Score float64 `db:"-" json:"-"`
 }
 
 // vector models
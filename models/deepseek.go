@@ -1,19 +1,19 @@
 package models
 
 type DSChatReq struct {
-	Messages         []RoleMsg `json:"messages"`
-	Model            string    `json:"model"`
-	Stream           bool      `json:"stream"`
-	FrequencyPenalty int       `json:"frequency_penalty"`
-	MaxTokens        int       `json:"max_tokens"`
-	PresencePenalty  int       `json:"presence_penalty"`
-	Temperature      float32   `json:"temperature"`
-	TopP             float32   `json:"top_p"`
+	Messages         []RoleMsg      `json:"messages"`
+	Model            string         `json:"model"`
+	Stream           bool           `json:"stream"`
+	FrequencyPenalty int            `json:"frequency_penalty"`
+	MaxTokens        int            `json:"max_tokens"`
+	PresencePenalty  int            `json:"presence_penalty"`
+	Temperature      float32        `json:"temperature"`
+	TopP             float32        `json:"top_p"`
+	StreamOptions    *StreamOptions `json:"stream_options,omitempty"`
 	// ResponseFormat   struct {
 	// 	Type string `json:"type"`
 	// } `json:"response_format"`
 	// Stop          any    `json:"stop"`
-	// StreamOptions any     `json:"stream_options"`
 	// Tools         any     `json:"tools"`
 	// ToolChoice    string  `json:"tool_choice"`
 	// Logprobs      bool    `json:"logprobs"`
@@ -21,7 +21,7 @@ type DSChatReq struct {
 }
 
 func NewDSChatReq(cb ChatBody) DSChatReq {
-	return DSChatReq{
+	req := DSChatReq{
 		Messages:         cb.Messages,
 		Model:            cb.Model,
 		Stream:           cb.Stream,
@@ -31,6 +31,10 @@ func NewDSChatReq(cb ChatBody) DSChatReq {
 		Temperature:      1.0,
 		TopP:             1.0,
 	}
+	if cb.Stream {
+		req.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	return req
 }
 
 type DSCompletionReq struct {
@@ -55,6 +59,7 @@ func NewDSCompletionReq(prompt, model string, temp float32, stopSlice []string)
 		Prompt:           prompt,
 		Temperature:      temp,
 		Stream:           true,
+		StreamOptions:    &StreamOptions{IncludeUsage: true},
 		Echo:             false,
 		MaxTokens:        2048,
 		PresencePenalty:  0,
@@ -78,20 +83,11 @@ type DSCompletionResp struct {
 		} `json:"logprobs"`
 		Text string `json:"text"`
 	} `json:"choices"`
-	Created           int    `json:"created"`
-	Model             string `json:"model"`
-	SystemFingerprint string `json:"system_fingerprint"`
-	Object            string `json:"object"`
-	Usage             struct {
-		CompletionTokens        int `json:"completion_tokens"`
-		PromptTokens            int `json:"prompt_tokens"`
-		PromptCacheHitTokens    int `json:"prompt_cache_hit_tokens"`
-		PromptCacheMissTokens   int `json:"prompt_cache_miss_tokens"`
-		TotalTokens             int `json:"total_tokens"`
-		CompletionTokensDetails struct {
-			ReasoningTokens int `json:"reasoning_tokens"`
-		} `json:"completion_tokens_details"`
-	} `json:"usage"`
+	Created           int        `json:"created"`
+	Model             string     `json:"model"`
+	SystemFingerprint string     `json:"system_fingerprint"`
+	Object            string     `json:"object"`
+	Usage             *RespUsage `json:"usage"`
 }
 
 type DSChatResp struct {
@@ -131,6 +127,7 @@ type DSChatStreamResp struct {
 		Logprobs     any    `json:"logprobs"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *RespUsage `json:"usage"`
 }
 
 type DSBalance struct {
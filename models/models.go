@@ -67,15 +67,11 @@ type LLMRespChunk struct {
 			ToolCalls        []ToolDeltaResp `json:"tool_calls"`
 		} `json:"delta"`
 	} `json:"choices"`
-	Created int    `json:"created"`
-	ID      string `json:"id"`
-	Model   string `json:"model"`
-	Object  string `json:"object"`
-	Usage   struct {
-		CompletionTokens int `json:"completion_tokens"`
-		PromptTokens     int `json:"prompt_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Created int        `json:"created"`
+	ID      string     `json:"id"`
+	Model   string     `json:"model"`
+	Object  string     `json:"object"`
+	Usage   *RespUsage `json:"usage"`
 }
 
 type TextChunk struct {
@@ -84,6 +80,7 @@ type TextChunk struct {
 	ToolResp  bool
 	Reasoning string // For models that send reasoning separately (OpenRouter, etc.)
 	ToolCalls []ToolDeltaResp // All tool call deltas from this chunk
+	Usage     *Usage          // usage of the whole reply, sent by the last chunks
 }
 
 type TextContentPart struct {
@@ -557,7 +554,8 @@ type Tool struct {
 
 type OpenAIReq struct {
 	*ChatBody
-	Tools any `json:"tools"`
+	Tools         any            `json:"tools"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
 }
 
 // ===
@@ -626,8 +624,10 @@ func NewLCPReq(prompt, model string, multimodalData []string, props map[string]f
 }
 
 type LlamaCPPResp struct {
-	Content string `json:"content"`
-	Stop    bool   `json:"stop"`
+	Content         string `json:"content"`
+	Stop            bool   `json:"stop"`
+	TokensEvaluated int    `json:"tokens_evaluated"` // prompt tokens, sent with stop
+	TokensPredicted int    `json:"tokens_predicted"`
 }
 
 type LCPModels struct {
@@ -672,13 +672,16 @@ type ResponseStats struct {
 	Tokens       int
 	Duration     float64
 	TokensPerSec float64
+	Usage        *Usage `json:"usage,omitempty"` // reported by the api, nil when it did not
 }
 
 type ChatRoundReq struct {
-	UserMsg string
-	Role    string
-	Regen   bool
-	Resume  bool
+	UserMsg  string
+	Role     string
+	Regen    bool
+	Resume   bool
+	Fallback int // cfg.Fallbacks used so far by this round
+	Resumes  int // times the broken stream of this reply was resumed
 }
 
 type MultimodalToolResp struct {
@@ -3,13 +3,20 @@ package models
 // openrouter
 // https://openrouter.ai/docs/api-reference/completion
 type OpenRouterCompletionReq struct {
-	Model       string   `json:"model"`
-	Prompt      string   `json:"prompt"`
-	Stream      bool     `json:"stream"`
-	Temperature float32  `json:"temperature"`
-	Stop        []string `json:"stop"` // not present in docs
-	MinP        float32  `json:"min_p"`
-	NPredict    int32    `json:"max_tokens"`
+	Model       string          `json:"model"`
+	Prompt      string          `json:"prompt"`
+	Stream      bool            `json:"stream"`
+	Temperature float32         `json:"temperature"`
+	Stop        []string        `json:"stop"` // not present in docs
+	MinP        float32         `json:"min_p"`
+	NPredict    int32           `json:"max_tokens"`
+	Usage       UsageAccounting `json:"usage"`
+}
+
+// UsageAccounting asks openrouter for the token usage and cost of the reply
+// https://openrouter.ai/docs/use-cases/usage-accounting
+type UsageAccounting struct {
+	Include bool `json:"include"`
 }
 
 func NewOpenRouterCompletionReq(model, prompt string, props map[string]float32, stopStrings []string) OpenRouterCompletionReq {
@@ -21,6 +28,7 @@ func NewOpenRouterCompletionReq(model, prompt string, props map[string]float32,
 		NPredict:    int32(props["n_predict"]),
 		Stop:        stopStrings,
 		Model:       model,
+		Usage:       UsageAccounting{Include: true},
 	}
 }
 
@@ -33,6 +41,7 @@ type OpenRouterChatReq struct {
 	NPredict    int32            `json:"max_tokens"`
 	Tools       any              `json:"tools"`
 	Reasoning   *ReasoningConfig `json:"reasoning,omitempty"`
+	Usage       UsageAccounting  `json:"usage"`
 }
 
 type ReasoningConfig struct {
@@ -48,6 +57,7 @@ func NewOpenRouterChatReq(cb ChatBody, props map[string]float32, reasoningEffort
 		Temperature: props["temperature"],
 		MinP:        props["min_p"],
 		NPredict:    int32(props["n_predict"]),
+		Usage:       UsageAccounting{Include: true},
 	}
 	// Only include reasoning config if effort is specified and not "none"
 	if reasoningEffort != "" && reasoningEffort != "none" {
@@ -102,6 +112,7 @@ type OpenRouterChatResp struct {
 		NativeFinishReason string `json:"native_finish_reason"`
 		Logprobs           any    `json:"logprobs"`
 	} `json:"choices"`
+	Usage *RespUsage `json:"usage"`
 }
 
 type OpenRouterCompletionResp struct {
@@ -116,6 +127,7 @@ type OpenRouterCompletionResp struct {
 		NativeFinishReason string `json:"native_finish_reason"`
 		Logprobs           any    `json:"logprobs"`
 	} `json:"choices"`
+	Usage *RespUsage `json:"usage"`
 }
 
 type ORModel struct {
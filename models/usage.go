@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// Usage is the token usage of one reply and its cost in USD
+type Usage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	ReasoningTokens  int     `json:"reasoning_tokens,omitempty"` // part of the completion tokens
+	Cost             float64 `json:"cost,omitempty"`
+}
+
+// Add sums the usage of two parts of one reply, like a broken stream and its resume
+func (u *Usage) Add(o *Usage) *Usage {
+	switch {
+	case u == nil:
+		return o
+	case o == nil:
+		return u
+	}
+	return &Usage{
+		PromptTokens:     u.PromptTokens + o.PromptTokens,
+		CompletionTokens: u.CompletionTokens + o.CompletionTokens,
+		ReasoningTokens:  u.ReasoningTokens + o.ReasoningTokens,
+		Cost:             u.Cost + o.Cost,
+	}
+}
+
+func (u *Usage) Total() int {
+	if u == nil {
+		return 0
+	}
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// StreamOptions asks openai-like apis to end the stream with a usage chunk
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// RespUsage is the usage object of openai-like replies; openrouter adds the cost
+type RespUsage struct {
+	PromptTokens            int     `json:"prompt_tokens"`
+	CompletionTokens        int     `json:"completion_tokens"`
+	TotalTokens             int     `json:"total_tokens"`
+	Cost                    float64 `json:"cost"`
+	CompletionTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+// ToUsage is nil for a missing usage object, so chunks without one do not reset it
+func (r *RespUsage) ToUsage() *Usage {
+	if r == nil {
+		return nil
+	}
+	return &Usage{
+		PromptTokens:     r.PromptTokens,
+		CompletionTokens: r.CompletionTokens,
+		ReasoningTokens:  r.CompletionTokensDetails.ReasoningTokens,
+		Cost:             r.Cost,
+	}
+}
+
+// UsageRecord is the usage of one reply in the usage table
+type UsageRecord struct {
+	ID               uint32    `db:"id"`
+	Chat             string    `db:"chat"`
+	API              string    `db:"api"`
+	Model            string    `db:"model"`
+	PromptTokens     int       `db:"prompt_tokens"`
+	CompletionTokens int       `db:"completion_tokens"`
+	ReasoningTokens  int       `db:"reasoning_tokens"`
+	Cost             float64   `db:"cost"`
+	CreatedAt        time.Time `db:"created_at"`
+}
+
+// UsageTotal sums the usage records of one chat, day or model
+type UsageTotal struct {
+	Key              string  `db:"key" json:"key"`
+	Replies          int     `db:"replies" json:"replies"`
+	PromptTokens     int     `db:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int     `db:"completion_tokens" json:"completion_tokens"`
+	ReasoningTokens  int     `db:"reasoning_tokens" json:"reasoning_tokens"`
+	Cost             float64 `db:"cost" json:"cost"`
+}
@@ -12,6 +12,7 @@ import (
 type Orator interface {
 	Speak(text string) error
 	Stop()
+	IsSpeaking() bool
 	GetLogger() *slog.Logger
 }
 
@@ -41,6 +42,10 @@ func (d *DefaultOrator) Stop() {
 	// No-op
 }
 
+func (d *DefaultOrator) IsSpeaking() bool {
+	return false
+}
+
 func (d *DefaultOrator) GetLogger() *slog.Logger {
 	return d.logger
 }
@@ -11,6 +11,7 @@ import (
 	"hash/crc32"
 	"io"
 	"os"
+	"strings"
 )
 
 const (
@@ -18,20 +19,27 @@ const (
 	textChunkType = "tEXt"
 )
 
-// WriteToPng embeds the metadata into the specified PNG file and writes the result to outfile.
+// WriteToPng embeds the card into the specified PNG file and writes the result to outfile.
+// Card is written both as v2 ("chara") and v3 ("ccv3"); other tEXt chunks (v3 assets) are kept.
 func WriteToPng(metadata *models.CharCardSpec, sourcePath, outfile string) error {
 	pngData, err := os.ReadFile(sourcePath)
 	if err != nil {
 		return err
 	}
-	jsonData, err := json.Marshal(metadata)
-	if err != nil {
-		return err
-	}
-	base64Data := base64.StdEncoding.EncodeToString(jsonData)
-	embedData := PngEmbed{
-		Key:   "gf-lt", // Replace with appropriate key constant
-		Value: base64Data,
+	embeds := make([]PngEmbed, 0, 2)
+	for _, v3 := range []bool{false, true} {
+		jsonData, err := json.Marshal(metadata.Wrap(v3))
+		if err != nil {
+			return err
+		}
+		key := cKey
+		if v3 {
+			key = v3Key
+		}
+		embeds = append(embeds, PngEmbed{
+			Key:   key,
+			Value: base64.StdEncoding.EncodeToString(jsonData),
+		})
 	}
 	var outputBuffer bytes.Buffer
 	if _, err := outputBuffer.Write([]byte(pngHeader)); err != nil {
@@ -44,16 +52,28 @@ func WriteToPng(metadata *models.CharCardSpec, sourcePath, outfile string) error
 	for _, chunk := range chunks {
 		outputBuffer.Write(chunk)
 	}
-	newChunk, err := createTextChunk(embedData)
-	if err != nil {
-		return err
+	for _, embed := range embeds {
+		newChunk, err := createTextChunk(embed)
+		if err != nil {
+			return err
+		}
+		outputBuffer.Write(newChunk)
 	}
-	outputBuffer.Write(newChunk)
 	outputBuffer.Write(iend)
 	return os.WriteFile(outfile, outputBuffer.Bytes(), 0666)
 }
 
-// processChunks extracts non-tEXt chunks and locates the IEND chunk
+// isCardChunk reports if tEXt chunk data holds a card that is going to be rewritten
+func isCardChunk(data []byte) bool {
+	key, _, _ := bytes.Cut(data, []byte{0})
+	switch strings.ToLower(string(key)) {
+	case cKey, v3Key, gfKey:
+		return true
+	}
+	return false
+}
+
+// processChunks extracts all chunks except card tEXt chunks and locates the IEND chunk
 func processChunks(data []byte) ([][]byte, []byte, error) {
 	var (
 		chunks    [][]byte
@@ -98,7 +118,10 @@ func processChunks(data []byte) ([][]byte, []byte, error) {
 			iendChunk = fullChunk.Bytes()
 			return chunks, iendChunk, nil
 		case textChunkType:
-			continue // Skip existing tEXt chunks
+			if isCardChunk(chunkData) {
+				continue // Skip existing card chunks
+			}
+			chunks = append(chunks, fullChunk.Bytes())
 		default:
 			chunks = append(chunks, fullChunk.Bytes())
 		}
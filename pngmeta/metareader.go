@@ -17,6 +17,9 @@ import (
 const (
 	embType     = "tEXt"
 	cKey        = "chara"
+	v3Key       = "ccv3"
+	gfKey       = "gf-lt" // older gf-lt writes
+	assetPrefix = "chara-ext-asset_:"
 	IEND        = "IEND"
 	header      = "\x89PNG\r\n\x1a\n"
 	writeHeader = "\x89\x50\x4E\x47\x0D\x0A\x1A\x0A"
@@ -27,26 +30,20 @@ type PngEmbed struct {
 	Value string
 }
 
+// GetDecodedValue reads v1 (flat), v2 and v3 (wrapped in data) cards
 func (c PngEmbed) GetDecodedValue() (*models.CharCardSpec, error) {
 	data, err := base64.StdEncoding.DecodeString(c.Value)
 	if err != nil {
 		return nil, err
 	}
-	card := &models.CharCardSpec{}
-	if err := json.Unmarshal(data, &card); err != nil {
+	wrapper := &models.CardWrapper{}
+	if err := json.Unmarshal(data, wrapper); err != nil {
 		return nil, err
 	}
-	specWrap := &models.Spec2Wrapper{}
-	if card.Name == "" {
-		if err := json.Unmarshal(data, &specWrap); err != nil {
-			return nil, err
-		}
-		return &specWrap.Data, nil
-	}
-	return card, nil
+	return wrapper.Unwrap(), nil
 }
 
-func extractChar(fname string) (*PngEmbed, error) {
+func readTextChunks(fname string) ([]PngEmbed, error) {
 	data, err := os.ReadFile(fname)
 	if err != nil {
 		return nil, err
@@ -56,12 +53,14 @@ func extractChar(fname string) (*PngEmbed, error) {
 	if err != nil {
 		return nil, err
 	}
+	resp := []PngEmbed{}
 	for {
 		step, err := pr.Next()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
 			}
+			return nil, err
 		}
 		if step.Type() != embType {
 			if _, err := io.Copy(io.Discard, step); err != nil {
@@ -72,19 +71,60 @@ func extractChar(fname string) (*PngEmbed, error) {
 			if err != nil {
 				return nil, err
 			}
-			dataInstep := string(buf)
-			values := strings.Split(dataInstep, "\x00")
+			values := strings.SplitN(string(buf), "\x00", 2)
 			if len(values) == 2 {
-				return &PngEmbed{Key: values[0], Value: values[1]}, nil
+				resp = append(resp, PngEmbed{Key: values[0], Value: values[1]})
 			}
 		}
 		if err := step.Close(); err != nil {
 			return nil, err
 		}
 	}
+	return resp, nil
+}
+
+// extractChar returns the card chunk; v3 is preferred since v2 chunk is kept only for older readers
+func extractChar(fname string) (*PngEmbed, error) {
+	embeds, err := readTextChunks(fname)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range []string{v3Key, cKey, gfKey} {
+		for i := range embeds {
+			if strings.EqualFold(embeds[i].Key, key) {
+				return &embeds[i], nil
+			}
+		}
+	}
+	for i := range embeds {
+		if !strings.HasPrefix(embeds[i].Key, assetPrefix) {
+			return &embeds[i], nil
+		}
+	}
 	return nil, errors.New("failed to find embedded char in png: " + fname)
 }
 
+// ReadEmbeddedAssets returns v3 assets stored in the png, keyed by their uri ("__asset:<path>")
+func ReadEmbeddedAssets(fname string) (map[string][]byte, error) {
+	embeds, err := readTextChunks(fname)
+	if err != nil {
+		return nil, err
+	}
+	resp := make(map[string][]byte)
+	for _, e := range embeds {
+		name, ok := strings.CutPrefix(e.Key, assetPrefix)
+		if !ok {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(e.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode asset %s: %w", name, err)
+		}
+		resp["__asset:"+name] = data
+	}
+	return resp, nil
+}
+
 func ReadCard(fname, uname string) (*models.CharCard, error) {
 	pe, err := extractChar(fname)
 	if err != nil {
@@ -115,8 +155,12 @@ func ReadCardJson(fname, uname string) (*models.CharCard, error) {
 	if card.ID == "" {
 		card.ID = models.ComputeCardID(card.Role, card.FilePath)
 	}
-	card.FirstMsg = strings.ReplaceAll(strings.ReplaceAll(card.FirstMsg, "{{char}}", card.Role), "{{user}}", uname)
-	card.SysPrompt = strings.ReplaceAll(strings.ReplaceAll(card.SysPrompt, "{{char}}", card.Role), "{{user}}", uname)
+	card.FirstMsg = models.FillMacros(card.FirstMsg, card.Role, uname)
+	card.SysPrompt = models.FillMacros(card.SysPrompt, card.Role, uname)
+	card.PostHistory = models.FillMacros(card.PostHistory, card.Role, uname)
+	for i, g := range card.AltGreetings {
+		card.AltGreetings[i] = models.FillMacros(g, card.Role, uname)
+	}
 	return &card, nil
 }
 
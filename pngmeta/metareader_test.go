@@ -192,3 +192,121 @@ func readTextChunk(t *testing.T, r io.ReadSeeker) *models.CharCardSpec {
 	}
 	return &result
 }
+
+func TestCardRoundTrip(t *testing.T) {
+	srcPath := createTestImage(t)
+	dir := filepath.Dir(srcPath)
+	// png with v3 card and an embedded asset, as other frontends write it
+	withAsset := filepath.Join(dir, "asset.png")
+	if err := WriteToPng(&models.CharCardSpec{Name: "old"}, srcPath, withAsset); err != nil {
+		t.Fatalf("WriteToPng failed: %v", err)
+	}
+	data, err := os.ReadFile(withAsset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assetChunk, err := createTextChunk(PngEmbed{
+		Key:   assetPrefix + "1",
+		Value: base64.StdEncoding.EncodeToString([]byte("icon")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	iendAt := len(data) - 12
+	data = append(data[:iendAt:iendAt], append(assetChunk, data[iendAt:]...)...)
+	if err := os.WriteFile(withAsset, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	depth := 4
+	spec := &models.CharCardSpec{
+		Name:                    "Seraphina",
+		Description:             "{{char}} is a guardian of the forest.",
+		Personality:             "kind",
+		Scenario:                "{{user}} wakes up in a glade.",
+		FirstMes:                "Hello, {{user}}.",
+		SystemPrompt:            "{{original}}Stay in character.",
+		PostHistoryInstructions: "Reply as {{char}} only.",
+		AlternateGreetings:      []string{"Welcome back, {{user}}."},
+		Creator:                 "tester",
+		Tags:                    []any{"fantasy"},
+		CharacterBook: &models.CharacterBook{
+			ScanDepth: &depth,
+			Entries: []models.CharacterBookEntry{
+				{Keys: []string{"glade"}, Content: "The glade is enchanted.", Enabled: true, InsertionOrder: 10},
+			},
+		},
+		Assets:   []models.CardAsset{{Type: "icon", URI: "__asset:1", Name: "main", Ext: "png"}},
+		Nickname: "Sera",
+	}
+	outPath := filepath.Join(dir, "card.png")
+	if err := WriteToPng(spec, withAsset, outPath); err != nil {
+		t.Fatalf("WriteToPng failed: %v", err)
+	}
+	embeds, err := readTextChunks(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{}
+	for _, e := range embeds {
+		keys = append(keys, e.Key)
+	}
+	if len(keys) != 3 {
+		t.Errorf("want chara, ccv3 and asset chunks, got %v", keys)
+	}
+	assets, err := ReadEmbeddedAssets(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(assets["__asset:1"]) != "icon" {
+		t.Errorf("asset was not preserved: %v", assets)
+	}
+	cc, err := ReadCard(outPath, "Adam")
+	if err != nil {
+		t.Fatalf("ReadCard failed: %v", err)
+	}
+	if cc.Spec.Spec != models.SpecV3 || cc.Spec.Nickname != "Sera" {
+		t.Errorf("expected v3 card, got spec %q nickname %q", cc.Spec.Spec, cc.Spec.Nickname)
+	}
+	wantSys := "Stay in character.\n\nSeraphina is a guardian of the forest.\n\n" +
+		"Seraphina's personality: kind\n\nScenario: Adam wakes up in a glade."
+	if cc.SysPrompt != wantSys {
+		t.Errorf("sys prompt mismatch\nWant: %q\nGot:  %q", wantSys, cc.SysPrompt)
+	}
+	if cc.PostHistory != "Reply as Seraphina only." {
+		t.Errorf("unexpected post history instructions: %q", cc.PostHistory)
+	}
+	if len(cc.AltGreetings) != 1 || cc.AltGreetings[0] != "Welcome back, Adam." {
+		t.Errorf("unexpected alternate greetings: %v", cc.AltGreetings)
+	}
+	if cc.CharacterBook == nil || len(cc.CharacterBook.Entries) != 1 || *cc.CharacterBook.ScanDepth != 4 {
+		t.Errorf("character book was not read: %+v", cc.CharacterBook)
+	}
+	// unedited card keeps its fields on write back
+	back := cc.ToSpec("Adam")
+	if back.Personality != "kind" || back.SystemPrompt != spec.SystemPrompt || back.Description != spec.Description {
+		t.Errorf("fields were lost on write back: %+v", back)
+	}
+	if back.FirstMes != spec.FirstMes || back.AlternateGreetings[0] != spec.AlternateGreetings[0] {
+		t.Errorf("greetings were not restored: %q %v", back.FirstMes, back.AlternateGreetings)
+	}
+	// edited sys prompt replaces composed fields
+	cc.SysPrompt = "Seraphina is a dragon."
+	back = cc.ToSpec("Adam")
+	if back.Description != "{{char}} is a dragon." || back.Personality != "" || back.SystemPrompt != "" {
+		t.Errorf("edited sys prompt was not stored: %+v", back)
+	}
+}
+
+func TestReadV1Card(t *testing.T) {
+	// flat v1 json under chara key
+	v1 := `{"name":"Old","description":"{{char}} is old.","first_mes":"hi {{user}}"}`
+	pe := PngEmbed{Key: cKey, Value: base64.StdEncoding.EncodeToString([]byte(v1))}
+	spec, err := pe.GetDecodedValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := spec.Simplify("Adam", "old.png")
+	if cc.Role != "Old" || cc.SysPrompt != "Old is old." || cc.FirstMsg != "hi Adam" {
+		t.Errorf("unexpected v1 card: %+v", cc)
+	}
+}
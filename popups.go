@@ -1,9 +1,12 @@
 package main
 
 import (
+	"fmt"
+	"gf-lt/chatio"
 	"gf-lt/models"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
@@ -339,6 +342,51 @@ func showBotRoleSelectionPopup() {
 	app.SetFocus(roleListWidget)
 }
 
+// showGreetingSelectionPopup lets to pick first message from card alternate greetings
+func showGreetingSelectionPopup(cc *models.CharCard) {
+	greetingListWidget := tview.NewList().ShowSecondaryText(false).
+		SetSelectedBackgroundColor(tcell.ColorGray)
+	greetingListWidget.SetTitle("Select Greeting").SetBorder(true)
+	for _, g := range cc.Greetings() {
+		line, _, _ := strings.Cut(strings.TrimSpace(g), "\n")
+		greetingListWidget.AddItem(tview.Escape(line), "", 0, nil)
+	}
+	greetingListWidget.SetSelectedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
+		if err := setGreeting(cc, index); err != nil {
+			logger.Warn("failed to set greeting", "error", err)
+			showToast("greeting", err.Error())
+		}
+		textView.SetText(chatToText(chatBody.Messages, cfg.ShowSys))
+		colorText()
+		pages.RemovePage("greetingSelectionPopup")
+		app.SetFocus(textArea)
+	})
+	greetingListWidget.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			pages.RemovePage("greetingSelectionPopup")
+			app.SetFocus(textArea)
+			return nil
+		}
+		if event.Key() == tcell.KeyRune && event.Rune() == 'x' {
+			pages.RemovePage("greetingSelectionPopup")
+			app.SetFocus(textArea)
+			return nil
+		}
+		return event
+	})
+	modal := func(p tview.Primitive, width, height int) tview.Primitive {
+		return tview.NewFlex().
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+				AddItem(nil, 0, 1, false).
+				AddItem(p, height, 1, true).
+				AddItem(nil, 0, 1, false), width, 1, true).
+			AddItem(nil, 0, 1, false)
+	}
+	pages.AddPage("greetingSelectionPopup", modal(greetingListWidget, 80, 20), true, true)
+	app.SetFocus(greetingListWidget)
+}
+
 func showShellFileCompletionPopup(filter string) {
 	baseDir := cfg.FilePickerDir
 	if baseDir == "" {
@@ -570,3 +618,98 @@ func showColorschemeSelectionPopup() {
 	pages.AddPage("colorschemeSelectionPopup", modal(schemeListWidget, 40, len(schemeNames)+2), true, true)
 	app.SetFocus(schemeListWidget)
 }
+
+// showToolCancelPopup lists concurrently running tool calls; enter cancels the picked one or all of them
+func showToolCancelPopup(running []runningToolCall) {
+	const pageName = "toolCancelPopup"
+	list := tview.NewList().ShowSecondaryText(true).
+		SetSelectedBackgroundColor(tcell.ColorGray)
+	list.SetTitle("Cancel tool call").SetBorder(true)
+	list.AddItem("all", fmt.Sprintf("%d running calls", len(running)), 0, nil)
+	for _, rt := range running {
+		args := rt.args
+		if len(args) > 60 {
+			args = args[:57] + "..."
+		}
+		list.AddItem(fmt.Sprintf("%s (%ds)", rt.name, int(time.Since(rt.started).Seconds())), args, 0, nil)
+	}
+	closePopup := func() {
+		pages.RemovePage(pageName)
+		app.SetFocus(textArea)
+	}
+	list.SetSelectedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
+		if index == 0 {
+			cancelToolCall(-1)
+		} else {
+			cancelToolCall(running[index-1].id)
+		}
+		closePopup()
+	})
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || (event.Key() == tcell.KeyRune && event.Rune() == 'x') {
+			closePopup()
+			return nil
+		}
+		return event
+	})
+	modal := func(p tview.Primitive, width, height int) tview.Primitive {
+		return tview.NewFlex().
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+				AddItem(nil, 0, 1, false).
+				AddItem(p, height, 1, true).
+				AddItem(nil, 0, 1, false), width, 1, true).
+			AddItem(nil, 0, 1, false)
+	}
+	pages.AddPage(pageName, modal(list, 80, 20), true, true)
+	app.SetFocus(list)
+}
+
+// showExportFormatPopup picks the format the active chat is exported in
+func showExportFormatPopup() {
+	const pageName = "exportFormatPopup"
+	descriptions := map[string]string{
+		chatio.FormatJSON:     "gf-lt json (F11 imports it back)",
+		chatio.FormatMarkdown: "markdown, tool calls folded",
+		chatio.FormatHTML:     "standalone html with embedded images",
+		chatio.FormatSTJSONL:  "SillyTavern chat (jsonl)",
+	}
+	list := tview.NewList().ShowSecondaryText(true).
+		SetSelectedBackgroundColor(tcell.ColorGray)
+	list.SetTitle("Export chat").SetBorder(true)
+	for _, format := range chatio.Formats {
+		list.AddItem(format, descriptions[format], 0, nil)
+	}
+	closePopup := func() {
+		pages.RemovePage(pageName)
+		app.SetFocus(textArea)
+	}
+	list.SetSelectedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
+		closePopup()
+		fp, err := exportChat(mainText)
+		if err != nil {
+			logger.Error("failed to export chat;", "error", err, "chat_name", activeChatName, "format", mainText)
+			showToast("export failed", err.Error())
+			return
+		}
+		showToast("exported chat", "chat: "+activeChatName+" was exported to "+fp)
+	})
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || (event.Key() == tcell.KeyRune && event.Rune() == 'x') {
+			closePopup()
+			return nil
+		}
+		return event
+	})
+	modal := func(p tview.Primitive, width, height int) tview.Primitive {
+		return tview.NewFlex().
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+				AddItem(nil, 0, 1, false).
+				AddItem(p, height, 1, true).
+				AddItem(nil, 0, 1, false), width, 1, true).
+			AddItem(nil, 0, 1, false)
+	}
+	pages.AddPage(pageName, modal(list, 60, len(chatio.Formats)*2+2), true, true)
+	app.SetFocus(list)
+}
@@ -148,6 +148,9 @@ func makePropsTable(props map[string]float32) *tview.Table {
 	addListPopupRow("Reasoning effort (OR)", reasoningEfforts, cfg.ReasoningEffort, func(option string) {
 		cfg.ReasoningEffort = option
 	})
+	addListPopupRow("Group chat next speaker", groupStrategies, cfg.GroupStrategy, func(option string) {
+		cfg.GroupStrategy = option
+	})
 	// Helper function to get model list for a given API
 	getModelListForAPI := func(api string) []string {
 		if strings.Contains(api, "api.deepseek.com/") {
@@ -167,6 +170,14 @@ func makePropsTable(props map[string]float32) *tview.Table {
 			cfg.WriteNextMsgAs = text
 		}
 	})
+	addInputRow("Muted group chars (comma separated)", strings.Join(cfg.GroupMuted, ", "), func(text string) {
+		cfg.GroupMuted = []string{}
+		for _, name := range strings.Split(text, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.GroupMuted = append(cfg.GroupMuted, name)
+			}
+		}
+	})
 	addInputRow("Username", cfg.UserRole, func(text string) {
 		if text != "" {
 			renameUser(cfg.UserRole, text)
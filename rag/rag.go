@@ -649,6 +649,32 @@ func (r *RAG) ListLoaded() ([]string, error) {
 	return r.storage.ListFiles()
 }
 
+// FileText joins chunks of the loaded file in their original order
+func (r *RAG) FileText(filename string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	chunks, err := r.storage.FileChunks(filename)
+	if err != nil {
+		return "", err
+	}
+	if len(chunks) == 0 {
+		return "", fmt.Errorf("file %s is not loaded", filename)
+	}
+	sort.SliceStable(chunks, func(i, j int) bool {
+		bi, ci, _ := parseSlugIndices(chunks[i].Slug)
+		bj, cj, _ := parseSlugIndices(chunks[j].Slug)
+		if bi != bj {
+			return bi < bj
+		}
+		return ci < cj
+	})
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.RawText
+	}
+	return strings.Join(texts, "\n\n"), nil
+}
+
 func (r *RAG) RemoveFile(filename string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
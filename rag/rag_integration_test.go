@@ -7,6 +7,7 @@ import (
 	"gf-lt/storage"
 	"log/slog"
 	"testing"
+	"time"
 
 	_ "github.com/glebarez/go-sqlite"
 	"github.com/jmoiron/sqlx"
@@ -49,10 +50,15 @@ func (d dummyStore) RemoveChat(id uint32) error                            { ret
 func (d dummyStore) ChatGetMaxID() (uint32, error)                         { return 0, nil }
 
 // Memories methods
-func (d dummyStore) Memorise(m *models.Memory) (*models.Memory, error) { return m, nil }
-func (d dummyStore) Recall(agent, topic string) (string, error)        { return "", nil }
-func (d dummyStore) RecallTopics(agent string) ([]string, error)       { return nil, nil }
-func (d dummyStore) Forget(agent, topic string) error                  { return nil }
+func (d dummyStore) Memorise(m *models.Memory) (*models.Memory, error)  { return m, nil }
+func (d dummyStore) Recall(agent, topic string) (string, error)         { return "", nil }
+func (d dummyStore) RecallTopics(agent string) ([]string, error)        { return nil, nil }
+func (d dummyStore) Forget(agent, topic string) error                   { return nil }
+func (d dummyStore) ListMemories(agent string) ([]models.Memory, error) { return nil, nil }
+func (d dummyStore) SearchMemories(agent string, q []float32, limit int, halfLife time.Duration) ([]models.Memory, error) {
+	return nil, nil
+}
+func (d dummyStore) TouchMemories(agent string, topics []string) error { return nil }
 
 // TableLister method
 func (d dummyStore) ListTables() ([]string, error) { return nil, nil }
@@ -68,6 +74,22 @@ func (d dummyStore) SearchClosest(q []float32, limit int) ([]models.VectorRow, e
 func (d dummyStore) ListFiles() ([]string, error)              { return nil, nil }
 func (d dummyStore) RemoveEmbByFileName(filename string) error { return nil }
 
+// UsageRepo methods
+func (d dummyStore) AddUsage(u *models.UsageRecord) error { return nil }
+func (d dummyStore) UsageTotals(groupBy string) ([]models.UsageTotal, error) {
+	return nil, nil
+}
+
+// ChatSearch methods
+func (d dummyStore) SearchChats(query string, limit int) ([]models.ChatHit, error) { return nil, nil }
+func (d dummyStore) SearchChatsByVector(q []float32, limit int) ([]models.ChatHit, error) {
+	return nil, nil
+}
+func (d dummyStore) ChatEmbeddingChecksums(chatID uint32) (map[int]uint32, error) { return nil, nil }
+func (d dummyStore) SaveChatEmbedding(chatID uint32, msgIdx int, checksum uint32, emb []float32) error {
+	return nil
+}
+
 var _ storage.FullRepo = dummyStore{}
 
 // setupTestRAG creates an in‑memory SQLite database, creates the necessary tables,
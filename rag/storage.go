@@ -399,6 +399,24 @@ func (vs *VectorStorage) ListFiles() ([]string, error) {
 	return allFiles, nil
 }
 
+// FileChunks returns chunks of the loaded file (slug and raw text, unordered)
+func (vs *VectorStorage) FileChunks(filename string) ([]models.VectorRow, error) {
+	rows, err := vs.sqlxDB.Query("SELECT slug, raw_text FROM fts_embeddings WHERE filename = ?", filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunks: %w", err)
+	}
+	defer rows.Close()
+	var results []models.VectorRow
+	for rows.Next() {
+		row := models.VectorRow{FileName: filename}
+		if err := rows.Scan(&row.Slug, &row.RawText); err != nil {
+			return nil, err
+		}
+		results = append(results, row)
+	}
+	return results, rows.Err()
+}
+
 // RemoveEmbByFileName removes all embeddings associated with a specific filename
 func (vs *VectorStorage) RemoveEmbByFileName(filename string) error {
 	var errors []string
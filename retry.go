@@ -0,0 +1,171 @@
+package main
+
+import (
+	"gf-lt/models"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRetryBaseDelay = time.Second
+	defaultRetryMaxDelay  = 30 * time.Second
+)
+
+var (
+	// set by sendMsgToLLM before it signals streamDone
+	llmRequestFailed bool // no reply: connection error or error status after the retries
+	llmStreamBroken  bool // stream closed before the reply finished
+	// provider of the last reply, shown on the status line when a fallback answered
+	answeredBy string
+)
+
+// retryableStatus are the statuses worth sending the same request again
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500
+}
+
+func retryMaxDelay() time.Duration {
+	if cfg.RetryMaxDelayMs > 0 {
+		return time.Duration(cfg.RetryMaxDelayMs) * time.Millisecond
+	}
+	return defaultRetryMaxDelay
+}
+
+// backoffDelay is the wait before retry number attempt+1: the base delay doubled per attempt
+func backoffDelay(attempt int) time.Duration {
+	delay := defaultRetryBaseDelay
+	if cfg.RetryBaseDelayMs > 0 {
+		delay = time.Duration(cfg.RetryBaseDelayMs) * time.Millisecond
+	}
+	for range attempt {
+		delay *= 2
+		if delay >= retryMaxDelay() {
+			break
+		}
+	}
+	return min(delay, retryMaxDelay())
+}
+
+// parseRetryAfter reads seconds or an http date; 0 when missing or in the past
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if sec, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(sec)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+// retryDelay tells if the failed request gets another try and how long to wait for it
+func retryDelay(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if attempt >= cfg.RetryMax || interruptResp.Load() {
+		return 0, false
+	}
+	if err != nil {
+		return backoffDelay(attempt), true
+	}
+	if !retryableStatus(resp.StatusCode) {
+		return 0, false
+	}
+	if after := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); after > 0 {
+		return min(after, retryMaxDelay()), true
+	}
+	return backoffDelay(attempt), true
+}
+
+// waitRetry sleeps until the retry is due; false when the user interrupted the response
+func waitRetry(delay time.Duration) bool {
+	deadline := time.Now().Add(delay)
+	for time.Now().Before(deadline) {
+		if interruptResp.Load() {
+			return false
+		}
+		time.Sleep(min(100*time.Millisecond, time.Until(deadline)))
+	}
+	return !interruptResp.Load()
+}
+
+// providerName is the model with the host of its api
+func providerName(api, model string) string {
+	host := api
+	if u, err := url.Parse(api); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	if model == "" {
+		return host
+	}
+	return model + "@" + host
+}
+
+// nextFallback switches the api and model to the next fallback provider of the round
+// and returns the function that switches them back; nil when the chain is used up
+func nextFallback(r *models.ChatRoundReq) func() {
+	for r.Fallback < len(cfg.Fallbacks) {
+		fb := cfg.Fallbacks[r.Fallback]
+		r.Fallback++
+		model := fb.Model
+		if model == "" {
+			model = chatBody.Model
+		}
+		if fb.API == "" || (fb.API == cfg.CurrentAPI && model == chatBody.Model) {
+			continue
+		}
+		api, prevModel := cfg.CurrentAPI, chatBody.Model
+		logger.Warn("falling back to another provider", "from", providerName(api, prevModel),
+			"to", providerName(fb.API, model))
+		outputHandler.Writef("\n[yellow::i][%s failed, asking %s][-:-:-]\n", providerName(api, prevModel), providerName(fb.API, model))
+		cfg.CurrentAPI, chatBody.Model = fb.API, model
+		choseChunkParser()
+		return func() {
+			cfg.CurrentAPI, chatBody.Model = api, prevModel
+			choseChunkParser()
+		}
+	}
+	return nil
+}
+
+// retryRound sends the round again when its request failed or its stream broke:
+// a broken reply is resumed, a failed request goes to the next fallback provider.
+// It returns false when the round is final.
+func retryRound(r *models.ChatRoundReq, msgIdx int, respText string) bool {
+	if interruptResp.Load() {
+		return false
+	}
+	if llmStreamBroken && respText != "" {
+		if r.Resumes >= cfg.RetryMax {
+			return false
+		}
+		logger.Warn("resuming broken reply", "resumes", r.Resumes+1, "link", cfg.CurrentAPI)
+		// the partial tool calls of the broken stream are not complete
+		lastCompletedToolCalls = nil
+		last := chatBody.Messages[len(chatBody.Messages)-1]
+		err := chatRound(&models.ChatRoundReq{Role: last.Role, Resume: true, Fallback: r.Fallback, Resumes: r.Resumes + 1})
+		if err != nil {
+			logger.Error("failed to resume reply", "error", err)
+		}
+		return true
+	}
+	if !(llmRequestFailed || llmStreamBroken) || respText != "" {
+		return false
+	}
+	restore := nextFallback(r)
+	if restore == nil {
+		return false
+	}
+	defer restore()
+	// the empty reply is asked again; the user msg is already in the chat
+	if !r.Resume && msgIdx < len(chatBody.Messages) && chatBody.Messages[msgIdx].Content == "" {
+		chatBody.Messages = chatBody.Messages[:msgIdx]
+	}
+	err := chatRound(&models.ChatRoundReq{Role: r.Role, Regen: r.Regen, Resume: r.Resume, Fallback: r.Fallback, Resumes: r.Resumes})
+	if err != nil {
+		logger.Error("failed to ask fallback provider", "error", err)
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"gf-lt/config"
+	"gf-lt/mockllm"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	cfg = &config.Config{RetryMax: 3, RetryBaseDelayMs: 100, RetryMaxDelayMs: 300}
+	for attempt, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond} {
+		if got := backoffDelay(attempt); got != want {
+			t.Errorf("backoff %d: got %s, want %s", attempt, got, want)
+		}
+	}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if got := parseRetryAfter("2", now); got != 2*time.Second {
+		t.Errorf("seconds: got %s", got)
+	}
+	if got := parseRetryAfter(now.Add(time.Minute).Format(http.TimeFormat), now); got != time.Minute {
+		t.Errorf("date: got %s", got)
+	}
+	if got := parseRetryAfter("soon", now); got != 0 {
+		t.Errorf("garbage: got %s", got)
+	}
+	resp := func(status int, retryAfter string) *http.Response {
+		return &http.Response{StatusCode: status, Header: http.Header{"Retry-After": []string{retryAfter}}}
+	}
+	if _, retry := retryDelay(0, resp(http.StatusBadRequest, ""), nil); retry {
+		t.Error("400 is retried")
+	}
+	if delay, retry := retryDelay(1, resp(http.StatusServiceUnavailable, ""), nil); !retry || delay != 200*time.Millisecond {
+		t.Errorf("503: got %s %v", delay, retry)
+	}
+	if delay, retry := retryDelay(0, resp(http.StatusTooManyRequests, "60"), nil); !retry || delay != 300*time.Millisecond {
+		t.Errorf("Retry-After is not capped: got %s %v", delay, retry)
+	}
+	if _, retry := retryDelay(3, nil, os.ErrDeadlineExceeded); retry {
+		t.Error("retried past RetryMax")
+	}
+}
+
+func TestMockRetryAndFallback(t *testing.T) {
+	if _, err := os.Stat("dumps"); os.IsNotExist(err) {
+		defer os.RemoveAll("dumps")
+	}
+	lastReply := func() string {
+		return chatBody.Messages[len(chatBody.Messages)-1].Content
+	}
+	setupMockChat(t, "mock://testdata/mock/retry.toml")
+	cfg.RetryMax, cfg.RetryMaxDelayMs = 1, 5
+	mockRound(t, "question")
+	if got := lastReply(); got != "Answer after the retry." {
+		t.Errorf("retry: got %q", got)
+	}
+	setupMockChat(t, "mock://testdata/mock/down.toml")
+	primary := cfg.CurrentAPI
+	fallback := "mock://testdata/mock/fallback.toml"
+	cfg.RetryMax, cfg.RetryBaseDelayMs = 1, 1
+	cfg.Fallbacks = []config.FallbackProvider{{API: primary}, {API: fallback, Model: "backup"}}
+	mockRound(t, "question")
+	if got := lastReply(); got != "Answer from the fallback." {
+		t.Errorf("fallback: got %q in %+v", got, chatBody.Messages)
+	}
+	if n := len(chatBody.Messages); n != 3 {
+		t.Errorf("expected system, user and one reply, got %d messages", n)
+	}
+	if cfg.CurrentAPI != primary || chatBody.Model != "mock" {
+		t.Errorf("provider not restored: %s %s", cfg.CurrentAPI, chatBody.Model)
+	}
+	if answeredBy != providerName(fallback, "backup") {
+		t.Errorf("answered by %q", answeredBy)
+	}
+	if down, _ := mockllm.Get(primary); len(down.Requests()) != 2 {
+		t.Errorf("expected a request and a retry to the primary, got %d", len(down.Requests()))
+	}
+	setupMockChat(t, "mock://testdata/mock/resume.toml")
+	cfg.RetryMax = 1
+	mockRound(t, "question")
+	if got := lastReply(); got != "The answer is 42." {
+		t.Errorf("resume: got %q", got)
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"gf-lt/chatio"
 	"gf-lt/models"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
@@ -29,40 +31,76 @@ func historyToSJSON(msgs []models.RoleMsg) (string, error) {
 	return string(data), nil
 }
 
-func exportChat() error {
-	data, err := json.MarshalIndent(chatBody.Messages, "", "  ")
+// chat files F11 offers to import
+var importExts = []string{".json", ".jsonl", ".txt"}
+
+// chatRoles are the speakers of the chat for export and import
+func chatRoles() chatio.Roles {
+	return chatio.Roles{User: cfg.UserRole, Char: cfg.AssistantRole, Tool: cfg.ToolRole}
+}
+
+// exportChat writes the active chat into the export dir in one of chatio.Formats;
+// returns the file path
+func exportChat(format string) (string, error) {
+	data, err := chatio.Export(format, activeChatName, chatBody.Messages, chatRoles())
 	if err != nil {
-		return err
+		return "", err
 	}
 	// Ensure the export directory exists
 	if err := os.MkdirAll(exportDir, 0755); err != nil {
-		return fmt.Errorf("failed to create export directory %s: %w", exportDir, err)
+		return "", fmt.Errorf("failed to create export directory %s: %w", exportDir, err)
 	}
-	fp := path.Join(exportDir, activeChatName+".json")
-	return os.WriteFile(fp, data, 0666)
+	fp := path.Join(exportDir, activeChatName+chatio.Ext(format))
+	return fp, os.WriteFile(fp, data, 0666)
 }
 
+// importChat loads a chat file of gf-lt or another frontend (see chatio.Import) as the active chat;
+// other conversations of a chatgpt export are saved as new chats named by their title
 func importChat(filename string) error {
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return err
 	}
-	messages := []models.RoleMsg{}
-	if err := json.Unmarshal(data, &messages); err != nil {
+	chats, err := chatio.Import(data, chatRoles())
+	if err != nil {
 		return err
 	}
+	for i := 1; i < len(chats); i++ {
+		name := uniqueChatName(chats[i].Title, fmt.Sprintf("%s_%d", filepath.Base(filename), i))
+		addNewChat(name)
+		if err := updateStorageChat(name, chats[i].Msgs); err != nil {
+			return fmt.Errorf("failed to save imported chat %s: %w", name, err)
+		}
+	}
 	activeChatName = filepath.Base(filename)
 	if _, ok := chatMap[activeChatName]; !ok {
 		addNewChat(activeChatName)
 	}
+	messages := chats[0].Msgs
 	chatBody.Messages = messages
-	cfg.AssistantRole = messages[1].Role
-	if cfg.AssistantRole == cfg.UserRole {
-		cfg.AssistantRole = messages[2].Role
+	// the first speaker that is not the user, system or a tool is the char
+	if i := slices.IndexFunc(messages, func(m models.RoleMsg) bool {
+		return !slices.Contains([]string{cfg.UserRole, cfg.ToolRole, "system", "tool"}, m.Role)
+	}); i >= 0 {
+		cfg.AssistantRole = messages[i].Role
 	}
 	return nil
 }
 
+// uniqueChatName is the name, or the fallback if it is empty, made unique among the chats
+func uniqueChatName(name, fallback string) string {
+	if strings.TrimSpace(name) == "" {
+		name = fallback
+	}
+	unique := name
+	for n := 2; ; n++ {
+		if _, ok := chatMap[unique]; !ok {
+			return unique
+		}
+		unique = fmt.Sprintf("%s_%d", name, n)
+	}
+}
+
 func updateStorageChat(name string, msgs []models.RoleMsg) error {
 	var err error
 	chat, ok := chatMap[name]
@@ -77,8 +115,12 @@ func updateStorageChat(name string, msgs []models.RoleMsg) error {
 	}
 	chat.UpdatedAt = time.Now()
 	// if new chat will create id
-	_, err = store.UpsertChat(chat)
-	return err
+	saved, err := store.UpsertChat(chat)
+	if err != nil {
+		return err
+	}
+	embedChatMsgs(saved.ID, msgs)
+	return nil
 }
 
 func loadHistoryChats() ([]string, error) {
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"gf-lt/chatio"
+	"gf-lt/config"
+	"gf-lt/models"
+	"gf-lt/storage"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportExportChat(t *testing.T) {
+	prevStore, prevCfg, prevBody, prevActive, prevExportDir := store, cfg, chatBody, activeChatName, exportDir
+	defer func() {
+		store, cfg, chatBody, activeChatName, exportDir = prevStore, prevCfg, prevBody, prevActive, prevExportDir
+	}()
+	dir := t.TempDir()
+	store = storage.NewProviderSQL(filepath.Join(dir, "import.db"), logger)
+	if store == nil {
+		t.Fatal("failed to open the import db")
+	}
+	exportDir = filepath.Join(dir, "exports")
+	cfg = &config.Config{UserRole: "user", AssistantRole: "Bob", ToolRole: "tool"}
+	chatBody = &models.ChatBody{}
+	write := func(name, data string) string {
+		fp := filepath.Join(dir, name)
+		if err := os.WriteFile(fp, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return fp
+	}
+	// used to index messages[1] and messages[2]
+	if err := importChat(write("short.txt", "user: hello?\n")); err != nil {
+		t.Fatal(err)
+	}
+	if len(chatBody.Messages) != 1 || cfg.AssistantRole != "Bob" || activeChatName != "short.txt" {
+		t.Errorf("short chat: %s %s %+v", activeChatName, cfg.AssistantRole, chatBody.Messages)
+	}
+	// group chat: the first char after the user is the assistant
+	group := "{\"user_name\":\"Ann\",\"character_name\":\"Alice\"}\n" +
+		"{\"name\":\"Ann\",\"is_user\":true,\"mes\":\"hi all\"}\n" +
+		"{\"name\":\"Alice\",\"mes\":\"hi Ann\"}\n" +
+		"{\"name\":\"Carol\",\"mes\":\"hello\"}\n"
+	if err := importChat(write("group.jsonl", group)); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AssistantRole != "Alice" || len(chatBody.Messages) != 3 {
+		t.Errorf("group chat: char %s, %+v", cfg.AssistantRole, chatBody.Messages)
+	}
+	if err := importChat(write("empty.json", "[]")); err == nil {
+		t.Error("empty chat is imported")
+	}
+	conv := func(title, text string) string {
+		return `{"title":"` + title + `","current_node":"a","mapping":{"a":{"parent":null,` +
+			`"message":{"author":{"role":"assistant"},"content":{"content_type":"text","parts":["` + text + `"]}}}}}`
+	}
+	if err := importChat(write("conversations.json", "["+conv("First", "one")+","+conv("Second", "two")+"]")); err != nil {
+		t.Fatal(err)
+	}
+	if activeChatName != "conversations.json" || cfg.AssistantRole != "assistant" || chatBody.Messages[0].Content != "one" {
+		t.Errorf("first conversation is not loaded: %s %s %+v", activeChatName, cfg.AssistantRole, chatBody.Messages)
+	}
+	saved, ok := chatMap["Second"]
+	if !ok {
+		t.Fatal("second conversation is not saved")
+	}
+	if stored, err := store.GetChatByID(saved.ID); err != nil || stored.Name != "Second" {
+		t.Errorf("second conversation is not in the store: %+v %v", stored, err)
+	}
+	for _, format := range chatio.Formats {
+		fp, err := exportChat(format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if filepath.Ext(fp) != chatio.Ext(format) {
+			t.Errorf("%s: exported to %s", format, fp)
+		}
+	}
+	if err := importChat(filepath.Join(exportDir, "conversations.json.jsonl")); err != nil {
+		t.Fatal(err)
+	}
+	if len(chatBody.Messages) != 1 || chatBody.Messages[0].GetText() != "one" {
+		t.Errorf("sillytavern export is not imported back: %+v", chatBody.Messages)
+	}
+}
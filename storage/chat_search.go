@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"gf-lt/models"
+	"hash/crc32"
+	"sort"
+	"strings"
+)
+
+type ChatSearch interface {
+	SearchChats(query string, limit int) ([]models.ChatHit, error)
+	SearchChatsByVector(q []float32, limit int) ([]models.ChatHit, error)
+	ChatEmbeddingChecksums(chatID uint32) (map[int]uint32, error)
+	SaveChatEmbedding(chatID uint32, msgIdx int, checksum uint32, emb []float32) error
+}
+
+// MsgChecksum tells if the embedding of a message is stale
+func MsgChecksum(text string) uint32 {
+	return crc32.ChecksumIEEE([]byte(text))
+}
+
+// FTSMatch turns user input into a fts5 query: every word (or "quoted phrase")
+// must match, a trailing * matches a prefix; fts5 operators are taken literally
+func FTSMatch(query string) string {
+	var terms []string
+	for i, part := range strings.Split(query, `"`) {
+		if i%2 == 1 {
+			if part = strings.TrimSpace(part); part != "" {
+				terms = append(terms, `"`+part+`"`)
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			prefix := strings.HasSuffix(word, "*")
+			word = strings.TrimRight(word, "*")
+			if word == "" {
+				continue
+			}
+			term := `"` + word + `"`
+			if prefix {
+				term += "*"
+			}
+			terms = append(terms, term)
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// indexChat updates the messages of the chat in the search index and drops
+// embeddings of messages that were deleted; a chat is saved several times a round,
+// so only new, edited and deleted messages are written
+func (p ProviderSQL) indexChat(chat *models.Chat) error {
+	msgs, err := chat.ToHistory()
+	if err != nil {
+		return err
+	}
+	tx, err := p.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	rows := []struct {
+		RowID   int64  `db:"rowid"`
+		MsgIdx  int    `db:"msg_idx"`
+		Role    string `db:"role"`
+		Content string `db:"content"`
+	}{}
+	query := "SELECT rowid, msg_idx, role, content FROM chat_fts WHERE chat_id = $1;"
+	if err := tx.Select(&rows, query, chat.ID); err != nil {
+		return err
+	}
+	indexed := make(map[int]int, len(rows))
+	for i := range rows {
+		indexed[rows[i].MsgIdx] = i
+	}
+	unindex := func(rowID int64) error {
+		_, err := tx.Exec("DELETE FROM chat_fts WHERE rowid = $1;", rowID)
+		return err
+	}
+	for i := range msgs {
+		text := msgs[i].GetText()
+		if r, ok := indexed[i]; ok {
+			delete(indexed, i)
+			if rows[r].Role == msgs[i].Role && rows[r].Content == text {
+				continue
+			}
+			if err := unindex(rows[r].RowID); err != nil {
+				return err
+			}
+		}
+		if text == "" {
+			continue
+		}
+		query := "INSERT INTO chat_fts (chat_id, msg_idx, role, content) VALUES ($1, $2, $3, $4);"
+		if _, err := tx.Exec(query, chat.ID, i, msgs[i].Role, text); err != nil {
+			return err
+		}
+	}
+	// messages that were deleted
+	for _, r := range indexed {
+		if err := unindex(rows[r].RowID); err != nil {
+			return err
+		}
+	}
+	query = "DELETE FROM chat_embeddings WHERE chat_id = $1 AND msg_idx >= $2;"
+	if _, err := tx.Exec(query, chat.ID, len(msgs)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// unindexChat removes the chat from the search index
+func (p ProviderSQL) unindexChat(id uint32) error {
+	if _, err := p.db.Exec("DELETE FROM chat_fts WHERE chat_id = $1;", id); err != nil {
+		return err
+	}
+	_, err := p.db.Exec("DELETE FROM chat_embeddings WHERE chat_id = $1;", id)
+	return err
+}
+
+// SearchChats finds messages of all chats matching the query, best first;
+// matched words of the snippet are in «»
+func (p ProviderSQL) SearchChats(query string, limit int) ([]models.ChatHit, error) {
+	match := FTSMatch(query)
+	if match == "" {
+		return nil, nil
+	}
+	// bm25 is negative, more negative is better
+	sqlQuery := `
+        SELECT f.chat_id, c.name AS chat_name, c.agent, f.msg_idx, f.role,
+            snippet(chat_fts, 3, '«', '»', '...', 12) AS snippet,
+            -bm25(chat_fts) AS score
+        FROM chat_fts f JOIN chats c ON c.id = f.chat_id
+        WHERE chat_fts MATCH $1
+        ORDER BY bm25(chat_fts) LIMIT $2;`
+	resp := []models.ChatHit{}
+	if err := p.db.Select(&resp, sqlQuery, match, limit); err != nil {
+		p.logger.Error("failed to search chats", "query", sqlQuery, "match", match, "error", err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SearchChatsByVector ranks embedded messages of all chats by cosine similarity to q
+func (p ProviderSQL) SearchChatsByVector(q []float32, limit int) ([]models.ChatHit, error) {
+	query := `
+        SELECT e.chat_id, c.name, c.agent, e.msg_idx, e.embedding, COALESCE(f.role, ''), COALESCE(f.content, '')
+        FROM chat_embeddings e JOIN chats c ON c.id = e.chat_id
+        LEFT JOIN chat_fts f ON f.chat_id = e.chat_id AND f.msg_idx = e.msg_idx;`
+	rows, err := p.db.Query(query)
+	if err != nil {
+		p.logger.Error("failed to search chat embeddings", "query", query, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+	resp := []models.ChatHit{}
+	for rows.Next() {
+		var hit models.ChatHit
+		var emb []byte
+		var content string
+		if err := rows.Scan(&hit.ChatID, &hit.ChatName, &hit.Agent, &hit.MsgIdx, &emb, &hit.Role, &content); err != nil {
+			return nil, err
+		}
+		hit.Score = float64(cosineSimilarity(q, DeserializeVector(emb)))
+		hit.Snippet = snippet(content, 80)
+		resp = append(resp, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(resp, func(i, j int) bool {
+		return resp[i].Score > resp[j].Score
+	})
+	if limit > 0 && len(resp) > limit {
+		resp = resp[:limit]
+	}
+	return resp, nil
+}
+
+// ChatEmbeddingChecksums are the checksums of the embedded messages of the chat by index
+func (p ProviderSQL) ChatEmbeddingChecksums(chatID uint32) (map[int]uint32, error) {
+	rows := []struct {
+		MsgIdx   int    `db:"msg_idx"`
+		Checksum uint32 `db:"checksum"`
+	}{}
+	query := "SELECT msg_idx, checksum FROM chat_embeddings WHERE chat_id = $1;"
+	if err := p.db.Select(&rows, query, chatID); err != nil {
+		p.logger.Error("failed to read chat embeddings", "query", query, "error", err)
+		return nil, err
+	}
+	resp := make(map[int]uint32, len(rows))
+	for _, r := range rows {
+		resp[r.MsgIdx] = r.Checksum
+	}
+	return resp, nil
+}
+
+func (p ProviderSQL) SaveChatEmbedding(chatID uint32, msgIdx int, checksum uint32, emb []float32) error {
+	query := `
+        INSERT INTO chat_embeddings (chat_id, msg_idx, checksum, embedding) VALUES ($1, $2, $3, $4)
+        ON CONFLICT(chat_id, msg_idx) DO UPDATE SET checksum=excluded.checksum, embedding=excluded.embedding;`
+	if _, err := p.db.Exec(query, chatID, msgIdx, checksum, SerializeVector(emb)); err != nil {
+		p.logger.Error("failed to save chat embedding", "query", query, "error", err)
+		return err
+	}
+	return nil
+}
+
+// snippet is the start of the text on one line, cut to n runes
+func snippet(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return text
+}
@@ -1,20 +1,30 @@
 package storage
 
-import "gf-lt/models"
+import (
+	"gf-lt/models"
+	"math"
+	"sort"
+	"time"
+)
 
 type Memories interface {
 	Memorise(m *models.Memory) (*models.Memory, error)
 	Recall(agent, topic string) (string, error)
 	RecallTopics(agent string) ([]string, error)
 	Forget(agent, topic string) error
+	ListMemories(agent string) ([]models.Memory, error)
+	SearchMemories(agent string, q []float32, limit int, halfLife time.Duration) ([]models.Memory, error)
+	TouchMemories(agent string, topics []string) error
 }
 
 func (p ProviderSQL) Memorise(m *models.Memory) (*models.Memory, error) {
 	query := `
-        INSERT INTO memories (agent, topic, mind)
-        VALUES (:agent, :topic, :mind)
+        INSERT INTO memories (agent, topic, mind, importance, embedding)
+        VALUES (:agent, :topic, :mind, :importance, :embedding)
         ON CONFLICT (agent, topic) DO UPDATE
         SET mind = excluded.mind,
+            importance = excluded.importance,
+            embedding = excluded.embedding,
             updated_at = CURRENT_TIMESTAMP
         RETURNING *;`
 	stmt, err := p.db.PrepareNamed(query)
@@ -63,3 +73,64 @@ func (p ProviderSQL) Forget(agent, topic string) error {
 	}
 	return nil
 }
+
+// ListMemories returns memories of the agent (all agents if empty), most recent first
+func (p ProviderSQL) ListMemories(agent string) ([]models.Memory, error) {
+	query := "SELECT * FROM memories WHERE $1 = '' OR agent = $1 ORDER BY updated_at DESC"
+	resp := []models.Memory{}
+	if err := p.db.Select(&resp, query, agent); err != nil {
+		p.logger.Error("failed to list memories", "query", query, "error", err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SearchMemories returns embedded memories of the agent ranked by MemoryScore
+func (p ProviderSQL) SearchMemories(agent string, q []float32, limit int, halfLife time.Duration) ([]models.Memory, error) {
+	query := "SELECT * FROM memories WHERE agent = $1 AND length(embedding) > 0"
+	memories := []models.Memory{}
+	if err := p.db.Select(&memories, query, agent); err != nil {
+		p.logger.Error("failed to search memories", "query", query, "error", err)
+		return nil, err
+	}
+	now := time.Now()
+	for i := range memories {
+		m := &memories[i]
+		last := m.UpdatedAt
+		if m.RecalledAt != nil && m.RecalledAt.After(last) {
+			last = *m.RecalledAt
+		}
+		similarity := float64(cosineSimilarity(q, DeserializeVector(m.Embedding)))
+		m.Score = MemoryScore(similarity, m.Importance, now.Sub(last), halfLife)
+	}
+	sort.SliceStable(memories, func(i, j int) bool {
+		return memories[i].Score > memories[j].Score
+	})
+	if limit > 0 && len(memories) > limit {
+		memories = memories[:limit]
+	}
+	return memories, nil
+}
+
+// TouchMemories marks memories as recalled, which resets their decay
+func (p ProviderSQL) TouchMemories(agent string, topics []string) error {
+	query := `UPDATE memories SET recall_count = recall_count + 1, recalled_at = CURRENT_TIMESTAMP
+        WHERE agent = $1 AND topic = $2`
+	for _, topic := range topics {
+		if _, err := p.db.Exec(query, agent, topic); err != nil {
+			p.logger.Error("failed to touch memory", "query", query, "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// MemoryScore is similarity weighted by importance (half weight for trivia)
+// and halved every halfLife since the memory was updated or recalled
+func MemoryScore(similarity, importance float64, age, halfLife time.Duration) float64 {
+	score := similarity * (0.5 + min(max(importance, 0), 1)/2)
+	if halfLife > 0 && age > 0 {
+		score *= math.Pow(0.5, age.Hours()/halfLife.Hours())
+	}
+	return score
+}
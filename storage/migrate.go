@@ -28,6 +28,14 @@ func (p *ProviderSQL) Migrate() error {
 	var ftsCount int
 	_ = p.db.QueryRow("SELECT COUNT(*) FROM fts_embeddings").Scan(&ftsCount)
 	skipFTSMigration := ftsCount > 0
+	// ALTER TABLE is not idempotent; skip memory columns migration if already applied
+	var importanceCols int
+	_ = p.db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('memories') WHERE name = 'importance'").Scan(&importanceCols)
+	skipMemoryMigration := importanceCols > 0
+	// chat index is kept in sync by UpsertChat; populate it only once
+	var chatFTSCount int
+	_ = p.db.QueryRow("SELECT COUNT(*) FROM chat_fts").Scan(&chatFTSCount)
+	skipChatFTSMigration := chatFTSCount > 0
 
 	// Execute each .up.sql file
 	for _, file := range files {
@@ -37,6 +45,14 @@ func (p *ProviderSQL) Migrate() error {
 				p.logger.Debug("Skipping FTS migration - already populated", "file", file.Name())
 				continue
 			}
+			if skipMemoryMigration && strings.Contains(file.Name(), "006_memory_recall") {
+				p.logger.Debug("Skipping memory migration - already applied", "file", file.Name())
+				continue
+			}
+			if skipChatFTSMigration && strings.Contains(file.Name(), "009_populate_chat_fts") {
+				p.logger.Debug("Skipping chat FTS migration - already populated", "file", file.Name())
+				continue
+			}
 			err := p.executeMigration(migrationsDir, file.Name())
 			if err != nil {
 				p.logger.Error("Failed to execute migration %s: %v", file.Name(), err)
@@ -0,0 +1,4 @@
+ALTER TABLE memories DROP COLUMN recalled_at;
+ALTER TABLE memories DROP COLUMN recall_count;
+ALTER TABLE memories DROP COLUMN embedding;
+ALTER TABLE memories DROP COLUMN importance;
@@ -0,0 +1,5 @@
+-- Semantic memory: embedding of the memory, importance (0..1) and recall stats for decay
+ALTER TABLE memories ADD COLUMN importance REAL NOT NULL DEFAULT 0.5;
+ALTER TABLE memories ADD COLUMN embedding BLOB;
+ALTER TABLE memories ADD COLUMN recall_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE memories ADD COLUMN recalled_at TIMESTAMP;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_usage_created_at;
+DROP TABLE IF EXISTS usage;
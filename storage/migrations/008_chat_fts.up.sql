@@ -0,0 +1,18 @@
+-- Full-text index of chat messages for search across all chats;
+-- kept in sync by UpsertChat and RemoveChat
+CREATE VIRTUAL TABLE IF NOT EXISTS chat_fts USING fts5(
+    chat_id UNINDEXED,
+    msg_idx UNINDEXED,
+    role UNINDEXED,
+    content,
+    tokenize='porter unicode61'
+);
+-- Embeddings of chat messages for semantic chat search (ChatSearchEmbeddings);
+-- checksum of the message text tells which ones are stale after an edit
+CREATE TABLE IF NOT EXISTS chat_embeddings (
+    chat_id INTEGER NOT NULL,
+    msg_idx INTEGER NOT NULL,
+    checksum INTEGER NOT NULL,
+    embedding BLOB NOT NULL,
+    PRIMARY KEY (chat_id, msg_idx)
+);
@@ -0,0 +1 @@
+DELETE FROM chat_fts;
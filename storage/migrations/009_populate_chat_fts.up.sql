@@ -0,0 +1,14 @@
+-- Index messages of existing chats; text of multimodal messages is the joined text parts
+INSERT INTO chat_fts (chat_id, msg_idx, role, content)
+SELECT chat_id, msg_idx, role, content FROM (
+    SELECT c.id AS chat_id, m.key AS msg_idx, json_extract(m.value, '$.role') AS role,
+        CASE json_type(m.value, '$.content')
+            WHEN 'array' THEN (
+                SELECT group_concat(json_extract(p.value, '$.text'), ' ')
+                FROM json_each(m.value, '$.content') p
+                WHERE json_extract(p.value, '$.type') = 'text')
+            ELSE json_extract(m.value, '$.content')
+        END AS content
+    FROM chats c, json_each(c.msgs) m
+    WHERE json_valid(c.msgs)
+) WHERE content IS NOT NULL AND content != '';
@@ -16,6 +16,8 @@ type FullRepo interface {
 	Memories
 	VectorRepo
 	TableLister
+	UsageRepo
+	ChatSearch
 }
 
 type TableLister interface {
@@ -87,14 +89,22 @@ func (p ProviderSQL) UpsertChat(chat *models.Chat) (*models.Chat, error) {
 	defer stmt.Close()
 	// Execute the query and scan the result into a new chat object
 	var resp models.Chat
-	err = stmt.Get(&resp, chat)
-	return &resp, err
+	if err = stmt.Get(&resp, chat); err != nil {
+		return &resp, err
+	}
+	// the chat is saved even if search index is not updated
+	if err := p.indexChat(&resp); err != nil {
+		p.logger.Error("failed to index chat for search", "chat_id", resp.ID, "error", err)
+	}
+	return &resp, nil
 }
 
 func (p ProviderSQL) RemoveChat(id uint32) error {
 	query := "DELETE FROM chats WHERE ID = $1;"
-	_, err := p.db.Exec(query, id)
-	return err
+	if _, err := p.db.Exec(query, id); err != nil {
+		return err
+	}
+	return p.unindexChat(id)
 }
 
 func (p ProviderSQL) ChatGetMaxID() (uint32, error) {
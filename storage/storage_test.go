@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"gf-lt/models"
 	"log/slog"
+	"math"
 	"os"
 	"testing"
 	"time"
@@ -25,6 +26,10 @@ CREATE TABLE IF NOT EXISTS memories (
     mind TEXT NOT NULL,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    importance REAL NOT NULL DEFAULT 0.5,
+    embedding BLOB,
+    recall_count INTEGER NOT NULL DEFAULT 0,
+    recalled_at TIMESTAMP,
     PRIMARY KEY (agent, topic)
 );`)
 	if err != nil {
@@ -95,6 +100,81 @@ CREATE TABLE IF NOT EXISTS memories (
 	}
 }
 
+func TestSearchMemories(t *testing.T) {
+	db, err := sqlx.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open SQLite in-memory database: %v", err)
+	}
+	defer db.Close()
+	provider := ProviderSQL{
+		db:     db,
+		logger: slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+	}
+	if err := provider.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	// migrations run on every start, memory columns must not be added twice
+	if err := provider.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate second time: %v", err)
+	}
+	memories := []models.Memory{
+		{Agent: "a", Topic: "cat", Mind: "has a cat", Importance: 0.2, Embedding: SerializeVector([]float32{1, 0})},
+		{Agent: "a", Topic: "city", Mind: "lives in Oslo", Importance: 1, Embedding: SerializeVector([]float32{0.8, 0.6})},
+		{Agent: "a", Topic: "unembedded", Mind: "no vector"},
+		{Agent: "b", Topic: "cat", Mind: "other agent", Importance: 1, Embedding: SerializeVector([]float32{1, 0})},
+	}
+	for i := range memories {
+		if _, err := provider.Memorise(&memories[i]); err != nil {
+			t.Fatalf("Failed to memorise: %v", err)
+		}
+	}
+	// cat is more similar, but city is more important: 1*0.6 < 0.8*1
+	got, err := provider.SearchMemories("a", []float32{1, 0}, 5, 0)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(got) != 2 || got[0].Topic != "city" || got[1].Topic != "cat" {
+		t.Fatalf("Unexpected search result: %+v", got)
+	}
+	if err := provider.TouchMemories("a", []string{"cat"}); err != nil {
+		t.Fatalf("Failed to touch: %v", err)
+	}
+	all, err := provider.ListMemories("")
+	if err != nil {
+		t.Fatalf("Failed to list: %v", err)
+	}
+	if len(all) != 4 {
+		t.Fatalf("Expected 4 memories, got %d", len(all))
+	}
+	for _, m := range all {
+		if m.Agent == "a" && m.Topic == "cat" && (m.RecallCount != 1 || m.RecalledAt == nil) {
+			t.Errorf("Recall was not recorded: %+v", m)
+		}
+	}
+}
+
+func TestMemoryScore(t *testing.T) {
+	week := 7 * 24 * time.Hour
+	cases := []struct {
+		similarity, importance float64
+		age, halfLife          time.Duration
+		want                   float64
+	}{
+		{similarity: 1, importance: 1, want: 1},
+		{similarity: 1, importance: 0, want: 0.5},
+		{similarity: 0.8, importance: 2, want: 0.8}, // importance is capped
+		{similarity: 1, importance: 1, age: week, halfLife: week, want: 0.5},
+		{similarity: 1, importance: 1, age: 2 * week, halfLife: week, want: 0.25},
+		{similarity: 1, importance: 1, age: week, want: 1}, // no decay
+	}
+	for _, tc := range cases {
+		got := MemoryScore(tc.similarity, tc.importance, tc.age, tc.halfLife)
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("MemoryScore(%+v) = %v, want %v", tc, got, tc.want)
+		}
+	}
+}
+
 func TestChatHistory(t *testing.T) {
 	// Create an in-memory SQLite database
 	db, err := sqlx.Open("sqlite", ":memory:")
@@ -115,8 +195,16 @@ func TestChatHistory(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create chat table: %v", err)
 	}
+	// chats are indexed for search on upsert
+	migration, err := migrationsFS.ReadFile("migrations/008_chat_fts.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(migration)); err != nil {
+		t.Fatalf("Failed to create chat search tables: %v", err)
+	}
 	// Initialize the ProviderSQL struct
-	provider := ProviderSQL{db: db}
+	provider := ProviderSQL{db: db, logger: slog.New(slog.NewJSONHandler(os.Stdout, nil))}
 	// List chats (should be empty)
 	chats, err := provider.ListChats()
 	if err != nil {
@@ -173,3 +261,181 @@ func TestChatHistory(t *testing.T) {
 		t.Errorf("Expected 0 chats, got %d", len(chats))
 	}
 }
+
+func TestUsageTotals(t *testing.T) {
+	db, err := sqlx.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open SQLite in-memory database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	migration, err := migrationsFS.ReadFile("migrations/007_usage.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(migration)); err != nil {
+		t.Fatalf("Failed to create usage table: %v", err)
+	}
+	provider := ProviderSQL{
+		db:     db,
+		logger: slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+	}
+	records := []models.UsageRecord{
+		{Chat: "1_a", Model: "big", PromptTokens: 100, CompletionTokens: 10, Cost: 0.5},
+		{Chat: "1_a", Model: "small", PromptTokens: 50, CompletionTokens: 5, ReasoningTokens: 2},
+		{Chat: "2_b", Model: "big", PromptTokens: 10, CompletionTokens: 1, Cost: 0.25},
+	}
+	for i := range records {
+		if err := provider.AddUsage(&records[i]); err != nil {
+			t.Fatalf("Failed to add usage: %v", err)
+		}
+	}
+	byModel, err := provider.UsageTotals(UsageByModel)
+	if err != nil {
+		t.Fatalf("Failed to sum usage: %v", err)
+	}
+	want := []models.UsageTotal{
+		{Key: "big", Replies: 2, PromptTokens: 110, CompletionTokens: 11, Cost: 0.75},
+		{Key: "small", Replies: 1, PromptTokens: 50, CompletionTokens: 5, ReasoningTokens: 2},
+	}
+	if fmt.Sprint(byModel) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, byModel)
+	}
+	byChat, err := provider.UsageTotals(UsageByChat)
+	if err != nil {
+		t.Fatalf("Failed to sum usage: %v", err)
+	}
+	if len(byChat) != 2 || byChat[0].Key != "1_a" || byChat[0].Replies != 2 {
+		t.Errorf("Unexpected chat totals: %v", byChat)
+	}
+	byDay, err := provider.UsageTotals(UsageByDay)
+	if err != nil {
+		t.Fatalf("Failed to sum usage: %v", err)
+	}
+	if len(byDay) != 1 || byDay[0].Replies != 3 || byDay[0].Key != time.Now().Format(time.DateOnly) {
+		t.Errorf("Unexpected day totals: %v", byDay)
+	}
+	if _, err := provider.UsageTotals("week"); err == nil {
+		t.Error("Expected error for unknown group")
+	}
+}
+
+func TestSearchChats(t *testing.T) {
+	db, err := sqlx.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open SQLite in-memory database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	provider := ProviderSQL{
+		db:     db,
+		logger: slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+	}
+	migration, err := migrationsFS.ReadFile("migrations/001_init.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(migration)); err != nil {
+		t.Fatalf("Failed to create chat table: %v", err)
+	}
+	// chat saved before the index existed is indexed by the migration, text parts included
+	old := `[{"role":"user","content":"where did we park the bicycle?"},` +
+		`{"role":"assistant","content":[{"type":"text","text":"next to the lighthouse"},{"type":"image_url","image_url":{"url":"x"}}]}]`
+	if _, err := db.Exec("INSERT INTO chats (id, name, msgs, agent) VALUES (1, 'old', $1, 'Bob');", old); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	hits, err := provider.SearchChats("lighthouse", 10)
+	if err != nil {
+		t.Fatalf("Failed to search chats: %v", err)
+	}
+	if len(hits) != 1 || hits[0].ChatName != "old" || hits[0].MsgIdx != 1 || hits[0].Role != "assistant" {
+		t.Fatalf("Unexpected hits for migrated chat: %+v", hits)
+	}
+	if hits[0].Snippet != "next to the «lighthouse»" {
+		t.Errorf("Unexpected snippet: %q", hits[0].Snippet)
+	}
+	// porter stemmer and fts5 syntax in user input
+	if hits, err = provider.SearchChats(`parking "bicycle?" AND`, 10); err != nil || len(hits) != 0 {
+		t.Errorf("Expected no hits (AND is a word), got %+v %v", hits, err)
+	}
+	if hits, err = provider.SearchChats(`parked bicyc*`, 10); err != nil || len(hits) != 1 || hits[0].MsgIdx != 0 {
+		t.Errorf("Expected stemmed and prefix hit, got %+v %v", hits, err)
+	}
+	// upsert reindexes, deleted messages lose their embeddings
+	if err := provider.SaveChatEmbedding(1, 1, MsgChecksum("next to the lighthouse"), []float32{0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	chat := &models.Chat{ID: 1, Name: "old", Agent: "Bob", Msgs: `[{"role":"user","content":"the bicycle is at home now"}]`,
+		CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if _, err := provider.UpsertChat(chat); err != nil {
+		t.Fatalf("Failed to upsert chat: %v", err)
+	}
+	if hits, _ = provider.SearchChats("lighthouse", 10); len(hits) != 0 {
+		t.Errorf("Expected deleted message to be unindexed, got %+v", hits)
+	}
+	if sums, _ := provider.ChatEmbeddingChecksums(1); len(sums) != 0 {
+		t.Errorf("Expected embedding of deleted message to be dropped, got %v", sums)
+	}
+	chat = &models.Chat{ID: 2, Name: "new", Agent: "Alice", Msgs: `[{"role":"user","content":"a bicycle race"},{"role":"assistant","content":"sounds fun"}]`,
+		CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if _, err := provider.UpsertChat(chat); err != nil {
+		t.Fatalf("Failed to upsert chat: %v", err)
+	}
+	if hits, _ = provider.SearchChats("bicycle", 10); len(hits) != 2 {
+		t.Errorf("Expected hits in both chats, got %+v", hits)
+	}
+	// saving again writes only the changed messages
+	rowID := func(chatID uint32, msgIdx int) (id int64) {
+		_ = db.Get(&id, "SELECT rowid FROM chat_fts WHERE chat_id = $1 AND msg_idx = $2;", chatID, msgIdx)
+		return id
+	}
+	first := rowID(2, 0)
+	chat.Msgs = `[{"role":"user","content":"a bicycle race"},{"role":"assistant","content":"sounds like fun"},{"role":"user","content":"yes"}]`
+	if _, err := provider.UpsertChat(chat); err != nil {
+		t.Fatalf("Failed to upsert chat: %v", err)
+	}
+	if rowID(2, 0) != first || rowID(2, 2) == 0 {
+		t.Errorf("Expected unchanged message to keep its row and new message to be indexed")
+	}
+	if hits, _ = provider.SearchChats("like fun", 10); len(hits) != 1 || hits[0].MsgIdx != 1 {
+		t.Errorf("Expected edited message to be found, got %+v", hits)
+	}
+	chat.Msgs = `[{"role":"user","content":"a bicycle race"},{"role":"assistant","content":"sounds fun"}]`
+	if _, err := provider.UpsertChat(chat); err != nil {
+		t.Fatalf("Failed to upsert chat: %v", err)
+	}
+	if rowID(2, 2) != 0 {
+		t.Errorf("Expected deleted message to be unindexed")
+	}
+	// semantic search
+	for i, emb := range [][]float32{{1, 0}, {0, 1}} {
+		if err := provider.SaveChatEmbedding(2, i, uint32(i), emb); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := provider.SaveChatEmbedding(1, 0, 7, []float32{0.6, 0.8}); err != nil {
+		t.Fatal(err)
+	}
+	hits, err = provider.SearchChatsByVector([]float32{0, 1}, 2)
+	if err != nil {
+		t.Fatalf("Failed to search chat embeddings: %v", err)
+	}
+	if len(hits) != 2 || hits[0].ChatName != "new" || hits[0].MsgIdx != 1 || hits[0].Snippet != "sounds fun" || hits[1].ChatName != "old" {
+		t.Errorf("Unexpected semantic hits: %+v", hits)
+	}
+	if sums, _ := provider.ChatEmbeddingChecksums(2); sums[0] != 0 || sums[1] != 1 {
+		t.Errorf("Unexpected checksums: %v", sums)
+	}
+	if err := provider.RemoveChat(2); err != nil {
+		t.Fatalf("Failed to remove chat: %v", err)
+	}
+	if hits, _ = provider.SearchChats("bicycle", 10); len(hits) != 1 || hits[0].ChatID != 1 {
+		t.Errorf("Expected removed chat to be unindexed, got %+v", hits)
+	}
+	if hits, _ = provider.SearchChatsByVector([]float32{0, 1}, 0); len(hits) != 1 {
+		t.Errorf("Expected embeddings of removed chat to be dropped, got %+v", hits)
+	}
+}
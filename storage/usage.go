@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"fmt"
+	"gf-lt/models"
+)
+
+type UsageRepo interface {
+	AddUsage(u *models.UsageRecord) error
+	UsageTotals(groupBy string) ([]models.UsageTotal, error)
+}
+
+// usage groups of UsageTotals
+const (
+	UsageByChat  = "chat"
+	UsageByDay   = "day"
+	UsageByModel = "model"
+)
+
+var usageGroups = map[string]struct{ key, order string }{
+	UsageByChat:  {key: "chat", order: "cost DESC, prompt_tokens + completion_tokens DESC"},
+	UsageByDay:   {key: "date(created_at, 'localtime')", order: "key DESC"},
+	UsageByModel: {key: "model", order: "cost DESC, prompt_tokens + completion_tokens DESC"},
+}
+
+func (p ProviderSQL) AddUsage(u *models.UsageRecord) error {
+	query := `
+        INSERT INTO usage (chat, api, model, prompt_tokens, completion_tokens, reasoning_tokens, cost)
+        VALUES (:chat, :api, :model, :prompt_tokens, :completion_tokens, :reasoning_tokens, :cost);`
+	if _, err := p.db.NamedExec(query, u); err != nil {
+		p.logger.Error("failed to add usage", "query", query, "error", err)
+		return err
+	}
+	return nil
+}
+
+// UsageTotals sums the usage per chat, day or model
+func (p ProviderSQL) UsageTotals(groupBy string) ([]models.UsageTotal, error) {
+	group, ok := usageGroups[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown usage group %q", groupBy)
+	}
+	query := fmt.Sprintf(`
+        SELECT %s AS key, COUNT(*) AS replies,
+            SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens,
+            SUM(reasoning_tokens) AS reasoning_tokens, SUM(cost) AS cost
+        FROM usage GROUP BY key ORDER BY %s;`, group.key, group.order)
+	resp := []models.UsageTotal{}
+	if err := p.db.Select(&resp, query); err != nil {
+		p.logger.Error("failed to sum usage", "query", query, "error", err)
+		return nil, err
+	}
+	return resp, nil
+}
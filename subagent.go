@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gf-lt/agent"
+	"gf-lt/models"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rivo/tview"
+)
+
+// max chars of a tool result shown in the sub agent transcript
+const subAgentResultPreview = 300
+
+// sub agent transcripts are kept in memory only, by tool call id (or msg index when there is no id);
+// they are shown under the delegate tool response when tool messages are expanded
+var (
+	subAgentMu          sync.Mutex
+	subAgentTranscripts = make(map[string][]string)
+)
+
+type subAgentLogKey struct{}
+
+// subAgentLog collects the transcript of the sub agents of one tool call,
+// so calls running at once keep their transcripts apart
+type subAgentLog struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func withSubAgentLog(ctx context.Context) (context.Context, *subAgentLog) {
+	log := &subAgentLog{}
+	return context.WithValue(ctx, subAgentLogKey{}, log), log
+}
+
+func (l *subAgentLog) transcript() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lines
+}
+
+func subAgentKey(i int, msg *models.RoleMsg) string {
+	if msg.ToolCallID != "" {
+		return msg.ToolCallID
+	}
+	return "#" + strconv.Itoa(i)
+}
+
+// formatSubAgentStep makes a transcript line
+func formatSubAgentStep(name string, step agent.SubAgentStep) string {
+	text := step.Text
+	if step.Kind == agent.StepToolResult && len([]rune(text)) > subAgentResultPreview {
+		text = string([]rune(text)[:subAgentResultPreview]) + "..."
+	}
+	text = tview.Escape(strings.TrimSpace(text))
+	name = tview.Escape(name)
+	switch step.Kind {
+	case agent.StepToolCall:
+		args := make([]string, 0, len(step.Args))
+		for k, v := range step.Args {
+			args = append(args, k+"="+v)
+		}
+		return fmt.Sprintf("[teal::i]%s -> %s[-:-:-] %s", name, step.Tool, tview.Escape(strings.Join(args, " ")))
+	case agent.StepToolResult:
+		return fmt.Sprintf("[teal::i]%s <- %s:[-:-:-] %s", name, step.Tool, text)
+	case agent.StepError:
+		return fmt.Sprintf("[red::i]%s error:[-:-:-] %s", name, text)
+	case agent.StepAnswer:
+		return fmt.Sprintf("[teal::b]%s answer:[-:-:-] %s", name, text)
+	}
+	return fmt.Sprintf("[teal::i]%s:[-:-:-] %s", name, text)
+}
+
+// onSubAgentStep shows the step while the delegate tool runs and keeps it
+// in the transcript of its tool call
+func onSubAgentStep(ctx context.Context, name string, step agent.SubAgentStep) {
+	line := formatSubAgentStep(name, step)
+	if log, ok := ctx.Value(subAgentLogKey{}).(*subAgentLog); ok {
+		log.mu.Lock()
+		log.lines = append(log.lines, line)
+		log.mu.Unlock()
+	}
+	outputHandler.Writef("\n%s", line)
+}
+
+// attachSubAgentTranscript links the transcript of a tool call to its response msg
+func attachSubAgentTranscript(i int, lines []string) {
+	subAgentMu.Lock()
+	defer subAgentMu.Unlock()
+	if len(lines) == 0 || i < 0 || i >= len(chatBody.Messages) {
+		return
+	}
+	subAgentTranscripts[subAgentKey(i, &chatBody.Messages[i])] = lines
+}
+
+// subAgentTranscriptText returns the transcript block for the tool response, if there is one
+func subAgentTranscriptText(i int, msg *models.RoleMsg) string {
+	subAgentMu.Lock()
+	lines, ok := subAgentTranscripts[subAgentKey(i, msg)]
+	subAgentMu.Unlock()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("[yellow::i][sub-agent transcript: %d steps][-:-:-]\n%s\n", len(lines), strings.Join(lines, "\n"))
+}
@@ -23,6 +23,9 @@ import (
 
 var currentFilePickerUeberzugImg *ueberzug.Image
 
+// filePickerOnImage, if set, gets the picked image instead of attaching it to a msg (card avatar)
+var filePickerOnImage func(filePath string)
+
 func getChatSummary(msgsJSON string, maxMsgs int) string {
 	var msgs []models.RoleMsg
 	if err := json.Unmarshal([]byte(msgsJSON), &msgs); err != nil {
@@ -561,7 +564,7 @@ func makeRAGTable(fileList []string, loadedFiles []string) *tview.Flex {
 }
 
 func makeAgentTable(cards []*models.CharCard) *tview.Table {
-	actions := []string{"filepath", "load"}
+	actions := []string{"filepath", "load", "edit"}
 	rows, cols := len(cards), len(actions)+1
 	chatActTable := tview.NewTable().
 		SetBorders(true)
@@ -627,6 +630,15 @@ func makeAgentTable(cards []*models.CharCard) *tview.Table {
 			pages.RemovePage(agentPage)
 			app.SetFocus(textArea)
 			return
+		case "edit":
+			pages.RemovePage(agentPage)
+			if cards[row].ID == basicCard.ID {
+				showToast("card", "default card is not stored in a file; press n for a new card")
+				return
+			}
+			spec, avatar := cardForEdit(cards[row])
+			showCardEditor(spec, avatar, cards[row].FilePath)
+			return
 		default:
 			pages.RemovePage(agentPage)
 			return
@@ -638,6 +650,11 @@ func makeAgentTable(cards []*models.CharCard) *tview.Table {
 			pages.RemovePage(agentPage)
 			return nil
 		}
+		if event.Key() == tcell.KeyRune && event.Rune() == 'n' {
+			pages.RemovePage(agentPage)
+			showCardEditor(nil, "", "")
+			return nil
+		}
 		return event
 	})
 	return chatActTable
@@ -777,7 +794,8 @@ func makeImportChatTable(filenames []string) *tview.Table {
 		switch tc.Text {
 		case "load":
 			if err := importChat(selected); err != nil {
-				logger.Warn("failed to import chat", "filename", selected)
+				logger.Warn("failed to import chat", "filename", selected, "error", err)
+				showToast("import failed", err.Error())
 				pages.RemovePage(historyPage)
 				return
 			}
@@ -822,6 +840,8 @@ func makeImportChatTable(filenames []string) *tview.Table {
 }
 
 func makeFilePicker() *tview.Flex {
+	// set by the caller after the picker is made
+	filePickerOnImage = nil
 	// Initialize with directory from config or current directory
 	startDir := cfg.FilePickerDir
 	if startDir == "" {
@@ -1214,6 +1234,12 @@ geom, err := getTerminalGeometry()
 								currentFilePickerUeberzugImg.Destroy()
 								currentFilePickerUeberzugImg = nil
 							}
+							if filePickerOnImage != nil {
+								filePickerOnImage(filePath)
+								filePickerOnImage = nil
+								pages.RemovePage(filePickerPage)
+								return nil
+							}
 							if editMode && selectedIndex >= 0 && selectedIndex < len(chatBody.Messages) {
 								imageURL, err := models.CreateImageURLFromPath(filePath)
 								if err != nil {
@@ -1408,6 +1434,12 @@ geom, err := getTerminalGeometry()
 							currentFilePickerUeberzugImg.Destroy()
 							currentFilePickerUeberzugImg = nil
 						}
+						if filePickerOnImage != nil {
+							filePickerOnImage(filePath)
+							filePickerOnImage = nil
+							pages.RemovePage(filePickerPage)
+							return nil
+						}
 						logger.Info("adding image", "file", actualItemName)
 						if editMode && selectedIndex >= 0 && selectedIndex < len(chatBody.Messages) {
 							imageURL, err := models.CreateImageURLFromPath(filePath)
@@ -1538,6 +1570,10 @@ func makeDbTable() *tview.Flex {
 		}
 		if event.Key() == tcell.KeyEnter {
 			idx := tblList.GetCurrentItem()
+			if idx >= 0 && idx < len(tables) && tables[idx] == "memories" {
+				pages.AddPage(memoryPage, makeMemoryTable(), true, true)
+				return nil
+			}
 			if idx >= 0 && idx < len(tables) {
 				showDbContentView(tables[idx])
 			}
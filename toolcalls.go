@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gf-lt/mission"
+	"gf-lt/models"
+	"gf-lt/tools"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+const (
+	defaultToolConcurrency = 4
+	defaultToolTimeout     = 300 * time.Second
+)
+
+// tools without side effects; consecutive calls of them in a batch run concurrently
+var defaultParallelTools = []string{"rag_search", "websearch", "websearch_raw", "read_url", "read_url_raw", "help"}
+
+// tools without a timeout unless ToolTimeouts sets one: sub agents and mission steps run as long as their work takes
+var untimedTools = []string{"delegate", "move_issue", "create_pr", "pm_consult", "add_issue_comment"}
+
+// runningToolCall is a tool call in progress, shown in the cancel popup
+type runningToolCall struct {
+	id      int
+	name    string
+	args    string
+	started time.Time
+	cancel  context.CancelFunc
+}
+
+var (
+	runningToolsMu sync.Mutex
+	runningTools   = make(map[int]*runningToolCall)
+	runningToolSeq int
+)
+
+// startToolCall registers the call; the returned func must be called when it ends
+func startToolCall(tc models.ToolCall) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runningToolsMu.Lock()
+	runningToolSeq++
+	id := runningToolSeq
+	runningTools[id] = &runningToolCall{id: id, name: tc.FuncCall.Name, args: tc.FuncCall.Args, started: time.Now(), cancel: cancel}
+	toolRunningMode.Store(true)
+	runningToolsMu.Unlock()
+	return ctx, func() {
+		cancel()
+		runningToolsMu.Lock()
+		delete(runningTools, id)
+		toolRunningMode.Store(len(runningTools) > 0)
+		runningToolsMu.Unlock()
+	}
+}
+
+// listRunningToolCalls returns running calls in start order
+func listRunningToolCalls() []runningToolCall {
+	runningToolsMu.Lock()
+	defer runningToolsMu.Unlock()
+	resp := make([]runningToolCall, 0, len(runningTools))
+	for _, rt := range runningTools {
+		resp = append(resp, *rt)
+	}
+	sort.Slice(resp, func(i, j int) bool { return resp[i].id < resp[j].id })
+	return resp
+}
+
+// cancelToolCall cancels the running call by id; negative id cancels all
+func cancelToolCall(id int) {
+	runningToolsMu.Lock()
+	defer runningToolsMu.Unlock()
+	for _, rt := range runningTools {
+		if id < 0 || rt.id == id {
+			logger.Info("tool call cancelled by user", "tool", rt.name, "args", rt.args)
+			rt.cancel()
+		}
+	}
+}
+
+// toolTimeout is ToolTimeouts[name], then ToolTimeout, then the default;
+// 0 is no timeout: a ToolTimeouts entry of 0 and untimedTools
+func toolTimeout(name string) time.Duration {
+	if sec, ok := cfg.ToolTimeouts[name]; ok {
+		return time.Duration(max(sec, 0)) * time.Second
+	}
+	if slices.Contains(untimedTools, name) {
+		return 0
+	}
+	if cfg.ToolTimeout > 0 {
+		return time.Duration(cfg.ToolTimeout) * time.Second
+	}
+	return defaultToolTimeout
+}
+
+// toolDeadline cancels the tool call when its time is up; the clock stops
+// while the tool waits for the user to confirm a command (see tools.WithTimeoutPause)
+type toolDeadline struct {
+	context.Context
+	mu       sync.Mutex
+	deadline time.Time
+	timer    *time.Timer
+	pauses   int
+	pausedAt time.Time
+}
+
+func withToolDeadline(parent context.Context, timeout time.Duration) (*toolDeadline, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	d := &toolDeadline{Context: ctx, deadline: time.Now().Add(timeout)}
+	d.timer = time.AfterFunc(timeout, func() { cancel(context.DeadlineExceeded) })
+	return d, func() {
+		d.timer.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+func (d *toolDeadline) Deadline() (time.Time, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.deadline, true
+}
+
+// Err is context.DeadlineExceeded when the time is up, like a ctx of context.WithTimeout
+func (d *toolDeadline) Err() error {
+	err := d.Context.Err()
+	if err != nil && errors.Is(context.Cause(d.Context), context.DeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// pause stops the clock until the returned func is called; pauses may overlap
+func (d *toolDeadline) pause() func() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pauses++
+	if d.pauses == 1 {
+		d.timer.Stop()
+		d.pausedAt = time.Now()
+	}
+	return sync.OnceFunc(func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.pauses--
+		if d.pauses == 0 {
+			d.deadline = d.deadline.Add(time.Since(d.pausedAt))
+			d.timer.Reset(time.Until(d.deadline))
+		}
+	})
+}
+
+// callToolCancelable runs the tool until it returns, times out or the user cancels it;
+// the tool gets the ctx, one that ignores it keeps running in background and its result is dropped.
+// transcript is what sub agents of the call (delegate) did
+func callToolCancelable(tc models.ToolCall, args map[string]string) (resp []byte, transcript []string, ok bool) {
+	ctx, done := startToolCall(tc)
+	defer done()
+	ctx, subAgents := withSubAgentLog(ctx)
+	defer func() { transcript = subAgents.transcript() }()
+	timeout := toolTimeout(tc.FuncCall.Name)
+	if timeout > 0 {
+		deadline, cancel := withToolDeadline(ctx, timeout)
+		defer cancel()
+		ctx = tools.WithTimeoutPause(deadline, deadline.pause)
+	}
+	// output of long commands is shown while they run
+	ctx = tools.WithOutputStream(ctx, func(chunk string) {
+		outputHandler.Write(tview.Escape(chunk))
+	})
+	type result struct {
+		resp []byte
+		ok   bool
+	}
+	resCh := make(chan result, 1)
+	start := time.Now()
+	go func() {
+		resp, ok := tools.CallToolWithAgent(ctx, tc.FuncCall.Name, args)
+		resCh <- result{resp: resp, ok: ok}
+	}()
+	select {
+	case r := <-resCh:
+		status := mission.TraceOK
+		if !r.ok {
+			status = mission.TraceFailed
+		} else if tools.IsToolError(tc.FuncCall.Name, string(r.resp)) {
+			status = mission.TraceError
+		}
+		traceToolCall(tc.FuncCall.Name, args, start, r.resp, status)
+		return r.resp, nil, r.ok
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			logger.Warn("tool call timed out", "tool", tc.FuncCall.Name, "timeout", timeout)
+			outputHandler.Writef("\n[red::i][tool: %s timed out][-:-:-]", tc.FuncCall.Name)
+			resp := []byte(fmt.Sprintf("[error] tool %s did not finish in %s and was cancelled", tc.FuncCall.Name, timeout))
+			traceToolCall(tc.FuncCall.Name, args, start, resp, mission.TraceTimeout)
+			return resp, nil, false
+		}
+		outputHandler.Writef("\n[red::i][tool: %s cancelled][-:-:-]", tc.FuncCall.Name)
+		resp := []byte("[cancelled] the user cancelled this tool call")
+		traceToolCall(tc.FuncCall.Name, args, start, resp, mission.TraceCancelled)
+		return resp, nil, false
+	}
+}
+
+// isParallelToolCall tells if the call can run together with its neighbours:
+// tool without side effects and nothing to confirm
+func isParallelToolCall(tc models.ToolCall) bool {
+	names := cfg.ParallelTools
+	if len(names) == 0 {
+		names = defaultParallelTools
+	}
+	if !slices.Contains(names, tc.FuncCall.Name) {
+		return false
+	}
+	args, err := convertJSONToMapStringString(tc.FuncCall.Args)
+	if err != nil {
+		return false
+	}
+	dangerous, _ := tools.IsDangerousCommand(tc.FuncCall.Name, args)
+	return !dangerous
+}
+
+// executeToolCalls runs the batch: consecutive parallel calls run concurrently
+// (up to ToolConcurrency), other calls run alone in order, so a call never overtakes
+// one it may depend on. Responses are appended in the original call order.
+func executeToolCalls(toolCalls []models.ToolCall) {
+	limit := cfg.ToolConcurrency
+	if limit <= 0 {
+		limit = defaultToolConcurrency
+	}
+	for i := 0; i < len(toolCalls); {
+		j := i
+		for j < len(toolCalls) && limit > 1 && isParallelToolCall(toolCalls[j]) {
+			j++
+		}
+		if j-i < 2 {
+			executeOneToolCall(toolCalls[i])
+			i++
+			continue
+		}
+		group := toolCalls[i:j]
+		logger.Info("running tool calls concurrently", "count", len(group), "limit", limit)
+		results := make([]toolCallResult, len(group))
+		sem := make(chan struct{}, limit)
+		var wg sync.WaitGroup
+		for k, tc := range group {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				results[k] = runToolCall(tc)
+			}()
+		}
+		wg.Wait()
+		for k, tc := range group {
+			appendToolCallResult(tc, results[k])
+		}
+		i = j
+	}
+}
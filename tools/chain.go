@@ -1,14 +1,20 @@
 package tools
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
+// how long a killed command may hold its output pipes (children of the command)
+const killWaitDelay = 2 * time.Second
+
 // Operator represents a chain operator between commands.
 type Operator int
 
@@ -130,6 +136,12 @@ func ParseChain(input string) []Segment {
 // ExecChain executes a command string with pipe/chaining support.
 // Returns the combined output of all commands.
 func ExecChain(command string) string {
+	return ExecChainContext(context.Background(), command)
+}
+
+// ExecChainContext is ExecChain that kills running commands and skips the rest when ctx is done;
+// output of system commands goes to the ctx output stream as it comes
+func ExecChainContext(ctx context.Context, command string) string {
 	segments := ParseChain(command)
 	if len(segments) == 0 {
 		return "[error] empty command"
@@ -168,7 +180,7 @@ func ExecChain(command string) string {
 		// Execute the redirect command
 		var lastOutput string
 		var lastErr error
-		lastOutput, lastErr = execSingle(redirectCmd, "")
+		lastOutput, lastErr = execSingle(ctx, redirectCmd, "")
 		if lastErr != nil {
 			return fmt.Sprintf("[error] redirect: %v", lastErr)
 		}
@@ -192,10 +204,14 @@ func ExecChain(command string) string {
 
 		// Execute remaining commands
 		for _, seg := range segments {
-			out, _ := execSingle(seg.Raw, "")
+			out, _ := execSingle(ctx, seg.Raw, "")
 			if out != "" {
 				collected = append(collected, out)
 			}
+			if err := ctx.Err(); err != nil {
+				collected = append(collected, stoppedMsg(err))
+				break
+			}
 		}
 		return strings.Join(collected, "\n")
 	} else if redirectIdx >= 0 && redirectIdx+1 >= len(segments) {
@@ -222,7 +238,14 @@ func ExecChain(command string) string {
 		} else if segments[i-1].Op == OpPipe {
 			segStdin = lastOutput
 		}
-		lastOutput, lastErr = execSingle(seg.Raw, segStdin)
+		lastOutput, lastErr = execSingle(ctx, seg.Raw, segStdin)
+		if err := ctx.Err(); err != nil {
+			if lastOutput != "" {
+				collected = append(collected, lastOutput)
+			}
+			collected = append(collected, stoppedMsg(err))
+			return strings.Join(collected, "\n")
+		}
 		if i < len(segments)-1 && seg.Op == OpPipe {
 			continue
 		}
@@ -248,8 +271,44 @@ func ExecChain(command string) string {
 	return strings.Join(collected, "\n")
 }
 
+func stoppedMsg(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "[error] command timed out and was killed"
+	}
+	return "[cancelled] command was cancelled by the user"
+}
+
+// streamWriter keeps the output and passes every chunk to the stream
+type streamWriter struct {
+	buf    bytes.Buffer
+	stream func(string)
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	w.stream(string(p))
+	return len(p), nil
+}
+
+// runCommand runs cmd (made with ctx) in FilePickerDir and returns its combined output;
+// the output goes to the ctx output stream as it comes
+func runCommand(ctx context.Context, cmd *exec.Cmd) (string, error) {
+	cmd.Dir = cfg.FilePickerDir
+	cmd.WaitDelay = killWaitDelay
+	stream := OutputStream(ctx)
+	if stream == nil {
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+	w := &streamWriter{stream: stream}
+	cmd.Stdout = w
+	cmd.Stderr = w
+	err := cmd.Run()
+	return w.buf.String(), err
+}
+
 // execSingle executes a single command (with arguments) and returns output and error.
-func execSingle(command, stdin string) (string, error) {
+func execSingle(ctx context.Context, command, stdin string) (string, error) {
 	parts := tokenize(command)
 	if len(parts) == 0 {
 		return "", errors.New("empty command")
@@ -257,23 +316,18 @@ func execSingle(command, stdin string) (string, error) {
 	name := parts[0]
 	args := parts[1:]
 	// Check if it's a built-in Go command
-	result, err := execBuiltin(name, args, stdin)
+	result, err := execBuiltin(ctx, name, args, stdin)
 	if err == nil {
 		return result, nil
 	}
 	// Check if it's a "not a builtin" error (meaning we should try system command)
 	if err.Error() == "not a builtin" {
 		// Execute as system command
-		cmd := exec.Command(name, args...)
-		cmd.Dir = cfg.FilePickerDir
+		cmd := exec.CommandContext(ctx, name, args...)
 		if stdin != "" {
 			cmd.Stdin = strings.NewReader(stdin)
 		}
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			return string(output), err
-		}
-		return string(output), nil
+		return runCommand(ctx, cmd)
 	}
 	// It's a builtin that returned an error
 	return result, err
@@ -317,7 +371,7 @@ func tokenize(input string) []string {
 // execBuiltin executes built-in commands that need Go-side state management.
 // All other commands (find, sed, grep, cat, ls, etc.) fall through to exec.Command
 // for full Unix flag support and pipe chaining.
-func execBuiltin(name string, args []string, stdin string) (string, error) {
+func execBuiltin(ctx context.Context, name string, args []string, stdin string) (string, error) {
 	var result string
 	switch name {
 	case "cd":
@@ -326,9 +380,7 @@ func execBuiltin(name string, args []string, stdin string) (string, error) {
 		if len(args) == 0 {
 			return "[error] usage: go <subcommand> [options]", nil
 		}
-		cmd := exec.Command("go", args...)
-		cmd.Dir = cfg.FilePickerDir
-		output, err := cmd.CombinedOutput()
+		output, err := runCommand(ctx, exec.CommandContext(ctx, "go", args...))
 		if err != nil {
 			return fmt.Sprintf("[error] go %s: %v\n%s", args[0], err, string(output)), nil
 		}
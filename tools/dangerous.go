@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"strings"
 )
 
@@ -82,3 +83,20 @@ func RequestConfirmation(req ConfirmRequest) bool {
 	ConfirmChan <- extendedReq
 	return <-resultCh
 }
+
+type timeoutPauseKey struct{}
+
+// WithTimeoutPause lets tools called with the returned ctx stop the timeout of their call
+// while they wait for the user; pause returns the func that starts it again
+func WithTimeoutPause(ctx context.Context, pause func() (resume func())) context.Context {
+	return context.WithValue(ctx, timeoutPauseKey{}, pause)
+}
+
+// RequestConfirmationCtx is RequestConfirmation that does not count the wait
+// for the user against the timeout of the tool call
+func RequestConfirmationCtx(ctx context.Context, req ConfirmRequest) bool {
+	if pause, ok := ctx.Value(timeoutPauseKey{}).(func() func()); ok {
+		defer pause()()
+	}
+	return RequestConfirmation(req)
+}
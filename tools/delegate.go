@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"gf-lt/agent"
+	"gf-lt/models"
+	"strconv"
+	"strings"
+)
+
+const (
+	// used when SubAgentMaxSteps is not set
+	defaultSubAgentMaxSteps = 8
+	defaultSubAgentPrompt   = `You are a sub agent working on a task given by the main assistant. Work on your own, use tools when needed. Finish with a concise result: what was found or done, without commentary.`
+)
+
+// subAgentObserver gets transcript steps of running sub agents (set by the UI);
+// ctx is the one of the delegate tool call
+var subAgentObserver func(ctx context.Context, name string, step agent.SubAgentStep)
+
+func SetSubAgentObserver(fn func(ctx context.Context, name string, step agent.SubAgentStep)) {
+	subAgentObserver = fn
+}
+
+// subAgentTools picks allowed tool defs by name; delegate itself is never given
+func subAgentTools(names []string) []models.Tool {
+	resp := []models.Tool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "delegate" {
+			continue
+		}
+		if _, ok := FnMap[name]; !ok {
+			continue
+		}
+		for _, t := range BaseTools {
+			if t.Function.Name == name {
+				resp = append(resp, t)
+				break
+			}
+		}
+	}
+	return resp
+}
+
+// callSubAgentTool runs the tool like the main chat does, asking to confirm dangerous commands
+func callSubAgentTool(ctx context.Context, name string, args map[string]string) ([]byte, bool) {
+	if err := ctx.Err(); err != nil {
+		return []byte("[cancelled] delegate call stopped: " + err.Error()), false
+	}
+	if !IsMissionMode() {
+		if dangerous, label := IsDangerousCommand(name, args); dangerous {
+			approved := RequestConfirmationCtx(ctx, ConfirmRequest{ToolName: name, Command: args["command"], ToolArgs: args})
+			if !approved {
+				return []byte("[denied] This command requires user confirmation: " + label), false
+			}
+		}
+	}
+	return CallToolWithAgent(ctx, name, args)
+}
+
+func delegateTool(ctx context.Context, args map[string]string) []byte {
+	task := strings.TrimSpace(args["task"])
+	if task == "" {
+		return []byte("[error] task is required")
+	}
+	if c := strings.TrimSpace(args["context"]); c != "" {
+		task = fmt.Sprintf("%s\n\ncontext:\n%s", task, c)
+	}
+	sysprompt := args["sysprompt"]
+	if strings.TrimSpace(sysprompt) == "" {
+		sysprompt = defaultSubAgentPrompt
+	}
+	names := cfg.SubAgentTools
+	if args["tools"] != "" {
+		names = strings.Split(args["tools"], ",")
+	}
+	maxSteps := cfg.SubAgentMaxSteps
+	if maxSteps <= 0 {
+		maxSteps = defaultSubAgentMaxSteps
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(args["max_steps"])); err == nil && n > 0 {
+		maxSteps = min(n, maxSteps)
+	}
+	name := strings.TrimSpace(args["name"])
+	if name == "" {
+		name = "sub-agent"
+	}
+	getToken := func() string {
+		if getTokenFunc != nil {
+			return getTokenFunc()
+		}
+		return ""
+	}
+	sa := agent.NewSubAgent(agent.NewAgentClient(cfg, logger, getToken), name, sysprompt,
+		subAgentTools(names), maxSteps, func(name string, args map[string]string) ([]byte, bool) {
+			return callSubAgentTool(ctx, name, args)
+		})
+	if subAgentObserver != nil {
+		sa.OnStep = func(step agent.SubAgentStep) { subAgentObserver(ctx, name, step) }
+	}
+	logger.Info("delegating task to sub agent", "name", name, "max_steps", maxSteps, "tools", names)
+	return sa.ProcessTask(task)
+}
+
+var delegateToolDef = models.Tool{
+	Type: "function",
+	Function: models.ToolFunc{
+		Name:        "delegate",
+		Description: "Delegate a self-contained task to a sub agent. It works on its own with the given tools and returns json with status, result and steps. Use for research or multi step work that would clutter the chat.",
+		Parameters: models.ToolFuncParams{
+			Type:     "object",
+			Required: []string{"task"},
+			Properties: map[string]models.ToolArgProps{
+				"task": {
+					Type:        "string",
+					Description: "what the sub agent has to do and what to return",
+				},
+				"context": {
+					Type:        "string",
+					Description: "facts from the chat the sub agent needs (it does not see the chat)",
+				},
+				"tools": {
+					Type:        "string",
+					Description: "comma separated tool names the sub agent may use (default from config)",
+				},
+				"sysprompt": {
+					Type:        "string",
+					Description: "system prompt of the sub agent (optional)",
+				},
+				"max_steps": {
+					Type:        "string",
+					Description: "max number of llm requests (optional, capped by config)",
+				},
+				"name": {
+					Type:        "string",
+					Description: "name of the sub agent shown in the transcript (optional)",
+				},
+			},
+		},
+	},
+}
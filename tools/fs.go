@@ -15,12 +15,16 @@ var memoryStore MemoryStore
 var agentRole string
 
 type MemoryStore interface {
-	Memorise(agent, topic, data string) (string, error)
+	Memorise(agent, topic, data string, importance float64) (string, error)
 	Recall(agent, topic string) (string, error)
 	RecallTopics(agent string) ([]string, error)
 	Forget(agent, topic string) error
+	Search(agent, query string, limit int) ([]models.Memory, error)
 }
 
+// defaultMemoryImportance is used when the importance is not given
+const defaultMemoryImportance = 0.5
+
 func SetMemoryStore(store MemoryStore, role string) {
 	memoryStore = store
 	agentRole = role
@@ -222,7 +226,7 @@ func FsCd(args []string, stdin string) string {
 
 func FsMemory(args []string, stdin string) string {
 	if len(args) == 0 {
-		return "[error] usage: memory store <topic> <data> | memory get <topic> | memory list | memory forget <topic>"
+		return "[error] usage: memory store <topic> <data> | memory get <topic> | memory search <query> | memory list | memory forget <topic>"
 	}
 	if memoryStore == nil {
 		return "[error] memory store not initialized"
@@ -239,11 +243,7 @@ func FsMemory(args []string, stdin string) string {
 		} else {
 			data = stdin
 		}
-		_, err := memoryStore.Memorise(agentRole, topic, data)
-		if err != nil {
-			return fmt.Sprintf("[error] failed to store: %v", err)
-		}
-		return "Stored under topic: " + topic
+		return storeMemory(topic, data, defaultMemoryImportance)
 	case "get":
 		if len(args) < 2 {
 			return "[error] usage: memory get <topic>"
@@ -254,6 +254,22 @@ func FsMemory(args []string, stdin string) string {
 			return fmt.Sprintf("[error] failed to recall: %v", err)
 		}
 		return fmt.Sprintf("Topic: %s\n%s", topic, data)
+	case "search":
+		if len(args) < 2 {
+			return "[error] usage: memory search <query>"
+		}
+		memories, err := memoryStore.Search(agentRole, strings.Join(args[1:], " "), 5)
+		if err != nil {
+			return fmt.Sprintf("[error] failed to search: %v", err)
+		}
+		if len(memories) == 0 {
+			return "No memories found."
+		}
+		lines := make([]string, len(memories))
+		for i, m := range memories {
+			lines[i] = fmt.Sprintf("%s: %s", m.Topic, m.Mind)
+		}
+		return strings.Join(lines, "\n")
 	case "list", "topics":
 		topics, err := memoryStore.RecallTopics(agentRole)
 		if err != nil {
@@ -274,6 +290,16 @@ func FsMemory(args []string, stdin string) string {
 		}
 		return "Deleted topic: " + topic
 	default:
-		return fmt.Sprintf("[error] unknown subcommand: %s. Use: store, get, list, topics, forget, delete", args[0])
+		return fmt.Sprintf("[error] unknown subcommand: %s. Use: store, get, search, list, topics, forget, delete", args[0])
+	}
+}
+
+func storeMemory(topic, data string, importance float64) string {
+	if memoryStore == nil {
+		return "[error] memory store not initialized"
+	}
+	if _, err := memoryStore.Memorise(agentRole, topic, data, importance); err != nil {
+		return fmt.Sprintf("[error] failed to store: %v", err)
 	}
+	return "Stored under topic: " + topic
 }
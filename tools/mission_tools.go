@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"gf-lt/agent"
@@ -113,6 +114,7 @@ func RegisterMissionTools() {
 	}
 }
 
+// InitPMAgent makes the PM client; MissionPMAPI and MissionPMModel give it its own provider and model
 func InitPMAgent(cfg *config.Config, log *slog.Logger) {
 	getToken := func() string {
 		if getTokenFunc != nil {
@@ -120,7 +122,37 @@ func InitPMAgent(cfg *config.Config, log *slog.Logger) {
 		}
 		return ""
 	}
-	pmAgent = agent.NewAgentClient(cfg, log, getToken)
+	pmCfg := cfg
+	if cfg.MissionPMAPI != "" || cfg.MissionPMModel != "" {
+		c := *cfg
+		pmCfg = &c
+		if cfg.MissionPMModel != "" {
+			pmCfg.CurrentModel = cfg.MissionPMModel
+		}
+		if cfg.MissionPMAPI != "" && cfg.MissionPMAPI != cfg.CurrentAPI {
+			pmCfg.CurrentAPI = cfg.MissionPMAPI
+			getToken = func() string {
+				return pmToken(cfg)
+			}
+		}
+	}
+	if cfg.MissionPMToken != "" {
+		getToken = func() string {
+			return cfg.MissionPMToken
+		}
+	}
+	pmAgent = agent.NewAgentClient(pmCfg, log, getToken)
+}
+
+// pmToken is the token of the provider of the PM API
+func pmToken(cfg *config.Config) string {
+	switch {
+	case strings.Contains(cfg.MissionPMAPI, "deepseek.com"):
+		return cfg.DeepSeekToken
+	case strings.Contains(cfg.MissionPMAPI, "openrouter.ai"):
+		return cfg.OpenRouterToken
+	}
+	return ""
 }
 
 func pmAgentChat(userMsg string) string {
@@ -206,7 +238,7 @@ func SummarizeChat(messages []models.RoleMsg) (string, error) {
 	return string(resp), nil
 }
 
-func moveIssueTool(args map[string]string) []byte {
+func moveIssueTool(ctx context.Context, args map[string]string) []byte {
 	if currentMission == nil {
 		return []byte(`{"error": "No active mission"}`)
 	}
@@ -244,7 +276,7 @@ func moveIssueTool(args map[string]string) []byte {
 	return []byte(fmt.Sprintf(`{"success": true, "status": "%s", "issue_id": "%s"}`, status, currentMission.Issue.ID))
 }
 
-func createIssueTool(args map[string]string) []byte {
+func createIssueTool(ctx context.Context, args map[string]string) []byte {
 	id := args["id"]
 	title := args["title"]
 	description := args["description"]
@@ -283,6 +315,14 @@ func createIssueTool(args map[string]string) []byte {
 		json.Unmarshal([]byte(cfJSON), &contextFiles)
 	}
 
+	// Parse checks from JSON array; a broken one would silently drop the PR gate
+	var checks []mission.Check
+	if chJSON := args["checks"]; chJSON != "" {
+		if err := json.Unmarshal([]byte(chJSON), &checks); err != nil {
+			return []byte(fmt.Sprintf(`{"error": "Invalid checks JSON: %v"}`, err))
+		}
+	}
+
 	// Parse labels from comma-separated string
 	var labels []string
 	if labelsStr := args["labels"]; labelsStr != "" {
@@ -307,6 +347,7 @@ func createIssueTool(args map[string]string) []byte {
 		UpdatedAt:          time.Now(),
 		AcceptanceCriteria: acceptanceCriteria,
 		ContextFiles:       contextFiles,
+		Checks:             checks,
 	}
 	if currentMission != nil {
 		issue.RelatedIssues = []string{currentMission.Issue.ID}
@@ -340,7 +381,7 @@ func createIssueTool(args map[string]string) []byte {
 	return []byte(fmt.Sprintf(`{"success": true, "issue_id": "%s", "path": "%s"}`, id, path))
 }
 
-func createPRTool(args map[string]string) []byte {
+func createPRTool(ctx context.Context, args map[string]string) []byte {
 	if currentMission == nil {
 		return []byte(`{"error": "No active mission"}`)
 	}
@@ -357,11 +398,36 @@ func createPRTool(args map[string]string) []byte {
 		body = fmt.Sprintf("## Summary\n\nFixes issue #%s\n\n## Changes\n\n<!-- Describe changes made -->\n\n## Testing\n\n<!-- Describe testing performed -->", currentMission.Issue.ID)
 	}
 
+	// Acceptance checks of the issue gate the PR
+	var checkResults []mission.CheckResult
+	if len(currentMission.Issue.Checks) > 0 {
+		// checks are limited by their own timeouts, not by the one of this tool call
+		checkResults = mission.RunChecks(context.WithoutCancel(ctx), currentMission.ProjectDir(), currentMission.Issue.Checks)
+		currentMission.Checkpoint.CheckResults = checkResults
+		if !mission.ChecksPassed(checkResults) {
+			failed := []mission.CheckResult{}
+			for _, r := range checkResults {
+				if !r.Passed {
+					failed = append(failed, r)
+					currentMission.Log("createPRTool: check %q failed: %s", r.Name, r.Error)
+				}
+			}
+			report := map[string]interface{}{
+				"success": false,
+				"error":   fmt.Sprintf("%d of %d acceptance checks failed; fix them and call create_pr again", len(failed), len(checkResults)),
+				"failed":  failed,
+				"passed":  len(checkResults) - len(failed),
+			}
+			return []byte(mustMarshalJSON(report))
+		}
+		currentMission.Log("createPRTool: all %d checks passed", len(checkResults))
+	}
+
 	// Auto-detect branch name from git if not set on the issue
 	branchName := currentMission.Issue.BranchName
 	currentMission.Log("createPRTool: issue.BranchName=%q", branchName)
 	if branchName == "" {
-		if projectPath := currentMission.Issue.ProjectPath; projectPath != "" {
+		if projectPath := currentMission.ProjectDir(); projectPath != "" {
 			if b, err := getCurrentBranch(projectPath); err == nil {
 				branchName = b
 				currentMission.Log("createPRTool: getCurrentBranch returned %q", branchName)
@@ -414,6 +480,9 @@ func createPRTool(args map[string]string) []byte {
 			if acBullets != "" {
 				prContent += "\n## Acceptance Criteria\n\n" + acBullets + "\n"
 			}
+			if len(checkResults) > 0 {
+				prContent += "\n## Checks\n\n" + mission.ChecksMarkdown(checkResults) + "\n"
+			}
 			prContent += "\n---\n\n*Generated by gf-lt auto-issue-solver*\n"
 
 			prPath := filepath.Join(reviewDir, fmt.Sprintf("issue-%s-pr.md", currentMission.Issue.ID))
@@ -435,6 +504,9 @@ func createPRTool(args map[string]string) []byte {
 		"pr_body":     body,
 		"pr_file":     prFile,
 	}
+	if len(checkResults) > 0 {
+		result["checks"] = checkResults
+	}
 
 	// Don't move to review here — missionComplete() handles that after signaling success.
 	// Otherwise the issue file gets double-moved and deleted.
@@ -458,7 +530,7 @@ func getCurrentBranch(projectPath string) (string, error) {
 	return branch, nil
 }
 
-func pmConsultTool(args map[string]string) []byte {
+func pmConsultTool(ctx context.Context, args map[string]string) []byte {
 	if currentMission == nil {
 		return []byte(`{"error": "No active mission"}`)
 	}
@@ -492,7 +564,7 @@ func pmConsultTool(args map[string]string) []byte {
 		currentMission.Checkpoint.ToolCallCount,
 		currentMission.Checkpoint.CommitsMade,
 		currentMission.Checkpoint.ConsecutiveFailures,
-		currentMission.Issue.ProjectPath,
+		currentMission.ProjectDir(),
 		question,
 	)
 
@@ -504,7 +576,7 @@ func pmConsultTool(args map[string]string) []byte {
 	}))
 }
 
-func addIssueCommentTool(args map[string]string) []byte {
+func addIssueCommentTool(ctx context.Context, args map[string]string) []byte {
 	if currentMission == nil {
 		return []byte(`{"error": "No active mission"}`)
 	}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"gf-lt/models"
@@ -8,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/playwright-community/playwright-go"
 )
@@ -24,7 +26,7 @@ func PwShutDown() error {
 	if pw == nil {
 		return nil
 	}
-	pwStop(nil)
+	pwStop(context.Background(), nil)
 	return pw.Stop()
 }
 
@@ -47,7 +49,16 @@ func CheckPlaywright() error {
 	return nil
 }
 
-func pwStart(args map[string]string) []byte {
+// pwTimeout gives the time left of ctx in ms for playwright options; nil keeps the playwright default
+func pwTimeout(ctx context.Context) *float64 {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	return playwright.Float(float64(max(time.Until(deadline).Milliseconds(), 1)))
+}
+
+func pwStart(ctx context.Context, args map[string]string) []byte {
 	browserStartMu.Lock()
 	defer browserStartMu.Unlock()
 	if browserStarted {
@@ -69,7 +80,7 @@ func pwStart(args map[string]string) []byte {
 	return []byte(`{"success": true, "message": "Browser started"}`)
 }
 
-func pwStop(args map[string]string) []byte {
+func pwStop(ctx context.Context, args map[string]string) []byte {
 	browserStartMu.Lock()
 	defer browserStartMu.Unlock()
 	if !browserStarted {
@@ -87,14 +98,14 @@ func pwStop(args map[string]string) []byte {
 	return []byte(`{"success": true, "message": "Browser stopped"}`)
 }
 
-func pwIsRunning(args map[string]string) []byte {
+func pwIsRunning(ctx context.Context, args map[string]string) []byte {
 	if browserStarted {
 		return []byte(`{"running": true, "message": "Browser is running"}`)
 	}
 	return []byte(`{"running": false, "message": "Browser is not running"}`)
 }
 
-func pwNavigate(args map[string]string) []byte {
+func pwNavigate(ctx context.Context, args map[string]string) []byte {
 	url, ok := args["url"]
 	if !ok || url == "" {
 		return []byte(`{"error": "url not provided"}`)
@@ -102,7 +113,7 @@ func pwNavigate(args map[string]string) []byte {
 	if !browserStarted || page == nil {
 		return []byte(`{"error": "Browser not started. Call pw_start first."}`)
 	}
-	_, err := page.Goto(url)
+	_, err := page.Goto(url, playwright.PageGotoOptions{Timeout: pwTimeout(ctx)})
 	if err != nil {
 		return []byte(fmt.Sprintf(`{"error": "failed to navigate: %s"}`, err.Error()))
 	}
@@ -111,7 +122,7 @@ func pwNavigate(args map[string]string) []byte {
 	return []byte(fmt.Sprintf(`{"success": true, "title": "%s", "url": "%s"}`, title, pageURL))
 }
 
-func pwClick(args map[string]string) []byte {
+func pwClick(ctx context.Context, args map[string]string) []byte {
 	selector, ok := args["selector"]
 	if !ok || selector == "" {
 		return []byte(`{"error": "selector not provided"}`)
@@ -135,14 +146,14 @@ func pwClick(args map[string]string) []byte {
 	if index >= count {
 		return []byte(fmt.Sprintf(`{"error": "Element not found at index %d (found %d elements)"}`, index, count))
 	}
-	err = locator.Nth(index).Click()
+	err = locator.Nth(index).Click(playwright.LocatorClickOptions{Timeout: pwTimeout(ctx)})
 	if err != nil {
 		return []byte(fmt.Sprintf(`{"error": "failed to click: %s"}`, err.Error()))
 	}
 	return []byte(`{"success": true, "message": "Clicked element"}`)
 }
 
-func pwFill(args map[string]string) []byte {
+func pwFill(ctx context.Context, args map[string]string) []byte {
 	selector, ok := args["selector"]
 	if !ok || selector == "" {
 		return []byte(`{"error": "selector not provided"}`)
@@ -170,14 +181,14 @@ func pwFill(args map[string]string) []byte {
 	if index >= count {
 		return []byte(fmt.Sprintf(`{"error": "Element not found at index %d"}`, index))
 	}
-	err = locator.Nth(index).Fill(text)
+	err = locator.Nth(index).Fill(text, playwright.LocatorFillOptions{Timeout: pwTimeout(ctx)})
 	if err != nil {
 		return []byte(fmt.Sprintf(`{"error": "failed to fill: %s"}`, err.Error()))
 	}
 	return []byte(`{"success": true, "message": "Filled input"}`)
 }
 
-func pwExtractText(args map[string]string) []byte {
+func pwExtractText(ctx context.Context, args map[string]string) []byte {
 	selector := args["selector"]
 	if selector == "" {
 		selector = "body"
@@ -222,7 +233,7 @@ func joinLines(lines []string) string {
 	return sb.String()
 }
 
-func pwScreenshot(args map[string]string) []byte {
+func pwScreenshot(ctx context.Context, args map[string]string) []byte {
 	selector := args["selector"]
 	fullPage := args["full_page"] == "true"
 	if !browserStarted || page == nil {
@@ -247,7 +258,7 @@ func pwScreenshot(args map[string]string) []byte {
 	return []byte(fmt.Sprintf(`{"path": "%s"}`, path))
 }
 
-func pwScreenshotAndView(args map[string]string) []byte {
+func pwScreenshotAndView(ctx context.Context, args map[string]string) []byte {
 	selector := args["selector"]
 	fullPage := args["full_page"] == "true"
 	if !browserStarted || page == nil {
@@ -287,7 +298,7 @@ func pwScreenshotAndView(args map[string]string) []byte {
 	return jsonResult
 }
 
-func pwWaitForSelector(args map[string]string) []byte {
+func pwWaitForSelector(ctx context.Context, args map[string]string) []byte {
 	selector, ok := args["selector"]
 	if !ok || selector == "" {
 		return []byte(`{"error": "selector not provided"}`)
@@ -303,6 +314,9 @@ func pwWaitForSelector(args map[string]string) []byte {
 			timeout = t
 		}
 	}
+	if left := pwTimeout(ctx); left != nil {
+		timeout = min(timeout, int(*left))
+	}
 	locator := page.Locator(selector)
 	err := locator.WaitFor(playwright.LocatorWaitForOptions{
 		Timeout: playwright.Float(float64(timeout)),
@@ -313,7 +327,7 @@ func pwWaitForSelector(args map[string]string) []byte {
 	return []byte(`{"success": true, "message": "Element found"}`)
 }
 
-func pwDrag(args map[string]string) []byte {
+func pwDrag(ctx context.Context, args map[string]string) []byte {
 	x1, ok := args["x1"]
 	if !ok {
 		return []byte(`{"error": "x1 not provided"}`)
@@ -374,7 +388,7 @@ func pwDrag(args map[string]string) []byte {
 	return []byte(fmt.Sprintf(`{"success": true, "message": "Dragged from (%s,%s) to (%s,%s)"}`, x1, y1, x2, y2))
 }
 
-func pwDragBySelector(args map[string]string) []byte {
+func pwDragBySelector(ctx context.Context, args map[string]string) []byte {
 	fromSelector, ok := args["fromSelector"]
 	if !ok || fromSelector == "" {
 		return []byte(`{"error": "fromSelector not provided"}`)
@@ -446,7 +460,7 @@ func pwDragBySelector(args map[string]string) []byte {
 }
 
 // nolint:unused
-func pwClickAt(args map[string]string) []byte {
+func pwClickAt(ctx context.Context, args map[string]string) []byte {
 	x, ok := args["x"]
 	if !ok {
 		return []byte(`{"error": "x not provided"}`)
@@ -474,7 +488,7 @@ func pwClickAt(args map[string]string) []byte {
 	return []byte(fmt.Sprintf(`{"success": true, "message": "Clicked at (%s,%s)"}`, x, y))
 }
 
-func pwGetHTML(args map[string]string) []byte {
+func pwGetHTML(ctx context.Context, args map[string]string) []byte {
 	selector := args["selector"]
 	if selector == "" {
 		selector = "body"
@@ -569,7 +583,7 @@ func elementToDOM(el playwright.Locator) (DOMElement, error) {
 	return dom, nil
 }
 
-func pwGetDOM(args map[string]string) []byte {
+func pwGetDOM(ctx context.Context, args map[string]string) []byte {
 	selector := args["selector"]
 	if selector == "" {
 		selector = "body"
@@ -597,7 +611,7 @@ func pwGetDOM(args map[string]string) []byte {
 }
 
 // nolint:unused
-func pwSearchElements(args map[string]string) []byte {
+func pwSearchElements(ctx context.Context, args map[string]string) []byte {
 	text := args["text"]
 	selector := args["selector"]
 	if text == "" && selector == "" {
@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"gf-lt/agent"
 	"gf-lt/config"
@@ -78,6 +79,11 @@ Your current tools:
 "name":"read_url_raw",
 "args": ["url"],
 "when_to_use": "get raw content from a webpage"
+},
+{
+"name":"delegate",
+"args": ["task", "context", "tools", "sysprompt", "max_steps"],
+"when_to_use": "give a self-contained task to a sub agent with comma separated tools; it returns json with status and result. Example: delegate task='find the latest go release notes' tools='websearch,read_url'"
 }
 ]
 </tools>
@@ -185,6 +191,10 @@ func InitTools(initCfg *config.Config, log *slog.Logger, store storage.FullRepo)
 	}
 	t.checkWindowTools()
 	t.initAgentsB()
+	if _, ok := FnMap["delegate"]; !ok {
+		FnMap["delegate"] = delegateTool
+		BaseTools = append(BaseTools, delegateToolDef)
+	}
 	if initCfg.MemoryEnabled {
 		SetMemoryStore(&memoryAdapter{store: store, cfg: cfg}, cfg.AssistantRole)
 		FnMap["memory"] = memoryTool
@@ -238,7 +248,7 @@ func RegisterWindowTools(modelHasVision bool) {
 // 	}
 // }
 
-func websearch(args map[string]string) []byte {
+func websearch(ctx context.Context, args map[string]string) []byte {
 	// make http request return bytes
 	query, ok := args["query"]
 	if !ok || query == "" {
@@ -272,7 +282,7 @@ func websearch(args map[string]string) []byte {
 }
 
 // rag search (searches local document database)
-func ragsearch(args map[string]string) []byte {
+func ragsearch(ctx context.Context, args map[string]string) []byte {
 	query, ok := args["query"]
 	if !ok || query == "" {
 		msg := "query not provided to rag_search tool"
@@ -311,7 +321,7 @@ func ragsearch(args map[string]string) []byte {
 }
 
 // web search raw (returns raw data without processing)
-func websearchRaw(args map[string]string) []byte {
+func websearchRaw(ctx context.Context, args map[string]string) []byte {
 	// make http request return bytes
 	query, ok := args["query"]
 	if !ok || query == "" {
@@ -340,7 +350,7 @@ func websearchRaw(args map[string]string) []byte {
 }
 
 // retrieves url content (text)
-func readURL(args map[string]string) []byte {
+func readURL(ctx context.Context, args map[string]string) []byte {
 	// make http request return bytes
 	link, ok := args["url"]
 	if !ok || link == "" {
@@ -364,7 +374,7 @@ func readURL(args map[string]string) []byte {
 }
 
 // retrieves url content raw (returns raw content without processing)
-func readURLRaw(args map[string]string) []byte {
+func readURLRaw(ctx context.Context, args map[string]string) []byte {
 	// make http request return bytes
 	link, ok := args["url"]
 	if !ok || link == "" {
@@ -383,7 +393,7 @@ func readURLRaw(args map[string]string) []byte {
 }
 
 // Unified run command - single entry point for shell, memory, and other built-in commands
-func runCmd(args map[string]string) []byte {
+func runCmd(ctx context.Context, args map[string]string) []byte {
 	commandStr := args["command"]
 	if commandStr == "" {
 		msg := "command not provided to run tool"
@@ -407,30 +417,30 @@ func runCmd(args map[string]string) []byte {
 		// help <cmd> - show help for specific command
 		return []byte(getHelp(rest))
 	case "memory":
-		// memory store <topic> <data> | memory get <topic> | memory list | memory forget <topic>
-		return []byte(FsMemory(append([]string{"store"}, rest...), ""))
+		// memory store <topic> <data> | memory get <topic> | memory search <query> | memory list | memory forget <topic>
+		return []byte(FsMemory(rest, ""))
 	case "window", "windows":
 		// window list - list all windows
-		return listWindows(args)
+		return listWindows(ctx, args)
 	case "capture", "screenshot":
 		// capture <window-name> - capture a window
-		return captureWindow(args)
+		return captureWindow(ctx, args)
 	case "capture_and_view", "screenshot_and_view":
 		// capture and view screenshot
-		return captureWindowAndView(args)
+		return captureWindowAndView(ctx, args)
 	case "view_img":
 		// view_img <file> - view image for multimodal
 		return []byte(FsViewImg(rest, ""))
 	case "browser":
 		// browser <action> [args...] - Playwright browser automation
-		return runBrowserCommand(rest, args)
+		return runBrowserCommand(ctx, rest, args)
 	case "file_edit":
 		return []byte(FsFileEdit(args))
 	case "insert_at":
 		return []byte(FsInsertAt(args))
 	case "mkdir", "ls", "cat", "stat", "pwd", "cd", "cp", "mv", "rm", "sed", "grep", "head", "tail", "wc", "sort", "uniq", "echo", "printf", "time", "go", "find", "file", "git":
 		// File operations, git, and shell commands - use ExecChain which has pipe/chaining support
-		return executeCommand(args)
+		return executeCommand(ctx, args)
 	default:
 		// Unknown subcommand - tell user to run help tool
 		return []byte("[error] command not allowed. Run 'help' tool to see available commands.")
@@ -438,7 +448,7 @@ func runCmd(args map[string]string) []byte {
 }
 
 // browserCmd handles top-level browser tool calls
-func browserCmd(args map[string]string) []byte {
+func browserCmd(ctx context.Context, args map[string]string) []byte {
 	action := args["action"]
 	argsStr := args["args"]
 	// Parse args string into slice (space-separated, respecting quoted strings)
@@ -464,11 +474,11 @@ Actions:
 	}
 	// Prepend action to args for runBrowserCommand
 	fullArgs := append([]string{action}, browserArgs...)
-	return runBrowserCommand(fullArgs, args)
+	return runBrowserCommand(ctx, fullArgs, args)
 }
 
 // runBrowserCommand routes browser subcommands to Playwright handlers
-func runBrowserCommand(args []string, originalArgs map[string]string) []byte {
+func runBrowserCommand(ctx context.Context, args []string, originalArgs map[string]string) []byte {
 	if len(args) == 0 {
 		return []byte(`usage: browser <action> [args...]
 Actions:
@@ -490,11 +500,11 @@ Actions:
 	rest := args[1:]
 	switch action {
 	case "start":
-		return pwStart(originalArgs)
+		return pwStart(ctx, originalArgs)
 	case "stop":
-		return pwStop(originalArgs)
+		return pwStop(ctx, originalArgs)
 	case "running":
-		return pwIsRunning(originalArgs)
+		return pwIsRunning(ctx, originalArgs)
 	case "go", "navigate", "open":
 		// browser go <url>
 		url := ""
@@ -504,7 +514,7 @@ Actions:
 		if url == "" {
 			return []byte("usage: browser go <url>")
 		}
-		return pwNavigate(map[string]string{"url": url})
+		return pwNavigate(ctx, map[string]string{"url": url})
 	case "click":
 		// browser click <selector> [index]
 		selector := ""
@@ -518,43 +528,43 @@ Actions:
 		if selector == "" {
 			return []byte("usage: browser click <selector> [index]")
 		}
-		return pwClick(map[string]string{"selector": selector, "index": index})
+		return pwClick(ctx, map[string]string{"selector": selector, "index": index})
 	case "fill":
 		// browser fill <selector> <text>
 		if len(rest) < 2 {
 			return []byte("usage: browser fill <selector> <text>")
 		}
-		return pwFill(map[string]string{"selector": rest[0], "text": strings.Join(rest[1:], " ")})
+		return pwFill(ctx, map[string]string{"selector": rest[0], "text": strings.Join(rest[1:], " ")})
 	case "text":
 		// browser text [selector]
 		selector := ""
 		if len(rest) > 0 {
 			selector = rest[0]
 		}
-		return pwExtractText(map[string]string{"selector": selector})
+		return pwExtractText(ctx, map[string]string{"selector": selector})
 	case "html":
 		// browser html [selector]
 		selector := ""
 		if len(rest) > 0 {
 			selector = rest[0]
 		}
-		return pwGetHTML(map[string]string{"selector": selector})
+		return pwGetHTML(ctx, map[string]string{"selector": selector})
 	case "dom":
-		return pwGetDOM(originalArgs)
+		return pwGetDOM(ctx, originalArgs)
 	case "screenshot":
 		// browser screenshot [path]
 		path := ""
 		if len(rest) > 0 {
 			path = rest[0]
 		}
-		return pwScreenshot(map[string]string{"path": path})
+		return pwScreenshot(ctx, map[string]string{"path": path})
 	case "screenshot_and_view":
 		// browser screenshot_and_view [path]
 		path := ""
 		if len(rest) > 0 {
 			path = rest[0]
 		}
-		return pwScreenshotAndView(map[string]string{"path": path})
+		return pwScreenshotAndView(ctx, map[string]string{"path": path})
 	case "wait":
 		// browser wait <selector>
 		selector := ""
@@ -564,7 +574,7 @@ Actions:
 		if selector == "" {
 			return []byte("usage: browser wait <selector>")
 		}
-		return pwWaitForSelector(map[string]string{"selector": selector})
+		return pwWaitForSelector(ctx, map[string]string{"selector": selector})
 	case "drag":
 		// browser drag <x1> <y1> <x2> <y2> OR browser drag <from_selector> <to_selector>
 		if len(rest) < 4 && len(rest) < 2 {
@@ -578,7 +588,7 @@ Actions:
 			if len(rest) < 4 {
 				return []byte("usage: browser drag <x1> <y1> <x2> <y2>")
 			}
-			return pwDrag(map[string]string{
+			return pwDrag(ctx, map[string]string{
 				"x1": rest[0], "y1": rest[1],
 				"x2": rest[2], "y2": rest[3],
 			})
@@ -586,7 +596,7 @@ Actions:
 		// Selectors: browser drag #item #container
 		// pwDrag needs coordinates, so we need to get element positions first
 		// This requires a different approach - use JavaScript to get centers
-		return pwDragBySelector(map[string]string{
+		return pwDragBySelector(ctx, map[string]string{
 			"fromSelector": rest[0],
 			"toSelector":   rest[1],
 		})
@@ -664,6 +674,7 @@ func getHelp(args []string) string {
   # Memory
   memory store <topic> <data>  - save to memory
   memory get <topic>           - retrieve from memory
+  memory search <query>        - find memories by meaning
   memory list                   - list all topics
   memory forget <topic>         - delete from memory
   
@@ -715,11 +726,13 @@ Use: command to execute. Example: ls -la | grep foo`
   Subcommands:
     store <topic> <data>  - save data to a topic
     get <topic>           - retrieve data from a topic
+    search <query>        - find memories related to the query
     list                  - list all topics
     forget <topic>        - delete a topic
   Examples:
     bash "memory store foo bar"
     bash "memory get foo"
+    bash "memory search where does user live"
     bash "memory list"`
 	case "insert_at":
 		return `insert_at <file> <line> <content>
@@ -825,7 +838,7 @@ Use: command to execute. Example: ls -la | grep foo`
 }
 
 // Command Execution Tool with pipe/chaining support
-func executeCommand(args map[string]string) []byte {
+func executeCommand(ctx context.Context, args map[string]string) []byte {
 	commandStr := args["command"]
 	if commandStr == "" {
 		msg := "command not provided to execute_command tool"
@@ -833,7 +846,7 @@ func executeCommand(args map[string]string) []byte {
 		return []byte(msg)
 	}
 	// Use chain execution for pipe/chaining support
-	result := ExecChain(commandStr)
+	result := ExecChainContext(ctx, commandStr)
 	return []byte(result)
 }
 
@@ -881,7 +894,7 @@ func executeCommand(args map[string]string) []byte {
 // }
 
 // Helper functions for command execution
-func viewImgTool(args map[string]string) []byte {
+func viewImgTool(ctx context.Context, args map[string]string) []byte {
 	file, ok := args["file"]
 	if !ok || file == "" {
 		msg := "file not provided to view_img tool"
@@ -892,7 +905,7 @@ func viewImgTool(args map[string]string) []byte {
 	return []byte(result)
 }
 
-func helpTool(args map[string]string) []byte {
+func helpTool(ctx context.Context, args map[string]string) []byte {
 	command, ok := args["command"]
 	var rest []string
 	if ok && command != "" {
@@ -921,7 +934,7 @@ func windowIDToHex(decimalID string) string {
 	return fmt.Sprintf("0x%x", id)
 }
 
-func listWindows(args map[string]string) []byte {
+func listWindows(ctx context.Context, args map[string]string) []byte {
 	cmd := exec.Command(xdotoolPath, "search", "--name", ".")
 	output, err := cmd.Output()
 	if err != nil {
@@ -953,7 +966,7 @@ func listWindows(args map[string]string) []byte {
 	return data
 }
 
-func captureWindow(args map[string]string) []byte {
+func captureWindow(ctx context.Context, args map[string]string) []byte {
 	window, ok := args["window"]
 	if !ok || window == "" {
 		return []byte("window parameter required (window ID or name)")
@@ -987,7 +1000,7 @@ func captureWindow(args map[string]string) []byte {
 	return []byte("screenshot saved: " + filename)
 }
 
-func captureWindowAndView(args map[string]string) []byte {
+func captureWindowAndView(ctx context.Context, args map[string]string) []byte {
 	window, ok := args["window"]
 	if !ok || window == "" {
 		return []byte("window parameter required (window ID or name)")
@@ -1040,7 +1053,22 @@ func captureWindowAndView(args map[string]string) []byte {
 	return jsonResult
 }
 
-type FnHandler func(map[string]string) []byte
+// FnHandler runs a tool; it should return when ctx is done (timeout or user cancel)
+type FnHandler func(ctx context.Context, args map[string]string) []byte
+
+type outputStreamKey struct{}
+
+// WithOutputStream makes tools called with the returned ctx send their output
+// to fn while they run (stdout of shell commands)
+func WithOutputStream(ctx context.Context, fn func(chunk string)) context.Context {
+	return context.WithValue(ctx, outputStreamKey{}, fn)
+}
+
+// OutputStream returns the stream set by WithOutputStream, nil if there is none
+func OutputStream(ctx context.Context) func(string) {
+	fn, _ := ctx.Value(outputStreamKey{}).(func(string))
+	return fn
+}
 
 // FS Command Handlers - Unix-style file operations
 // Convert map[string]string to []string for tools package
@@ -1055,15 +1083,24 @@ func argsToSlice(args map[string]string) []string {
 	return result
 }
 
-func memoryTool(args map[string]string) []byte {
+func memoryTool(ctx context.Context, args map[string]string) []byte {
 	action := args["action"]
 	topic := args["topic"]
 	data := args["data"]
 	switch action {
 	case "store":
-		return []byte(FsMemory([]string{"store", topic, data}, ""))
+		if topic == "" || data == "" {
+			return []byte("[error] topic and data are required for store")
+		}
+		importance := defaultMemoryImportance
+		if v, err := strconv.ParseFloat(args["importance"], 64); err == nil {
+			importance = min(max(v, 0), 1)
+		}
+		return []byte(storeMemory(topic, data, importance))
 	case "get":
 		return []byte(FsMemory([]string{"get", topic}, ""))
+	case "search":
+		return []byte(FsMemory([]string{"search", args["query"]}, ""))
 	case "list", "topics":
 		return []byte(FsMemory([]string{action}, ""))
 	case "forget", "delete":
@@ -1077,14 +1114,16 @@ var memoryToolDef = models.Tool{
 	Type: "function",
 	Function: models.ToolFunc{
 		Name:        "memory",
-		Description: "Persistent memory storage. Store and retrieve information by topic.",
+		Description: "Persistent memory storage. Store and retrieve information by topic or search it by meaning. Relevant memories may also be recalled automatically.",
 		Parameters: models.ToolFuncParams{
 			Type:     "object",
 			Required: []string{"action"},
 			Properties: map[string]models.ToolArgProps{
-				"action": {Type: "string", Description: "store, get, list, topics, forget, delete"},
-				"topic":  {Type: "string", Description: "topic name (required for store/get/forget)"},
-				"data":   {Type: "string", Description: "data content (required for store)"},
+				"action":     {Type: "string", Description: "store, get, search, list, topics, forget, delete"},
+				"topic":      {Type: "string", Description: "topic name (required for store/get/forget)"},
+				"data":       {Type: "string", Description: "data content (required for store)"},
+				"importance": {Type: "string", Description: "optional for store: 0 (trivia) to 1 (core fact), default 0.5"},
+				"query":      {Type: "string", Description: "what to look for (required for search)"},
 			},
 		},
 	},
@@ -1095,13 +1134,20 @@ type memoryAdapter struct {
 	cfg   *config.Config
 }
 
-func (m *memoryAdapter) Memorise(agent, topic, data string) (string, error) {
+func (m *memoryAdapter) Memorise(agent, topic, data string, importance float64) (string, error) {
 	mem := &models.Memory{
-		Agent:     agent,
-		Topic:     topic,
-		Mind:      data,
-		UpdatedAt: time.Now(),
-		CreatedAt: time.Now(),
+		Agent:      agent,
+		Topic:      topic,
+		Mind:       data,
+		Importance: importance,
+		UpdatedAt:  time.Now(),
+		CreatedAt:  time.Now(),
+	}
+	// memory is still stored without embedding; it can be found by topic
+	if emb, err := EmbedMemory(topic, data); err != nil {
+		logger.Warn("failed to embed memory", "topic", topic, "error", err)
+	} else {
+		mem.Embedding = storage.SerializeVector(emb)
 	}
 	result, err := m.store.Memorise(mem)
 	if err != nil {
@@ -1122,6 +1168,33 @@ func (m *memoryAdapter) Forget(agent, topic string) error {
 	return m.store.Forget(agent, topic)
 }
 
+func (m *memoryAdapter) Search(agent, query string, limit int) ([]models.Memory, error) {
+	emb, err := EmbedText(query)
+	if err != nil {
+		return nil, err
+	}
+	return m.store.SearchMemories(agent, emb, limit, MemoryHalfLife(m.cfg))
+}
+
+// EmbedText embeds with the rag embedder
+func EmbedText(text string) ([]float32, error) {
+	ragInstance := rag.GetInstance()
+	if ragInstance == nil {
+		return nil, errors.New("embeddings are not available")
+	}
+	return ragInstance.LineToVector(text)
+}
+
+// EmbedMemory makes the vector memories are searched by
+func EmbedMemory(topic, mind string) ([]float32, error) {
+	return EmbedText(topic + ": " + mind)
+}
+
+// MemoryHalfLife converts MemoryDecayDays into the decay half-life
+func MemoryHalfLife(c *config.Config) time.Duration {
+	return time.Duration(c.MemoryDecayDays * 24 * float64(time.Hour))
+}
+
 var FnMap = map[string]FnHandler{
 	"rag_search":    ragsearch,
 	"websearch":     websearch,
@@ -1130,10 +1203,10 @@ var FnMap = map[string]FnHandler{
 	"read_url_raw":  readURLRaw,
 	"view_img":      viewImgTool,
 	"help":          helpTool,
-	"file_edit": func(args map[string]string) []byte {
+	"file_edit": func(ctx context.Context, args map[string]string) []byte {
 		return []byte(FsFileEdit(args))
 	},
-	"insert_at": func(args map[string]string) []byte {
+	"insert_at": func(ctx context.Context, args map[string]string) []byte {
 		return []byte(FsInsertAt(args))
 	},
 	// Unified run command
@@ -1163,7 +1236,7 @@ func removeWindowToolsFromBaseTools() {
 	delete(FnMap, "capture_window_and_view")
 }
 
-func summarizeChat(args map[string]string) []byte {
+func summarizeChat(ctx context.Context, args map[string]string) []byte {
 	data, err := json.Marshal(args)
 	if err != nil {
 		return []byte("error: failed to marshal arguments")
@@ -1178,12 +1251,32 @@ func summarizeChat(args map[string]string) []byte {
 
 // Always provide clear feedback about what you're doing and what you found.`
 
-func CallToolWithAgent(name string, args map[string]string) ([]byte, bool) {
-	f, ok := FnMap[name]
+// toolResolvers find handlers of tools that appear at runtime (mcp servers reconnecting)
+var toolResolvers []func(name string) (FnHandler, bool)
+
+func RegisterToolResolver(fn func(name string) (FnHandler, bool)) {
+	toolResolvers = append(toolResolvers, fn)
+}
+
+func lookupTool(name string) (FnHandler, bool) {
+	if f, ok := FnMap[name]; ok {
+		return f, true
+	}
+	for _, resolve := range toolResolvers {
+		if f, ok := resolve(name); ok {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// CallToolWithAgent runs the tool with ctx; tools stop early when ctx is done
+func CallToolWithAgent(ctx context.Context, name string, args map[string]string) ([]byte, bool) {
+	f, ok := lookupTool(name)
 	if !ok {
 		return []byte(fmt.Sprintf("tool %s not found", name)), false
 	}
-	raw := f(args)
+	raw := f(ctx, args)
 	if a := agent.Get(name); a != nil {
 		return a.Process(args, raw), true
 	}
@@ -1442,6 +1535,7 @@ var BaseTools = []models.Tool{
 					"project_path":        {Type: "string", Description: "Path to the project repository (optional, defaults to current working directory)"},
 					"acceptance_criteria": {Type: "string", Description: "JSON array of acceptance criteria strings, e.g. '[\"criteria 1\", \"criteria 2\"]' (optional)"},
 					"context_files":       {Type: "string", Description: "JSON array of file paths relevant to this issue, e.g. '[\"main.go\", \"main_test.go\"]' (optional)"},
+					"checks":              {Type: "string", Description: "JSON array of checks create_pr runs before it accepts a PR; a check has name and either command (must exit 0) or file (must exist), with optional contains text, e.g. '[{\"name\": \"test\", \"command\": \"go test ./...\"}]' (optional)"},
 					"labels":              {Type: "string", Description: "Comma-separated labels, e.g. 'bug,validation' (optional)"},
 					"priority":            {Type: "string", Description: "Priority level: low, medium, high, critical (optional)"},
 					"branch_name":         {Type: "string", Description: "Suggested branch name for this issue (optional)"},
@@ -1,10 +1,12 @@
 package tools
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"gf-lt/config"
 )
@@ -188,3 +190,22 @@ func TestUnixComplexPiping(t *testing.T) {
 		})
 	}
 }
+
+func TestExecChainContextTimeoutAndStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result := ExecChainContext(ctx, "sleep 10 ; echo after")
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("command was not killed in time: %s", time.Since(start))
+	}
+	if !strings.Contains(result, "timed out") || strings.Contains(result, "after") {
+		t.Errorf("expected timeout and no further commands, got %q", result)
+	}
+	var streamed strings.Builder
+	ctx = WithOutputStream(context.Background(), func(chunk string) { streamed.WriteString(chunk) })
+	result = ExecChainContext(ctx, "printf 'one\\ntwo\\n'")
+	if result != "one\ntwo\n" || streamed.String() != result {
+		t.Errorf("unexpected output %q, streamed %q", result, streamed.String())
+	}
+}
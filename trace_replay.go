@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"gf-lt/mission"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// step of the replay when it plays
+const replayStepDelay = 700 * time.Millisecond
+
+func runTraceSummary(path string) {
+	events, err := mission.LoadTrace(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load trace: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(mission.FormatTraceSummary(events))
+}
+
+// runTraceReplay steps through the mission trace: j/k or arrows move, g/G jump to the first/last step,
+// space plays the steps one by one, s toggles the summary, x/q exits
+func runTraceReplay(path string) {
+	events, err := mission.LoadTrace(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load trace: %v\n", err)
+		os.Exit(1)
+	}
+	if len(events) == 0 {
+		fmt.Fprintf(os.Stderr, "Trace %s has no steps\n", path)
+		os.Exit(1)
+	}
+	tview.Styles = colorschemes["default"]
+	replayApp := tview.NewApplication()
+	steps := tview.NewList().ShowSecondaryText(false).SetHighlightFullLine(true)
+	steps.SetTitle("Steps: " + path).SetBorder(true)
+	details := tview.NewTextView().SetDynamicColors(true).SetWordWrap(true)
+	details.SetBorder(true)
+	footer := tview.NewTextView().SetDynamicColors(true).
+		SetText("j/k: step | g/G: first/last | space: play | s: summary | x/q: exit")
+	start := events[0].Time
+	for i := range events {
+		steps.AddItem(replayStepLine(&events[i], start), "", 0, nil)
+	}
+	showSummary := false
+	show := func(i int) {
+		if showSummary {
+			details.SetTitle("Summary")
+			details.SetText(tview.Escape(mission.FormatTraceSummary(events[:i+1])))
+			details.ScrollToBeginning()
+			return
+		}
+		details.SetTitle(fmt.Sprintf("Step %d of %d", i+1, len(events)))
+		details.SetText(replayStepDetails(&events[i]))
+		details.ScrollToBeginning()
+	}
+	steps.SetChangedFunc(func(i int, _, _ string, _ rune) {
+		show(i)
+	})
+	var stopPlay chan struct{}
+	stop := func() {
+		if stopPlay != nil {
+			close(stopPlay)
+			stopPlay = nil
+		}
+	}
+	play := func() {
+		done := make(chan struct{})
+		stopPlay = done
+		go func() {
+			ticker := time.NewTicker(replayStepDelay)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					replayApp.QueueUpdateDraw(func() {
+						if stopPlay != done {
+							return
+						}
+						next := steps.GetCurrentItem() + 1
+						if next >= len(events) {
+							stop()
+							return
+						}
+						steps.SetCurrentItem(next)
+					})
+				}
+			}
+		}()
+	}
+	steps.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'x', 'q':
+			stop()
+			replayApp.Stop()
+			return nil
+		case 'j':
+			return tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone)
+		case 'k':
+			return tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone)
+		case 'g':
+			steps.SetCurrentItem(0)
+			return nil
+		case 'G':
+			steps.SetCurrentItem(len(events) - 1)
+			return nil
+		case ' ':
+			if stopPlay != nil {
+				stop()
+			} else {
+				if steps.GetCurrentItem() == len(events)-1 {
+					steps.SetCurrentItem(0)
+				}
+				play()
+			}
+			return nil
+		case 's':
+			showSummary = !showSummary
+			show(steps.GetCurrentItem())
+			return nil
+		}
+		return event
+	})
+	body := tview.NewFlex().
+		AddItem(steps, 0, 1, true).
+		AddItem(details, 0, 2, false)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(body, 0, 1, true).
+		AddItem(footer, 1, 0, false)
+	show(0)
+	if err := replayApp.SetRoot(layout, true).SetFocus(steps).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to run replay: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// replayStepLine is the list line of the step: time since the start, name, duration and status
+func replayStepLine(ev *mission.TraceEvent, start time.Time) string {
+	line := fmt.Sprintf("%4d %8s %-10s %8s", ev.Seq, ev.Time.Sub(start).Round(time.Second), ev.Name(), ev.Duration().Round(time.Millisecond))
+	status := ev.Status
+	if ev.Error != "" {
+		status = "error"
+	}
+	switch status {
+	case "", mission.TraceOK, mission.PMContinue:
+		return tview.Escape(line)
+	case string(mission.StatusSuccess):
+		return "[green]" + tview.Escape(line+" "+status) + "[-]"
+	case mission.PMReplan:
+		return "[yellow]" + tview.Escape(line+" "+status) + "[-]"
+	}
+	return "[red]" + tview.Escape(line+" "+status) + "[-]"
+}
+
+func replayStepDetails(ev *mission.TraceEvent) string {
+	sb := strings.Builder{}
+	field := func(name string, value any) {
+		fmt.Fprintf(&sb, "[yellow]%s:[-] %s\n", name, tview.Escape(fmt.Sprint(value)))
+	}
+	field("step", fmt.Sprintf("%d %s", ev.Seq, ev.Name()))
+	field("time", ev.Time.Format(time.DateTime))
+	if ev.IssueID != "" {
+		field("issue", ev.IssueID)
+	}
+	if ev.Kind != mission.TraceStart {
+		field("duration", ev.Duration())
+	}
+	if ev.Model != "" {
+		field("model", ev.Model)
+	}
+	if ev.Kind == mission.TraceLLM {
+		field("prompt tokens", ev.PromptTokens)
+		field("completion tokens", ev.CompletionTokens)
+		field("first token", time.Duration(ev.FirstTokenMs)*time.Millisecond)
+	}
+	if ev.Kind == mission.TraceSummarize {
+		field("context tokens", ev.PromptTokens)
+		field("messages summarized", ev.Messages)
+	}
+	if ev.Status != "" {
+		field("status", ev.Status)
+	}
+	if ev.Kind == mission.TraceTool {
+		field("output size", ev.OutputSize)
+	}
+	if ev.Error != "" {
+		fmt.Fprintf(&sb, "[red]error:[-] %s\n", tview.Escape(ev.Error))
+	}
+	if len(ev.Args) > 0 {
+		sb.WriteString("\n[yellow]args[-]\n")
+		keys := make([]string, 0, len(ev.Args))
+		for k := range ev.Args {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&sb, "%s: %s\n", tview.Escape(k), tview.Escape(ev.Args[k]))
+		}
+	}
+	if ev.Text != "" {
+		sb.WriteString("\n[yellow]text[-]\n" + tview.Escape(ev.Text) + "\n")
+	}
+	return sb.String()
+}
@@ -10,6 +10,7 @@ import (
 	_ "image/png"
 	"os"
 	"path"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -80,30 +81,31 @@ var (
 	forkPageName   = "forkOverlay"
 	// help text
 	helpText = `
-[yellow]Esc[white]: send msg
+[yellow]Esc[white]: send msg (cancel running tool calls while a tool runs)
 [yellow]PgUp/Down[white]: switch focus between input and chat widgets
 [yellow]F1[white]: manage chats
 [yellow]F2[white]: regen last
 [yellow]F3[white]: delete last msg
 [yellow]F4[white]: edit msg
 [yellow]F5[white]: toggle fullscreen for input/chat window
-[yellow]F6[white]: interrupt bot resp
+[yellow]F6[white]: interrupt bot resp or cancel running tool calls (pick one when several run at once)
 [yellow]F7[white]: copy last msg to clipboard (linux xclip or wl-copy)
 [yellow]F8[white]: copy n msg to clipboard (linux xclip or wl-copy)
 [yellow]F9[white]: table to copy from; with all code blocks
 [yellow]F10[white]: switch if LLM will respond on this message (for user to write multiple messages in a row)
-[yellow]F11[white]: import json chat file
+[yellow]F11[white]: import chat file (gf-lt or OpenAI/ChatGPT json, SillyTavern jsonl, "role: text" transcript txt)
 [yellow]F12[white]: show this help page
 [yellow]Ctrl+][white]: save current chat to database
 [yellow]Ctrl+w[white]: resume generation on the last msg
-[yellow]Ctrl+s[white]: load new char/agent
-[yellow]Ctrl+e[white]: export chat to json file
+[yellow]Ctrl+s[white]: load new char/agent (edit card in the table, n: create a new card)
+[yellow]Ctrl+e[white]: export chat (json, markdown, html or SillyTavern jsonl)
 [yellow]Ctrl+c[white]: close programm
 [yellow]Ctrl+n[white]: start a new chat
 [yellow]Ctrl+o[white]: open image file picker
 [yellow]Ctrl+p[white]: props edit form (min-p, dry, etc.)
 [yellow]Ctrl+v[white]: show API link selection popup to choose current API
 [yellow]Ctrl+r[white]: start/stop recording from your microphone (needs stt server or whisper binary)
+[yellow]Alt+v[white]: toggle voice conversation mode (hands-free: speech is sent as msg, reply is spoken; talk to interrupt)
 [yellow]Ctrl+t[white]: (un)collapse tool messages
 [yellow]Ctrl+l[white]: show model selection popup to choose current model
 [yellow]Ctrl+k[white]: switch tool use (recommend tool use to llm after user msg)
@@ -126,6 +128,13 @@ var (
 [yellow]Ctrl+t[white]: toggle tool call/response visibility (collapse/expand tool calls and non-shell tool responses)
 [yellow]Alt+i[white]: show colorscheme selection popup
 [yellow]Alt+p[white]: show images from current chat (preview, attach to next msg)
+[yellow]Alt+c[white]: export current chat's card (sys prompt and first msg) into the card editor
+[yellow]Alt+w[white]: lorebook (world info) entries of current card and lorebook dir
+[yellow]Alt+m[white]: MCP servers and tools (enable/disable, restart)
+[yellow]Alt+r[white]: MCP resources (attach to next msg) and prompts (/server:prompt commands)
+[yellow]Alt+b[white]: issues board (mission issues by status: edit, comment, move, run or resume missions with live log)
+[yellow]Alt+u[white]: token usage and cost per chat, day and model
+[yellow]Alt+f[white]: search messages of all chats (Enter on a hit opens its chat at the message)
 [yellow]Insert[white]: paste from clipboard to the text area (use it instead shift+insert)
 
 === scrolling chat window (some keys similar to vim) ===
@@ -821,6 +830,10 @@ func initTUI() {
 		textView.ScrollToEnd()
 	}
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		// editor pages need esc and tab for themselves
+		if name, _ := pages.GetFrontPage(); name == cardEditorPage || name == lorebookEditPage || name == memoryEditPage || name == issueEditPage {
+			return event
+		}
 		if event.Key() == tcell.KeyRune && event.Rune() == '5' && event.Modifiers()&tcell.ModAlt != 0 {
 			// switch cfg.ShowSys
 			cfg.ShowSys = !cfg.ShowSys
@@ -994,6 +1007,12 @@ func initTUI() {
 			return nil
 		}
 		if event.Key() == tcell.KeyF6 {
+			// several tool calls run concurrently: pick which one to cancel
+			if running := listRunningToolCalls(); len(running) > 1 {
+				showToolCancelPopup(running)
+				return nil
+			}
+			cancelToolCall(-1)
 			interruptResp.Store(true)
 			botRespMode.Store(false)
 			toolRunningMode.Store(false)
@@ -1043,7 +1062,7 @@ func initTUI() {
 			}
 			fli := []string{}
 			for _, f := range filelist {
-				if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+				if f.IsDir() || !slices.Contains(importExts, path.Ext(f.Name())) {
 					continue
 				}
 				fpath := path.Join(exportDir, f.Name())
@@ -1063,12 +1082,8 @@ func initTUI() {
 			return nil
 		}
 		if event.Key() == tcell.KeyCtrlE {
-			// export loaded chat into json file
-			if err := exportChat(); err != nil {
-				logger.Error("failed to export chat;", "error", err, "chat_name", activeChatName)
-				return nil
-			}
-			showToast("exported chat", "chat: "+activeChatName+" was exported")
+			// export loaded chat into a file of the picked format
+			showExportFormatPopup()
 			return nil
 		}
 		if event.Key() == tcell.KeyCtrlP {
@@ -1091,6 +1106,43 @@ func initTUI() {
 			pages.AddPage(filePickerPage, filePicker, true, true)
 			return nil
 		}
+		if event.Key() == tcell.KeyRune && event.Rune() == 'c' && event.Modifiers()&tcell.ModAlt != 0 {
+			spec, avatar, savePath := currentChatCard()
+			showCardEditor(spec, avatar, savePath)
+			return nil
+		}
+		if event.Key() == tcell.KeyRune && event.Rune() == 'w' && event.Modifiers()&tcell.ModAlt != 0 {
+			pages.AddPage(lorebookPage, makeLorebookTable(), true, true)
+			return nil
+		}
+		if event.Key() == tcell.KeyRune && event.Rune() == 'b' && event.Modifiers()&tcell.ModAlt != 0 {
+			showIssuesBoard()
+			return nil
+		}
+		if event.Key() == tcell.KeyRune && event.Rune() == 'u' && event.Modifiers()&tcell.ModAlt != 0 {
+			pages.AddPage(usagePage, makeUsageTable(), true, true)
+			return nil
+		}
+		if event.Key() == tcell.KeyRune && event.Rune() == 'f' && event.Modifiers()&tcell.ModAlt != 0 {
+			pages.AddPage(chatSearchPage, makeChatSearchPage(), true, true)
+			return nil
+		}
+		if event.Key() == tcell.KeyRune && event.Rune() == 'm' && event.Modifiers()&tcell.ModAlt != 0 {
+			if mcpManager == nil {
+				showToast("mcp", "no MCP servers configured (or tool use is off at start)")
+				return nil
+			}
+			pages.AddPage(mcpPage, makeMCPTable(), true, true)
+			return nil
+		}
+		if event.Key() == tcell.KeyRune && event.Rune() == 'r' && event.Modifiers()&tcell.ModAlt != 0 {
+			if mcpManager == nil {
+				showToast("mcp", "no MCP servers configured (or tool use is off at start)")
+				return nil
+			}
+			showMCPResources()
+			return nil
+		}
 		if event.Key() == tcell.KeyRune && event.Rune() == 'p' && event.Modifiers()&tcell.ModAlt != 0 {
 			// show images from current chat
 			imgTable := makeImagesTable()
@@ -1169,7 +1221,19 @@ func initTUI() {
 			pages.AddPage(imgPage, imgView, true, true)
 			return nil
 		}
+		if event.Key() == tcell.KeyRune && event.Rune() == 'v' && event.Modifiers()&tcell.ModAlt != 0 && cfg.STT_ENABLED {
+			if err := toggleVoiceMode(); err != nil {
+				logger.Error("failed to toggle voice mode", "error", err)
+				showToast("voice mode", err.Error())
+			}
+			updateStatusLine()
+			return nil
+		}
 		if event.Key() == tcell.KeyCtrlR && cfg.STT_ENABLED {
+			if voiceMode.Load() {
+				showToast("stt", "voice mode is on (alt+v to turn off)")
+				return nil
+			}
 			if asr.IsRecording() {
 				if sttTranscribing {
 					return nil
@@ -1285,6 +1349,7 @@ func initTUI() {
 			logger.Info("caught Ctrl+C via tcell event")
 			go func() {
 				destroyChatOverlay()
+				stopBoardMissions()
 				if err := tools.PwShutDown(); err != nil {
 					logger.Error("shutdown failed", "err", err)
 				}
@@ -1349,6 +1414,13 @@ func initTUI() {
 			showToast("model warmup", "loading model: "+chatBody.Model)
 			return nil
 		}
+		// Esc on the main page cancels running tool calls (F6 picks one of several)
+		if event.Key() == tcell.KeyEscape && toolRunningMode.Load() {
+			if name, _ := pages.GetFrontPage(); name == "main" {
+				cancelToolCall(-1)
+				return nil
+			}
+		}
 		// cannot send msg in editMode or botRespMode
 		if event.Key() == tcell.KeyEscape && !editMode && !botRespMode.Load() {
 			if shellMode {
@@ -1360,6 +1432,15 @@ func initTUI() {
 				return nil
 			}
 			msgText := textArea.GetText()
+			// mcp prompt command is expanded into the text area to be reviewed before sending
+			if expanded, ok, err := expandMCPPrompt(msgText); ok {
+				if err != nil {
+					showToast("mcp prompt", err.Error())
+					return nil
+				}
+				textArea.SetText(expanded, true)
+				return nil
+			}
 			nl := "\n\n" // keep empty lines between messages
 			prevText := textView.GetText(true)
 			persona := cfg.UserRole
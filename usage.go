@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"gf-lt/config"
+	"gf-lt/models"
+	"gf-lt/tools"
+	"strconv"
+	"strings"
+)
+
+// usage chunks come right after the finish chunk; a stream with more lines does not send one
+const maxUsageDrainLines = 20
+
+// drainUsage reads the rest of a finished stream for the usage chunk
+// that openai-like apis send after the finish reason
+func drainUsage(reader *bufio.Reader) *models.Usage {
+	for range maxUsageDrainLines {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return nil
+		}
+		data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data: "))
+		if !ok || len(data) == 0 {
+			continue
+		}
+		if bytes.Equal(data, []byte("[DONE]")) {
+			return nil
+		}
+		chunk, err := chunkParser.ParseChunk(data)
+		if err == nil && chunk.Usage != nil {
+			return chunk.Usage
+		}
+	}
+	return nil
+}
+
+// modelPrice is the price of the model from the Pricing config,
+// or from the openrouter model list for openrouter apis
+func modelPrice(api, model string) (config.ModelPricing, bool) {
+	if price, ok := cfg.Pricing[model]; ok {
+		return price, true
+	}
+	if !strings.Contains(api, "openrouter") || orModelsData == nil {
+		return config.ModelPricing{}, false
+	}
+	for i := range orModelsData.Data {
+		m := &orModelsData.Data[i]
+		if m.ID != model {
+			continue
+		}
+		// openrouter prices are USD per token
+		prompt, err := strconv.ParseFloat(m.Pricing.Prompt, 64)
+		if err != nil {
+			return config.ModelPricing{}, false
+		}
+		completion, err := strconv.ParseFloat(m.Pricing.Completion, 64)
+		if err != nil {
+			return config.ModelPricing{}, false
+		}
+		return config.ModelPricing{Prompt: prompt * 1e6, Completion: completion * 1e6}, true
+	}
+	return config.ModelPricing{}, false
+}
+
+// usageCost is the cost of the reply in USD; 0 when the price of the model is unknown
+func usageCost(api, model string, u *models.Usage) float64 {
+	price, ok := modelPrice(api, model)
+	if !ok {
+		return 0
+	}
+	return (float64(u.PromptTokens)*price.Prompt + float64(u.CompletionTokens)*price.Completion) / 1e6
+}
+
+// recordUsage saves the usage of a reply to the usage table
+// and counts it against the budget of the running mission
+func recordUsage(u *models.Usage) {
+	if u == nil {
+		return
+	}
+	if m := tools.GetCurrentMission(); m != nil {
+		m.AddUsage(u.Total(), u.Cost)
+	}
+	if store == nil {
+		return
+	}
+	err := store.AddUsage(&models.UsageRecord{
+		Chat:             activeChatName,
+		API:              cfg.CurrentAPI,
+		Model:            chatBody.Model,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		ReasoningTokens:  u.ReasoningTokens,
+		Cost:             u.Cost,
+	})
+	if err != nil {
+		logger.Warn("failed to save usage", "error", err, "chat", activeChatName)
+	}
+}
+
+// chatUsage sums the usage of the chat messages
+func chatUsage(msgs []models.RoleMsg) *models.Usage {
+	var total *models.Usage
+	for i := range msgs {
+		if msgs[i].Stats != nil {
+			total = total.Add(msgs[i].Stats.Usage)
+		}
+	}
+	return total
+}
+
+// usageInfo is the api usage shown after the stats of a message
+func usageInfo(u *models.Usage) string {
+	if u == nil {
+		return ""
+	}
+	info := fmt.Sprintf(", in %d, out %d", u.PromptTokens, u.CompletionTokens)
+	if u.ReasoningTokens > 0 {
+		info += fmt.Sprintf(" (%d reasoning)", u.ReasoningTokens)
+	}
+	if u.Cost > 0 {
+		info += fmt.Sprintf(", $%.4f", u.Cost)
+	}
+	return info
+}
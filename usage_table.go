@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"gf-lt/storage"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const usagePage = "usagePage"
+
+// makeUsageTable shows the token usage and cost of all replies summed per chat, day or model.
+// 'c', 'd' and 'm' switch the grouping, 'x' exits.
+func makeUsageTable() *tview.Table {
+	headers := []string{"", "Replies", "Prompt tok", "Completion tok", "Reasoning tok", "Cost $"}
+	table := tview.NewTable().SetBorders(true)
+	table.SetBorder(true)
+	groupBy := storage.UsageByChat
+	balance := ""
+	setTitle := func() {
+		title := fmt.Sprintf("Usage by %s (c: chat, d: day, m: model, x: exit)", groupBy)
+		if u := chatUsage(chatBody.Messages); u != nil {
+			title += fmt.Sprintf(" | this chat: %d tok, $%.4f", u.Total(), u.Cost)
+		}
+		table.SetTitle(title + balance)
+	}
+	fill := func() {
+		table.Clear()
+		headers[0] = strings.ToUpper(groupBy[:1]) + groupBy[1:]
+		for c, h := range headers {
+			table.SetCell(0, c,
+				tview.NewTableCell(h).
+					SetSelectable(false).
+					SetTextColor(tcell.ColorYellow).
+					SetAlign(tview.AlignCenter).
+					SetAttributes(tcell.AttrBold))
+		}
+		setTitle()
+		totals, err := store.UsageTotals(groupBy)
+		if err != nil {
+			showToast("usage", err.Error())
+			return
+		}
+		for r, t := range totals {
+			cells := []string{
+				tview.Escape(t.Key),
+				fmt.Sprint(t.Replies),
+				fmt.Sprint(t.PromptTokens),
+				fmt.Sprint(t.CompletionTokens),
+				fmt.Sprint(t.ReasoningTokens),
+				fmt.Sprintf("%.4f", t.Cost),
+			}
+			for c, text := range cells {
+				align := tview.AlignRight
+				if c == 0 {
+					align = tview.AlignLeft
+				}
+				table.SetCell(r+1, c,
+					tview.NewTableCell(text).
+						SetTextColor(tcell.ColorWhite).
+						SetAlign(align))
+			}
+		}
+	}
+	fill()
+	// deepseek tells the balance left; asked in background, it is a network call
+	if strings.Contains(cfg.CurrentAPI, "deepseek") {
+		go func() {
+			b := fetchDSBalance()
+			if b == nil || len(b.BalanceInfos) == 0 {
+				return
+			}
+			app.QueueUpdateDraw(func() {
+				balance = fmt.Sprintf(" | deepseek balance: %s %s", b.BalanceInfos[0].TotalBalance, b.BalanceInfos[0].Currency)
+				setTitle()
+			})
+		}()
+	}
+	table.Select(1, 0).SetSelectable(true, false).SetFixed(1, 0)
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() != tcell.KeyRune {
+			return event
+		}
+		switch event.Rune() {
+		case 'x':
+			pages.RemovePage(usagePage)
+			app.SetFocus(textArea)
+			return nil
+		case 'c':
+			groupBy = storage.UsageByChat
+		case 'd':
+			groupBy = storage.UsageByDay
+		case 'm':
+			groupBy = storage.UsageByModel
+		default:
+			return event
+		}
+		fill()
+		table.Select(1, 0)
+		return nil
+	})
+	return table
+}
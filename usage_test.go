@@ -0,0 +1,82 @@
+package main
+
+import (
+	"gf-lt/config"
+	"gf-lt/mockllm"
+	"gf-lt/models"
+	"gf-lt/storage"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMockUsage(t *testing.T) {
+	prevStore := store
+	defer func() { store = prevStore }()
+	store = storage.NewProviderSQL(filepath.Join(t.TempDir(), "usage.db"), logger)
+	if store == nil {
+		t.Fatal("failed to open the usage db")
+	}
+	for _, format := range mockllm.Formats {
+		t.Run(format, func(t *testing.T) {
+			setupMockChat(t, "mock://testdata/mock/usage.toml?format="+format)
+			// USD per million tokens: 120*1 + 30*2
+			cfg.Pricing = map[string]config.ModelPricing{"mock": {Prompt: 1, Completion: 2}}
+			mockRound(t, "2+2?")
+			stats := chatBody.Messages[len(chatBody.Messages)-1].Stats
+			if stats == nil || stats.Usage == nil {
+				t.Fatalf("no usage in %+v", chatBody.Messages[len(chatBody.Messages)-1])
+			}
+			u := stats.Usage
+			if u.PromptTokens != 120 || u.CompletionTokens != 30 {
+				t.Errorf("tokens: %+v", u)
+			}
+			wantReasoning := 5
+			if format == mockllm.FormatLCPCompletion {
+				wantReasoning = 0 // llama.cpp /completion does not count reasoning
+			}
+			if u.ReasoningTokens != wantReasoning {
+				t.Errorf("reasoning tokens: %+v", u)
+			}
+			wantCost := 0.00018
+			if strings.HasPrefix(format, "openrouter") {
+				wantCost = 0.002 // reported by the api
+			}
+			if math.Abs(u.Cost-wantCost) > 1e-9 {
+				t.Errorf("cost: got %f, want %f", u.Cost, wantCost)
+			}
+		})
+	}
+	byModel, err := store.UsageTotals(storage.UsageByModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byModel) != 1 || byModel[0].Key != "mock" || byModel[0].Replies != len(mockllm.Formats) ||
+		byModel[0].PromptTokens != 120*len(mockllm.Formats) {
+		t.Errorf("unexpected usage totals: %+v", byModel)
+	}
+}
+
+func TestModelPrice(t *testing.T) {
+	cfg = &config.Config{Pricing: map[string]config.ModelPricing{"local": {Prompt: 0.5, Completion: 1.5}}}
+	prevModels := orModelsData
+	defer func() { orModelsData = prevModels }()
+	orModelsData = nil
+	if _, ok := modelPrice("https://openrouter.ai/api/v1/chat/completions", "vendor/model"); ok {
+		t.Error("price without a model list")
+	}
+	if price, ok := modelPrice("http://localhost:8080/completion", "local"); !ok || price.Completion != 1.5 {
+		t.Errorf("config price: %+v %v", price, ok)
+	}
+	orModelsData = &models.ORModels{Data: []models.ORModel{{ID: "vendor/model"}}}
+	orModelsData.Data[0].Pricing.Prompt = "0.000001"
+	orModelsData.Data[0].Pricing.Completion = "0.000004"
+	price, ok := modelPrice("https://openrouter.ai/api/v1/chat/completions", "vendor/model")
+	if !ok || math.Abs(price.Prompt-1) > 1e-9 || math.Abs(price.Completion-4) > 1e-9 {
+		t.Errorf("openrouter price: %+v %v", price, ok)
+	}
+	if _, ok := modelPrice("https://api.deepseek.com/chat/completions", "vendor/model"); ok {
+		t.Error("openrouter price used for another api")
+	}
+}
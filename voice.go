@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gf-lt/models"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// voice conversation mode: every utterance recognized by asr is sent as a user msg,
+// the reply is spoken by orator; speaking over the tts interrupts it (barge-in)
+
+type voiceState int32
+
+const (
+	voiceOff voiceState = iota
+	voiceListening
+	voiceThinking
+	voiceSpeaking
+)
+
+func (s voiceState) String() string {
+	switch s {
+	case voiceListening:
+		return "listening"
+	case voiceThinking:
+		return "thinking"
+	case voiceSpeaking:
+		return "speaking"
+	default:
+		return "off"
+	}
+}
+
+func (s voiceState) color() string {
+	switch s {
+	case voiceListening:
+		return "green"
+	case voiceThinking:
+		return "orange"
+	case voiceSpeaking:
+		return "blue"
+	default:
+		return "red"
+	}
+}
+
+var (
+	voiceMode      atomic.Bool
+	voiceCurrent   atomic.Int32
+	voiceRoundDone = make(chan bool, 1)
+	voiceStop      chan struct{}
+	// how often to check if orator is done speaking
+	voicePollInterval = 200 * time.Millisecond
+)
+
+func getVoiceState() voiceState {
+	return voiceState(voiceCurrent.Load())
+}
+
+func setVoiceState(s voiceState) {
+	if voiceState(voiceCurrent.Swap(int32(s))) == s {
+		return
+	}
+	reportVoiceState(s)
+}
+
+// casVoiceState changes state only if it was not changed by someone else
+func casVoiceState(from, to voiceState) bool {
+	if !voiceCurrent.CompareAndSwap(int32(from), int32(to)) {
+		return false
+	}
+	reportVoiceState(to)
+	return true
+}
+
+func reportVoiceState(s voiceState) {
+	if cfg.CLIMode {
+		if s != voiceOff {
+			fmt.Printf("\n[voice: %s]\n", s)
+		}
+		return
+	}
+	if app != nil {
+		app.QueueUpdateDraw(updateStatusLine)
+	}
+}
+
+// notifyVoiceRoundDone is called at the end of a chat round (after all tool calls)
+func notifyVoiceRoundDone() {
+	if !voiceMode.Load() {
+		return
+	}
+	select {
+	case voiceRoundDone <- true:
+	default:
+	}
+}
+
+func startVoiceMode() error {
+	if voiceMode.Load() {
+		return nil
+	}
+	if !cfg.STT_ENABLED {
+		return errors.New("voice mode needs STT_ENABLED")
+	}
+	if asr.IsRecording() {
+		return errors.New("recording is already in progress (ctrl+r)")
+	}
+	if err := asr.StartRecording(); err != nil {
+		return fmt.Errorf("failed to start recording: %w", err)
+	}
+	ch := asr.Utterances()
+	if ch == nil {
+		// sync backend (WhisperBinary) transcribes only on stop
+		if _, err := asr.StopRecording(); err != nil {
+			logger.Warn("failed to stop recording", "error", err)
+		}
+		return errors.New("voice mode needs a stt backend with utterance detection")
+	}
+	// drop signal left from previous session
+	select {
+	case <-voiceRoundDone:
+	default:
+	}
+	voiceStop = make(chan struct{})
+	voiceMode.Store(true)
+	setVoiceState(voiceListening)
+	go voiceLoop(ch, asr.Errors())
+	go voiceRoundWatcher(voiceStop)
+	return nil
+}
+
+func stopVoiceMode() {
+	if !voiceMode.Swap(false) {
+		return
+	}
+	if asr.IsRecording() {
+		if _, err := asr.StopRecording(); err != nil {
+			logger.Warn("failed to stop recording", "error", err)
+		}
+	}
+	close(voiceStop)
+	setVoiceState(voiceOff)
+}
+
+func toggleVoiceMode() error {
+	if voiceMode.Load() {
+		stopVoiceMode()
+		return nil
+	}
+	return startVoiceMode()
+}
+
+// bargeIn stops the current tts playback and bot response, if any
+func bargeIn() {
+	// tts starts speaking while the reply is still streaming
+	if cfg.TTS_ENABLED && getVoiceState() != voiceListening {
+		select {
+		case TTSDoneChan <- true:
+		default:
+		}
+	}
+	if botRespMode.Load() {
+		interruptResp.Store(true)
+	}
+}
+
+func voiceLoop(ch <-chan string, errCh <-chan error) {
+	for ch != nil || errCh != nil {
+		select {
+		case text, ok := <-ch:
+			if !ok {
+				ch = nil
+				continue
+			}
+			text = strings.TrimSpace(text)
+			if text == "" || !voiceMode.Load() {
+				continue
+			}
+			bargeIn()
+			sendVoiceMsg(text)
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+			logger.Error("stt error in voice mode", "error", err)
+			if !cfg.CLIMode && app != nil {
+				app.QueueUpdateDraw(func() {
+					showToast("stt error", err.Error())
+				})
+			}
+		}
+	}
+	// channels closed: recording was stopped outside of the voice mode
+	if voiceMode.Load() {
+		stopVoiceMode()
+	}
+}
+
+func sendVoiceMsg(text string) {
+	persona := cfg.UserRole
+	if cfg.WriteNextMsgAs != "" {
+		persona = cfg.WriteNextMsgAs
+	}
+	setVoiceState(voiceThinking)
+	if cfg.CLIMode {
+		outputHandler.Writef("\n(%d) <%s>: %s\n", len(chatBody.Messages), persona, text)
+		chatRoundChan <- &models.ChatRoundReq{Role: persona, UserMsg: text}
+		return
+	}
+	app.QueueUpdateDraw(func() {
+		nl := "\n\n"
+		prevText := textView.GetText(true)
+		if strings.HasSuffix(prevText, nl) {
+			nl = ""
+		} else if strings.HasSuffix(prevText, "\n") {
+			nl = "\n"
+		}
+		fmt.Fprintf(textView, "%s[-:-:b](%d) <%s>: [-:-:-]\n%s\n",
+			nl, len(chatBody.Messages), persona, text)
+		if cfg.AutoScrollEnabled {
+			textView.ScrollToEnd()
+		}
+		colorText()
+	})
+	chatRoundChan <- &models.ChatRoundReq{Role: persona, UserMsg: text}
+}
+
+// voiceRoundWatcher moves state from thinking to speaking and back to listening
+func voiceRoundWatcher(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-voiceRoundDone:
+		}
+		if !cfg.TTS_ENABLED {
+			casVoiceState(voiceThinking, voiceListening)
+			continue
+		}
+		if casVoiceState(voiceThinking, voiceSpeaking) {
+			go waitForOrator()
+		}
+	}
+}
+
+// waitForOrator switches back to listening once tts is done,
+// unless state was changed by a new utterance
+func waitForOrator() {
+	// give tts a moment to pick up the flush
+	time.Sleep(voicePollInterval)
+	for voiceMode.Load() && getVoiceState() == voiceSpeaking {
+		if !orator.IsSpeaking() && len(TTSTextChan) == 0 {
+			casVoiceState(voiceSpeaking, voiceListening)
+			return
+		}
+		time.Sleep(voicePollInterval)
+	}
+}
+
+func makeVoiceStatus() string {
+	s := getVoiceState()
+	return fmt.Sprintf(" | voice: [%s:-:b]%s[-:-:-] (alt+v)", s.color(), s)
+}